@@ -28,10 +28,16 @@ import (
 	"shell-server-go/internal/files"
 	"shell-server-go/internal/logger"
 	"shell-server-go/internal/middleware"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/ratelimit"
+	"shell-server-go/internal/scan"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/snapshot"
 	"shell-server-go/internal/templates"
 	"shell-server-go/internal/terminal"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 )
 
 // testServer holds the test server and dependencies
@@ -79,6 +85,7 @@ func setupTestServer(t *testing.T) *testServer {
 		ResolvedDefaultCwd:      workspaceDir,
 		ResolvedUploadCwd:       uploadsDir,
 		ResolvedSitesPath:       sitesDir,
+		ResolvedTrashPath:       filepath.Join(tempDir, ".trash"),
 		WorkspaceBase:           tempDir,
 		AllowWorkspaceSelection: true,
 		EditableDirectories:     []config.EditableDirectory{},
@@ -94,9 +101,13 @@ func setupTestServer(t *testing.T) *testServer {
 	limiter := ratelimit.NewLimiter(rateLimitFile)
 
 	// Create handlers
+	trashStore := trash.NewStore(cfg)
+	quotas := quota.NewManager(cfg)
+	watches := watch.NewManager()
+	thumbs := thumbnail.NewCache(filepath.Join(tempDir, ".thumbnails"), thumbnail.DefaultCacheBytes)
 	authHandler := auth.NewHandler(cfg, sessions, limiter)
-	fileHandler := files.NewHandler(cfg, sessions)
-	editorHandler := editor.NewHandler(cfg, sessions)
+	fileHandler := files.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs, scan.New(cfg.UploadScan), snapshot.NewManager(filepath.Join(tempDir, ".snapshots")), filepath.Join(tempDir, ".uploads"))
+	editorHandler := editor.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs)
 	wsHandler := terminal.NewWSHandler(cfg, sessions)
 	templateHandler := templates.NewHandler(cfg, sessions)
 
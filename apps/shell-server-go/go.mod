@@ -8,9 +8,10 @@ require (
 	github.com/creack/pty v1.1.21
 	github.com/getsentry/sentry-go v0.35.3
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
+	github.com/yuin/goldmark v1.7.13
+	golang.org/x/image v0.18.0
+	golang.org/x/sys v0.18.0
 )
 
-require (
-	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
-)
+require golang.org/x/text v0.16.0 // indirect
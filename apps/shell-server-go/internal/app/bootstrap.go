@@ -12,11 +12,19 @@ import (
 	"shell-server-go/internal/editor"
 	"shell-server-go/internal/files"
 	"shell-server-go/internal/logger"
+	"shell-server-go/internal/processes"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/ratelimit"
+	"shell-server-go/internal/scan"
 	"shell-server-go/internal/sentryx"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/snapshot"
+	"shell-server-go/internal/supervisor"
 	"shell-server-go/internal/templates"
 	"shell-server-go/internal/terminal"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 )
 
 // ServerApp holds all runtime dependencies for the shell server.
@@ -29,6 +37,11 @@ type ServerApp struct {
 	EditorHandler   *editor.Handler
 	WSHandler       *terminal.WSHandler
 	TemplateHandler *templates.Handler
+	Supervisor      *supervisor.Manager
+	ServiceHandler  *supervisor.Handler
+	ProcessHandler  *processes.Handler
+	Trash           *trash.Store
+	Quotas          *quota.Manager
 	ClientFS        fs.FS
 	Logger          *logger.Logger
 	WorkingDir      string
@@ -70,6 +83,7 @@ func New(clientFS fs.FS, configPath string) (*ServerApp, error) {
 	log.Info("Port: %d", cfg.Port)
 	log.Info("Default workspace: %s", cfg.ResolvedDefaultCwd)
 	log.Info("Workspace selection: %v", cfg.AllowWorkspaceSelection)
+	log.Info("Umask for created files: %04o", uint32(cfg.Umask))
 
 	for _, dir := range cfg.EditableDirectories {
 		if _, statErr := os.Stat(dir.Path); statErr == nil {
@@ -86,15 +100,43 @@ func New(clientFS fs.FS, configPath string) (*ServerApp, error) {
 	rateLimitFile := filepath.Join(cwd, ".rate-limit-state.json")
 	limiter := ratelimit.NewLimiter(rateLimitFile)
 
+	services := supervisor.NewManager(cfg, filepath.Join(cwd, ".supervisor"))
+
+	trashStore := trash.NewStore(cfg)
+	trashStore.Start()
+	log.Info("Trash: %s (retention %s)", cfg.ResolvedTrashPath, trashStore.Retention())
+
+	quotas := quota.NewManager(cfg)
+	quotas.Start()
+	watches := watch.NewManager()
+	thumbs := thumbnail.NewCache(filepath.Join(cwd, ".thumbnails"), thumbnail.DefaultCacheBytes)
+	if cfg.SiteQuota != (config.QuotaLimit{}) || len(cfg.SiteQuotas) > 0 {
+		log.Info("Site quotas: default %d bytes / %d inodes, %d site overrides",
+			cfg.SiteQuota.Bytes, cfg.SiteQuota.Inodes, len(cfg.SiteQuotas))
+	}
+	scanner := scan.New(cfg.UploadScan)
+	if scanner != nil {
+		mode := cfg.UploadScan.Mode
+		if mode == "" {
+			mode = scan.ModeBlock
+		}
+		log.Info("Upload scanning enabled: mode %s, clamd %q", mode, cfg.UploadScan.ClamdAddress)
+	}
+
 	return &ServerApp{
 		Config:          cfg,
 		Sessions:        sessions,
 		Limiter:         limiter,
 		AuthHandler:     auth.NewHandler(cfg, sessions, limiter),
-		FileHandler:     files.NewHandler(cfg, sessions),
-		EditorHandler:   editor.NewHandler(cfg, sessions),
+		FileHandler:     files.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs, scanner, snapshot.NewManager(filepath.Join(cwd, ".snapshots")), filepath.Join(cwd, ".uploads")),
+		EditorHandler:   editor.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs),
 		WSHandler:       terminal.NewWSHandler(cfg, sessions),
 		TemplateHandler: templates.NewHandler(cfg, sessions),
+		Supervisor:      services,
+		ServiceHandler:  supervisor.NewHandler(sessions, services),
+		ProcessHandler:  processes.NewHandler(cfg, sessions),
+		Trash:           trashStore,
+		Quotas:          quotas,
 		ClientFS:        clientFS,
 		Logger:          log,
 		WorkingDir:      cwd,
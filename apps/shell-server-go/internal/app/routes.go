@@ -31,22 +31,62 @@ func (a *ServerApp) Router() (http.Handler, error) {
 	mux.HandleFunc("/ws", a.WSHandler.Handle)
 	mux.Handle("POST /api/ws-lease", authAPIMiddleware(http.HandlerFunc(a.WSHandler.CreateLease)))
 	mux.HandleFunc("POST /internal/lease", a.WSHandler.CreateInternalLease)
+	mux.Handle("GET /api/terminal/transcripts", authAPIMiddleware(http.HandlerFunc(a.WSHandler.ListTranscripts)))
+	mux.Handle("GET /api/terminal/transcript", authAPIMiddleware(http.HandlerFunc(a.WSHandler.DownloadTranscript)))
 
 	mux.Handle("POST /api/check-directory", authAPIMiddleware(http.HandlerFunc(a.FileHandler.CheckDirectory)))
 	mux.Handle("POST /api/create-directory", authAPIMiddleware(http.HandlerFunc(a.FileHandler.CreateDirectory)))
 	mux.Handle("POST /api/upload", authAPIMiddleware(http.HandlerFunc(a.FileHandler.Upload)))
+	mux.Handle("OPTIONS /api/uploads/{$}", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TusOptions)))
+	mux.Handle("POST /api/uploads/{$}", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TusCreate)))
+	mux.Handle("HEAD /api/uploads/{id}", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TusHead)))
+	mux.Handle("PATCH /api/uploads/{id}", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TusPatch)))
+	mux.Handle("DELETE /api/uploads/{id}", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TusDelete)))
 	mux.Handle("POST /api/list-files", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ListFiles)))
 	mux.Handle("POST /api/read-file", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ReadFile)))
 	mux.Handle("GET /api/download-file", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DownloadFile)))
+	mux.Handle("GET /api/download-archive", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DownloadArchive)))
+	mux.Handle("GET /api/files/list", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ListDirectory)))
+	mux.Handle("GET /api/files/search", authAPIMiddleware(http.HandlerFunc(a.FileHandler.SearchFiles)))
+	mux.Handle("GET /api/files/tail", authAPIMiddleware(http.HandlerFunc(a.FileHandler.TailFile)))
+	mux.Handle("GET /api/files/watch", authAPIMiddleware(http.HandlerFunc(a.FileHandler.WatchFiles)))
+	mux.Handle("GET /api/files/thumbnail", authAPIMiddleware(http.HandlerFunc(a.FileHandler.Thumbnail)))
+	mux.Handle("GET /api/files/disk-usage", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DiskUsage)))
+	mux.Handle("POST /api/move", authAPIMiddleware(http.HandlerFunc(a.FileHandler.MovePath)))
+	mux.Handle("POST /api/batch", authAPIMiddleware(http.HandlerFunc(a.FileHandler.Batch)))
 	mux.Handle("POST /api/delete-folder", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DeleteFolder)))
+	mux.Handle("GET /api/trash", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ListTrash)))
+	mux.Handle("POST /api/trash/restore", authAPIMiddleware(http.HandlerFunc(a.FileHandler.RestoreTrash)))
+	mux.Handle("POST /api/trash/purge", authAPIMiddleware(http.HandlerFunc(a.FileHandler.PurgeTrash)))
+	mux.Handle("GET /api/snapshots", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ListSnapshots)))
+	mux.Handle("POST /api/snapshots", authAPIMiddleware(http.HandlerFunc(a.FileHandler.CreateSnapshot)))
+	mux.Handle("GET /api/snapshots/diff", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DiffSnapshots)))
+	mux.Handle("POST /api/snapshots/restore", authAPIMiddleware(http.HandlerFunc(a.FileHandler.RestoreSnapshot)))
+	mux.Handle("POST /api/snapshots/delete", authAPIMiddleware(http.HandlerFunc(a.FileHandler.DeleteSnapshot)))
 	mux.Handle("GET /api/sites", authAPIMiddleware(http.HandlerFunc(a.FileHandler.ListSites)))
+	mux.Handle("GET /api/quota", authAPIMiddleware(http.HandlerFunc(a.FileHandler.QuotaUsage)))
 
 	mux.Handle("POST /api/edit/list-files", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.ListFiles)))
 	mux.Handle("POST /api/edit/read-file", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.ReadFile)))
 	mux.Handle("POST /api/edit/write-file", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.WriteFile)))
 	mux.Handle("POST /api/edit/check-mtimes", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.CheckMtimes)))
 	mux.Handle("POST /api/edit/delete", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.Delete)))
+	mux.Handle("GET /api/edit/trash", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.ListTrash)))
+	mux.Handle("POST /api/edit/trash/restore", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.RestoreTrash)))
+	mux.Handle("POST /api/edit/trash/purge", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.PurgeTrash)))
 	mux.Handle("POST /api/edit/copy", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.Copy)))
+	mux.Handle("GET /api/edit/watch", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.WatchFiles)))
+	mux.Handle("GET /api/edit/thumbnail", authAPIMiddleware(http.HandlerFunc(a.EditorHandler.Thumbnail)))
+
+	mux.Handle("GET /api/supervisor/services", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.ListServices)))
+	mux.Handle("POST /api/supervisor/start", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.StartService)))
+	mux.Handle("POST /api/supervisor/stop", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.StopService)))
+	mux.Handle("POST /api/supervisor/restart", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.RestartService)))
+	mux.Handle("POST /api/supervisor/remove", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.RemoveService)))
+	mux.Handle("GET /api/supervisor/logs", authAPIMiddleware(http.HandlerFunc(a.ServiceHandler.ServiceLogs)))
+
+	mux.Handle("GET /api/processes", authAPIMiddleware(http.HandlerFunc(a.ProcessHandler.List)))
+	mux.Handle("POST /api/processes/signal", authAPIMiddleware(http.HandlerFunc(a.ProcessHandler.Signal)))
 
 	mux.Handle("GET /api/templates", authAPIMiddleware(http.HandlerFunc(a.TemplateHandler.ListTemplates)))
 	mux.Handle("POST /api/templates", authAPIMiddleware(http.HandlerFunc(a.TemplateHandler.CreateTemplate)))
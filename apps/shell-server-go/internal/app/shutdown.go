@@ -65,6 +65,9 @@ func (a *ServerApp) Run() error {
 	a.Logger.Info("Closing WebSocket connections...")
 	a.WSHandler.Shutdown(ctx)
 
+	a.Logger.Info("Stopping supervised services...")
+	a.Supervisor.Shutdown(ctx)
+
 	a.Logger.Info("Shutting down HTTP server...")
 	if shutdownErr := server.Shutdown(ctx); shutdownErr != nil {
 		a.Logger.Error("Server shutdown error: %v", shutdownErr)
@@ -110,4 +113,10 @@ func (a *ServerApp) cleanup() {
 	if a.Sessions != nil {
 		a.Sessions.Stop()
 	}
+	if a.Trash != nil {
+		a.Trash.Stop()
+	}
+	if a.Quotas != nil {
+		a.Quotas.Stop()
+	}
 }
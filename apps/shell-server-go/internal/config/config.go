@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"shell-server-go/internal/logger"
 )
@@ -21,6 +23,64 @@ type EnvConfig struct {
 	SitesPath               string `json:"sitesPath"`
 	WorkspaceBase           string `json:"workspaceBase"`
 	AllowWorkspaceSelection bool   `json:"allowWorkspaceSelection"`
+	// TranscriptMaxBytes caps the plain-text transcript kept per terminal session.
+	TranscriptMaxBytes int `json:"transcriptMaxBytes,omitempty"`
+	// TranscriptRetentionMinutes is how long a closed session's transcript stays downloadable.
+	TranscriptRetentionMinutes int `json:"transcriptRetentionMinutes,omitempty"`
+	// TranscriptMaxPerWorkspace and TranscriptMaxTotal cap how many transcripts
+	// are kept per workspace and overall.
+	TranscriptMaxPerWorkspace int `json:"transcriptMaxPerWorkspace,omitempty"`
+	TranscriptMaxTotal        int `json:"transcriptMaxTotal,omitempty"`
+	// TrashPath holds deleted items of the root workspace and editor directories.
+	// Site workspaces keep their own trash next to the served tree.
+	TrashPath string `json:"trashPath,omitempty"`
+	// TrashRetentionDays is how long deleted items stay restorable.
+	TrashRetentionDays int `json:"trashRetentionDays,omitempty"`
+	// SiteQuota is the default disk quota of every site.
+	SiteQuota QuotaLimit `json:"siteQuota,omitempty"`
+	// SiteQuotas overrides SiteQuota for individual sites.
+	SiteQuotas map[string]QuotaLimit `json:"siteQuotas,omitempty"`
+	// Umask is cleared from the mode of files and directories the server creates
+	// in workspaces, as an octal string such as "0002". Defaults to DefaultUmask.
+	Umask string `json:"umask,omitempty"`
+	// UploadScan configures scanning of uploads and extracted archive entries.
+	UploadScan UploadScanConfig `json:"uploadScan,omitempty"`
+}
+
+// QuotaLimit caps the disk usage of a site. Zero means unlimited.
+type QuotaLimit struct {
+	Bytes  int64 `json:"bytes,omitempty"`
+	Inodes int64 `json:"inodes,omitempty"`
+}
+
+// UploadScanConfig configures the upload scanner. The rule scanner runs when
+// any rule is set and clamd when ClamdAddress is set.
+type UploadScanConfig struct {
+	// Mode is "block" (default) to reject uploads with findings, or "report"
+	// to store them and return the findings.
+	Mode string `json:"mode,omitempty"`
+	// DenyExtensions rejects names with one of these extensions anywhere in
+	// their extension chain, such as ".php" in "shell.php.jpg".
+	DenyExtensions []string `json:"denyExtensions,omitempty"`
+	// DenyMIMETypes rejects content sniffed as one of these types; "type/*"
+	// matches a whole family.
+	DenyMIMETypes []string `json:"denyMimeTypes,omitempty"`
+	// MaxFileBytes caps the size of every scanned file. Zero means unlimited.
+	MaxFileBytes int64 `json:"maxFileBytes,omitempty"`
+	// SizeRules cap the size of files matching an extension or MIME type.
+	SizeRules []ScanSizeRule `json:"sizeRules,omitempty"`
+	// ClamdAddress is a clamd socket: "unix:///run/clamav/clamd.ctl", a bare
+	// socket path, or "tcp://host:port".
+	ClamdAddress string `json:"clamdAddress,omitempty"`
+	// ClamdTimeoutSeconds bounds one clamd scan. Defaults to 30.
+	ClamdTimeoutSeconds int `json:"clamdTimeoutSeconds,omitempty"`
+}
+
+// ScanSizeRule caps the size of files matching any of its extensions or MIME types.
+type ScanSizeRule struct {
+	Extensions []string `json:"extensions,omitempty"`
+	MIMETypes  []string `json:"mimeTypes,omitempty"`
+	MaxBytes   int64    `json:"maxBytes"`
 }
 
 // Config holds all configuration
@@ -48,8 +108,21 @@ type AppConfig struct {
 	AllowWorkspaceSelection bool
 	EditableDirectories     []EditableDirectory
 	ShellPassword           string
+	TranscriptMaxBytes      int
+	TranscriptRetention     time.Duration
+	TranscriptMaxPerWS      int
+	TranscriptMaxTotal      int
+	ResolvedTrashPath       string
+	TrashRetention          time.Duration
+	SiteQuota               QuotaLimit
+	SiteQuotas              map[string]QuotaLimit
+	Umask                   os.FileMode
+	UploadScan              UploadScanConfig
 }
 
+// DefaultUmask is applied to created files when the config sets none.
+const DefaultUmask os.FileMode = 0022
+
 // Common configuration errors
 var (
 	ErrMissingPassword   = errors.New("SHELL_PASSWORD environment variable is required")
@@ -119,6 +192,48 @@ func (c *AppConfig) Validate() ValidationErrors {
 		}
 	}
 
+	if c.TranscriptMaxBytes < 0 {
+		errs = append(errs, ValidationError{Field: "transcriptMaxBytes", Message: "must not be negative"})
+	}
+	if c.TranscriptRetention < 0 {
+		errs = append(errs, ValidationError{Field: "transcriptRetentionMinutes", Message: "must not be negative"})
+	}
+	if c.TranscriptMaxPerWS < 0 {
+		errs = append(errs, ValidationError{Field: "transcriptMaxPerWorkspace", Message: "must not be negative"})
+	}
+	if c.TranscriptMaxTotal < 0 {
+		errs = append(errs, ValidationError{Field: "transcriptMaxTotal", Message: "must not be negative"})
+	}
+	if c.TrashRetention < 0 {
+		errs = append(errs, ValidationError{Field: "trashRetentionDays", Message: "must not be negative"})
+	}
+	if c.SiteQuota.Bytes < 0 || c.SiteQuota.Inodes < 0 {
+		errs = append(errs, ValidationError{Field: "siteQuota", Message: "limits must not be negative"})
+	}
+	for site, limit := range c.SiteQuotas {
+		if limit.Bytes < 0 || limit.Inodes < 0 {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("siteQuotas[%s]", site), Message: "limits must not be negative"})
+		}
+	}
+
+	if c.Umask&^os.ModePerm != 0 {
+		errs = append(errs, ValidationError{Field: "umask", Message: "must be an octal mode between 0000 and 0777"})
+	}
+
+	switch c.UploadScan.Mode {
+	case "", "block", "report":
+	default:
+		errs = append(errs, ValidationError{Field: "uploadScan.mode", Message: fmt.Sprintf("unknown mode %q, must be block or report", c.UploadScan.Mode)})
+	}
+	if c.UploadScan.MaxFileBytes < 0 || c.UploadScan.ClamdTimeoutSeconds < 0 {
+		errs = append(errs, ValidationError{Field: "uploadScan", Message: "limits must not be negative"})
+	}
+	for i, rule := range c.UploadScan.SizeRules {
+		if rule.MaxBytes <= 0 || len(rule.Extensions)+len(rule.MIMETypes) == 0 {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("uploadScan.sizeRules[%d]", i), Message: "needs maxBytes and at least one extension or MIME type"})
+		}
+	}
+
 	// Editable directories validation
 	seenIDs := make(map[string]bool)
 	for i, dir := range c.EditableDirectories {
@@ -198,6 +313,10 @@ func Load(configPath string) (*AppConfig, error) {
 	resolvedDefaultCwd := resolvePathFn(defaultCwd)
 	resolvedUploadCwd := resolvePathFn(envConfig.UploadDefaultCwd)
 	resolvedSitesPath := resolvePathFn(envConfig.SitesPath)
+	resolvedTrashPath := filepath.Join(cwd, ".trash")
+	if envConfig.TrashPath != "" {
+		resolvedTrashPath = resolvePathFn(envConfig.TrashPath)
+	}
 
 	// Create development workspace if needed
 	if env == "development" {
@@ -254,6 +373,11 @@ When you run the shell server and access the terminal, this is your working dire
 		},
 	}
 
+	umask, err := parseUmask(envConfig.Umask)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, ValidationError{Field: "umask", Message: err.Error()})
+	}
+
 	cfg := &AppConfig{
 		Env:                     env,
 		Port:                    envConfig.Port,
@@ -265,6 +389,16 @@ When you run the shell server and access the terminal, this is your working dire
 		AllowWorkspaceSelection: envConfig.AllowWorkspaceSelection,
 		EditableDirectories:     editableDirs,
 		ShellPassword:           shellPassword,
+		TranscriptMaxBytes:      envConfig.TranscriptMaxBytes,
+		TranscriptRetention:     time.Duration(envConfig.TranscriptRetentionMinutes) * time.Minute,
+		TranscriptMaxPerWS:      envConfig.TranscriptMaxPerWorkspace,
+		TranscriptMaxTotal:      envConfig.TranscriptMaxTotal,
+		ResolvedTrashPath:       resolvedTrashPath,
+		TrashRetention:          time.Duration(envConfig.TrashRetentionDays) * 24 * time.Hour,
+		SiteQuota:               envConfig.SiteQuota,
+		SiteQuotas:              envConfig.SiteQuotas,
+		Umask:                   umask,
+		UploadScan:              envConfig.UploadScan,
 	}
 
 	// Validate configuration
@@ -288,6 +422,26 @@ func MustLoad(configPath string) *AppConfig {
 	return cfg
 }
 
+// parseUmask parses an octal umask; an empty value means DefaultUmask.
+func parseUmask(value string) (os.FileMode, error) {
+	if value == "" {
+		return DefaultUmask, nil
+	}
+	umask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octal value %q", value)
+	}
+	return os.FileMode(umask), nil
+}
+
+// QuotaFor returns the quota of a site: its own entry in SiteQuotas, else SiteQuota.
+func (c *AppConfig) QuotaFor(site string) QuotaLimit {
+	if limit, ok := c.SiteQuotas[site]; ok {
+		return limit
+	}
+	return c.SiteQuota
+}
+
 // GetEditableDirectory returns an editable directory by ID
 func (c *AppConfig) GetEditableDirectory(id string) *EditableDirectory {
 	for _, dir := range c.EditableDirectories {
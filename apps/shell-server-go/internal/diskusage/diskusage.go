@@ -0,0 +1,386 @@
+// Package diskusage reports where the space below a directory goes: sizes and
+// entry counts per directory, the largest files and a breakdown by extension.
+// Sizes are apparent sizes of regular files, counted like the site quota, and
+// symlinks are counted but not followed.
+//
+// What a directory directly contains is summarized once and cached by path. A
+// summary is reused while the directory's modification time is unchanged and
+// it is younger than the analyzer's max age, so a repeated report only stats
+// directories. Files growing in place leave their directory's mtime alone;
+// the max age bounds how long that goes unseen.
+package diskusage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Report limits.
+const (
+	DefaultDepth   = 1
+	MaxDepth       = 8
+	DefaultTop     = 20
+	MaxTop         = 100
+	MaxChildren    = 100
+	MaxExtensions  = 50
+	DefaultWorkers = 8
+	DefaultMaxAge  = 10 * time.Minute
+)
+
+// maxCachedDirs bounds the summary cache; it is emptied when full.
+const maxCachedDirs = 200000
+
+// Options tune a report.
+type Options struct {
+	// Depth is how many levels of subdirectories the tree shows. Deeper
+	// directories still count towards their ancestors.
+	Depth int
+	// Top is how many of the largest files to list.
+	Top int
+	// Refresh rereads every directory instead of trusting cached summaries.
+	Refresh bool
+}
+
+// Node is a directory with everything below it. Paths are slash-separated and
+// relative to the analyzed directory. Omitted counts the smallest children
+// left out beyond MaxChildren.
+type Node struct {
+	Name     string  `json:"name"`
+	Path     string  `json:"path"`
+	Bytes    int64   `json:"bytes"`
+	Files    int64   `json:"files"`
+	Dirs     int64   `json:"dirs"`
+	Children []*Node `json:"children,omitempty"`
+	Omitted  int     `json:"omitted,omitempty"`
+}
+
+// File is one of the largest files.
+type File struct {
+	Path    string    `json:"path"`
+	Bytes   int64     `json:"bytes"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Extension totals the files with one extension ("" for none), lowercased.
+type Extension struct {
+	Ext   string `json:"ext"`
+	Bytes int64  `json:"bytes"`
+	Files int64  `json:"files"`
+}
+
+// Report is the result of Analyze. ReadDirs were read from disk and
+// CachedDirs reused; Unreadable could not be read at all.
+type Report struct {
+	Root              *Node       `json:"root"`
+	Largest           []File      `json:"largest"`
+	Extensions        []Extension `json:"extensions"`
+	ExtensionsOmitted int         `json:"extensionsOmitted,omitempty"`
+	ReadDirs          int64       `json:"readDirs"`
+	CachedDirs        int64       `json:"cachedDirs"`
+	Unreadable        int64       `json:"unreadable"`
+	Duration          int64       `json:"durationMs"`
+}
+
+// summary is what one directory directly contains.
+type summary struct {
+	modTime time.Time
+	readAt  time.Time
+	bytes   int64
+	files   int64
+	exts    map[string]*Extension
+	largest []File // names only, largest first, at most MaxTop
+	subdirs []string
+}
+
+// Analyzer builds reports. Directory reads of all reports together run on at
+// most workers goroutines besides the callers'.
+type Analyzer struct {
+	sem    chan struct{}
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	dirs map[string]*summary
+}
+
+// NewAnalyzer creates an analyzer. Zero values select DefaultWorkers and
+// DefaultMaxAge.
+func NewAnalyzer(workers int, maxAge time.Duration) *Analyzer {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Analyzer{
+		sem:    make(chan struct{}, workers),
+		maxAge: maxAge,
+		dirs:   make(map[string]*summary),
+	}
+}
+
+// Analyze reports on the directory root. When ctx ends first it returns
+// ctx.Err(); summaries read until then stay cached, so a retry continues
+// where the last attempt stopped.
+func (a *Analyzer) Analyze(ctx context.Context, root string, opts Options) (*Report, error) {
+	start := time.Now()
+	root = filepath.Clean(root)
+	if opts.Depth < 0 {
+		opts.Depth = 0
+	}
+	opts.Depth = min(opts.Depth, MaxDepth)
+	if opts.Top <= 0 {
+		opts.Top = DefaultTop
+	}
+	opts.Top = min(opts.Top, MaxTop)
+
+	s := &scan{
+		a:       a,
+		ctx:     ctx,
+		opts:    opts,
+		exts:    make(map[string]*Extension),
+		visited: make(map[string]bool),
+	}
+	node := s.walk(root, "", 0)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	node.Name = filepath.Base(root)
+	a.prune(root, s.visited)
+
+	report := &Report{
+		Root:       node,
+		Largest:    s.topFiles(),
+		ReadDirs:   s.read.Load(),
+		CachedDirs: s.cached.Load(),
+		Unreadable: s.unreadable.Load(),
+		Duration:   time.Since(start).Milliseconds(),
+	}
+	report.Extensions, report.ExtensionsOmitted = s.extensions()
+	return report, nil
+}
+
+// scan is the state of one Analyze call.
+type scan struct {
+	a    *Analyzer
+	ctx  context.Context
+	opts Options
+
+	read, cached, unreadable atomic.Int64
+
+	mu      sync.Mutex
+	largest []File
+	exts    map[string]*Extension
+	visited map[string]bool
+}
+
+// walk totals the directory at path. Subdirectories are walked on a free
+// worker when there is one and inline otherwise.
+func (s *scan) walk(path, rel string, level int) *Node {
+	node := &Node{Name: filepath.Base(path), Path: rel}
+	if s.ctx.Err() != nil {
+		return node
+	}
+	sum := s.a.summarize(s, path)
+	if sum == nil {
+		s.unreadable.Add(1)
+		return node
+	}
+	node.Bytes, node.Files = sum.bytes, sum.files
+	s.merge(path, rel, sum)
+
+	children := make([]*Node, len(sum.subdirs))
+	var wg sync.WaitGroup
+	for i, name := range sum.subdirs {
+		childPath, childRel := filepath.Join(path, name), name
+		if rel != "" {
+			childRel = rel + "/" + name
+		}
+		select {
+		case s.a.sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-s.a.sem }()
+				children[i] = s.walk(childPath, childRel, level+1)
+			}()
+		default:
+			children[i] = s.walk(childPath, childRel, level+1)
+		}
+	}
+	wg.Wait()
+
+	for _, child := range children {
+		node.Bytes += child.Bytes
+		node.Files += child.Files
+		node.Dirs += child.Dirs + 1
+	}
+	if level < s.opts.Depth && len(children) > 0 {
+		sort.Slice(children, func(i, j int) bool {
+			if children[i].Bytes != children[j].Bytes {
+				return children[i].Bytes > children[j].Bytes
+			}
+			return children[i].Name < children[j].Name
+		})
+		if len(children) > MaxChildren {
+			node.Omitted = len(children) - MaxChildren
+			children = children[:MaxChildren]
+		}
+		node.Children = children
+	}
+	return node
+}
+
+// merge adds a directory's files to the largest files and extension totals.
+func (s *scan) merge(path, rel string, sum *summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visited[path] = true
+	for ext, e := range sum.exts {
+		total, ok := s.exts[ext]
+		if !ok {
+			total = &Extension{Ext: ext}
+			s.exts[ext] = total
+		}
+		total.Bytes += e.Bytes
+		total.Files += e.Files
+	}
+	for _, f := range sum.largest[:min(len(sum.largest), s.opts.Top)] {
+		if rel != "" {
+			f.Path = rel + "/" + f.Path
+		}
+		s.largest = append(s.largest, f)
+	}
+	if len(s.largest) > 4*s.opts.Top {
+		sortFiles(s.largest)
+		s.largest = s.largest[:s.opts.Top]
+	}
+}
+
+func (s *scan) topFiles() []File {
+	sortFiles(s.largest)
+	return append([]File{}, s.largest[:min(len(s.largest), s.opts.Top)]...)
+}
+
+func (s *scan) extensions() ([]Extension, int) {
+	list := make([]Extension, 0, len(s.exts))
+	for _, e := range s.exts {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Bytes != list[j].Bytes {
+			return list[i].Bytes > list[j].Bytes
+		}
+		return list[i].Ext < list[j].Ext
+	})
+	if len(list) > MaxExtensions {
+		return list[:MaxExtensions], len(list) - MaxExtensions
+	}
+	return list, 0
+}
+
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Bytes != files[j].Bytes {
+			return files[i].Bytes > files[j].Bytes
+		}
+		return files[i].Path < files[j].Path
+	})
+}
+
+// summarize returns what path directly contains, from the cache when the
+// directory is unchanged, or nil when it cannot be read.
+func (a *Analyzer) summarize(s *scan, path string) *summary {
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() {
+		a.forget(path)
+		return nil
+	}
+	a.mu.Lock()
+	cached := a.dirs[path]
+	a.mu.Unlock()
+	if !s.opts.Refresh && cached != nil && cached.modTime.Equal(info.ModTime()) && time.Since(cached.readAt) < a.maxAge {
+		s.cached.Add(1)
+		return cached
+	}
+
+	// The mtime is taken before reading, so a change during the read makes
+	// the next report read again.
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		a.forget(path)
+		return nil
+	}
+	s.read.Add(1)
+	sum := &summary{modTime: info.ModTime(), readAt: time.Now(), exts: make(map[string]*Extension)}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sum.subdirs = append(sum.subdirs, entry.Name())
+			continue
+		}
+		sum.files++
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		sum.bytes += fi.Size()
+		ext := extension(entry.Name())
+		e, ok := sum.exts[ext]
+		if !ok {
+			e = &Extension{Ext: ext}
+			sum.exts[ext] = e
+		}
+		e.Bytes += fi.Size()
+		e.Files++
+		sum.largest = append(sum.largest, File{Path: entry.Name(), Bytes: fi.Size(), ModTime: fi.ModTime().UTC()})
+	}
+	sortFiles(sum.largest)
+	if len(sum.largest) > MaxTop {
+		sum.largest = sum.largest[:MaxTop]
+	}
+
+	a.mu.Lock()
+	if len(a.dirs) >= maxCachedDirs {
+		a.dirs = make(map[string]*summary)
+	}
+	a.dirs[path] = sum
+	a.mu.Unlock()
+	return sum
+}
+
+func (a *Analyzer) forget(path string) {
+	a.mu.Lock()
+	delete(a.dirs, path)
+	a.mu.Unlock()
+}
+
+// prune drops cached summaries below root that a complete walk no longer
+// reached, such as deleted directories.
+func (a *Analyzer) prune(root string, visited map[string]bool) {
+	prefix := root + string(filepath.Separator)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for path := range a.dirs {
+		if (path == root || strings.HasPrefix(path, prefix)) && !visited[path] {
+			delete(a.dirs, path)
+		}
+	}
+}
+
+// extension returns the lowercased extension of name, "" for none. A leading
+// dot alone (".bashrc") is not an extension.
+func extension(name string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
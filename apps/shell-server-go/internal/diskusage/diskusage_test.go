@@ -0,0 +1,138 @@
+package diskusage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func newTestTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "index.html"), 100)
+	writeFile(t, filepath.Join(root, "assets", "logo.PNG"), 1000)
+	writeFile(t, filepath.Join(root, "assets", "img", "photo.png"), 5000)
+	writeFile(t, filepath.Join(root, "node_modules", "dep", "index.js"), 300)
+	writeFile(t, filepath.Join(root, ".env"), 10)
+	if err := os.Symlink("index.html", filepath.Join(root, "home.html")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	return root
+}
+
+func TestAnalyze_TotalsLargestAndExtensions(t *testing.T) {
+	root := newTestTree(t)
+	a := NewAnalyzer(2, 0)
+
+	report, err := a.Analyze(context.Background(), root, Options{Depth: 1, Top: 2})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	r := report.Root
+	if r.Bytes != 6410 || r.Files != 6 || r.Dirs != 4 {
+		t.Fatalf("unexpected root totals %+v", r)
+	}
+	if len(r.Children) != 2 || r.Children[0].Path != "assets" || r.Children[0].Bytes != 6000 || r.Children[0].Dirs != 1 {
+		t.Fatalf("unexpected children %+v", r.Children)
+	}
+	// Depth 1 stops the tree, not the totals.
+	if r.Children[0].Children != nil {
+		t.Fatalf("tree deeper than requested: %+v", r.Children[0].Children)
+	}
+
+	if len(report.Largest) != 2 || report.Largest[0].Path != "assets/img/photo.png" || report.Largest[1].Path != "assets/logo.PNG" {
+		t.Fatalf("unexpected largest %+v", report.Largest)
+	}
+	want := []Extension{{".png", 6000, 2}, {".js", 300, 1}, {".html", 100, 1}, {"", 10, 1}}
+	if len(report.Extensions) != len(want) {
+		t.Fatalf("unexpected extensions %+v", report.Extensions)
+	}
+	for i := range want {
+		if report.Extensions[i] != want[i] {
+			t.Fatalf("extension %d: got %+v, want %+v", i, report.Extensions[i], want[i])
+		}
+	}
+}
+
+func TestAnalyze_ReusesUnchangedDirectories(t *testing.T) {
+	root := newTestTree(t)
+	a := NewAnalyzer(0, 0)
+
+	first, err := a.Analyze(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if first.ReadDirs != 5 || first.CachedDirs != 0 {
+		t.Fatalf("unexpected first counts %+v", first)
+	}
+
+	// Only the directory whose mtime changed is read again.
+	writeFile(t, filepath.Join(root, "assets", "img", "second.png"), 2000)
+	second, err := a.Analyze(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if second.ReadDirs != 1 || second.CachedDirs != 4 || second.Root.Bytes != first.Root.Bytes+2000 {
+		t.Fatalf("unexpected second report %+v root=%+v", second, second.Root)
+	}
+
+	refreshed, err := a.Analyze(context.Background(), root, Options{Refresh: true})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if refreshed.ReadDirs != 5 {
+		t.Fatalf("refresh reused summaries: %+v", refreshed)
+	}
+
+	// A removed directory leaves the cache.
+	if err := os.RemoveAll(filepath.Join(root, "node_modules")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := a.Analyze(context.Background(), root, Options{}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if _, ok := a.dirs[filepath.Join(root, "node_modules", "dep")]; ok {
+		t.Fatalf("stale summary kept")
+	}
+}
+
+func TestAnalyze_MaxAgeRereads(t *testing.T) {
+	root := newTestTree(t)
+	a := NewAnalyzer(0, time.Nanosecond)
+
+	if _, err := a.Analyze(context.Background(), root, Options{}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	report, err := a.Analyze(context.Background(), root, Options{})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if report.CachedDirs != 0 {
+		t.Fatalf("expired summaries reused: %+v", report)
+	}
+}
+
+func TestAnalyze_Cancelled(t *testing.T) {
+	root := newTestTree(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewAnalyzer(0, 0).Analyze(ctx, root, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,8 +11,16 @@ import (
 	"strings"
 
 	"shell-server-go/internal/config"
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/fsutil"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
 	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/ignore"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 	workspacepkg "shell-server-go/internal/workspace"
 )
 
@@ -24,14 +31,23 @@ type Handler struct {
 	config   *config.AppConfig
 	sessions *session.Store
 	resolver *workspacepkg.Resolver
+	trash    *trash.Store
+	quotas   *quota.Manager
+	watches  *watch.Manager
+	thumbs   *thumbnail.Cache
 }
 
-// NewHandler creates a new editor handler.
-func NewHandler(cfg *config.AppConfig, sessions *session.Store) *Handler {
+// NewHandler creates a new editor handler. trashStore, quotas, watches and
+// thumbs are shared with the file handler.
+func NewHandler(cfg *config.AppConfig, sessions *session.Store, trashStore *trash.Store, quotas *quota.Manager, watches *watch.Manager, thumbs *thumbnail.Cache) *Handler {
 	return &Handler{
 		config:   cfg,
 		sessions: sessions,
 		resolver: workspacepkg.NewResolver(cfg),
+		trash:    trashStore,
+		quotas:   quotas,
+		watches:  watches,
+		thumbs:   thumbs,
 	}
 }
 
@@ -43,32 +59,6 @@ type TreeNode struct {
 	Children []TreeNode `json:"children,omitempty"`
 }
 
-var imageExtensions = map[string]bool{
-	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
-	".ico": true, ".webp": true, ".bmp": true, ".svg": true,
-}
-
-var binaryExtensions = map[string]bool{
-	".pdf": true, ".zip": true, ".tar": true, ".gz": true, ".rar": true,
-	".7z": true, ".mp3": true, ".mp4": true, ".wav": true, ".avi": true,
-	".mov": true, ".mkv": true, ".exe": true, ".dll": true, ".so": true,
-	".dylib": true, ".woff": true, ".woff2": true, ".ttf": true,
-	".eot": true, ".otf": true, ".node": true, ".wasm": true,
-}
-
-func getMimeType(ext string) string {
-	switch ext {
-	case ".svg":
-		return "image/svg+xml"
-	case ".ico":
-		return "image/x-icon"
-	case ".jpg":
-		return "image/jpeg"
-	default:
-		return "image/" + strings.TrimPrefix(ext, ".")
-	}
-}
-
 // ListFiles handles POST /api/edit/list-files.
 func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	if !h.ensureSessionCanUseEditor(w, r) {
@@ -76,7 +66,8 @@ func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		Directory string `json:"directory"`
+		Directory   string `json:"directory"`
+		ShowIgnored bool   `json:"showIgnored"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid request")
@@ -101,7 +92,11 @@ func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tree := buildTree(editableDir.Path, "", 0, 5)
+	var rules *ignore.Matcher
+	if !body.ShowIgnored {
+		rules = ignore.New(editableDir.Path)
+	}
+	tree := buildTree(editableDir.Path, "", 0, 5, rules)
 	response.JSON(w, http.StatusOK, map[string]any{
 		"path":  editableDir.Path,
 		"label": editableDir.Label,
@@ -109,7 +104,8 @@ func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func buildTree(dirPath, relativePath string, depth, maxDepth int) []TreeNode {
+// buildTree lists the editable tree, hiding dotfiles and what rules ignores.
+func buildTree(dirPath, relativePath string, depth, maxDepth int, rules *ignore.Matcher) []TreeNode {
 	if depth >= maxDepth {
 		return nil
 	}
@@ -121,7 +117,7 @@ func buildTree(dirPath, relativePath string, depth, maxDepth int) []TreeNode {
 
 	var filtered []os.DirEntry
 	for _, e := range entries {
-		if !workspacepkg.DefaultExcludedDirs[e.Name()] && !strings.HasPrefix(e.Name(), ".") {
+		if !strings.HasPrefix(e.Name(), ".") && !rules.Ignored(filepath.Join(dirPath, e.Name()), e.IsDir()) {
 			filtered = append(filtered, e)
 		}
 	}
@@ -147,7 +143,7 @@ func buildTree(dirPath, relativePath string, depth, maxDepth int) []TreeNode {
 				Name:     entry.Name(),
 				Path:     entryRelPath,
 				Type:     "directory",
-				Children: buildTree(filepath.Join(dirPath, entry.Name()), entryRelPath, depth+1, maxDepth),
+				Children: buildTree(filepath.Join(dirPath, entry.Name()), entryRelPath, depth+1, maxDepth, rules),
 			})
 			continue
 		}
@@ -211,46 +207,45 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(body.Path))
-	if imageExtensions[ext] {
-		data, err := os.ReadFile(resolvedPath)
-		if err != nil {
-			response.Error(w, http.StatusInternalServerError, "Failed to read file")
-			return
-		}
-		base64Data := base64.StdEncoding.EncodeToString(data)
+	content, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	fileType := filetype.Detect(body.Path, content)
+	if fileType.Kind == filetype.KindImage {
+		base64Data := base64.StdEncoding.EncodeToString(content)
 		response.JSON(w, http.StatusOK, map[string]any{
 			"image":    true,
-			"dataUrl":  fmt.Sprintf("data:%s;base64,%s", getMimeType(ext), base64Data),
+			"dataUrl":  fmt.Sprintf("data:%s;base64,%s", fileType.MIMEType, base64Data),
 			"path":     resolvedPath,
 			"filename": filepath.Base(body.Path),
 			"size":     info.Size(),
 			"mtime":    info.ModTime().UnixMilli(),
+			"mimeType": fileType.MIMEType,
 		})
 		return
 	}
 
-	if binaryExtensions[ext] {
+	if !fileType.Text {
 		response.JSON(w, http.StatusUnsupportedMediaType, map[string]any{
 			"error":     "Binary file cannot be edited",
 			"binary":    true,
-			"extension": ext,
+			"extension": strings.ToLower(filepath.Ext(body.Path)),
+			"mimeType":  fileType.MIMEType,
+			"kind":      fileType.Kind,
 		})
 		return
 	}
 
-	content, err := os.ReadFile(resolvedPath)
-	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to read file")
-		return
-	}
-
 	response.JSON(w, http.StatusOK, map[string]any{
 		"content":  string(content),
 		"path":     resolvedPath,
 		"filename": filepath.Base(body.Path),
 		"size":     info.Size(),
 		"mtime":    info.ModTime().UnixMilli(),
+		"mimeType": fileType.MIMEType,
 	})
 }
 
@@ -299,15 +294,37 @@ func (h *Handler) WriteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.MkdirAll(filepath.Dir(resolvedPath), 0755); err != nil {
+	// Only growth counts against the quota, so saving a smaller file always works.
+	growth, newInodes := int64(contentSize), int64(1)
+	if existing, err := os.Stat(resolvedPath); err == nil {
+		growth -= existing.Size()
+		newInodes = 0
+	}
+	reservation, err := h.quotas.Reserve(resolvedPath, growth, newInodes)
+	if err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+
+	owner := h.ownerFor(editableDir.Path, resolvedPath)
+	if err := owner.MkdirAll(filepath.Dir(resolvedPath)); err != nil {
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
 
-	if err := os.WriteFile(resolvedPath, []byte(body.Content), 0644); err != nil {
+	// Saving over an existing file keeps its owner and mode.
+	write := func() error { return os.WriteFile(resolvedPath, []byte(body.Content), 0644) }
+	if newInodes > 0 {
+		write = func() error { return owner.CreateFile(resolvedPath, strings.NewReader(body.Content), fsutil.FilePerm) }
+	}
+	if err := write(); err != nil {
+		reservation.Release()
+		h.quotas.Invalidate(resolvedPath)
 		response.Error(w, http.StatusInternalServerError, "Failed to write file")
 		return
 	}
+	reservation.Commit()
 
 	info, _ := os.Stat(resolvedPath)
 	response.JSON(w, http.StatusOK, map[string]any{
@@ -377,7 +394,8 @@ func (h *Handler) CheckMtimes(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusOK, map[string]any{"results": results})
 }
 
-// Delete handles POST /api/edit/delete.
+// Delete handles POST /api/edit/delete. Items are moved to the directory's
+// trash unless "permanent" is set.
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	if !h.ensureSessionCanUseEditor(w, r) {
 		return
@@ -386,6 +404,7 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Directory string `json:"directory"`
 		Path      string `json:"path"`
+		Permanent bool   `json:"permanent"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid request")
@@ -436,16 +455,43 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		typeStr = "directory"
 	}
 
-	if err := os.RemoveAll(resolvedPath); err != nil {
+	if body.Permanent {
+		freedBytes, freedInodes := h.siteUsageOf(resolvedPath)
+		if err := os.RemoveAll(resolvedPath); err != nil {
+			h.quotas.Invalidate(resolvedPath)
+			response.Error(w, http.StatusInternalServerError, "Failed to delete")
+			return
+		}
+		h.quotas.Add(resolvedPath, -freedBytes, -freedInodes)
+		response.JSON(w, http.StatusOK, map[string]any{
+			"success":     true,
+			"message":     fmt.Sprintf("Deleted %s: %s", typeStr, body.Path),
+			"deletedPath": body.Path,
+			"type":        typeStr,
+		})
+		return
+	}
+
+	entryPath, basePath, err := h.resolveEntryPath(editableDir.Path, body.Path)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	// Editor trash lives outside the sites, so trashing frees site quota.
+	freedBytes, freedInodes := h.siteUsageOf(entryPath)
+	item, err := h.trash.Trash(trashWorkspace(editableDir.ID), basePath, entryPath, httpxmiddleware.GetSessionToken(r))
+	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to delete")
 		return
 	}
+	h.quotas.Add(entryPath, -freedBytes, -freedInodes)
 
 	response.JSON(w, http.StatusOK, map[string]any{
 		"success":     true,
-		"message":     fmt.Sprintf("Deleted %s: %s", typeStr, body.Path),
+		"message":     fmt.Sprintf("Moved %s to trash: %s", typeStr, body.Path),
 		"deletedPath": body.Path,
 		"type":        typeStr,
+		"trashId":     item.ID,
 	})
 }
 
@@ -509,29 +555,34 @@ func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.MkdirAll(filepath.Dir(resolvedDest), 0755); err != nil {
+	reservation, err := h.quotas.Reserve(resolvedDest, srcInfo.Size(), 1)
+	if err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+
+	owner := h.ownerFor(editableDir.Path, resolvedDest)
+	if err := owner.MkdirAll(filepath.Dir(resolvedDest)); err != nil {
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
 
 	srcFile, err := os.Open(resolvedSource)
 	if err != nil {
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to open source file")
 		return
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(resolvedDest)
-	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to create destination file")
-		return
-	}
-	defer dstFile.Close()
-
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	if err := owner.CreateFile(resolvedDest, srcFile, srcInfo.Mode().Perm()); err != nil {
+		reservation.Release()
+		h.quotas.Invalidate(resolvedDest)
 		response.Error(w, http.StatusInternalServerError, "Failed to copy file")
 		return
 	}
+	reservation.Commit()
 
 	response.JSON(w, http.StatusOK, map[string]any{
 		"success":    true,
@@ -541,6 +592,33 @@ func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ownerFor returns the owner of entries created at path in an editable
+// directory. Inside a site that is the site workspace's owner, since editable
+// directories such as the sites root belong to root.
+func (h *Handler) ownerFor(root, path string) *fsutil.Owner {
+	base := root
+	if site := h.quotas.SiteFor(path); site != "" {
+		if siteBase, err := h.resolver.ResolveWorkspaceBase("site:" + site); err == nil {
+			base = siteBase
+		}
+	}
+	owner, err := workspacepkg.ResolveWorkspaceOwner(base, h.config.Umask)
+	if err != nil {
+		return &fsutil.Owner{Umask: h.config.Umask}
+	}
+	return owner
+}
+
+// siteUsageOf measures path when it belongs to a site, so a delete can give
+// its space back without a full recount.
+func (h *Handler) siteUsageOf(path string) (bytes, inodes int64) {
+	if h.quotas.SiteFor(path) == "" {
+		return 0, 0
+	}
+	bytes, inodes, _ = quota.DirUsage(path)
+	return bytes, inodes
+}
+
 func (h *Handler) handlePathError(w http.ResponseWriter, err error) {
 	workspacepkg.HandlePathSecurityError(w, err)
 }
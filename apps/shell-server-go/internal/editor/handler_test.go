@@ -11,7 +11,11 @@ import (
 
 	"shell-server-go/internal/config"
 	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 )
 
 func TestHandler_ListFilesScopedSessionForbidden(t *testing.T) {
@@ -97,6 +101,7 @@ func setupEditorHandler(t *testing.T) (*Handler, *session.Store, string) {
 		ResolvedDefaultCwd: filepath.Join(tmp, "workspace"),
 		ResolvedUploadCwd:  filepath.Join(tmp, "uploads"),
 		ResolvedSitesPath:  filepath.Join(tmp, "sites"),
+		ResolvedTrashPath:  filepath.Join(tmp, ".trash"),
 		WorkspaceBase:      tmp,
 		EditableDirectories: []config.EditableDirectory{
 			{ID: "docs", Label: "Docs", Path: docsDir},
@@ -111,5 +116,86 @@ func setupEditorHandler(t *testing.T) (*Handler, *session.Store, string) {
 	}
 
 	sessions := session.NewStore(filepath.Join(tmp, ".sessions.json"))
-	return NewHandler(cfg, sessions), sessions, docsDir
+	thumbs := thumbnail.NewCache(filepath.Join(tmp, ".thumbnails"), thumbnail.DefaultCacheBytes)
+	return NewHandler(cfg, sessions, trash.NewStore(cfg), quota.NewManager(cfg), watch.NewManager(), thumbs), sessions, docsDir
+}
+
+func TestHandler_DeleteMovesToTrashAndRestores(t *testing.T) {
+	h, sessions, docsDir := setupEditorHandler(t)
+	defer sessions.Stop()
+
+	if err := os.WriteFile(filepath.Join(docsDir, "notes.md"), []byte("# notes"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/edit/delete", bytes.NewReader([]byte(`{"directory":"docs","path":"notes.md"}`)))
+	w := httptest.NewRecorder()
+	h.Delete(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var deleted struct {
+		TrashID string `json:"trashId"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &deleted); err != nil || deleted.TrashID == "" {
+		t.Fatalf("expected trash id, body=%s", w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(docsDir, "notes.md")); !os.IsNotExist(err) {
+		t.Fatalf("deleted file still present")
+	}
+
+	w = httptest.NewRecorder()
+	h.ListTrash(w, httptest.NewRequest(http.MethodGet, "/api/edit/trash?directory=docs", nil))
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"originalPath":"notes.md"`)) {
+		t.Fatalf("unexpected trash listing %d %s", w.Code, w.Body.String())
+	}
+
+	body, _ := json.Marshal(map[string]string{"directory": "docs", "id": deleted.TrashID})
+	w = httptest.NewRecorder()
+	h.RestoreTrash(w, httptest.NewRequest(http.MethodPost, "/api/edit/trash/restore", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected restore, got %d body=%s", w.Code, w.Body.String())
+	}
+	if content, err := os.ReadFile(filepath.Join(docsDir, "notes.md")); err != nil || string(content) != "# notes" {
+		t.Fatalf("restored content %q err=%v", content, err)
+	}
+}
+
+func TestHandler_ReadFileDetectsTypeFromContent(t *testing.T) {
+	h, sessions, docsDir := setupEditorHandler(t)
+	defer sessions.Stop()
+
+	files := map[string]string{
+		"clip.ogg": "OggS\x00\x02\x00\x00\x00\x00",
+		"pixel":    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"notes":    "plain notes",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(docsDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	cases := []struct {
+		path     string
+		code     int
+		mimeType string
+	}{
+		{"clip.ogg", http.StatusUnsupportedMediaType, "application/ogg"},
+		{"pixel", http.StatusOK, "image/png"},
+		{"notes", http.StatusOK, "text/plain; charset=utf-8"},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(map[string]string{"directory": "docs", "path": tc.path})
+		w := httptest.NewRecorder()
+		h.ReadFile(w, httptest.NewRequest(http.MethodPost, "/api/edit/read-file", bytes.NewReader(body)))
+
+		var payload struct {
+			MimeType string `json:"mimeType"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &payload)
+		if w.Code != tc.code || payload.MimeType != tc.mimeType {
+			t.Errorf("%s: got %d %q, want %d %q", tc.path, w.Code, payload.MimeType, tc.code, tc.mimeType)
+		}
+	}
 }
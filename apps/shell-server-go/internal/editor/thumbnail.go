@@ -0,0 +1,40 @@
+package editor
+
+import (
+	"net/http"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/thumbnail"
+)
+
+// Thumbnail handles GET /api/edit/thumbnail?directory=X&path=Y[&size=N][&v=M],
+// the editor counterpart of the files thumbnail endpoint.
+func (h *Handler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	if !h.ensureSessionCanUseEditor(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	editableDir := h.config.GetEditableDirectory(query.Get("directory"))
+	if editableDir == nil {
+		response.Error(w, http.StatusBadRequest, "Invalid directory")
+		return
+	}
+	filePath := query.Get("path")
+	if filePath == "" {
+		response.Error(w, http.StatusBadRequest, "No file path provided")
+		return
+	}
+	size, err := thumbnail.ParseSize(query.Get("size"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedPath, err := h.resolver.ResolveSafePath(editableDir.Path, filePath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	h.thumbs.Serve(w, r, resolvedPath, size)
+}
@@ -0,0 +1,164 @@
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	"shell-server-go/internal/trash"
+)
+
+// trashWorkspace is the trash key of an editable directory.
+func trashWorkspace(directoryID string) string {
+	return "editor:" + directoryID
+}
+
+// resolveEntryPath resolves userPath inside root without following a final
+// symlink, returning the entry path and the real root it is relative to.
+func (h *Handler) resolveEntryPath(root, userPath string) (string, string, error) {
+	cleaned := filepath.Clean(userPath)
+	parent, err := h.resolver.ResolveSafePath(root, filepath.Dir(cleaned))
+	if err != nil {
+		return "", "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	return filepath.Join(parent, filepath.Base(cleaned)), realRoot, nil
+}
+
+func handleTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		response.Error(w, http.StatusNotFound, "Trash item not found")
+	case errors.Is(err, trash.ErrExists):
+		response.Error(w, http.StatusConflict, "Cannot restore - original path already exists")
+	default:
+		response.Error(w, http.StatusInternalServerError, "Trash operation failed")
+	}
+}
+
+// ListTrash handles GET /api/edit/trash?directory=X.
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	if !h.ensureSessionCanUseEditor(w, r) {
+		return
+	}
+
+	editableDir := h.config.GetEditableDirectory(r.URL.Query().Get("directory"))
+	if editableDir == nil {
+		response.Error(w, http.StatusBadRequest, "Invalid directory")
+		return
+	}
+
+	items, err := h.trash.List(trashWorkspace(editableDir.ID))
+	if err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{
+		"items":         items,
+		"retentionDays": int(h.trash.Retention().Hours() / 24),
+	})
+}
+
+// RestoreTrash handles POST /api/edit/trash/restore.
+func (h *Handler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	if !h.ensureSessionCanUseEditor(w, r) {
+		return
+	}
+
+	var body struct {
+		Directory string `json:"directory"`
+		ID        string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	editableDir := h.config.GetEditableDirectory(body.Directory)
+	if editableDir == nil {
+		response.Error(w, http.StatusBadRequest, "Invalid directory")
+		return
+	}
+
+	workspace := trashWorkspace(editableDir.ID)
+	item, err := h.trash.Get(workspace, body.ID)
+	if err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	dest, _, err := h.resolveEntryPath(editableDir.Path, item.OriginalPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	reservation, err := h.quotas.Reserve(dest, item.Size, 1)
+	if err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+	if err := h.ownerFor(editableDir.Path, dest).MkdirAll(filepath.Dir(dest)); err != nil {
+		reservation.Release()
+		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
+		return
+	}
+	if _, err := h.trash.Restore(workspace, body.ID, dest); err != nil {
+		reservation.Release()
+		handleTrashError(w, err)
+		return
+	}
+	reservation.Commit()
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"success":      true,
+		"message":      "Restored " + item.OriginalPath,
+		"restoredPath": item.OriginalPath,
+		"type":         item.Type,
+	})
+}
+
+// PurgeTrash handles POST /api/edit/trash/purge.
+func (h *Handler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	if !h.ensureSessionCanUseEditor(w, r) {
+		return
+	}
+
+	var body struct {
+		Directory string `json:"directory"`
+		ID        string `json:"id"`
+		All       bool   `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	editableDir := h.config.GetEditableDirectory(body.Directory)
+	if editableDir == nil {
+		response.Error(w, http.StatusBadRequest, "Invalid directory")
+		return
+	}
+
+	workspace := trashWorkspace(editableDir.ID)
+	if body.All {
+		purged, err := h.trash.PurgeAll(workspace)
+		if err != nil {
+			handleTrashError(w, err)
+			return
+		}
+		response.JSON(w, http.StatusOK, map[string]any{"success": true, "purged": purged})
+		return
+	}
+	if body.ID == "" {
+		response.Error(w, http.StatusBadRequest, "No trash item provided")
+		return
+	}
+	if err := h.trash.Purge(workspace, body.ID); err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "purged": 1})
+}
@@ -0,0 +1,48 @@
+package editor
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/watch"
+)
+
+// WatchFiles handles GET /api/edit/watch?directory=X[&showIgnored=1]. It streams changes in an
+// editable directory as Server-Sent Events (see watch.Serve), replacing
+// polling of check-mtimes for open files.
+func (h *Handler) WatchFiles(w http.ResponseWriter, r *http.Request) {
+	if !h.ensureSessionCanUseEditor(w, r) {
+		return
+	}
+
+	editableDir := h.config.GetEditableDirectory(r.URL.Query().Get("directory"))
+	if editableDir == nil {
+		response.Error(w, http.StatusBadRequest, "Invalid directory")
+		return
+	}
+	if info, err := os.Stat(editableDir.Path); err != nil || !info.IsDir() {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+
+	token := httpxmiddleware.GetSessionToken(r)
+	showIgnored := r.URL.Query().Get("showIgnored")
+	sub, err := h.watches.Subscribe(editableDir.Path, watch.Options{
+		ShowIgnored: showIgnored == "1" || showIgnored == "true",
+		Key:         token,
+	})
+	if errors.Is(err, watch.ErrTooManySubscriptions) {
+		response.Error(w, http.StatusTooManyRequests, "Too many open watches - close some before watching more")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to watch directory")
+		return
+	}
+	defer sub.Close()
+
+	watch.Serve(w, r, sub, func() bool { return token != "" && h.sessions.Valid(token) })
+}
@@ -0,0 +1,496 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/ignore"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+const (
+	// MaxArchiveSize caps the total size of regular files in one archive download.
+	MaxArchiveSize = 2 << 30
+
+	// MaxArchiveEntries caps the number of entries in one archive download.
+	MaxArchiveEntries = 100000
+)
+
+// Archive formats accepted by DownloadArchive.
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatTar   = "tar"
+)
+
+var (
+	errArchiveTooLarge   = errors.New("archive too large")
+	errArchiveTooMany    = errors.New("archive has too many entries")
+	errRangeNotSatisfied = errors.New("range not satisfiable")
+	errFileChanged       = errors.New("file was replaced since it was collected")
+)
+
+// archiveEntry is one file, directory or symlink collected for an archive.
+type archiveEntry struct {
+	name       string // slash-separated path inside the archive
+	path       string // absolute path on disk
+	info       fs.FileInfo
+	linkTarget string
+}
+
+// collectArchiveEntries walks root without following symlinks, skipping what
+// rules ignores. Symlinks are kept only when their target stays inside base;
+// escaping or dangling links are skipped.
+func collectArchiveEntries(base, root string, rules *ignore.Matcher) ([]archiveEntry, int64, error) {
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return nil, 0, err
+	}
+	prefix := filepath.Base(root)
+
+	var entries []archiveEntry
+	var total int64
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			filesLog.Warn("Archive walk skipped %s: %v", path, walkErr)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path != root && rules.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := prefix
+		if rel != "." {
+			name = prefix + "/" + filepath.ToSlash(rel)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entry := archiveEntry{name: name, path: path, info: info}
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			realTarget, err := filepath.EvalSymlinks(path)
+			if err != nil || !isWithinDir(realTarget, realBase) {
+				filesLog.Warn("Archive skipped symlink escaping workspace: %s", path)
+				return nil
+			}
+			target, err := os.Readlink(path)
+			if err != nil {
+				return nil
+			}
+			entry.linkTarget = target
+		case info.Mode().IsRegular():
+			total += info.Size()
+			if total > MaxArchiveSize {
+				return errArchiveTooLarge
+			}
+		case info.IsDir():
+		default:
+			// Sockets, devices and FIFOs have no useful archive representation.
+			return nil
+		}
+
+		entries = append(entries, entry)
+		if len(entries) > MaxArchiveEntries {
+			return errArchiveTooMany
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func isWithinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// archiveETag identifies the archive contents by entry names, sizes and mtimes.
+func archiveETag(format string, entries []archiveEntry) string {
+	h := sha256.New()
+	io.WriteString(h, format)
+	for _, e := range entries {
+		fmt.Fprintf(h, "\x00%s\x00%d\x00%d\x00%s", e.name, e.info.Size(), e.info.ModTime().UnixNano(), e.linkTarget)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// DownloadArchive handles GET /api/download-archive?workspace=X&path=Y&format=zip|tar.gz|tar.
+// The archive is streamed while it is built. Only the uncompressed tar format has a
+// deterministic length, so only it supports Range requests.
+func (h *Handler) DownloadArchive(w http.ResponseWriter, r *http.Request) {
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	dirPath := r.URL.Query().Get("path")
+	if dirPath == "" {
+		dirPath = "./"
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ArchiveFormatZip
+	}
+	if format != ArchiveFormatZip && format != ArchiveFormatTarGz && format != ArchiveFormatTar {
+		response.Error(w, http.StatusBadRequest, "Unsupported archive format")
+		return
+	}
+
+	basePath, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, dirPath)
+	if err != nil {
+		filesLog.Warn("Archive path resolution failed for %s: %v", dirPath, err)
+		h.handlePathError(w, err)
+		return
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if os.IsNotExist(err) {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to access directory")
+		return
+	}
+	if !info.IsDir() {
+		response.Error(w, http.StatusBadRequest, "Path is not a directory")
+		return
+	}
+
+	entries, _, err := collectArchiveEntries(basePath, resolvedPath, queryIgnore(r, basePath))
+	switch {
+	case errors.Is(err, errArchiveTooLarge):
+		response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Directory too large to archive (max %dMB)", MaxArchiveSize>>20))
+		return
+	case errors.Is(err, errArchiveTooMany):
+		response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Directory has too many entries (max %d)", MaxArchiveEntries))
+		return
+	case err != nil:
+		filesLog.Error("Failed to collect archive entries for %s: %v", resolvedPath, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to read directory")
+		return
+	}
+
+	filename := filepath.Base(resolvedPath) + "." + format
+	etag := archiveETag(format, entries)
+	header := w.Header()
+	header.Set("Content-Disposition", contentDisposition("attachment", filename))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("ETag", etag)
+
+	// Large archives take longer than the server's write timeout to stream.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		filesLog.Debug("Failed to clear write deadline for archive: %v", err)
+	}
+
+	filesLog.Info("Streaming %s archive of %s (%d entries)", format, resolvedPath, len(entries))
+
+	switch format {
+	case ArchiveFormatZip:
+		header.Set("Content-Type", "application/zip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		if err := writeZipArchive(w, entries); err != nil {
+			filesLog.Warn("Zip archive of %s aborted: %v", resolvedPath, err)
+		}
+	case ArchiveFormatTarGz:
+		header.Set("Content-Type", "application/gzip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		gz := gzip.NewWriter(w)
+		err := writeTarArchive(gz, entries)
+		if err == nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			filesLog.Warn("tar.gz archive of %s aborted: %v", resolvedPath, err)
+		}
+	case ArchiveFormatTar:
+		h.serveTarArchive(w, r, entries, etag, resolvedPath)
+	}
+}
+
+// serveTarArchive streams an uncompressed tar with single-range support. The
+// length is computed with a dry run that writes zeros instead of file contents.
+func (h *Handler) serveTarArchive(w http.ResponseWriter, r *http.Request, entries []archiveEntry, etag, resolvedPath string) {
+	counter := &countingWriter{}
+	if err := writeTarArchiveWith(counter, entries, zeroContent); err != nil {
+		filesLog.Error("Failed to size tar archive of %s: %v", resolvedPath, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to build archive")
+		return
+	}
+	size := counter.n
+
+	header := w.Header()
+	header.Set("Content-Type", "application/x-tar")
+	header.Set("Accept-Ranges", "bytes")
+
+	start, end := int64(0), size-1
+	status := http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && ifRangeMatches(r, etag) {
+		s, e, err := parseSingleRange(rangeHeader, size)
+		switch {
+		case errors.Is(err, errRangeNotSatisfied):
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			response.Error(w, http.StatusRequestedRangeNotSatisfiable, "Range not satisfiable")
+			return
+		case err == nil:
+			start, end, status = s, e, http.StatusPartialContent
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		}
+		// Malformed or multi-range headers fall back to the full archive.
+	}
+
+	header.Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead || size == 0 {
+		return
+	}
+
+	window := &rangeWriter{w: w, skip: start, remaining: end - start + 1}
+	if err := writeTarArchiveWith(window, entries, fileContent); err != nil && !errors.Is(err, errRangeComplete) {
+		filesLog.Warn("tar archive of %s aborted: %v", resolvedPath, err)
+	}
+}
+
+// ifRangeMatches reports whether a Range header should be honoured given If-Range.
+func ifRangeMatches(r *http.Request, etag string) bool {
+	ifRange := r.Header.Get("If-Range")
+	return ifRange == "" || ifRange == etag
+}
+
+// parseSingleRange parses "bytes=a-b", "bytes=a-" or "bytes=-n" against size.
+func parseSingleRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errors.New("unsupported range")
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errors.New("malformed range")
+	}
+
+	if startStr == "" {
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, 0, errors.New("malformed range")
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfied
+		}
+		return max(size-suffix, 0), size - 1, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("malformed range")
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfied
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errors.New("malformed range")
+		}
+		end = min(end, size-1)
+	}
+	return start, end, nil
+}
+
+// contentSource supplies the bytes of a regular file entry.
+type contentSource func(entry archiveEntry) (io.ReadCloser, error)
+
+// fileContent opens the file that was collected for entry. A path swapped for a
+// symlink, or whose directories were, no longer leads to the collected file
+// and is refused rather than followed out of the workspace.
+func fileContent(entry archiveEntry) (io.ReadCloser, error) {
+	file, err := os.OpenFile(entry.path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || !os.SameFile(info, entry.info) {
+		file.Close()
+		return nil, errFileChanged
+	}
+	return file, nil
+}
+
+func zeroContent(archiveEntry) (io.ReadCloser, error) {
+	return io.NopCloser(zeroReader{}), nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func writeTarArchive(w io.Writer, entries []archiveEntry) error {
+	return writeTarArchiveWith(w, entries, fileContent)
+}
+
+// writeTarArchiveWith writes entries as a tar stream. Headers use the sizes seen
+// during collection and contents are padded or cut to match, so the output length
+// only depends on the collected entries.
+func writeTarArchiveWith(w io.Writer, entries []archiveEntry, content contentSource) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		hdr, err := tar.FileInfoHeader(entry.info, entry.linkTarget)
+		if err != nil {
+			return err
+		}
+		hdr.Name = entry.name
+		if entry.info.IsDir() {
+			hdr.Name += "/"
+		}
+		// Owner names and access times vary between runs; leave them out.
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.Format = tar.FormatPAX
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := copyExactly(tw, entry, hdr.Size, content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// copyExactly writes exactly size bytes of an entry, zero-padding files that shrank.
+func copyExactly(w io.Writer, entry archiveEntry, size int64, content contentSource) error {
+	src, err := content(entry)
+	if err != nil {
+		// Keep the stream consistent even if the file vanished.
+		filesLog.Warn("Archive could not read %s: %v", entry.path, err)
+		src = io.NopCloser(zeroReader{})
+	}
+	defer src.Close()
+
+	n, err := io.CopyN(w, src, size)
+	if err == io.EOF {
+		_, err = io.CopyN(w, zeroReader{}, size-n)
+	}
+	return err
+}
+
+func writeZipArchive(w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		hdr, err := zip.FileInfoHeader(entry.info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = entry.name
+		switch {
+		case entry.info.IsDir():
+			hdr.Name += "/"
+			hdr.Method = zip.Store
+		case entry.linkTarget != "":
+			hdr.Method = zip.Store
+		default:
+			hdr.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.info.IsDir():
+		case entry.linkTarget != "":
+			if _, err := io.WriteString(fw, entry.linkTarget); err != nil {
+				return err
+			}
+		default:
+			if err := copyExactly(fw, entry, entry.info.Size(), fileContent); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// countingWriter discards data and counts bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+var errRangeComplete = errors.New("range complete")
+
+// rangeWriter forwards only the bytes inside [skip, skip+remaining).
+type rangeWriter struct {
+	w         io.Writer
+	skip      int64
+	remaining int64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if rw.skip > 0 {
+		if int64(len(p)) <= rw.skip {
+			rw.skip -= int64(len(p))
+			return total, nil
+		}
+		p = p[rw.skip:]
+		rw.skip = 0
+	}
+	if rw.remaining <= 0 {
+		return 0, errRangeComplete
+	}
+	if int64(len(p)) > rw.remaining {
+		p = p[:rw.remaining]
+	}
+	n, err := rw.w.Write(p)
+	rw.remaining -= int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
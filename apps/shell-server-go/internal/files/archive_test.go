@@ -0,0 +1,208 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func writeArchiveFixture(t *testing.T, root string) {
+	t.Helper()
+	files := map[string]string{
+		"project/index.html":                "<h1>hi</h1>",
+		"project/src/app.js":                "console.log(1)",
+		"project/node_modules/dep/index.js": "module.exports = 1",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if err := os.Symlink("src/app.js", filepath.Join(root, "project", "inside-link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(root, "project", "escape-link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+}
+
+func tarNames(t *testing.T, data []byte) []string {
+	t.Helper()
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDownloadArchive_ZipSkipsExcludedAndEscapingLinks(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeArchiveFixture(t, h.config.ResolvedUploadCwd)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/download-archive?workspace=root&path=project", nil)
+	w := httptest.NewRecorder()
+	h.DownloadArchive(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=project.zip` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	if !names["project/index.html"] || !names["project/src/app.js"] || !names["project/inside-link"] {
+		t.Fatalf("missing expected entries: %v", names)
+	}
+	if names["project/escape-link"] || names["project/node_modules/dep/index.js"] {
+		t.Fatalf("archive contains excluded or escaping entries: %v", names)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/download-archive?workspace=root&path=project&format=tar.gz&includeExcluded=1&exclude=src", nil)
+	w = httptest.NewRecorder()
+	h.DownloadArchive(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("expected gzip archive, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestDownloadArchive_TarSupportsRange(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeArchiveFixture(t, h.config.ResolvedUploadCwd)
+
+	target := "/api/download-archive?workspace=root&path=project&format=tar"
+	w := httptest.NewRecorder()
+	h.DownloadArchive(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected 200 with Accept-Ranges, got %d %v", w.Code, w.Header())
+	}
+	full := w.Body.Bytes()
+	if w.Header().Get("Content-Length") != strconv.Itoa(len(full)) {
+		t.Fatalf("Content-Length %s does not match body %d", w.Header().Get("Content-Length"), len(full))
+	}
+	names := tarNames(t, full)
+	want := []string{"project/", "project/index.html", "project/inside-link", "project/src/", "project/src/app.js"}
+	if len(names) != len(want) {
+		t.Fatalf("unexpected tar entries %v", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("unexpected tar entries %v", names)
+		}
+	}
+
+	// Resuming from the middle must reproduce the remaining bytes exactly.
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=700-")
+	req.Header.Set("If-Range", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.DownloadArchive(w, req)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), full[700:]) {
+		t.Fatalf("range body does not match the tail of the full archive")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Range", "bytes=99999999-")
+	w = httptest.NewRecorder()
+	h.DownloadArchive(w, req)
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", w.Code)
+	}
+}
+
+func TestDownloadArchive_RejectsFilesAndTraversal(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeArchiveFixture(t, h.config.ResolvedUploadCwd)
+
+	for target, want := range map[string]int{
+		"/api/download-archive?workspace=root&path=project/index.html": http.StatusBadRequest,
+		"/api/download-archive?workspace=root&path=../":                http.StatusBadRequest,
+		"/api/download-archive?workspace=root&path=project&format=rar": http.StatusBadRequest,
+		"/api/download-archive?workspace=root&path=missing":            http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.DownloadArchive(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d body=%s", target, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWriteTarArchive_RefusesEntriesSwappedForSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeArchiveFixture(t, root)
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.MkdirAll(filepath.Join(secret, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"index.html", "src/app.js"} {
+		if err := os.WriteFile(filepath.Join(secret, name), []byte("top secret!"), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	project := filepath.Join(root, "project")
+	entries, _, err := collectArchiveEntries(root, project, nil)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+
+	// Between collecting and streaming, a file and a directory become symlinks
+	// to files of the same size outside the workspace.
+	index := filepath.Join(project, "index.html")
+	if err := os.Remove(index); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(secret, "index.html"), index); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	src := filepath.Join(project, "src")
+	if err := os.RemoveAll(src); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(secret, "src"), src); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTarArchive(&buf, entries); err != nil {
+		t.Fatalf("write tar: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("top secret")) {
+		t.Fatalf("archive streamed a file outside the workspace")
+	}
+}
@@ -0,0 +1,557 @@
+package files
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shell-server-go/internal/fsutil"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// MaxBatchOperations caps the operations of one batch request.
+const MaxBatchOperations = 500
+
+// maxBatchBody caps the JSON body of a batch request.
+const maxBatchBody = 1 << 20
+
+// Batch operations.
+const (
+	batchDelete = "delete"
+	batchMove   = "move"
+	batchCopy   = "copy"
+	batchMkdir  = "mkdir"
+)
+
+// Per-item batch statuses.
+const (
+	batchStatusOK             = "ok"
+	batchStatusFailed         = "failed"
+	batchStatusInvalid        = "invalid"
+	batchStatusSkipped        = "skipped"
+	batchStatusRolledBack     = "rolled_back"
+	batchStatusRollbackFailed = "rollback_failed"
+)
+
+// batchOperation is one requested operation. delete and mkdir use Path; move
+// and copy use From and To.
+type batchOperation struct {
+	Op        string `json:"op"`
+	Path      string `json:"path,omitempty"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+	Permanent bool   `json:"permanent,omitempty"`
+}
+
+// batchResult is the outcome of one operation, in request order.
+type batchResult struct {
+	Index       int    `json:"index"`
+	Op          string `json:"op"`
+	Path        string `json:"path,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Created     bool   `json:"created,omitempty"`
+	Overwritten bool   `json:"overwritten,omitempty"`
+	TrashID     string `json:"trashId,omitempty"`
+}
+
+// batchStep is a validated operation. Running it fills in undo, which reverts
+// it, and finish, which drops what was kept for undo once the batch stands.
+type batchStep struct {
+	index int
+	op    batchOperation
+	src   string // entry to delete, move or copy; directory to create
+	dst   string // move and copy destination
+
+	undo   func() error
+	finish func()
+}
+
+// batchRun carries what every step of one batch needs.
+type batchRun struct {
+	h            *Handler
+	workspaceID  string
+	basePath     string
+	owner        *fsutil.Owner
+	sessionToken string
+	atomic       bool
+}
+
+// Batch handles POST /api/batch (JSON: workspace, atomic, operations).
+//
+// Every path is validated before anything runs; one invalid path rejects the
+// whole batch with 400. Operations then run in order. Without atomic, a failed
+// operation does not stop the rest. With atomic, the first failure stops the
+// batch and completed operations are undone in reverse order: deletes come
+// back from the trash or from a staged copy, moves are moved back, and copies
+// and created directories are removed.
+func (h *Handler) Batch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBody)
+	var body struct {
+		Workspace  string           `json:"workspace"`
+		Atomic     bool             `json:"atomic"`
+		Operations []batchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if len(body.Operations) == 0 {
+		response.Error(w, http.StatusBadRequest, "No operations provided")
+		return
+	}
+	if len(body.Operations) > MaxBatchOperations {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("Too many operations (max %d)", MaxBatchOperations))
+		return
+	}
+
+	workspaceID := workspacepkg.SessionWorkspace(r, h.sessions)
+	if workspaceID == "" {
+		workspaceID = body.Workspace
+	}
+	if workspaceID == "" {
+		workspaceID = "root"
+	}
+	basePath, err := h.resolver.ResolveWorkspaceBase(workspaceID)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if realBase, err := filepath.EvalSymlinks(basePath); err == nil {
+		basePath = realBase
+	}
+
+	results := make([]batchResult, len(body.Operations))
+	steps := make([]*batchStep, len(body.Operations))
+	valid := true
+	for i, op := range body.Operations {
+		results[i] = batchResult{Index: i, Op: op.Op, Path: op.Path, From: op.From, To: op.To, Status: batchStatusSkipped}
+		step, err := h.prepareBatchStep(workspaceID, i, op)
+		if err != nil {
+			results[i].Status = batchStatusInvalid
+			results[i].Error = err.Error()
+			valid = false
+			continue
+		}
+		steps[i] = step
+	}
+	if !valid {
+		response.JSON(w, http.StatusBadRequest, map[string]any{
+			"error":   "Batch validation failed; nothing was changed",
+			"atomic":  body.Atomic,
+			"results": results,
+		})
+		return
+	}
+
+	run := &batchRun{
+		h:            h,
+		workspaceID:  workspaceID,
+		basePath:     basePath,
+		owner:        h.workspaceOwner(workspaceID),
+		sessionToken: httpxmiddleware.GetSessionToken(r),
+		atomic:       body.Atomic,
+	}
+
+	var done []*batchStep
+	succeeded, failed := 0, 0
+	rolledBack := false
+	for i, step := range steps {
+		if err := run.runStep(step, &results[i]); err != nil {
+			filesLog.Warn("Batch %s #%d failed: %v", step.op.Op, i, err)
+			results[i].Status = batchStatusFailed
+			results[i].Error = err.Error()
+			failed++
+			if body.Atomic {
+				run.rollback(done, results)
+				rolledBack = true
+				succeeded = 0
+				break
+			}
+			continue
+		}
+		results[i].Status = batchStatusOK
+		succeeded++
+		if body.Atomic {
+			done = append(done, step)
+		} else if step.finish != nil {
+			step.finish()
+		}
+	}
+	if body.Atomic && !rolledBack {
+		for _, step := range done {
+			if step.finish != nil {
+				step.finish()
+			}
+		}
+	}
+
+	filesLog.Info("Batch in %s: %d succeeded, %d failed (atomic=%v)", workspaceID, succeeded, failed, body.Atomic)
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	response.JSON(w, status, map[string]any{
+		"success":    failed == 0,
+		"atomic":     body.Atomic,
+		"succeeded":  succeeded,
+		"failed":     failed,
+		"rolledBack": rolledBack,
+		"results":    results,
+	})
+}
+
+// prepareBatchStep validates the paths of one operation with the resolver.
+// Existence is checked when the operation runs, since earlier operations of
+// the batch may create or remove what it refers to.
+func (h *Handler) prepareBatchStep(workspaceID string, index int, op batchOperation) (*batchStep, error) {
+	step := &batchStep{index: index, op: op}
+	var err error
+	switch op.Op {
+	case batchDelete:
+		if op.Path == "" {
+			return nil, errors.New("No path provided")
+		}
+		step.src, err = h.resolveEntryPath(workspaceID, op.Path)
+	case batchMkdir:
+		if op.Path == "" {
+			return nil, errors.New("No directory path provided")
+		}
+		_, step.src, err = h.resolver.ResolveForWorkspace(workspaceID, op.Path)
+	case batchMove, batchCopy:
+		if op.From == "" || op.To == "" {
+			return nil, errors.New("Source and destination paths required")
+		}
+		if step.src, err = h.resolveEntryPath(workspaceID, op.From); err != nil {
+			break
+		}
+		if step.dst, err = h.resolveEntryPath(workspaceID, op.To); err != nil {
+			break
+		}
+		switch {
+		case step.src == step.dst:
+			return nil, errors.New("Source and destination are the same")
+		case strings.HasPrefix(step.dst, step.src+string(os.PathSeparator)):
+			return nil, fmt.Errorf("Cannot %s a directory into itself", op.Op)
+		case strings.HasPrefix(step.src, step.dst+string(os.PathSeparator)):
+			return nil, errors.New("Cannot replace a directory that contains the source")
+		}
+	default:
+		return nil, fmt.Errorf("Unknown operation %q", op.Op)
+	}
+	if err != nil {
+		return nil, batchPathError(err)
+	}
+	return step, nil
+}
+
+// batchPathError turns a resolver error into a per-item message.
+func batchPathError(err error) error {
+	switch {
+	case workspacepkg.IsPathTraversal(err):
+		return errors.New("Path traversal detected")
+	case workspacepkg.IsSymlinkEscape(err):
+		return errors.New("Path escapes the workspace through a symlink")
+	case errors.Is(err, workspacepkg.ErrRootDeletion):
+		return errors.New("Cannot operate on the workspace root")
+	default:
+		return errors.New("Invalid path")
+	}
+}
+
+// batchQuotaError turns a quota reservation error into a per-item message.
+func batchQuotaError(err error) error {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		return fmt.Errorf("Site quota exceeded (%s)", exceeded.Resource)
+	}
+	return errors.New("Failed to check site quota")
+}
+
+func (b *batchRun) runStep(step *batchStep, result *batchResult) error {
+	switch step.op.Op {
+	case batchDelete:
+		return b.runDelete(step, result)
+	case batchMove:
+		return b.runMove(step, result)
+	case batchCopy:
+		return b.runCopy(step, result)
+	default:
+		return b.runMkdir(step, result)
+	}
+}
+
+// rollback undoes completed steps in reverse order and recounts the site,
+// since undone steps do not track their quota changes individually.
+func (b *batchRun) rollback(done []*batchStep, results []batchResult) {
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		result := &results[step.index]
+		if step.undo == nil {
+			result.Status = batchStatusRolledBack
+			continue
+		}
+		if err := step.undo(); err != nil {
+			filesLog.Error("Batch rollback of %s #%d failed: %v", step.op.Op, step.index, err)
+			result.Status = batchStatusRollbackFailed
+			result.Error = "Rollback failed"
+			continue
+		}
+		result.Status = batchStatusRolledBack
+	}
+	b.h.quotas.Invalidate(b.basePath)
+}
+
+// stagedPath names the sibling an entry is set aside as while it may still be
+// needed for undo. The random suffix keeps concurrent batches apart and the
+// name unguessable.
+func stagedPath(path string) string {
+	var b [6]byte
+	rand.Read(b[:])
+	return path + ".batch-" + hex.EncodeToString(b[:])
+}
+
+func (b *batchRun) runDelete(step *batchStep, result *batchResult) error {
+	entry := step.src
+	if _, err := os.Lstat(entry); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("Path not found")
+		}
+		return errors.New("Failed to stat path")
+	}
+
+	if step.op.Permanent {
+		freedBytes, freedInodes := b.h.siteUsageOf(entry)
+		if !b.atomic {
+			if err := os.RemoveAll(entry); err != nil {
+				b.h.quotas.Invalidate(entry)
+				return errors.New("Failed to delete")
+			}
+			b.h.quotas.Add(entry, -freedBytes, -freedInodes)
+			return nil
+		}
+		// Keep the entry aside until the whole batch has succeeded.
+		staged := stagedPath(entry)
+		if err := os.Rename(entry, staged); err != nil {
+			return errors.New("Failed to delete")
+		}
+		step.undo = func() error { return os.Rename(staged, entry) }
+		step.finish = func() {
+			if err := os.RemoveAll(staged); err != nil {
+				filesLog.Warn("Failed to remove deleted entry %s: %v", staged, err)
+				b.h.quotas.Invalidate(entry)
+				return
+			}
+			b.h.quotas.Add(entry, -freedBytes, -freedInodes)
+		}
+		return nil
+	}
+
+	var freedBytes, freedInodes int64
+	if b.h.trashLeavesSite(b.workspaceID, entry) {
+		freedBytes, freedInodes = b.h.siteUsageOf(entry)
+	}
+	item, err := b.h.trash.Trash(b.workspaceID, b.basePath, entry, b.sessionToken)
+	if err != nil {
+		filesLog.Error("Failed to move %s to trash: %v", entry, err)
+		return errors.New("Failed to delete")
+	}
+	b.h.quotas.Add(entry, -freedBytes, -freedInodes)
+	result.TrashID = item.ID
+	step.undo = func() error {
+		_, err := b.h.trash.Restore(b.workspaceID, item.ID, entry)
+		return err
+	}
+	return nil
+}
+
+// setAsideDestination moves an existing destination out of the way when
+// overwrite is allowed. The returned restore puts it back and discard removes
+// it for good.
+func (b *batchRun) setAsideDestination(step *batchStep, result *batchResult) (restore func() error, discard func(), err error) {
+	if _, err := os.Lstat(step.dst); err != nil {
+		return func() error { return nil }, func() {}, nil
+	}
+	if !step.op.Overwrite {
+		return nil, nil, errors.New("Destination already exists")
+	}
+	staged := stagedPath(step.dst)
+	if err := os.Rename(step.dst, staged); err != nil {
+		filesLog.Error("Failed to set aside %s: %v", step.dst, err)
+		return nil, nil, errors.New("Failed to replace destination")
+	}
+	result.Overwritten = true
+	restore = func() error {
+		if err := os.RemoveAll(step.dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.Rename(staged, step.dst)
+	}
+	discard = func() {
+		if err := os.RemoveAll(staged); err != nil {
+			filesLog.Warn("Failed to remove replaced destination %s: %v", staged, err)
+		}
+		b.h.quotas.Invalidate(step.dst)
+	}
+	return restore, discard, nil
+}
+
+// makeParents creates the missing parents of path for the workspace owner and
+// returns an undo that removes the ones it created, if they are empty again.
+func (b *batchRun) makeParents(path string) (func(), error) {
+	created := missingDirs(filepath.Dir(path))
+	if err := b.owner.MkdirAll(filepath.Dir(path)); err != nil {
+		return nil, errors.New("Failed to create directory")
+	}
+	return func() { removeEmptyDirs(created) }, nil
+}
+
+// missingDirs lists dir and its ancestors that do not exist, deepest first.
+func missingDirs(dir string) []string {
+	var missing []string
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			return missing
+		}
+		missing = append(missing, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return missing
+		}
+		dir = parent
+	}
+}
+
+// removeEmptyDirs removes directories deepest first, leaving any that are not empty.
+func removeEmptyDirs(dirs []string) {
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
+}
+
+func (b *batchRun) runMove(step *batchStep, result *batchResult) error {
+	if _, err := os.Lstat(step.src); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("Source not found")
+		}
+		return errors.New("Failed to stat source")
+	}
+	restoreDst, discardDst, err := b.setAsideDestination(step, result)
+	if err != nil {
+		return err
+	}
+	removeParents, err := b.makeParents(step.dst)
+	if err != nil {
+		restoreDst()
+		return err
+	}
+	if err := fsutil.Move(step.src, step.dst); err != nil {
+		filesLog.Error("Failed to move %s to %s: %v", step.src, step.dst, err)
+		removeParents()
+		if restoreErr := restoreDst(); restoreErr != nil {
+			filesLog.Error("Failed to restore %s: %v", step.dst, restoreErr)
+		}
+		return errors.New("Failed to move")
+	}
+
+	step.undo = func() error {
+		if err := b.owner.MkdirAll(filepath.Dir(step.src)); err != nil {
+			return err
+		}
+		if err := fsutil.Move(step.dst, step.src); err != nil {
+			return err
+		}
+		removeParents()
+		return restoreDst()
+	}
+	step.finish = discardDst
+	return nil
+}
+
+func (b *batchRun) runCopy(step *batchStep, result *batchResult) error {
+	if _, err := os.Lstat(step.src); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("Source not found")
+		}
+		return errors.New("Failed to stat source")
+	}
+	bytes, inodes := b.h.siteUsageOf(step.src)
+	reservation, err := b.h.quotas.Reserve(step.dst, bytes, inodes)
+	if err != nil {
+		return batchQuotaError(err)
+	}
+	restoreDst, discardDst, err := b.setAsideDestination(step, result)
+	if err != nil {
+		reservation.Release()
+		return err
+	}
+	removeParents, err := b.makeParents(step.dst)
+	if err != nil {
+		reservation.Release()
+		restoreDst()
+		return err
+	}
+	if err := fsutil.CopyTree(step.src, step.dst); err != nil {
+		filesLog.Error("Failed to copy %s to %s: %v", step.src, step.dst, err)
+		reservation.Release()
+		os.RemoveAll(step.dst)
+		removeParents()
+		if restoreErr := restoreDst(); restoreErr != nil {
+			filesLog.Error("Failed to restore %s: %v", step.dst, restoreErr)
+		}
+		return errors.New("Failed to copy")
+	}
+	reservation.Commit()
+
+	step.undo = func() error {
+		if err := os.RemoveAll(step.dst); err != nil {
+			return err
+		}
+		removeParents()
+		return restoreDst()
+	}
+	step.finish = discardDst
+	return nil
+}
+
+func (b *batchRun) runMkdir(step *batchStep, result *batchResult) error {
+	if info, err := os.Stat(step.src); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return errors.New("A file with that name already exists")
+	}
+	reservation, err := b.h.quotas.Reserve(step.src, 0, 1)
+	if err != nil {
+		return batchQuotaError(err)
+	}
+	created := missingDirs(step.src)
+	if err := b.owner.MkdirAll(step.src); err != nil {
+		reservation.Release()
+		filesLog.Error("Failed to create directory %s: %v", step.src, err)
+		return errors.New("Failed to create directory")
+	}
+	reservation.Commit()
+	result.Created = true
+
+	step.undo = func() error {
+		removeEmptyDirs(created)
+		if _, err := os.Lstat(step.src); err == nil {
+			return fmt.Errorf("%s is not empty", step.src)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type batchResponse struct {
+	Success    bool          `json:"success"`
+	Succeeded  int           `json:"succeeded"`
+	Failed     int           `json:"failed"`
+	RolledBack bool          `json:"rolledBack"`
+	Results    []batchResult `json:"results"`
+}
+
+func batchRequest(t *testing.T, h *Handler, payload string) (int, batchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.Batch(w, req)
+
+	var resp batchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v body=%s", err, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func batchStatuses(resp batchResponse) []string {
+	statuses := make([]string, len(resp.Results))
+	for i, result := range resp.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestBatch_RunsOperationsInOrder(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	code, resp := batchRequest(t, h, `{"workspace":"root","operations":[
+		{"op":"mkdir","path":"archive/old"},
+		{"op":"move","from":"src/app.js","to":"archive/old/app.js"},
+		{"op":"copy","from":"src/lib","to":"archive/lib"},
+		{"op":"delete","path":"dist"},
+		{"op":"delete","path":"src/lib","permanent":true}
+	]}`)
+	if code != http.StatusOK || !resp.Success || resp.Succeeded != 5 {
+		t.Fatalf("expected all operations to succeed, got %d %+v", code, resp)
+	}
+	if !resp.Results[0].Created || resp.Results[3].TrashID == "" {
+		t.Fatalf("missing per-item details: %+v", resp.Results)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "archive", "old", "app.js")); string(content) != "app" {
+		t.Fatalf("move missing: %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "archive", "lib", "util.js")); string(content) != "util" {
+		t.Fatalf("copy missing: %q", content)
+	}
+	for _, gone := range []string{"src/app.js", "src/lib", "dist"} {
+		if _, err := os.Lstat(filepath.Join(root, gone)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be gone: %v", gone, err)
+		}
+	}
+}
+
+func TestBatch_ContinuesPastFailuresWithoutAtomic(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	code, resp := batchRequest(t, h, `{"operations":[
+		{"op":"delete","path":"missing.txt"},
+		{"op":"move","from":"src/app.js","to":"dist/app.js"},
+		{"op":"mkdir","path":"logs"}
+	]}`)
+	if code != http.StatusMultiStatus || resp.Success || resp.Succeeded != 1 || resp.Failed != 2 {
+		t.Fatalf("expected partial success, got %d %+v", code, resp)
+	}
+	got := strings.Join(batchStatuses(resp), ",")
+	if got != "failed,failed,ok" {
+		t.Fatalf("unexpected statuses %s: %+v", got, resp.Results)
+	}
+	if resp.Results[1].Error != "Destination already exists" {
+		t.Fatalf("unexpected error: %q", resp.Results[1].Error)
+	}
+	if info, err := os.Stat(filepath.Join(root, "logs")); err != nil || !info.IsDir() {
+		t.Fatalf("mkdir after failures did not run: %v", err)
+	}
+}
+
+func TestBatch_AtomicRollsBackCompletedOperations(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	code, resp := batchRequest(t, h, `{"atomic":true,"operations":[
+		{"op":"mkdir","path":"archive/old"},
+		{"op":"move","from":"src/app.js","to":"archive/old/app.js"},
+		{"op":"copy","from":"src/lib/util.js","to":"dist/app.js","overwrite":true},
+		{"op":"delete","path":"src/lib","permanent":true},
+		{"op":"delete","path":"dist"},
+		{"op":"move","from":"missing.txt","to":"elsewhere.txt"},
+		{"op":"mkdir","path":"never"}
+	]}`)
+	if code != http.StatusMultiStatus || !resp.RolledBack || resp.Succeeded != 0 || resp.Failed != 1 {
+		t.Fatalf("expected rollback, got %d %+v", code, resp)
+	}
+	got := strings.Join(batchStatuses(resp), ",")
+	want := "rolled_back,rolled_back,rolled_back,rolled_back,rolled_back,failed,skipped"
+	if got != want {
+		t.Fatalf("statuses = %s, want %s", got, want)
+	}
+
+	for name, content := range map[string]string{
+		"src/app.js":      "app",
+		"src/lib/util.js": "util",
+		"dist/app.js":     "old build",
+	} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err != nil || string(data) != content {
+			t.Fatalf("%s not restored: %q %v", name, data, err)
+		}
+	}
+	entries, _ := os.ReadDir(root)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "dist,src" {
+		t.Fatalf("rollback left entries behind: %v", names)
+	}
+	items, _ := h.trash.List("root")
+	if len(items) != 0 {
+		t.Fatalf("trashed entry not restored: %+v", items)
+	}
+}
+
+func TestBatch_RejectsInvalidPathsBeforeRunning(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	code, resp := batchRequest(t, h, `{"operations":[
+		{"op":"delete","path":"dist/app.js"},
+		{"op":"move","from":"src/app.js","to":"../outside.js"},
+		{"op":"copy","from":"src","to":"src/nested"},
+		{"op":"rename","path":"src"},
+		{"op":"delete","path":"."}
+	]}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %+v", code, resp)
+	}
+	got := strings.Join(batchStatuses(resp), ",")
+	if got != "skipped,invalid,invalid,invalid,invalid" {
+		t.Fatalf("unexpected statuses %s: %+v", got, resp.Results)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dist", "app.js")); err != nil {
+		t.Fatalf("valid operation ran despite invalid batch: %v", err)
+	}
+}
+
+func TestBatch_StagedPathsAreUnique(t *testing.T) {
+	first, second := stagedPath("/srv/a.txt"), stagedPath("/srv/a.txt")
+	if first == second {
+		t.Fatalf("staged paths collide: %s", first)
+	}
+	if !strings.HasPrefix(first, "/srv/a.txt.batch-") || len(first) != len("/srv/a.txt.batch-")+12 {
+		t.Fatalf("unexpected staged path %s", first)
+	}
+}
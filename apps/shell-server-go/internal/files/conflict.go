@@ -0,0 +1,373 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shell-server-go/internal/fsutil"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/scan"
+)
+
+// ConflictMode decides what an upload does with entries that already exist in
+// the target directory.
+type ConflictMode string
+
+const (
+	// ConflictFail rejects the upload with 409 if any root item exists.
+	ConflictFail ConflictMode = "fail"
+	// ConflictOverwrite replaces existing root items as a whole.
+	ConflictOverwrite ConflictMode = "overwrite"
+	// ConflictSkipExisting only writes entries that do not exist yet.
+	ConflictSkipExisting ConflictMode = "skip-existing"
+	// ConflictRename extracts existing root items under a free name (dist-1).
+	ConflictRename ConflictMode = "rename"
+	// ConflictMerge merges directories and overwrites files that exist.
+	ConflictMerge ConflictMode = "merge"
+)
+
+// Plan actions reported per entry.
+const (
+	actionCreate    = "create"
+	actionOverwrite = "overwrite"
+	actionReplace   = "replace"
+	actionMerge     = "merge"
+	actionSkip      = "skip"
+	actionRename    = "rename"
+	actionDelete    = "delete"
+	actionConflict  = "conflict"
+)
+
+const maxRenameAttempts = 1000
+
+// parseConflictMode accepts the mode names; an empty value means ConflictFail.
+func parseConflictMode(value string) (ConflictMode, error) {
+	switch mode := ConflictMode(value); mode {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictOverwrite, ConflictSkipExisting, ConflictRename, ConflictMerge:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid conflict mode %q (use fail, overwrite, skip-existing, rename or merge)", value)
+	}
+}
+
+// uploadOptions controls how an upload treats existing entries.
+type uploadOptions struct {
+	conflict ConflictMode
+	dryRun   bool
+	findings []scan.Finding // reported by the upload scan, echoed in the response
+}
+
+// uploadOptionsFromForm reads the conflict and dryRun form fields.
+func uploadOptionsFromForm(r *http.Request) (uploadOptions, error) {
+	mode, err := parseConflictMode(r.FormValue("conflict"))
+	if err != nil {
+		return uploadOptions{}, err
+	}
+	dryRun := r.FormValue("dryRun") == "true" || r.FormValue("dryRun") == "1"
+	return uploadOptions{conflict: mode, dryRun: dryRun}, nil
+}
+
+// PlannedAction is one entry of an extraction plan. Target is set when the entry
+// is written somewhere other than Path, i.e. below a renamed root item.
+type PlannedAction struct {
+	Path   string `json:"path"`
+	Target string `json:"target,omitempty"`
+	Type   string `json:"type"`
+	Action string `json:"action"`
+}
+
+// planEntry is an upload or archive entry as seen by the planner.
+type planEntry struct {
+	name  string
+	isDir bool
+	size  int64
+}
+
+// extractionPlan maps archive entries to destinations for one conflict mode.
+// Existing entries are inspected with Lstat and only real directories are merged
+// into, so nothing is ever written through a symlink already in the target.
+type extractionPlan struct {
+	mode     ConflictMode
+	target   string
+	actions  []PlannedAction
+	dest     map[string]string // entry name -> destination relative to target
+	sizes    map[string]int64  // entry name -> bytes written
+	existing []string          // root items that block a ConflictFail upload
+	replaced []string          // destinations set aside before writing
+	backups  map[string]string
+	owner    *fsutil.Owner // owner of everything the plan writes
+}
+
+// planName normalises an entry name to a slash-separated relative path.
+func planName(name string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// planExtraction decides an action for every entry and its implicit parent
+// directories. Parents sort before their children, so each entry can follow the
+// decision made for its parent.
+func planExtraction(target string, entries []planEntry, mode ConflictMode) *extractionPlan {
+	isDir := make(map[string]bool)
+	sizes := make(map[string]int64)
+	for _, e := range entries {
+		name := planName(e.name)
+		if name == "" {
+			continue
+		}
+		isDir[name] = isDir[name] || e.isDir
+		sizes[name] += e.size
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			isDir[dir] = true
+		}
+	}
+	names := make([]string, 0, len(isDir))
+	for name := range isDir {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plan := &extractionPlan{
+		mode:    mode,
+		target:  target,
+		dest:    make(map[string]string, len(names)),
+		sizes:   sizes,
+		backups: make(map[string]string),
+	}
+	decided := make(map[string]string, len(names))
+	for _, name := range names {
+		typeStr := "file"
+		if isDir[name] {
+			typeStr = "directory"
+		}
+		parent := path.Dir(name)
+		destRel := name
+		if parent != "." {
+			destRel = path.Join(plan.dest[parent], path.Base(name))
+		}
+
+		action := ""
+		switch decided[parent] {
+		case actionSkip, actionConflict:
+			action = decided[parent]
+		case actionCreate, actionReplace, actionRename:
+			action = actionCreate
+		default: // top level, or a directory that exists and is merged into
+			action = plan.decide(name, destRel, isDir, parent == ".")
+			if action == actionRename {
+				destRel = plan.renameRoot(name, isDir)
+			}
+		}
+		decided[name] = action
+
+		entry := PlannedAction{Path: name, Type: typeStr, Action: action}
+		if destRel != name {
+			entry.Target = destRel
+		}
+		plan.actions = append(plan.actions, entry)
+
+		switch action {
+		case actionSkip, actionConflict:
+			continue
+		case actionReplace:
+			plan.replaced = append(plan.replaced, destRel)
+			plan.listDeletes(destRel, isDir)
+		}
+		plan.dest[name] = destRel
+	}
+	return plan
+}
+
+// decide picks the action for an entry whose parent is a real directory.
+func (p *extractionPlan) decide(name, destRel string, isDir map[string]bool, root bool) string {
+	info, err := os.Lstat(filepath.Join(p.target, filepath.FromSlash(destRel)))
+	if err != nil {
+		return actionCreate
+	}
+	realDir := info.IsDir()
+	switch p.mode {
+	case ConflictOverwrite:
+		return actionReplace
+	case ConflictRename:
+		return actionRename
+	case ConflictMerge:
+		switch {
+		case realDir && isDir[name]:
+			return actionMerge
+		case info.Mode().IsRegular() && !isDir[name]:
+			return actionOverwrite
+		default:
+			return actionReplace
+		}
+	case ConflictSkipExisting:
+		if realDir && isDir[name] {
+			return actionMerge
+		}
+		return actionSkip
+	default:
+		if root {
+			p.existing = append(p.existing, name)
+		}
+		return actionConflict
+	}
+}
+
+// renameRoot finds a free name for a root item: name-1.ext, name-2.ext, ...
+// Names used by other root items of the same upload are avoided too.
+func (p *extractionPlan) renameRoot(name string, isDir map[string]bool) string {
+	ext := ""
+	if !isDir[name] {
+		ext = filepath.Ext(name)
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", stem, i, ext)
+		if _, taken := isDir[candidate]; taken {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(p.target, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+	// Every numbered name is taken; fall back to a random suffix.
+	suffix, _ := generateUploadID()
+	return fmt.Sprintf("%s-%.8s%s", stem, suffix, ext)
+}
+
+// listDeletes reports what disappears with a replaced directory: existing
+// entries below it that the upload does not write again.
+func (p *extractionPlan) listDeletes(destRel string, isDir map[string]bool) {
+	root := filepath.Join(p.target, filepath.FromSlash(destRel))
+	if info, err := os.Lstat(root); err != nil || !info.IsDir() {
+		return
+	}
+	filepath.WalkDir(root, func(entryPath string, d fs.DirEntry, err error) error {
+		if err != nil || entryPath == root {
+			return nil
+		}
+		rel, relErr := filepath.Rel(p.target, entryPath)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if _, written := isDir[rel]; written {
+			return nil
+		}
+		typeStr := "file"
+		if d.IsDir() {
+			typeStr = "directory"
+		}
+		p.actions = append(p.actions, PlannedAction{Path: rel, Type: typeStr, Action: actionDelete})
+		return nil
+	})
+}
+
+// destination returns where an entry is written, or false if it is skipped.
+func (p *extractionPlan) destination(name string) (string, bool) {
+	destRel, ok := p.dest[planName(name)]
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(p.target, filepath.FromSlash(destRel)), true
+}
+
+// writeTotals returns the bytes and entries the plan writes, for quota checks.
+// Entries that replace or overwrite existing ones are counted in full.
+func (p *extractionPlan) writeTotals() (bytes, inodes int64) {
+	for name := range p.dest {
+		bytes += p.sizes[name]
+		inodes++
+	}
+	return bytes, inodes
+}
+
+// replacesExisting reports whether the plan removes or overwrites anything,
+// in which case the exact change in disk usage is only known after a recount.
+func (p *extractionPlan) replacesExisting() bool {
+	if len(p.replaced) > 0 {
+		return true
+	}
+	for _, a := range p.actions {
+		if a.Action == actionOverwrite {
+			return true
+		}
+	}
+	return false
+}
+
+// summary counts entries per action.
+func (p *extractionPlan) summary() map[string]int {
+	counts := make(map[string]int)
+	for _, a := range p.actions {
+		counts[a.Action]++
+	}
+	return counts
+}
+
+// setAside renames replaced entries out of the way. They are deleted by commit
+// once the upload succeeded, or put back by rollback.
+func (p *extractionPlan) setAside() error {
+	for _, rel := range p.replaced {
+		original := filepath.Join(p.target, filepath.FromSlash(rel))
+		backup := fmt.Sprintf("%s.replacing-%d", original, os.Getpid())
+		if err := os.Rename(original, backup); err != nil {
+			p.rollback()
+			return err
+		}
+		p.backups[original] = backup
+	}
+	return nil
+}
+
+// commit removes the entries that were replaced.
+func (p *extractionPlan) commit() {
+	for original, backup := range p.backups {
+		if err := os.RemoveAll(backup); err != nil {
+			filesLog.Warn("Failed to remove replaced entry %s: %v", original, err)
+		}
+	}
+	p.backups = make(map[string]string)
+}
+
+// rollback restores replaced entries after a failed upload. Anything written at
+// their place is removed first.
+func (p *extractionPlan) rollback() {
+	for original, backup := range p.backups {
+		os.RemoveAll(original)
+		if err := os.Rename(backup, original); err != nil {
+			filesLog.Error("Failed to restore %s: %v", original, err)
+		}
+	}
+	p.backups = make(map[string]string)
+}
+
+// writeDryRun responds with the plan instead of writing anything.
+func (p *extractionPlan) writeDryRun(w http.ResponseWriter, targetDir string) {
+	response.JSON(w, http.StatusOK, map[string]any{
+		"success":       true,
+		"dryRun":        true,
+		"conflict":      p.mode,
+		"targetDir":     targetDir,
+		"extractTo":     p.target,
+		"actions":       p.actions,
+		"summary":       p.summary(),
+		"existingItems": p.existing,
+	})
+}
+
+// writeConflict responds 409 for a ConflictFail upload whose root items exist.
+func (p *extractionPlan) writeConflict(w http.ResponseWriter, message, hint string) {
+	response.JSON(w, http.StatusConflict, map[string]any{
+		"error":         message,
+		"existingItems": p.existing,
+		"targetDir":     p.target,
+		"hint":          hint,
+	})
+}
@@ -0,0 +1,194 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeSiteBuild creates an existing dist/ tree to upload a new build over.
+func writeSiteBuild(t *testing.T, root string) {
+	t.Helper()
+	for name, content := range map[string]string{
+		"dist/index.html": "old index",
+		"dist/old.js":     "stale",
+		"dist/keep.txt":   "local",
+	} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+}
+
+func readFixture(t *testing.T, root, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func TestUpload_ZipConflictModes(t *testing.T) {
+	build := map[string]string{"dist/index.html": "new index", "dist/app.js": "app"}
+
+	cases := []struct {
+		mode  string
+		check func(t *testing.T, root string)
+	}{
+		{"overwrite", func(t *testing.T, root string) {
+			if readFixture(t, root, "dist/index.html") != "new index" || readFixture(t, root, "dist/old.js") != "" {
+				t.Fatalf("dist was not replaced as a whole")
+			}
+		}},
+		{"merge", func(t *testing.T, root string) {
+			if readFixture(t, root, "dist/index.html") != "new index" || readFixture(t, root, "dist/keep.txt") != "local" {
+				t.Fatalf("merge did not overwrite and keep files")
+			}
+		}},
+		{"skip-existing", func(t *testing.T, root string) {
+			if readFixture(t, root, "dist/index.html") != "old index" || readFixture(t, root, "dist/app.js") != "app" {
+				t.Fatalf("skip-existing changed existing files or missed new ones")
+			}
+		}},
+		{"rename", func(t *testing.T, root string) {
+			if readFixture(t, root, "dist/index.html") != "old index" || readFixture(t, root, "dist-1/index.html") != "new index" {
+				t.Fatalf("rename did not extract next to the existing dist")
+			}
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.mode, func(t *testing.T) {
+			h, sessions := setupFilesHandler(t)
+			defer sessions.Stop()
+			root := h.config.ResolvedUploadCwd
+			writeSiteBuild(t, root)
+
+			w := uploadWithFields(t, h, "build.zip", buildZip(t, build), map[string]string{"conflict": tc.mode})
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+			}
+			tc.check(t, root)
+			if matches, _ := filepath.Glob(filepath.Join(root, "*.replacing-*")); len(matches) != 0 {
+				t.Fatalf("replaced entries left behind: %v", matches)
+			}
+		})
+	}
+}
+
+func TestUpload_ConflictFailAndDryRun(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeSiteBuild(t, root)
+	build := buildZip(t, map[string]string{"dist/index.html": "new index", "dist/app.js": "app"})
+
+	if w := uploadArchive(t, h, "build.zip", build); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 by default, got %d", w.Code)
+	}
+	if w := uploadWithFields(t, h, "build.zip", build, map[string]string{"conflict": "clobber"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown mode, got %d", w.Code)
+	}
+
+	w := uploadWithFields(t, h, "build.zip", build, map[string]string{"conflict": "overwrite", "dryRun": "true"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var plan struct {
+		DryRun  bool            `json:"dryRun"`
+		Actions []PlannedAction `json:"actions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := make(map[string]string)
+	for _, a := range plan.Actions {
+		got[a.Path] = a.Action
+	}
+	want := map[string]string{
+		"dist":            actionReplace,
+		"dist/index.html": actionCreate,
+		"dist/app.js":     actionCreate,
+		"dist/old.js":     actionDelete,
+		"dist/keep.txt":   actionDelete,
+	}
+	for path, action := range want {
+		if got[path] != action {
+			t.Errorf("%s: expected %s, got %q (plan %+v)", path, action, got[path], plan.Actions)
+		}
+	}
+	if !plan.DryRun || readFixture(t, root, "dist/old.js") != "stale" {
+		t.Fatalf("dry run changed the target")
+	}
+}
+
+func TestUpload_MergeNeverWritesThroughSymlinks(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+
+	other := filepath.Join(root, "other")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Symlink(other, filepath.Join(root, "assets")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	data := buildTar(t, []tarFixtureEntry{{name: "assets/logo.svg", typeflag: tar.TypeReg, body: "<svg/>"}})
+	w := uploadWithFields(t, h, "assets.tar", data, map[string]string{"conflict": "merge"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if info, err := os.Lstat(filepath.Join(root, "assets")); err != nil || !info.IsDir() {
+		t.Fatalf("symlink was not replaced by a directory")
+	}
+	if _, err := os.Stat(filepath.Join(other, "logo.svg")); !os.IsNotExist(err) {
+		t.Fatalf("merge wrote through the existing symlink")
+	}
+}
+
+func TestUpload_RegularFileRename(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	if err := os.WriteFile(filepath.Join(root, "report.pdf"), []byte("v1"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	w := uploadWithFields(t, h, "report.pdf", []byte("v2"), map[string]string{"conflict": "rename"})
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"filename":"report-1.pdf"`)) {
+		t.Fatalf("expected rename, got %d body=%s", w.Code, w.Body.String())
+	}
+	if readFixture(t, root, "report.pdf") != "v1" || readFixture(t, root, "report-1.pdf") != "v2" {
+		t.Fatalf("unexpected files after rename")
+	}
+}
@@ -0,0 +1,125 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"shell-server-go/internal/diskusage"
+	"shell-server-go/internal/httpx/response"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// diskUsageTimeout bounds one disk usage scan.
+const diskUsageTimeout = 2 * time.Minute
+
+// siteStorageDirs returns the site's storage outside its served tree by the
+// name it is reported under: its trash and its snapshots.
+func (h *Handler) siteStorageDirs(workspaceID, basePath string) map[string]string {
+	dirs := make(map[string]string, 2)
+	if dir, err := h.snapshots.Dir(filepath.Base(filepath.Dir(basePath))); err == nil {
+		dirs[".snapshots"] = dir
+	}
+	if dir, err := h.trash.Dir(workspaceID); err == nil {
+		dirs[".trash"] = dir
+	}
+	return dirs
+}
+
+// DiskUsage handles GET /api/files/disk-usage?workspace=X[&path=P][&depth=N][&top=N][&refresh=1].
+// It reports sizes per directory down to depth (default 1, max 8), the top
+// largest files (default 20, max 100) and totals per extension. Ignored paths
+// are included, as they take space all the same. At the root of a site
+// workspace, siteDirs adds the site's trash and snapshots.
+func (h *Handler) DiskUsage(w http.ResponseWriter, r *http.Request) {
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	query := r.URL.Query()
+	dirPath := query.Get("path")
+	if dirPath == "" {
+		dirPath = "."
+	}
+
+	basePath, resolvedDir, err := h.resolver.ResolveForWorkspace(workspaceID, dirPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if info, err := os.Stat(resolvedDir); err != nil || !info.IsDir() {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+
+	opts := diskusage.Options{
+		Depth:   diskusage.DefaultDepth,
+		Top:     diskusage.DefaultTop,
+		Refresh: query.Get("refresh") == "1" || query.Get("refresh") == "true",
+	}
+	if raw := query.Get("depth"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid depth")
+			return
+		}
+		opts.Depth = min(n, diskusage.MaxDepth)
+	}
+	if raw := query.Get("top"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			response.Error(w, http.StatusBadRequest, "Invalid top")
+			return
+		}
+		opts.Top = min(n, diskusage.MaxTop)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), diskUsageTimeout)
+	defer cancel()
+
+	report, err := h.usage.Analyze(ctx, resolvedDir, opts)
+	if err != nil {
+		h.handleDiskUsageError(w, r, resolvedDir, err)
+		return
+	}
+
+	body := map[string]any{
+		"workspace": workspaceID,
+		"path":      dirPath,
+		"usage":     report,
+	}
+	atBase := filepath.Clean(resolvedDir) == filepath.Clean(basePath)
+	if realBase, err := filepath.EvalSymlinks(basePath); err == nil {
+		atBase = filepath.Clean(resolvedDir) == realBase
+	}
+	if strings.HasPrefix(workspaceID, "site:") && atBase {
+		siteDirs := make(map[string]*diskusage.Node)
+		for name, dir := range h.siteStorageDirs(workspaceID, basePath) {
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			sub, err := h.usage.Analyze(ctx, dir, diskusage.Options{Depth: 0, Top: 1, Refresh: opts.Refresh})
+			if err != nil {
+				h.handleDiskUsageError(w, r, dir, err)
+				return
+			}
+			siteDirs[name] = sub.Root
+		}
+		body["siteDirs"] = siteDirs
+	}
+	response.JSON(w, http.StatusOK, body)
+}
+
+func (h *Handler) handleDiskUsageError(w http.ResponseWriter, r *http.Request, dir string, err error) {
+	switch {
+	case r.Context().Err() != nil:
+		// The client went away; nobody reads a response.
+	case errors.Is(err, context.DeadlineExceeded):
+		response.Error(w, http.StatusServiceUnavailable, "Disk usage scan timed out - retry to continue from the directories already read")
+	default:
+		filesLog.Error("Disk usage scan of %s failed: %v", dir, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to scan disk usage")
+	}
+}
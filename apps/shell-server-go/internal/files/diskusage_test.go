@@ -0,0 +1,103 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shell-server-go/internal/diskusage"
+)
+
+func TestDiskUsage_ReportsSiteWorkspace(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	siteDir := filepath.Join(h.config.ResolvedSitesPath, "example.com")
+	for path, size := range map[string]int{
+		"user/index.html":          10,
+		"user/dist/bundle.js":      400,
+		"user/dist/assets/app.css": 90,
+	} {
+		full := filepath.Join(siteDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, make([]byte, size), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	// Site trash is kept under the server's trash root, outside the site.
+	trashItem := filepath.Join(h.config.ResolvedTrashPath, "sites", "example.com", "x", "item")
+	if err := os.MkdirAll(filepath.Dir(trashItem), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(trashItem, make([]byte, 50), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files/disk-usage?workspace=site:example.com&depth=2&top=1", nil)
+	w := httptest.NewRecorder()
+	h.DiskUsage(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	var payload struct {
+		Usage    diskusage.Report           `json:"usage"`
+		SiteDirs map[string]*diskusage.Node `json:"siteDirs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	root := payload.Usage.Root
+	if root.Bytes != 500 || root.Files != 3 || len(root.Children) != 1 || len(root.Children[0].Children) != 1 {
+		t.Fatalf("unexpected tree %+v", root)
+	}
+	if len(payload.Usage.Largest) != 1 || payload.Usage.Largest[0].Path != "dist/bundle.js" {
+		t.Fatalf("unexpected largest %+v", payload.Usage.Largest)
+	}
+	if trash := payload.SiteDirs[".trash"]; trash == nil || trash.Bytes != 50 {
+		t.Fatalf("unexpected site dirs %+v", payload.SiteDirs)
+	}
+
+	// Below the root only that directory is reported.
+	req = httptest.NewRequest(http.MethodGet, "/api/files/disk-usage?workspace=site:example.com&path=dist", nil)
+	w = httptest.NewRecorder()
+	h.DiskUsage(w, req)
+	var sub struct {
+		Usage    diskusage.Report           `json:"usage"`
+		SiteDirs map[string]*diskusage.Node `json:"siteDirs"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &sub) != nil {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if sub.Usage.Root.Bytes != 490 || sub.SiteDirs != nil {
+		t.Fatalf("unexpected subdirectory report %+v siteDirs=%+v", sub.Usage.Root, sub.SiteDirs)
+	}
+}
+
+func TestDiskUsage_RejectsInvalidRequests(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	cases := []struct {
+		query string
+		code  int
+	}{
+		{"workspace=root&depth=-1", http.StatusBadRequest},
+		{"workspace=root&top=abc", http.StatusBadRequest},
+		{"workspace=root&path=missing", http.StatusNotFound},
+		{"workspace=root&path=../../etc", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/disk-usage?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		h.DiskUsage(w, req)
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d body=%s", tc.query, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
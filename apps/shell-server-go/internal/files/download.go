@@ -0,0 +1,63 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strings"
+	"syscall"
+
+	"shell-server-go/internal/filetype"
+)
+
+// activeContentCSP neuters scripts, plugins and navigation in documents the
+// browser would otherwise execute (HTML, SVG, XML) when viewed inline.
+const activeContentCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox"
+
+// activeContentTypes are MIME types that can run script when rendered inline,
+// besides every XML type (see isActiveContent).
+var activeContentTypes = map[string]bool{
+	"text/html": true,
+	"text/xsl":  true,
+}
+
+// detectContentType returns the MIME type for a file from its first bytes and
+// its name. A binary file is never served under a text type, and vice versa.
+func detectContentType(file io.ReadSeeker, name string) string {
+	info, err := filetype.DetectReader(file, name)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return info.MIMEType
+}
+
+// isActiveContent reports whether a Content-Type may execute script in a
+// browser. Any XML type counts: browsers render XML documents, and with an
+// XSLT or XHTML namespace those run script (application/atom+xml,
+// application/rss+xml, application/xslt+xml, image/svg+xml, ...).
+func isActiveContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return activeContentTypes[mediaType] || strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "/xml")
+}
+
+// strongETag builds an ETag from size, modification time and inode, so any
+// rewrite or replacement of the file changes it.
+func strongETag(info os.FileInfo) string {
+	var inode uint64
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		inode = stat.Ino
+	}
+	return fmt.Sprintf(`"%x-%x-%x"`, info.Size(), info.ModTime().UnixNano(), inode)
+}
+
+// contentDisposition formats a Content-Disposition header with a safely encoded filename.
+func contentDisposition(disposition, filename string) string {
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
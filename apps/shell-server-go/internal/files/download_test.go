@@ -0,0 +1,130 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFile_RangeAndConditionalRequests(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "report.pdf"), []byte("%PDF-12345"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=report.pdf", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	h.DownloadFile(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "DF-1" {
+		t.Fatalf("unexpected range body %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Fatalf("unexpected Content-Range %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected strong ETag and Last-Modified, got %q / %q", etag, w.Header().Get("Last-Modified"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=report.pdf", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.DownloadFile(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+
+	// A stale If-Range validator must return the full file instead of the range.
+	req = httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=report.pdf", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	req.Header.Set("If-Range", `"stale"`)
+	w = httptest.NewRecorder()
+	h.DownloadFile(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "%PDF-12345" {
+		t.Fatalf("expected full 200 response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDownloadFile_InlineActiveContentIsSandboxed(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "logo \"x\".svg"), []byte(svg), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=logo+%22x%22.svg&inline=1", nil)
+	w := httptest.NewRecorder()
+	h.DownloadFile(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `inline; filename="logo \"x\".svg"` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); !strings.Contains(got, "sandbox") {
+		t.Fatalf("expected sandboxing CSP, got %q", got)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Fatalf("expected nosniff, got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=logo+%22x%22.svg", nil)
+	w = httptest.NewRecorder()
+	h.DownloadFile(w, req)
+	if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment;") {
+		t.Fatalf("expected attachment by default, got %q", got)
+	}
+}
+
+func TestDownloadFile_InlineXMLTypesAreSandboxed(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	feed := `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><html:script xmlns:html="http://www.w3.org/1999/xhtml">alert(1)</html:script></feed>`
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "feed.atom"), []byte(feed), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/download-file?workspace=root&path=feed.atom&inline=1", nil)
+	w := httptest.NewRecorder()
+	h.DownloadFile(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Security-Policy"); !strings.Contains(got, "sandbox") {
+		t.Fatalf("expected sandboxing CSP for %q, got %q", w.Header().Get("Content-Type"), got)
+	}
+
+	for contentType, active := range map[string]bool{
+		"application/atom+xml":      true,
+		"application/rss+xml":       true,
+		"application/xslt+xml":      true,
+		"text/xml; charset=utf-8":   true,
+		"text/html; charset=utf-8":  true,
+		"text/plain; charset=utf-8": false,
+		"image/png":                 false,
+	} {
+		if got := isActiveContent(contentType); got != active {
+			t.Fatalf("isActiveContent(%q) = %v, want %v", contentType, got, active)
+		}
+	}
+}
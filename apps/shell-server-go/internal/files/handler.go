@@ -2,6 +2,7 @@ package files
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,28 +14,62 @@ import (
 	"time"
 
 	"shell-server-go/internal/config"
+	"shell-server-go/internal/diskusage"
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/fsutil"
 	httpxmiddleware "shell-server-go/internal/httpx/middleware"
 	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/ignore"
 	"shell-server-go/internal/logger"
+	"shell-server-go/internal/preview"
+	"shell-server-go/internal/quota"
+	"shell-server-go/internal/scan"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/snapshot"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 	workspacepkg "shell-server-go/internal/workspace"
 )
 
 var filesLog = logger.WithComponent("FILES")
 
+// MaxPreviewSize is the maximum file size for text preview (1MB).
+const MaxPreviewSize = 1024 * 1024
+
 // Handler handles file operations.
 type Handler struct {
-	config   *config.AppConfig
-	sessions *session.Store
-	resolver *workspacepkg.Resolver
+	config    *config.AppConfig
+	sessions  *session.Store
+	resolver  *workspacepkg.Resolver
+	uploads   *resumableStore
+	trash     *trash.Store
+	quotas    *quota.Manager
+	watches   *watch.Manager
+	scanner   scan.Scanner // nil when upload scanning is off
+	thumbs    *thumbnail.Cache
+	snapshots *snapshot.Manager
+	usage     *diskusage.Analyzer
 }
 
-// NewHandler creates a new file handler.
-func NewHandler(cfg *config.AppConfig, sessions *session.Store) *Handler {
+// NewHandler creates a new file handler. trashStore, quotas, watches and
+// thumbs are shared with the editor so both see the same trash, site usage,
+// watch limits and thumbnail cache. scanner checks uploads and may be nil to
+// skip scanning. Resumable uploads are kept in uploadDir, which like the
+// snapshot stores must be server state outside every workspace.
+func NewHandler(cfg *config.AppConfig, sessions *session.Store, trashStore *trash.Store, quotas *quota.Manager, watches *watch.Manager, thumbs *thumbnail.Cache, scanner scan.Scanner, snapshots *snapshot.Manager, uploadDir string) *Handler {
 	return &Handler{
-		config:   cfg,
-		sessions: sessions,
-		resolver: workspacepkg.NewResolver(cfg),
+		config:    cfg,
+		sessions:  sessions,
+		resolver:  workspacepkg.NewResolver(cfg),
+		uploads:   newResumableStore(uploadDir),
+		trash:     trashStore,
+		quotas:    quotas,
+		watches:   watches,
+		scanner:   scanner,
+		thumbs:    thumbs,
+		snapshots: snapshots,
+		usage:     diskusage.NewAnalyzer(diskusage.DefaultWorkers, diskusage.DefaultMaxAge),
 	}
 }
 
@@ -99,7 +134,7 @@ func (h *Handler) CreateDirectory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, resolvedTarget, err := h.resolver.ResolveForWorkspace(workspaceID, targetDir)
+	basePath, resolvedTarget, err := h.resolver.ResolveForWorkspace(workspaceID, targetDir)
 	if err != nil {
 		h.handlePathError(w, err)
 		return
@@ -119,11 +154,18 @@ func (h *Handler) CreateDirectory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.MkdirAll(resolvedTarget, 0755); err != nil {
+	reservation, err := h.quotas.Reserve(resolvedTarget, 0, 1)
+	if err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+	if err := h.ownerFor(basePath).MkdirAll(resolvedTarget); err != nil {
+		reservation.Release()
 		filesLog.Error("Failed to create directory %s: %v", resolvedTarget, err)
 		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
+	reservation.Commit()
 
 	filesLog.Info("Created directory: %s", resolvedTarget)
 	response.JSON(w, http.StatusOK, map[string]any{
@@ -146,6 +188,11 @@ func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
 		targetDir = "./"
 	}
 	customName := r.FormValue("name")
+	opts, err := uploadOptionsFromForm(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -155,7 +202,6 @@ func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 
 	originalFilename := header.Filename
-	isZipFile := strings.HasSuffix(strings.ToLower(originalFilename), ".zip")
 
 	basePath, resolvedTarget, err := h.resolver.ResolveForWorkspace(workspaceID, targetDir)
 	if err != nil {
@@ -179,15 +225,40 @@ func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	tempFile.Close()
 
-	if !isZipFile {
-		h.handleRegularUpload(w, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName)
-		return
+	h.handleUploadedFile(r.Context(), w, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName, opts)
+}
+
+// handleUploadedFile scans the upload, then extracts ZIP and tar-family archives
+// and stores anything else as-is. Everything written belongs to the workspace owner.
+func (h *Handler) handleUploadedFile(ctx context.Context, w http.ResponseWriter, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName string, opts uploadOptions) {
+	if h.scanner != nil && !opts.dryRun {
+		tempInfo, err := os.Stat(tempPath)
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "Failed to read upload")
+			return
+		}
+		name := originalFilename
+		if customName != "" {
+			name = customName
+		}
+		findings, err := h.scanTarget(ctx, scan.FileTarget(name, tempPath, tempInfo.Size()))
+		if !h.checkFindings(w, findings, err, &opts) {
+			return
+		}
 	}
 
-	h.handleZipUpload(w, resolvedTarget, targetDir, tempPath)
+	owner := h.ownerFor(basePath)
+	switch kind := uploadArchiveKind(originalFilename); kind {
+	case archiveKindZip:
+		h.handleZipUpload(ctx, w, resolvedTarget, targetDir, tempPath, opts, owner)
+	case archiveKindTar, archiveKindTarGz, archiveKindTarZs:
+		h.handleTarUpload(ctx, w, resolvedTarget, targetDir, tempPath, kind, opts, owner)
+	default:
+		h.handleRegularUpload(w, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName, opts, owner)
+	}
 }
 
-func (h *Handler) handleRegularUpload(w http.ResponseWriter, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName string) {
+func (h *Handler) handleRegularUpload(w http.ResponseWriter, basePath, resolvedTarget, targetDir, tempPath, originalFilename, customName string, opts uploadOptions, owner *fsutil.Owner) {
 	destFilename := originalFilename
 	if customName != "" {
 		destFilename = customName
@@ -198,42 +269,78 @@ func (h *Handler) handleRegularUpload(w http.ResponseWriter, basePath, resolvedT
 		return
 	}
 
-	destPath, err := h.resolver.ResolveSafePath(basePath, filepath.Join(targetDir, destFilename))
-	if err != nil {
+	if _, err := h.resolver.ResolveSafePath(basePath, filepath.Join(targetDir, destFilename)); err != nil {
 		h.handlePathError(w, err)
 		return
 	}
 
-	if _, err := os.Stat(destPath); err == nil {
-		response.JSON(w, http.StatusConflict, map[string]any{
-			"error":         "File already exists",
-			"existingItems": []string{destFilename},
-			"targetDir":     resolvedTarget,
-			"hint":          "Delete existing file first or use a different name",
+	tempInfo, err := os.Stat(tempPath)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	plan := planExtraction(resolvedTarget, []planEntry{{name: destFilename, size: tempInfo.Size()}}, opts.conflict)
+	plan.owner = owner
+	if opts.dryRun {
+		plan.writeDryRun(w, targetDir)
+		return
+	}
+	if len(plan.existing) > 0 {
+		plan.writeConflict(w, "File already exists", "Delete existing file first, use a different name or choose a conflict mode")
+		return
+	}
+
+	destPath, ok := plan.destination(destFilename)
+	if !ok {
+		response.JSON(w, http.StatusOK, map[string]any{
+			"success":     true,
+			"message":     fmt.Sprintf("Skipped %s, it already exists in %s", destFilename, targetDir),
+			"extractedTo": resolvedTarget,
+			"fileCount":   0,
+			"filename":    destFilename,
+			"summary":     plan.summary(),
 		})
 		return
 	}
+	destFilename = filepath.Base(destPath)
 
-	if err := os.MkdirAll(resolvedTarget, 0755); err != nil {
+	reservation, ok := h.reserveQuota(w, plan)
+	if !ok {
+		return
+	}
+	if err := owner.MkdirAll(resolvedTarget); err != nil {
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
+	if err := plan.setAside(); err != nil {
+		reservation.Release()
+		filesLog.Error("Failed to set aside %s: %v", destPath, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to replace existing file")
+		return
+	}
 
-	if err := copyFile(tempPath, destPath); err != nil {
+	if err := copyFile(tempPath, destPath, owner); err != nil {
+		plan.rollback()
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to save file")
 		return
 	}
+	plan.commit()
+	h.commitQuota(reservation, plan)
 
-	response.JSON(w, http.StatusOK, map[string]any{
+	response.JSON(w, http.StatusOK, withFindings(map[string]any{
 		"success":     true,
 		"message":     fmt.Sprintf("Uploaded %s to %s", destFilename, targetDir),
 		"extractedTo": resolvedTarget,
 		"fileCount":   1,
 		"filename":    destFilename,
-	})
+		"summary":     plan.summary(),
+	}, opts))
 }
 
-func (h *Handler) handleZipUpload(w http.ResponseWriter, resolvedTarget, targetDir, tempPath string) {
+func (h *Handler) handleZipUpload(ctx context.Context, w http.ResponseWriter, resolvedTarget, targetDir, tempPath string, opts uploadOptions, owner *fsutil.Owner) {
 	zipReader, err := zip.OpenReader(tempPath)
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid ZIP file")
@@ -248,7 +355,7 @@ func (h *Handler) handleZipUpload(w http.ResponseWriter, resolvedTarget, targetD
 	}
 
 	var totalUncompressedSize uint64
-	rootItems := make(map[string]struct{})
+	entries := make([]planEntry, 0, len(zipReader.File))
 	for _, f := range zipReader.File {
 		if _, err := h.resolver.ResolveSafePath(resolvedTarget, f.Name); err != nil {
 			filesLog.Warn("ZIP rejected: path security issue in archive: %s: %v", f.Name, err)
@@ -277,78 +384,110 @@ func (h *Handler) handleZipUpload(w http.ResponseWriter, resolvedTarget, targetD
 			return
 		}
 
-		parts := strings.Split(f.Name, "/")
-		if len(parts) > 0 && parts[0] != "" {
-			rootItems[parts[0]] = struct{}{}
-		}
+		entries = append(entries, planEntry{name: f.Name, isDir: f.FileInfo().IsDir(), size: int64(f.UncompressedSize64)})
 	}
 
-	var existingItems []string
-	for item := range rootItems {
-		itemPath := filepath.Join(resolvedTarget, item)
-		if _, err := os.Stat(itemPath); err == nil {
-			existingItems = append(existingItems, item)
-		}
+	plan := planExtraction(resolvedTarget, entries, opts.conflict)
+	plan.owner = owner
+	if opts.dryRun {
+		plan.writeDryRun(w, targetDir)
+		return
 	}
-	if len(existingItems) > 0 {
-		response.JSON(w, http.StatusConflict, map[string]any{
-			"error":         "Cannot extract - items already exist in target",
-			"existingItems": existingItems,
-			"targetDir":     resolvedTarget,
-			"hint":          "Delete existing items first or remove them from ZIP",
-		})
+	if len(plan.existing) > 0 {
+		plan.writeConflict(w, "Cannot extract - items already exist in target", "Delete existing items first, remove them from ZIP or choose a conflict mode")
 		return
 	}
 
-	if err := os.MkdirAll(resolvedTarget, 0755); err != nil {
+	if h.scanner != nil {
+		var findings []scan.Finding
+		var scanErr error
+		for _, f := range zipReader.File {
+			if _, ok := plan.destination(f.Name); !ok || f.FileInfo().IsDir() {
+				continue
+			}
+			found, err := h.scanTarget(ctx, scan.Target{Name: f.Name, Size: int64(f.UncompressedSize64), Open: f.Open})
+			findings = append(findings, found...)
+			if err != nil {
+				scanErr = err
+				break
+			}
+		}
+		if !h.checkFindings(w, findings, scanErr, &opts) {
+			return
+		}
+	}
+
+	reservation, ok := h.reserveQuota(w, plan)
+	if !ok {
+		return
+	}
+	if err := owner.MkdirAll(resolvedTarget); err != nil {
+		reservation.Release()
 		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
+	if err := plan.setAside(); err != nil {
+		reservation.Release()
+		filesLog.Error("Failed to set aside replaced items in %s: %v", resolvedTarget, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to replace existing items")
+		return
+	}
 
+	fileCount, err := extractZip(zipReader, plan)
+	if err != nil {
+		filesLog.Error("ZIP extraction into %s failed: %v", resolvedTarget, err)
+		plan.rollback()
+		// Whatever was extracted before the failure stays; recount it.
+		reservation.Release()
+		h.quotas.Invalidate(resolvedTarget)
+		response.Error(w, http.StatusInternalServerError, "Failed to extract file")
+		return
+	}
+	plan.commit()
+	h.commitQuota(reservation, plan)
+
+	response.JSON(w, http.StatusOK, withFindings(map[string]any{
+		"success":     true,
+		"message":     fmt.Sprintf("Extracted %d files to %s", fileCount, targetDir),
+		"extractedTo": resolvedTarget,
+		"fileCount":   fileCount,
+		"summary":     plan.summary(),
+	}, opts))
+}
+
+// extractZip writes every entry the plan does not skip.
+func extractZip(zipReader *zip.ReadCloser, plan *extractionPlan) (int, error) {
+	fileCount := 0
 	for _, f := range zipReader.File {
-		destPath := filepath.Join(resolvedTarget, f.Name)
+		destPath, ok := plan.destination(f.Name)
+		if !ok {
+			continue
+		}
 
 		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(destPath, 0755); err != nil {
-				response.Error(w, http.StatusInternalServerError, "Failed to create directory")
-				return
+			if err := plan.owner.MkdirAll(destPath); err != nil {
+				return fileCount, err
 			}
+			fileCount++
 			continue
 		}
 
-		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-			response.Error(w, http.StatusInternalServerError, "Failed to create directory")
-			return
+		if err := plan.owner.MkdirAll(filepath.Dir(destPath)); err != nil {
+			return fileCount, err
 		}
 
 		srcFile, err := f.Open()
 		if err != nil {
-			response.Error(w, http.StatusInternalServerError, "Failed to extract file")
-			return
-		}
-
-		destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			srcFile.Close()
-			response.Error(w, http.StatusInternalServerError, "Failed to write file")
-			return
+			return fileCount, err
 		}
-
-		_, err = io.Copy(destFile, srcFile)
+		err = plan.owner.CreateFile(destPath, srcFile, f.Mode())
 		srcFile.Close()
-		destFile.Close()
 		if err != nil {
-			response.Error(w, http.StatusInternalServerError, "Failed to extract file")
-			return
+			return fileCount, err
 		}
+		fileCount++
 	}
-
-	response.JSON(w, http.StatusOK, map[string]any{
-		"success":     true,
-		"message":     fmt.Sprintf("Extracted %d files to %s", len(zipReader.File), targetDir),
-		"extractedTo": resolvedTarget,
-		"fileCount":   len(zipReader.File),
-	})
+	return fileCount, nil
 }
 
 // ListFiles handles POST /api/list-files.
@@ -378,14 +517,18 @@ func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tree := buildTree(basePath, "", 0, 4, workspacepkg.DefaultExcludedDirs)
+	var rules *ignore.Matcher
+	if !isShowIgnored(r.FormValue) {
+		rules = ignore.New(basePath)
+	}
+	tree := buildTree(basePath, "", 0, 4, rules)
 	response.JSON(w, http.StatusOK, map[string]any{
 		"path": basePath,
 		"tree": tree,
 	})
 }
 
-func buildTree(dirPath, relativePath string, depth, maxDepth int, excludeDirs map[string]bool) []TreeNode {
+func buildTree(dirPath, relativePath string, depth, maxDepth int, rules *ignore.Matcher) []TreeNode {
 	if depth >= maxDepth {
 		return nil
 	}
@@ -397,7 +540,7 @@ func buildTree(dirPath, relativePath string, depth, maxDepth int, excludeDirs ma
 
 	var filtered []os.DirEntry
 	for _, e := range entries {
-		if !excludeDirs[e.Name()] {
+		if !rules.Ignored(filepath.Join(dirPath, e.Name()), e.IsDir()) {
 			filtered = append(filtered, e)
 		}
 	}
@@ -428,7 +571,7 @@ func buildTree(dirPath, relativePath string, depth, maxDepth int, excludeDirs ma
 			if depth < 2 {
 				node.State = &NodeState{Opened: true}
 			}
-			node.Children = buildTree(subPath, entryRelPath, depth+1, maxDepth, excludeDirs)
+			node.Children = buildTree(subPath, entryRelPath, depth+1, maxDepth, rules)
 			nodes = append(nodes, node)
 			continue
 		}
@@ -443,10 +586,13 @@ func buildTree(dirPath, relativePath string, depth, maxDepth int, excludeDirs ma
 	return nodes
 }
 
-// DownloadFile handles GET /api/download-file?workspace=X&path=Y.
+// DownloadFile handles GET /api/download-file?workspace=X&path=Y[&inline=1].
+// Range, If-Range, If-None-Match and If-Modified-Since are honoured, so downloads
+// can be resumed and media can be seeked.
 func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
 	filePath := r.URL.Query().Get("path")
+	inline := r.URL.Query().Get("inline") == "1" || r.URL.Query().Get("inline") == "true"
 
 	if workspaceID == "" {
 		response.Error(w, http.StatusBadRequest, "No workspace provided")
@@ -464,11 +610,19 @@ func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	info, err := os.Stat(resolvedPath)
+	file, err := os.Open(resolvedPath)
 	if os.IsNotExist(err) {
 		response.Error(w, http.StatusNotFound, "File not found")
 		return
 	}
+	if err != nil {
+		filesLog.Error("Failed to open file for download %s: %v", resolvedPath, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to open file")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to access file")
 		return
@@ -478,25 +632,29 @@ func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, err := os.Open(resolvedPath)
-	if err != nil {
-		filesLog.Error("Failed to open file for download %s: %v", resolvedPath, err)
-		response.Error(w, http.StatusInternalServerError, "Failed to open file")
-		return
-	}
-	defer file.Close()
-
 	filename := filepath.Base(filePath)
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-
-	if _, err := io.Copy(w, file); err != nil {
-		filesLog.Error("Failed to stream file %s: %v", resolvedPath, err)
+	contentType := detectContentType(file, filename)
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("ETag", strongETag(info))
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("X-Content-Type-Options", "nosniff")
+	if inline {
+		header.Set("Content-Disposition", contentDisposition("inline", filename))
+		if isActiveContent(contentType) {
+			header.Set("Content-Security-Policy", activeContentCSP)
+		}
+	} else {
+		header.Set("Content-Disposition", contentDisposition("attachment", filename))
 	}
+
+	http.ServeContent(w, r, filename, info.ModTime(), file)
 }
 
-// ReadFile handles POST /api/read-file.
+// ReadFile handles POST /api/read-file. mode selects the preview: text (the
+// default) returns the content, markdown, table and json return it rendered
+// (see preview.go), and hex dumps a byte range of any file.
 func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 	if !httpxmiddleware.ParseFormRequest(w, r) {
 		return
@@ -508,6 +666,14 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "No file path provided")
 		return
 	}
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = preview.ModeText
+	}
+	if !preview.ValidMode(mode) {
+		response.Error(w, http.StatusBadRequest, "Invalid preview mode")
+		return
+	}
 
 	_, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, filePath)
 	if err != nil {
@@ -525,6 +691,14 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusInternalServerError, "Failed to access file")
 		return
 	}
+	if info.IsDir() {
+		response.Error(w, http.StatusBadRequest, "Path is a directory")
+		return
+	}
+	if mode == preview.ModeHex {
+		h.readHex(w, r, resolvedPath, info)
+		return
+	}
 
 	if info.Size() > MaxPreviewSize {
 		response.JSON(w, http.StatusRequestEntityTooLarge, map[string]any{
@@ -534,31 +708,42 @@ func (h *Handler) ReadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if IsBinaryFile(filePath) {
+	content, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+
+	fileType := filetype.Detect(filePath, content)
+	if !fileType.Text {
 		response.JSON(w, http.StatusUnsupportedMediaType, map[string]any{
 			"error":     "Binary file cannot be previewed",
 			"binary":    true,
 			"extension": strings.ToLower(filepath.Ext(filePath)),
+			"mimeType":  fileType.MIMEType,
+			"kind":      fileType.Kind,
 		})
 		return
 	}
 
-	content, err := os.ReadFile(resolvedPath)
-	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to read file")
-		return
-	}
-
 	filename := filepath.Base(filePath)
-	response.JSON(w, http.StatusOK, map[string]any{
-		"content":  string(content),
+	body := map[string]any{
 		"path":     resolvedPath,
 		"filename": filename,
 		"size":     info.Size(),
-	})
+		"mimeType": fileType.MIMEType,
+		"kind":     fileType.Kind,
+	}
+	if mode != preview.ModeText {
+		h.writePreview(w, r, mode, filename, content, body)
+		return
+	}
+	body["content"] = string(content)
+	response.JSON(w, http.StatusOK, body)
 }
 
-// DeleteFolder handles POST /api/delete-folder.
+// DeleteFolder handles POST /api/delete-folder. Items are moved to the workspace
+// trash unless permanent=true is given.
 func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 	if !httpxmiddleware.ParseFormRequest(w, r) {
 		return
@@ -570,8 +755,9 @@ func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "No folder path provided")
 		return
 	}
+	permanent := r.FormValue("permanent") == "true" || r.FormValue("permanent") == "1"
 
-	_, resolvedPath, err := h.resolver.ValidateForDeletion(workspaceID, folderPath)
+	basePath, resolvedPath, err := h.resolver.ValidateForDeletion(workspaceID, folderPath)
 	if err != nil {
 		h.handlePathError(w, err)
 		return
@@ -592,16 +778,50 @@ func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		typeStr = "directory"
 	}
 
-	if err := os.RemoveAll(resolvedPath); err != nil {
+	if permanent {
+		freedBytes, freedInodes := h.siteUsageOf(resolvedPath)
+		if err := os.RemoveAll(resolvedPath); err != nil {
+			h.quotas.Invalidate(resolvedPath)
+			response.Error(w, http.StatusInternalServerError, "Failed to delete")
+			return
+		}
+		h.quotas.Add(resolvedPath, -freedBytes, -freedInodes)
+		response.JSON(w, http.StatusOK, map[string]any{
+			"success":     true,
+			"message":     fmt.Sprintf("Deleted %s: %s", typeStr, folderPath),
+			"deletedPath": resolvedPath,
+			"type":        typeStr,
+		})
+		return
+	}
+
+	// Trash the entry itself, so deleting a symlink does not trash its target.
+	entryPath, err := h.resolveEntryPath(workspaceID, folderPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if realBase, err := filepath.EvalSymlinks(basePath); err == nil {
+		basePath = realBase
+	}
+	var freedBytes, freedInodes int64
+	if h.trashLeavesSite(workspaceID, entryPath) {
+		freedBytes, freedInodes = h.siteUsageOf(entryPath)
+	}
+	item, err := h.trash.Trash(workspaceID, basePath, entryPath, httpxmiddleware.GetSessionToken(r))
+	if err != nil {
+		filesLog.Error("Failed to move %s to trash: %v", entryPath, err)
 		response.Error(w, http.StatusInternalServerError, "Failed to delete")
 		return
 	}
+	h.quotas.Add(entryPath, -freedBytes, -freedInodes)
 
 	response.JSON(w, http.StatusOK, map[string]any{
 		"success":     true,
-		"message":     fmt.Sprintf("Deleted %s: %s", typeStr, folderPath),
+		"message":     fmt.Sprintf("Moved %s to trash: %s", typeStr, folderPath),
 		"deletedPath": resolvedPath,
 		"type":        typeStr,
+		"trashId":     item.ID,
 	})
 }
 
@@ -615,13 +835,18 @@ func (h *Handler) ListSites(w http.ResponseWriter, r *http.Request) {
 			userDir := filepath.Join(sitesPath, site, "user")
 
 			sites := make([]string, 0, 1)
+			usage := make(map[string]quota.Usage, 1)
 			if info, err := os.Stat(userDir); err == nil && info.IsDir() {
 				sites = append(sites, site)
+				if siteUsage, err := h.quotas.Usage(site); err == nil {
+					usage[site] = siteUsage
+				}
 			}
 
 			response.JSON(w, http.StatusOK, map[string]any{
 				"sites":     sites,
 				"sitesPath": "",
+				"usage":     usage,
 			})
 			return
 		}
@@ -640,11 +865,17 @@ func (h *Handler) ListSites(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var sites []string
+	// Listing stays cheap: usage comes from the cache the background scan keeps
+	// warm, and sites not scanned yet are left out.
+	usage := make(map[string]quota.Usage)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			userDir := filepath.Join(sitesPath, entry.Name(), "user")
 			if _, err := os.Stat(userDir); err == nil {
 				sites = append(sites, entry.Name())
+				if siteUsage, ok := h.quotas.Cached(entry.Name()); ok {
+					usage[entry.Name()] = siteUsage
+				}
 			}
 		}
 	}
@@ -653,6 +884,7 @@ func (h *Handler) ListSites(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusOK, map[string]any{
 		"sites":     sites,
 		"sitesPath": sitesPath,
+		"usage":     usage,
 	})
 }
 
@@ -692,6 +924,9 @@ type ConfigResponse struct {
 	DefaultWorkspace        string      `json:"defaultWorkspace"`
 	AllowWorkspaceSelection bool        `json:"allowWorkspaceSelection"`
 	EditableDirectories     []DirConfig `json:"editableDirectories"`
+	// SiteQuota is the default site quota; Quota is the usage of a scoped site session.
+	SiteQuota *config.QuotaLimit `json:"siteQuota,omitempty"`
+	Quota     *quota.Usage       `json:"quota,omitempty"`
 }
 
 // DirConfig represents a directory config for editable directories.
@@ -716,6 +951,8 @@ func (h *Handler) Config(w http.ResponseWriter, r *http.Request) {
 	uploadPath := h.config.ResolvedUploadCwd
 	sitesPath := h.config.ResolvedSitesPath
 	workspaceBase := h.config.WorkspaceBase
+	siteQuota := &h.config.SiteQuota
+	var siteUsage *quota.Usage
 	if scopedWorkspace := workspacepkg.SessionWorkspace(r, h.sessions); scopedWorkspace != "" {
 		defaultWorkspace = scopedWorkspace
 		allowWorkspaceSelection = false
@@ -724,6 +961,14 @@ func (h *Handler) Config(w http.ResponseWriter, r *http.Request) {
 		uploadPath = ""
 		sitesPath = ""
 		workspaceBase = ""
+		siteQuota = nil
+		if base, err := h.resolver.ResolveWorkspaceBase(scopedWorkspace); err == nil {
+			if site := h.quotas.SiteFor(base); site != "" {
+				if usage, err := h.quotas.Usage(site); err == nil {
+					siteUsage = &usage
+				}
+			}
+		}
 	}
 
 	cfg := ConfigResponse{
@@ -734,28 +979,43 @@ func (h *Handler) Config(w http.ResponseWriter, r *http.Request) {
 		DefaultWorkspace:        defaultWorkspace,
 		AllowWorkspaceSelection: allowWorkspaceSelection,
 		EditableDirectories:     configDirs,
+		SiteQuota:               siteQuota,
+		Quota:                   siteUsage,
 	}
 
 	response.JSON(w, http.StatusOK, cfg)
 }
 
+// ownerFor returns the owner of entries created in the workspace at base. If
+// the base cannot be inspected, only the umask is applied.
+func (h *Handler) ownerFor(base string) *fsutil.Owner {
+	owner, err := workspacepkg.ResolveWorkspaceOwner(base, h.config.Umask)
+	if err != nil {
+		filesLog.Warn("Cannot resolve owner of %s: %v", base, err)
+		return &fsutil.Owner{Umask: h.config.Umask}
+	}
+	return owner
+}
+
+// workspaceOwner is ownerFor the base of a workspace ID.
+func (h *Handler) workspaceOwner(workspaceID string) *fsutil.Owner {
+	base, err := h.resolver.ResolveWorkspaceBase(workspaceID)
+	if err != nil {
+		return &fsutil.Owner{Umask: h.config.Umask}
+	}
+	return h.ownerFor(base)
+}
+
 func (h *Handler) handlePathError(w http.ResponseWriter, err error) {
 	workspacepkg.HandlePathSecurityError(w, err)
 }
 
-func copyFile(src, dst string) error {
+func copyFile(src, dst string, owner *fsutil.Owner) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
-
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	return owner.CreateFile(dst, srcFile, fsutil.FilePerm)
 }
@@ -15,7 +15,12 @@ import (
 
 	"shell-server-go/internal/config"
 	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/snapshot"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 )
 
 func TestHandler_ReadFileBlocksTraversal(t *testing.T) {
@@ -102,6 +107,7 @@ func setupFilesHandler(t *testing.T) (*Handler, *session.Store) {
 		ResolvedDefaultCwd:      workspaceDir,
 		ResolvedUploadCwd:       uploadsDir,
 		ResolvedSitesPath:       sitesDir,
+		ResolvedTrashPath:       filepath.Join(tmp, ".trash"),
 		WorkspaceBase:           tmp,
 		AllowWorkspaceSelection: true,
 		EditableDirectories:     []config.EditableDirectory{},
@@ -109,7 +115,8 @@ func setupFilesHandler(t *testing.T) (*Handler, *session.Store) {
 	}
 
 	sessions := session.NewStore(filepath.Join(tmp, ".sessions.json"))
-	return NewHandler(cfg, sessions), sessions
+	thumbs := thumbnail.NewCache(filepath.Join(tmp, ".thumbnails"), thumbnail.DefaultCacheBytes)
+	return NewHandler(cfg, sessions, trash.NewStore(cfg), quota.NewManager(cfg), watch.NewManager(), thumbs, nil, snapshot.NewManager(filepath.Join(tmp, ".snapshots")), filepath.Join(tmp, ".uploads")), sessions
 }
 
 func TestHandler_ListSitesScopedSessionHidesPath(t *testing.T) {
@@ -145,3 +152,43 @@ func TestHandler_ListSitesScopedSessionHidesPath(t *testing.T) {
 		t.Fatalf("expected sitesPath hidden for scoped session, got %q", payload.SitesPath)
 	}
 }
+
+func TestHandler_ReadFileSniffsContent(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+
+	files := map[string]string{
+		"server":   "\x7fELF\x02\x01\x01\x00\x00\x00",
+		"logo.svg": `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	cases := []struct {
+		path     string
+		code     int
+		mimeType string
+	}{
+		{"server", http.StatusUnsupportedMediaType, "application/x-executable"},
+		{"logo.svg", http.StatusOK, "image/svg+xml"},
+	}
+	for _, tc := range cases {
+		form := url.Values{"workspace": {"root"}, "path": {tc.path}}
+		req := httptest.NewRequest(http.MethodPost, "/api/read-file", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ReadFile(w, req)
+
+		var payload struct {
+			MimeType string `json:"mimeType"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &payload)
+		if w.Code != tc.code || payload.MimeType != tc.mimeType {
+			t.Errorf("%s: got %d %q, want %d %q", tc.path, w.Code, payload.MimeType, tc.code, tc.mimeType)
+		}
+	}
+}
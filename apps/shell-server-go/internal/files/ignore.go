@@ -0,0 +1,41 @@
+package files
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"shell-server-go/internal/ignore"
+)
+
+// isShowIgnored reads the toggle that disables ignore rules. includeExcluded
+// is its older name.
+func isShowIgnored(values func(string) string) bool {
+	for _, key := range []string{"showIgnored", "includeExcluded"} {
+		if v := values(key); v == "1" || v == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// queryIgnore builds the ignore rules for a request below base: the defaults
+// and the workspace's ignore files unless showIgnored=1, plus the
+// comma-separated patterns of exclude. Rules are relative to the real base,
+// where resolved paths live.
+func queryIgnore(r *http.Request, base string) *ignore.Matcher {
+	if realBase, err := filepath.EvalSymlinks(base); err == nil {
+		base = realBase
+	}
+	query := r.URL.Query()
+	var extra []string
+	for _, pattern := range strings.Split(query.Get("exclude"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			extra = append(extra, pattern)
+		}
+	}
+	if isShowIgnored(query.Get) {
+		return ignore.Only(base, extra...)
+	}
+	return ignore.New(base, extra...)
+}
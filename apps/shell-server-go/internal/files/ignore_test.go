@@ -0,0 +1,71 @@
+package files
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRules_ApplyToListingAndSearch(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	root := h.config.ResolvedUploadCwd
+	files := map[string]string{
+		".gitignore":          "build/\n*.log\n",
+		".aliveignore":        "coverage/\n",
+		"app/.gitignore":      "!keep.log\n/.next\n",
+		"app/keep.log":        "match",
+		"app/debug.log":       "match",
+		"app/.next/page.js":   "match",
+		"app/main.js":         "match",
+		"build/out.js":        "match",
+		"coverage/index.html": "match",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	names := func(entries []DirEntry) string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Name)
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+	code, payload := listDirectory(t, h, url.Values{"workspace": {"root"}})
+	if code != http.StatusOK || names(payload.Entries) != ".aliveignore,.gitignore,app" {
+		t.Fatalf("root listing: %d %s", code, names(payload.Entries))
+	}
+	code, payload = listDirectory(t, h, url.Values{"workspace": {"root"}, "path": {"app"}})
+	if code != http.StatusOK || names(payload.Entries) != ".gitignore,keep.log,main.js" {
+		t.Fatalf("app listing: %d %s", code, names(payload.Entries))
+	}
+	code, payload = listDirectory(t, h, url.Values{"workspace": {"root"}, "showIgnored": {"1"}})
+	if code != http.StatusOK || names(payload.Entries) != ".aliveignore,.gitignore,app,build,coverage" {
+		t.Fatalf("showIgnored listing: %d %s", code, names(payload.Entries))
+	}
+
+	_, results := runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"match"}, "mode": {"content"}})
+	var paths []string
+	for _, r := range results {
+		if r.Type == "match" {
+			paths = append(paths, r.Path)
+		}
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, ",") != "app/keep.log,app/main.js" {
+		t.Fatalf("search matched %v", paths)
+	}
+}
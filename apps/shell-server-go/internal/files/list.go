@@ -0,0 +1,283 @@
+package files
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/ignore"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+const (
+	// DefaultListLimit is the page size when the client does not ask for one.
+	DefaultListLimit = 200
+	// MaxListLimit caps the page size of a directory listing.
+	MaxListLimit = 1000
+)
+
+// Sort keys accepted by ListDirectory.
+const (
+	ListSortName  = "name"
+	ListSortMtime = "mtime"
+	ListSortSize  = "size"
+)
+
+// DirEntry describes one entry of a directory listing.
+type DirEntry struct {
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Type        string    `json:"type"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+	Mode        string    `json:"mode"`
+	Symlink     bool      `json:"symlink,omitempty"`
+	HasChildren bool      `json:"hasChildren,omitempty"`
+	Binary      bool      `json:"binary,omitempty"`
+	MimeType    string    `json:"mimeType,omitempty"`
+}
+
+// listCursor is the keyset position after the last entry of a page. It is
+// tied to the sort it was issued for.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Dir   bool   `json:"dir"`
+	Size  int64  `json:"sz,omitempty"`
+	Mtime int64  `json:"mt,omitempty"`
+	Name  string `json:"n"`
+}
+
+func encodeListCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(value string) (listCursor, bool) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return c, false
+	}
+	return c, true
+}
+
+func cursorFor(e DirEntry, sortKey string, desc bool) listCursor {
+	return listCursor{
+		Sort:  sortKey,
+		Desc:  desc,
+		Dir:   e.Type == "directory",
+		Size:  e.Size,
+		Mtime: e.Modified.UnixNano(),
+		Name:  e.Name,
+	}
+}
+
+// compareListKeys orders directories before files, then by the sort key, then by
+// name so that every position is unique. desc reverses only the sort key.
+func compareListKeys(a, b listCursor, sortKey string, desc bool) int {
+	if a.Dir != b.Dir {
+		if a.Dir {
+			return -1
+		}
+		return 1
+	}
+
+	order := 0
+	switch sortKey {
+	case ListSortMtime:
+		order = cmp.Compare(a.Mtime, b.Mtime)
+	case ListSortSize:
+		order = cmp.Compare(a.Size, b.Size)
+	}
+	if order == 0 {
+		order = cmp.Compare(a.Name, b.Name)
+	}
+	if desc {
+		order = -order
+	}
+	return order
+}
+
+// dirHasEntries reports whether a directory has at least one entry that is not ignored.
+func dirHasEntries(path string, rules *ignore.Matcher) bool {
+	dir, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+
+	for {
+		entries, err := dir.ReadDir(64)
+		for _, e := range entries {
+			if !rules.Ignored(filepath.Join(path, e.Name()), e.IsDir()) {
+				return true
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// readDirEntries lists one directory level with metadata. Symlinks report the type
+// of their target but keep Symlink set so the client can tell them apart.
+func readDirEntries(dirPath, relDir string, rules *ignore.Matcher) ([]DirEntry, error) {
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirEntry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		fullPath := filepath.Join(dirPath, d.Name())
+		if rules.Ignored(fullPath, d.IsDir()) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+
+		entry := DirEntry{
+			Name:     d.Name(),
+			Path:     filepath.ToSlash(filepath.Join(relDir, d.Name())),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			Mode:     info.Mode().String(),
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			entry.Symlink = true
+			if target, err := os.Stat(fullPath); err == nil {
+				info = target
+			}
+		}
+
+		if info.IsDir() {
+			entry.Type = "directory"
+			entry.Size = 0
+			entry.HasChildren = dirHasEntries(fullPath, rules)
+		} else {
+			entry.Type = "file"
+			fileType := filetype.ByName(d.Name())
+			entry.Binary = !fileType.Text
+			entry.MimeType = fileType.MIMEType
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ListDirectory handles GET /api/files/list?workspace=X&path=Y&sort=name|mtime|size&order=asc|desc&limit=N&cursor=C.
+// It returns a single directory level so clients can expand the tree on demand.
+func (h *Handler) ListDirectory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	dirPath := query.Get("path")
+	if dirPath == "" {
+		dirPath = "./"
+	}
+
+	sortKey := query.Get("sort")
+	if sortKey == "" {
+		sortKey = ListSortName
+	}
+	if sortKey != ListSortName && sortKey != ListSortMtime && sortKey != ListSortSize {
+		response.Error(w, http.StatusBadRequest, "Invalid sort (expected name, mtime or size)")
+		return
+	}
+	order := query.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		response.Error(w, http.StatusBadRequest, "Invalid order (expected asc or desc)")
+		return
+	}
+	desc := order == "desc"
+
+	limit := DefaultListLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			response.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, MaxListLimit)
+	}
+
+	var after *listCursor
+	if raw := query.Get("cursor"); raw != "" {
+		c, ok := decodeListCursor(raw)
+		if !ok || c.Sort != sortKey || c.Desc != desc {
+			response.Error(w, http.StatusBadRequest, "Invalid cursor for this sort order")
+			return
+		}
+		after = &c
+	}
+
+	basePath, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, dirPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if os.IsNotExist(err) {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to access directory")
+		return
+	}
+	if !info.IsDir() {
+		response.Error(w, http.StatusBadRequest, "Path is not a directory")
+		return
+	}
+
+	if realBase, err := filepath.EvalSymlinks(basePath); err == nil {
+		basePath = realBase
+	}
+	relDir, err := filepath.Rel(basePath, resolvedPath)
+	if err != nil || relDir == "." {
+		relDir = ""
+	}
+
+	entries, err := readDirEntries(resolvedPath, relDir, queryIgnore(r, basePath))
+	if err != nil {
+		filesLog.Error("Failed to list directory %s: %v", resolvedPath, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to read directory")
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return compareListKeys(cursorFor(entries[i], sortKey, desc), cursorFor(entries[j], sortKey, desc), sortKey, desc) < 0
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return compareListKeys(cursorFor(entries[i], sortKey, desc), *after, sortKey, desc) > 0
+		})
+	}
+	end := min(start+limit, len(entries))
+	page := entries[start:end]
+
+	nextCursor := ""
+	if end < len(entries) && len(page) > 0 {
+		nextCursor = encodeListCursor(cursorFor(page[len(page)-1], sortKey, desc))
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"path":       filepath.ToSlash(relDir),
+		"entries":    page,
+		"total":      len(entries),
+		"nextCursor": nextCursor,
+	})
+}
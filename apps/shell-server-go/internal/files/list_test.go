@@ -0,0 +1,126 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listPayload struct {
+	Path       string     `json:"path"`
+	Entries    []DirEntry `json:"entries"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"nextCursor"`
+}
+
+func listDirectory(t *testing.T, h *Handler, params url.Values) (int, listPayload) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/files/list?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	h.ListDirectory(w, req)
+
+	var payload listPayload
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, payload
+}
+
+func TestListDirectory_PaginatesWithCursor(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	root := h.config.ResolvedUploadCwd
+	for _, dir := range []string{"src/deep/er", "empty", "node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range []string{"c.txt", "a.txt", "e.png", "b.txt", "d.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", i+1)), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	var names []string
+	params := url.Values{"workspace": {"root"}, "limit": {"3"}}
+	for page := 0; ; page++ {
+		code, payload := listDirectory(t, h, params)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if payload.Total != 7 {
+			t.Fatalf("expected 7 entries without node_modules, got %d", payload.Total)
+		}
+		for _, e := range payload.Entries {
+			names = append(names, e.Name)
+		}
+		if payload.NextCursor == "" {
+			break
+		}
+		if page > 3 {
+			t.Fatalf("pagination did not terminate")
+		}
+		params.Set("cursor", payload.NextCursor)
+	}
+
+	want := "empty,src,a.txt,b.txt,c.txt,d.txt,e.png"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("unexpected order %s, want %s", got, want)
+	}
+
+	code, payload := listDirectory(t, h, url.Values{"workspace": {"root"}, "sort": {"mtime"}, "order": {"desc"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	var got []string
+	for _, e := range payload.Entries {
+		got = append(got, e.Name)
+	}
+	if !strings.HasSuffix(strings.Join(got, ","), "d.txt,b.txt,e.png,a.txt,c.txt") {
+		t.Fatalf("unexpected mtime order %v", got)
+	}
+
+	code, payload = listDirectory(t, h, url.Values{"workspace": {"root"}, "path": {"src"}})
+	if code != http.StatusOK || len(payload.Entries) != 1 {
+		t.Fatalf("unexpected src listing %d %+v", code, payload)
+	}
+	if e := payload.Entries[0]; e.Path != "src/deep" || e.Type != "directory" || !e.HasChildren {
+		t.Fatalf("unexpected entry metadata %+v", e)
+	}
+}
+
+func TestListDirectory_RejectsBadInput(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "file.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	nameCursor := encodeListCursor(listCursor{Sort: ListSortName, Name: "a"})
+	for _, params := range []url.Values{
+		{"workspace": {"root"}, "path": {"../"}},
+		{"workspace": {"root"}, "path": {"file.txt"}},
+		{"workspace": {"root"}, "sort": {"owner"}},
+		{"workspace": {"root"}, "limit": {"0"}},
+		{"workspace": {"root"}, "sort": {"size"}, "cursor": {nameCursor}},
+		{"workspace": {"root"}, "cursor": {"!!"}},
+	} {
+		if code, _ := listDirectory(t, h, params); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", params, code)
+		}
+	}
+}
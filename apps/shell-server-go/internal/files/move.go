@@ -0,0 +1,136 @@
+package files
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shell-server-go/internal/fsutil"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// resolveEntryPath validates userPath like a deletion (inside the workspace, not
+// its root, no symlink escape) and returns the path of the entry itself. Unlike
+// ResolveSafePath, a symlink resolves to the link rather than its target.
+func (h *Handler) resolveEntryPath(workspaceID, userPath string) (string, error) {
+	cleaned := filepath.Clean(userPath)
+	if _, _, err := h.resolver.ValidateForDeletion(workspaceID, cleaned); err != nil {
+		return "", err
+	}
+	_, parent, err := h.resolver.ResolveForWorkspace(workspaceID, filepath.Dir(cleaned))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(cleaned)), nil
+}
+
+// MovePath handles POST /api/move (form: workspace, from, to, overwrite).
+func (h *Handler) MovePath(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspaceID := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	from := r.FormValue("from")
+	to := r.FormValue("to")
+	overwrite := r.FormValue("overwrite") == "true" || r.FormValue("overwrite") == "1"
+	if from == "" || to == "" {
+		response.Error(w, http.StatusBadRequest, "Source and destination paths required")
+		return
+	}
+
+	srcPath, err := h.resolveEntryPath(workspaceID, from)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	dstPath, err := h.resolveEntryPath(workspaceID, to)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	srcInfo, err := os.Lstat(srcPath)
+	if os.IsNotExist(err) {
+		response.Error(w, http.StatusNotFound, "Source not found")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to stat source")
+		return
+	}
+
+	if srcPath == dstPath {
+		response.Error(w, http.StatusBadRequest, "Source and destination are the same")
+		return
+	}
+	if srcInfo.IsDir() && strings.HasPrefix(dstPath, srcPath+string(os.PathSeparator)) {
+		response.Error(w, http.StatusBadRequest, "Cannot move a directory into itself")
+		return
+	}
+	if strings.HasPrefix(srcPath, dstPath+string(os.PathSeparator)) {
+		response.Error(w, http.StatusBadRequest, "Cannot replace a directory that contains the source")
+		return
+	}
+
+	typeStr := "file"
+	if srcInfo.IsDir() {
+		typeStr = "directory"
+	}
+
+	overwritten := false
+	backupPath := ""
+	if _, err := os.Lstat(dstPath); err == nil {
+		if !overwrite {
+			response.JSON(w, http.StatusConflict, map[string]any{
+				"error":         "Destination already exists",
+				"existingItems": []string{to},
+				"hint":          "Pass overwrite=true to replace it",
+			})
+			return
+		}
+		// Keep the old destination until the move succeeds.
+		backupPath = fmt.Sprintf("%s.moving-%d", dstPath, os.Getpid())
+		if err := os.Rename(dstPath, backupPath); err != nil {
+			filesLog.Error("Failed to set aside %s: %v", dstPath, err)
+			response.Error(w, http.StatusInternalServerError, "Failed to replace destination")
+			return
+		}
+		overwritten = true
+	}
+
+	if err := h.workspaceOwner(workspaceID).MkdirAll(filepath.Dir(dstPath)); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
+		return
+	}
+
+	if err := fsutil.Move(srcPath, dstPath); err != nil {
+		filesLog.Error("Failed to move %s to %s: %v", srcPath, dstPath, err)
+		if backupPath != "" {
+			if restoreErr := os.Rename(backupPath, dstPath); restoreErr != nil {
+				filesLog.Error("Failed to restore %s: %v", dstPath, restoreErr)
+			}
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to move")
+		return
+	}
+	if backupPath != "" {
+		if err := os.RemoveAll(backupPath); err != nil {
+			filesLog.Warn("Failed to remove replaced destination %s: %v", backupPath, err)
+		}
+	}
+
+	filesLog.Info("Moved %s to %s", srcPath, dstPath)
+	response.JSON(w, http.StatusOK, map[string]any{
+		"success":     true,
+		"message":     fmt.Sprintf("Moved %s to %s", from, to),
+		"from":        from,
+		"to":          to,
+		"type":        typeStr,
+		"overwritten": overwritten,
+	})
+}
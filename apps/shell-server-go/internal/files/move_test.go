@@ -0,0 +1,104 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func movePathRequest(h *Handler, values url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/move", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.MovePath(w, req)
+	return w
+}
+
+func writeMoveFixture(t *testing.T, root string) {
+	t.Helper()
+	for name, content := range map[string]string{
+		"src/app.js":      "app",
+		"src/lib/util.js": "util",
+		"dist/app.js":     "old build",
+	} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+}
+
+func TestMovePath_RenamesAndRefusesOverwrite(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	w := movePathRequest(h, url.Values{"workspace": {"root"}, "from": {"src/lib"}, "to": {"shared/lib"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"type":"directory"`) {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if content, err := os.ReadFile(filepath.Join(root, "shared", "lib", "util.js")); err != nil || string(content) != "util" {
+		t.Fatalf("moved file missing: %q %v", content, err)
+	}
+
+	w = movePathRequest(h, url.Values{"workspace": {"root"}, "from": {"src/app.js"}, "to": {"dist/app.js"}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 without overwrite, got %d", w.Code)
+	}
+
+	w = movePathRequest(h, url.Values{"workspace": {"root"}, "from": {"src/app.js"}, "to": {"dist/app.js"}, "overwrite": {"true"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"overwritten":true`) {
+		t.Fatalf("expected overwrite, got %d body=%s", w.Code, w.Body.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "dist", "app.js")); string(content) != "app" {
+		t.Fatalf("destination not replaced: %q", content)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "dist"))
+	if len(entries) != 1 {
+		t.Fatalf("replaced destination left behind: %v", entries)
+	}
+}
+
+func TestMovePath_RejectsUnsafeMoves(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+	writeMoveFixture(t, root)
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	cases := []struct {
+		from, to string
+		want     int
+	}{
+		{"src", "src/nested", http.StatusBadRequest},
+		{"src/lib", "src", http.StatusBadRequest},
+		{"src/app.js", "../app.js", http.StatusBadRequest},
+		{"src/app.js", "escape/app.js", http.StatusBadRequest},
+		{".", "moved", http.StatusBadRequest},
+		{"missing.txt", "other.txt", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		values := url.Values{"workspace": {"root"}, "from": {tc.from}, "to": {tc.to}, "overwrite": {"true"}}
+		if w := movePathRequest(h, values); w.Code != tc.want {
+			t.Errorf("%s -> %s: expected %d, got %d body=%s", tc.from, tc.to, tc.want, w.Code, w.Body.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "app.js")); !os.IsNotExist(err) {
+		t.Fatalf("file escaped through symlink")
+	}
+	if _, err := os.Stat(filepath.Join(root, "src", "app.js")); err != nil {
+		t.Fatalf("source changed by rejected move: %v", err)
+	}
+}
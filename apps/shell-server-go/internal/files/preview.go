@@ -0,0 +1,144 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/preview"
+)
+
+// writePreview answers ReadFile for the markdown, table and json modes. content
+// is text of at most MaxPreviewSize bytes; body holds the common fields.
+func (h *Handler) writePreview(w http.ResponseWriter, r *http.Request, mode, filename string, content []byte, body map[string]any) {
+	body["mode"] = mode
+	switch mode {
+	case preview.ModeMarkdown:
+		html, err := preview.Markdown(content)
+		if err != nil {
+			filesLog.Error("Failed to render markdown %s: %v", filename, err)
+			response.Error(w, http.StatusInternalServerError, "Failed to render markdown")
+			return
+		}
+		body["html"] = html
+
+	case preview.ModeJSON:
+		pretty, err := preview.JSON(content)
+		if errors.Is(err, preview.ErrTooLarge) {
+			response.Error(w, http.StatusRequestEntityTooLarge, "JSON too deeply nested to pretty-print (use mode=text)")
+			return
+		}
+		if err != nil {
+			response.JSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"error":  "Invalid JSON",
+				"detail": err.Error(),
+			})
+			return
+		}
+		body["content"] = string(pretty)
+
+	case preview.ModeTable:
+		opts, err := tableOptions(r)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if opts.Delimiter == 0 {
+			opts.Delimiter = preview.DetectDelimiter(filename, content)
+		}
+		page, err := preview.Table(content, opts)
+		if err != nil {
+			response.JSON(w, http.StatusUnprocessableEntity, map[string]any{
+				"error":  "Invalid delimited text",
+				"detail": err.Error(),
+			})
+			return
+		}
+		body["table"] = page
+	}
+	response.JSON(w, http.StatusOK, body)
+}
+
+func tableOptions(r *http.Request) (preview.TableOptions, error) {
+	delimiter, err := preview.ParseDelimiter(r.FormValue("delimiter"))
+	if err != nil {
+		return preview.TableOptions{}, err
+	}
+	opts := preview.TableOptions{
+		Delimiter: delimiter,
+		Header:    r.FormValue("header") != "0" && r.FormValue("header") != "false",
+		Limit:     preview.DefaultTableRows,
+	}
+	if raw := r.FormValue("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return opts, errors.New("Invalid offset")
+		}
+		opts.Offset = n
+	}
+	if raw := r.FormValue("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return opts, errors.New("Invalid limit")
+		}
+		opts.Limit = min(n, preview.MaxTableRows)
+	}
+	return opts, nil
+}
+
+// readHex answers ReadFile with mode=hex: a dump of length bytes (default 4KB,
+// max 64KB) from offset. Only the range is read, so it works on files of any
+// size and type.
+func (h *Handler) readHex(w http.ResponseWriter, r *http.Request, resolvedPath string, info os.FileInfo) {
+	var offset int64
+	if raw := r.FormValue("offset"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+		offset = n
+	}
+	length := preview.DefaultHexLength
+	if raw := r.FormValue("length"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			response.Error(w, http.StatusBadRequest, "Invalid length")
+			return
+		}
+		length = min(n, preview.MaxHexLength)
+	}
+
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	fileType, err := filetype.DetectReader(file, info.Name())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	rows, n, err := preview.Hex(file, offset, length)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{
+		"mode":     preview.ModeHex,
+		"path":     resolvedPath,
+		"filename": info.Name(),
+		"size":     info.Size(),
+		"mimeType": fileType.MIMEType,
+		"kind":     fileType.Kind,
+		"offset":   offset,
+		"length":   n,
+		"eof":      offset+int64(n) >= info.Size(),
+		"rows":     rows,
+	})
+}
@@ -0,0 +1,87 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFilePreview(t *testing.T, h *Handler, form url.Values) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	form.Set("workspace", "root")
+	req := httptest.NewRequest(http.MethodPost, "/api/read-file", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ReadFile(w, req)
+	var payload map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &payload)
+	return w, payload
+}
+
+func TestReadFile_PreviewModes(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+
+	files := map[string]string{
+		"README.md":   "# Hi\n\n<script>alert(1)</script>\n",
+		"data.tsv":    "id\tname\n1\tone\n2\ttwo\n3\tthree\n",
+		"conf.json":   `{"a":[1,2]}`,
+		"broken.json": `{"a":`,
+		"server":      "\x7fELF\x02\x01\x01\x00\x00\x00",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	w, payload := readFilePreview(t, h, url.Values{"path": {"README.md"}, "mode": {"markdown"}})
+	if w.Code != http.StatusOK || !strings.Contains(payload["html"].(string), "<h1>Hi</h1>") || strings.Contains(w.Body.String(), "<script") {
+		t.Fatalf("markdown: %d %s", w.Code, w.Body.String())
+	}
+
+	w, payload = readFilePreview(t, h, url.Values{"path": {"data.tsv"}, "mode": {"table"}, "offset": {"1"}, "limit": {"1"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("table: %d %s", w.Code, w.Body.String())
+	}
+	table := payload["table"].(map[string]any)
+	if table["delimiter"] != "\t" || table["totalRows"] != float64(3) || table["hasMore"] != true {
+		t.Fatalf("unexpected table %v", table)
+	}
+	if rows := table["rows"].([]any); len(rows) != 1 || rows[0].([]any)[1] != "two" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	w, payload = readFilePreview(t, h, url.Values{"path": {"conf.json"}, "mode": {"json"}})
+	if w.Code != http.StatusOK || payload["content"] != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Fatalf("json: %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = readFilePreview(t, h, url.Values{"path": {"broken.json"}, "mode": {"json"}}); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("broken json: expected 422, got %d", w.Code)
+	}
+
+	// Binary files are refused as text but can be dumped.
+	if w, _ = readFilePreview(t, h, url.Values{"path": {"server"}, "mode": {"markdown"}}); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("binary markdown: expected 415, got %d", w.Code)
+	}
+	w, payload = readFilePreview(t, h, url.Values{"path": {"server"}, "mode": {"hex"}, "offset": {"1"}, "length": {"3"}})
+	if w.Code != http.StatusOK || payload["length"] != float64(3) || payload["eof"] != false {
+		t.Fatalf("hex: %d %s", w.Code, w.Body.String())
+	}
+	if row := payload["rows"].([]any)[0].(map[string]any); row["hex"] != "45 4c 46" || row["ascii"] != "ELF" {
+		t.Fatalf("unexpected hex row %v", row)
+	}
+
+	if w, _ = readFilePreview(t, h, url.Values{"path": {"data.tsv"}, "mode": {"pdf"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown mode: expected 400, got %d", w.Code)
+	}
+	if w, _ = readFilePreview(t, h, url.Values{"path": {"../secret"}, "mode": {"hex"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("hex traversal: expected 400, got %d", w.Code)
+	}
+}
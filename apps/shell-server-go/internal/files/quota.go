@@ -0,0 +1,78 @@
+package files
+
+import (
+	"net/http"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// reserveQuota holds quota for everything a plan writes and responds 507 when
+// it does not fit.
+func (h *Handler) reserveQuota(w http.ResponseWriter, plan *extractionPlan) (*quota.Reservation, bool) {
+	bytes, inodes := plan.writeTotals()
+	reservation, err := h.quotas.Reserve(plan.target, bytes, inodes)
+	if err != nil {
+		quota.HandleError(w, err)
+		return nil, false
+	}
+	return reservation, true
+}
+
+// commitQuota commits a plan's reservation. Replaced entries were counted in
+// full, so the site is recounted to give their space back.
+func (h *Handler) commitQuota(reservation *quota.Reservation, plan *extractionPlan) {
+	reservation.Commit()
+	if plan.replacesExisting() {
+		h.quotas.Invalidate(plan.target)
+	}
+}
+
+// siteUsageOf measures path when it belongs to a site, so a delete can give
+// its space back without a full recount.
+func (h *Handler) siteUsageOf(path string) (bytes, inodes int64) {
+	if h.quotas.SiteFor(path) == "" {
+		return 0, 0
+	}
+	bytes, inodes, _ = quota.DirUsage(path)
+	return bytes, inodes
+}
+
+// trashLeavesSite reports whether trashing path moves it out of its site.
+// The trash root normally lies outside every site, so a delete frees the
+// space; a trashPath configured inside a site keeps it counted there.
+func (h *Handler) trashLeavesSite(workspaceID, path string) bool {
+	dir, err := h.trash.Dir(workspaceID)
+	if err != nil {
+		return false
+	}
+	return h.quotas.SiteFor(dir) != h.quotas.SiteFor(path)
+}
+
+// QuotaUsage handles GET /api/quota?workspace=X&refresh=1.
+func (h *Handler) QuotaUsage(w http.ResponseWriter, r *http.Request) {
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	base, err := h.resolver.ResolveWorkspaceBase(workspaceID)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	site := h.quotas.SiteFor(base)
+	if site == "" {
+		response.Error(w, http.StatusBadRequest, "Quotas only apply to site workspaces")
+		return
+	}
+
+	var usage quota.Usage
+	if r.URL.Query().Get("refresh") == "1" {
+		usage, err = h.quotas.Refresh(site)
+	} else {
+		usage, err = h.quotas.Usage(site)
+	}
+	if err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,64 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/quota"
+)
+
+func TestUpload_RejectsWritesOverSiteQuota(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.config.SiteQuota = config.QuotaLimit{Bytes: 64}
+	site := filepath.Join(h.config.ResolvedSitesPath, "example.com", "user")
+	if err := os.MkdirAll(site, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	fields := map[string]string{"workspace": "site:example.com"}
+
+	w := uploadWithFields(t, h, "small.txt", []byte(strings.Repeat("a", 40)), fields)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected upload within quota, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	archive := buildZip(t, map[string]string{"dist/app.js": strings.Repeat("b", 30)})
+	w = uploadWithFields(t, h, "build.zip", archive, fields)
+	if w.Code != http.StatusInsufficientStorage || !strings.Contains(w.Body.String(), `"code":"QUOTA_EXCEEDED"`) {
+		t.Fatalf("expected 507 quota exceeded, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(site, "dist")); !os.IsNotExist(err) {
+		t.Fatalf("rejected archive was extracted")
+	}
+
+	// A per-site override replaces the default limit.
+	h.config.SiteQuotas = map[string]config.QuotaLimit{"example.com": {Bytes: 1024}}
+	w = uploadWithFields(t, h, "build.zip", archive, fields)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected upload under site override, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/quota?workspace=site:example.com", nil)
+	rec := httptest.NewRecorder()
+	h.QuotaUsage(rec, req)
+	var usage quota.Usage
+	if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected quota response %d body=%s", rec.Code, rec.Body.String())
+	}
+	if usage.Site != "example.com" || usage.Bytes != 70 || usage.LimitBytes != 1024 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/quota?workspace=root", nil)
+	rec = httptest.NewRecorder()
+	h.QuotaUsage(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 outside sites, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"context"
+	"net/http"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/scan"
+)
+
+// scanTarget runs the upload scanner over one target; without a configured
+// scanner it finds nothing.
+func (h *Handler) scanTarget(ctx context.Context, target scan.Target) ([]scan.Finding, error) {
+	if h.scanner == nil {
+		return nil, nil
+	}
+	return h.scanner.Scan(ctx, target)
+}
+
+// checkFindings logs what a scan found and adds it to opts, where it is echoed
+// in the upload response. It responds and returns false when the upload must
+// stop: 503 when the scan failed, 422 when findings block it.
+func (h *Handler) checkFindings(w http.ResponseWriter, findings []scan.Finding, scanErr error, opts *uploadOptions) bool {
+	for _, f := range findings {
+		filesLog.Warn("Upload scan: %s flagged by %s (%s): %s", f.Path, f.Scanner, f.Rule, f.Detail)
+	}
+	opts.findings = append(opts.findings, findings...)
+
+	if scanErr != nil {
+		filesLog.Error("Upload scan failed: %v", scanErr)
+		response.Error(w, http.StatusServiceUnavailable, "Upload could not be scanned")
+		return false
+	}
+	if scan.Blocks(h.config.UploadScan.Mode, opts.findings) {
+		response.JSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":    "Upload rejected by scanner",
+			"code":     "UPLOAD_REJECTED",
+			"findings": opts.findings,
+		})
+		return false
+	}
+	return true
+}
+
+// withFindings adds the scan findings of an upload to its success response.
+func withFindings(body map[string]any, opts uploadOptions) map[string]any {
+	if len(opts.findings) > 0 {
+		body["findings"] = opts.findings
+	}
+	return body
+}
@@ -0,0 +1,90 @@
+package files
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/scan"
+)
+
+func enableUploadScan(h *Handler, cfg config.UploadScanConfig) {
+	h.config.UploadScan = cfg
+	h.scanner = scan.New(cfg)
+}
+
+func decodeFindings(t *testing.T, body []byte) []scan.Finding {
+	t.Helper()
+	var resp struct {
+		Findings []scan.Finding `json:"findings"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode response: %v body=%s", err, body)
+	}
+	return resp.Findings
+}
+
+func TestUpload_ScannerRejectsDeniedZipEntries(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	enableUploadScan(h, config.UploadScanConfig{DenyExtensions: []string{"php"}})
+	root := h.config.ResolvedUploadCwd
+
+	data := buildZip(t, map[string]string{
+		"site/index.html":            "<h1>hi</h1>",
+		"site/uploads/shell.php.jpg": "<?php system($_GET['c']);",
+	})
+	w := uploadWithFields(t, h, "site.zip", data, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d body=%s", w.Code, w.Body.String())
+	}
+	findings := decodeFindings(t, w.Body.Bytes())
+	if len(findings) != 1 || findings[0].Path != "site/uploads/shell.php.jpg" || findings[0].Rule != scan.RuleExtension {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+	if _, err := os.Stat(filepath.Join(root, "site")); !os.IsNotExist(err) {
+		t.Fatalf("rejected archive was extracted: %v", err)
+	}
+}
+
+func TestUpload_ScannerReportModeKeepsUpload(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	enableUploadScan(h, config.UploadScanConfig{Mode: scan.ModeReport, DenyMIMETypes: []string{"application/x-*", "application/x-executable"}})
+	root := h.config.ResolvedUploadCwd
+
+	data := buildTar(t, []tarFixtureEntry{
+		{name: "bin/", typeflag: tar.TypeDir},
+		{name: "bin/tool", typeflag: tar.TypeReg, body: "\x7fELF\x02\x01\x01"},
+		{name: "README", typeflag: tar.TypeReg, body: "hello"},
+	})
+	w := uploadWithFields(t, h, "tools.tar", data, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	findings := decodeFindings(t, w.Body.Bytes())
+	if len(findings) != 1 || findings[0].Path != "bin/tool" || findings[0].Rule != scan.RuleMIMEType {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bin", "tool")); err != nil {
+		t.Fatalf("report mode should keep the upload: %v", err)
+	}
+}
+
+func TestUpload_ScannerFailureRejectsUpload(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	enableUploadScan(h, config.UploadScanConfig{ClamdAddress: filepath.Join(t.TempDir(), "missing.sock")})
+
+	w := uploadWithFields(t, h, "notes.txt", []byte("hello"), nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(h.config.ResolvedUploadCwd, "notes.txt")); !os.IsNotExist(err) {
+		t.Fatalf("unscanned upload was stored: %v", err)
+	}
+}
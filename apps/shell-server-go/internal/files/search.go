@@ -0,0 +1,375 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/httpx/response"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+const (
+	// DefaultSearchLimit is the result cap when the client does not ask for one.
+	DefaultSearchLimit = 200
+	// MaxSearchLimit caps the number of results of one search.
+	MaxSearchLimit = 2000
+	// MaxSearchContext caps the context lines sent around a content match.
+	MaxSearchContext = 5
+	// MaxSearchFileSize skips larger files during content search.
+	MaxSearchFileSize = MaxPreviewSize
+	// MaxSearchLineLength truncates long lines in content results.
+	MaxSearchLineLength = 500
+)
+
+// searchTimeout bounds one search request. Tests shorten it.
+var searchTimeout = 15 * time.Second
+
+// Search modes and matchers accepted by SearchFiles.
+const (
+	SearchModeName    = "name"
+	SearchModeContent = "content"
+
+	SearchMatchGlob    = "glob"
+	SearchMatchFuzzy   = "fuzzy"
+	SearchMatchRegex   = "regex"
+	SearchMatchLiteral = "literal"
+)
+
+var errSearchLimit = errors.New("search result limit reached")
+
+// SearchResult is one NDJSON line of a search response.
+type SearchResult struct {
+	Type   string   `json:"type"`
+	Path   string   `json:"path,omitempty"`
+	Score  int      `json:"score,omitempty"`
+	Line   int      `json:"line,omitempty"`
+	Column int      `json:"column,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+
+	// Set on the final "done" line only.
+	Results   int    `json:"results,omitempty"`
+	Files     int    `json:"filesScanned,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	TimedOut  bool   `json:"timedOut,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// searchStream writes NDJSON results and flushes after each one.
+type searchStream struct {
+	enc     *json.Encoder
+	rc      *http.ResponseController
+	limit   int
+	results int
+}
+
+func (s *searchStream) send(result SearchResult) error {
+	if s.results >= s.limit {
+		return errSearchLimit
+	}
+	s.results++
+	if err := s.enc.Encode(result); err != nil {
+		return err
+	}
+	s.rc.Flush()
+	return nil
+}
+
+// nameMatcher scores a relative path; ok=false means no match.
+type nameMatcher func(relPath string) (score int, ok bool)
+
+func newGlobMatcher(pattern string, caseSensitive bool) (nameMatcher, error) {
+	if !caseSensitive {
+		pattern = strings.ToLower(pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	matchPath := strings.Contains(pattern, "/")
+	return func(relPath string) (int, bool) {
+		if !caseSensitive {
+			relPath = strings.ToLower(relPath)
+		}
+		subject := path.Base(relPath)
+		if matchPath {
+			subject = relPath
+		}
+		ok, _ := path.Match(pattern, subject)
+		return 0, ok
+	}, nil
+}
+
+// newFuzzyMatcher matches when the query is a subsequence of the path. Matches
+// at word starts, consecutive runs and in the base name score higher.
+func newFuzzyMatcher(query string) nameMatcher {
+	needle := []rune(strings.ToLower(query))
+	return func(relPath string) (int, bool) {
+		hay := []rune(strings.ToLower(relPath))
+		baseStart := strings.LastIndex(relPath, "/") + 1
+		baseStart = utf8.RuneCountInString(relPath[:baseStart])
+
+		score, n, prev := 0, 0, -2
+		for i, r := range hay {
+			if n == len(needle) {
+				break
+			}
+			if r != needle[n] {
+				continue
+			}
+			score++
+			if i == prev+1 {
+				score += 5
+			}
+			if i == 0 || !unicode.IsLetter(hay[i-1]) && !unicode.IsDigit(hay[i-1]) {
+				score += 3
+			}
+			if i >= baseStart {
+				score += 2
+			}
+			prev = i
+			n++
+		}
+		if n < len(needle) {
+			return 0, false
+		}
+		return score, true
+	}
+}
+
+func newContentPattern(query, match string, caseSensitive bool) (*regexp.Regexp, error) {
+	if match == SearchMatchLiteral {
+		query = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		query = "(?i)" + query
+	}
+	return regexp.Compile(query)
+}
+
+func truncateLine(line string) string {
+	if len(line) <= MaxSearchLineLength {
+		return line
+	}
+	cut := MaxSearchLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "…"
+}
+
+// searchContent sends one result per matching line of a file.
+func searchContent(stream *searchStream, relPath string, data []byte, pattern *regexp.Regexp, contextLines int) error {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range lines {
+		loc := pattern.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		result := SearchResult{
+			Type:   "match",
+			Path:   relPath,
+			Line:   i + 1,
+			Column: utf8.RuneCountInString(line[:loc[0]]) + 1,
+			Text:   truncateLine(strings.TrimSuffix(line, "\r")),
+		}
+		for _, l := range lines[max(0, i-contextLines):i] {
+			result.Before = append(result.Before, truncateLine(strings.TrimSuffix(l, "\r")))
+		}
+		for _, l := range lines[i+1 : min(len(lines), i+1+contextLines)] {
+			result.After = append(result.After, truncateLine(strings.TrimSuffix(l, "\r")))
+		}
+		if err := stream.send(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchFiles handles GET /api/files/search?workspace=X&path=Y&q=Q&mode=name|content&match=M.
+// Results are streamed as NDJSON and end with a "done" line carrying totals.
+func (h *Handler) SearchFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	q := query.Get("q")
+	if q == "" {
+		response.Error(w, http.StatusBadRequest, "Missing search query")
+		return
+	}
+	dirPath := query.Get("path")
+	if dirPath == "" {
+		dirPath = "./"
+	}
+	caseSensitive := query.Get("case") == "1" || query.Get("case") == "true"
+
+	mode := query.Get("mode")
+	if mode == "" {
+		mode = SearchModeName
+	}
+	match := query.Get("match")
+
+	var matchName nameMatcher
+	var pattern *regexp.Regexp
+	var err error
+	switch mode {
+	case SearchModeName:
+		switch match {
+		case "", SearchMatchFuzzy:
+			matchName = newFuzzyMatcher(q)
+		case SearchMatchGlob:
+			matchName, err = newGlobMatcher(q, caseSensitive)
+		default:
+			response.Error(w, http.StatusBadRequest, "Invalid match (expected glob or fuzzy)")
+			return
+		}
+	case SearchModeContent:
+		if match == "" {
+			match = SearchMatchLiteral
+		}
+		if match != SearchMatchLiteral && match != SearchMatchRegex {
+			response.Error(w, http.StatusBadRequest, "Invalid match (expected literal or regex)")
+			return
+		}
+		pattern, err = newContentPattern(q, match, caseSensitive)
+	default:
+		response.Error(w, http.StatusBadRequest, "Invalid mode (expected name or content)")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid pattern: "+err.Error())
+		return
+	}
+
+	limit := DefaultSearchLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			response.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(n, MaxSearchLimit)
+	}
+	contextLines := 0
+	if raw := query.Get("context"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid context")
+			return
+		}
+		contextLines = min(n, MaxSearchContext)
+	}
+
+	basePath, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, dirPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if info, err := os.Stat(resolvedPath); err != nil || !info.IsDir() {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+	if realBase, err := filepath.EvalSymlinks(basePath); err == nil {
+		basePath = realBase
+	}
+	rules := queryIgnore(r, basePath)
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		filesLog.Debug("Failed to clear write deadline for search: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &searchStream{enc: json.NewEncoder(w), rc: rc, limit: limit}
+	filesScanned := 0
+
+	// WalkDir does not follow symlinks, so the search stays inside the workspace.
+	walkErr := filepath.WalkDir(resolvedPath, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p != resolvedPath && rules.Ignored(p, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if p == resolvedPath {
+			return nil
+		}
+
+		rel, err := filepath.Rel(basePath, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if mode == SearchModeName {
+			filesScanned++
+			if score, ok := matchName(rel); ok {
+				kind := "file"
+				if d.IsDir() {
+					kind = "directory"
+				}
+				return stream.send(SearchResult{Type: kind, Path: rel, Score: score})
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || !filetype.ByName(p).Text {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > MaxSearchFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || !filetype.Detect(p, data).Text {
+			return nil
+		}
+		filesScanned++
+		return searchContent(stream, rel, data, pattern, contextLines)
+	})
+
+	done := SearchResult{Type: "done", Files: filesScanned}
+	switch {
+	case walkErr == nil:
+	case errors.Is(walkErr, errSearchLimit):
+		done.Truncated = true
+	case errors.Is(walkErr, context.DeadlineExceeded):
+		done.TimedOut = true
+	case errors.Is(walkErr, context.Canceled):
+		filesLog.Debug("Search in %s cancelled by client", resolvedPath)
+		return
+	default:
+		filesLog.Warn("Search in %s failed: %v", resolvedPath, walkErr)
+		done.Error = "Search failed"
+	}
+	done.Results = stream.results
+	stream.enc.Encode(done)
+	rc.Flush()
+}
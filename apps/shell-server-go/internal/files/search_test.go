@@ -0,0 +1,131 @@
+package files
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func runSearch(t *testing.T, h *Handler, params url.Values) (int, []SearchResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/files/search?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	h.SearchFiles(w, req)
+
+	var results []SearchResult
+	scanner := bufio.NewScanner(w.Body)
+	for w.Code == http.StatusOK && scanner.Scan() {
+		var result SearchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+	return w.Code, results
+}
+
+func writeSearchFixture(t *testing.T, root string) {
+	t.Helper()
+	files := map[string]string{
+		"src/components/UserCard.tsx":  "import x\nexport function UserCard() {\n  return TODO_marker\n}\n",
+		"src/utils/format.ts":          "// todo_marker lowercase\nexport const f = 1\n",
+		"node_modules/lib/UserCard.js": "TODO_marker in dependency\n",
+		"assets/blob.dat":              "TODO_marker\x00\x01\x02",
+		"README.md":                    "nothing here\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+}
+
+func TestSearchFiles_ByName(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeSearchFixture(t, h.config.ResolvedUploadCwd)
+
+	code, results := runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"ucard"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(results) != 2 || results[0].Path != "src/components/UserCard.tsx" || results[0].Score == 0 {
+		t.Fatalf("unexpected fuzzy results %+v", results)
+	}
+	if done := results[len(results)-1]; done.Type != "done" || done.Results != 1 {
+		t.Fatalf("unexpected done line %+v", done)
+	}
+
+	_, results = runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"*.ts"}, "match": {"glob"}})
+	if len(results) != 2 || results[0].Path != "src/utils/format.ts" {
+		t.Fatalf("unexpected glob results %+v", results)
+	}
+}
+
+func TestSearchFiles_ByContentWithContextAndLimit(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeSearchFixture(t, h.config.ResolvedUploadCwd)
+
+	code, results := runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"todo_marker"}, "mode": {"content"}, "context": {"1"}})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	matches := results[:len(results)-1]
+	if len(matches) != 2 {
+		t.Fatalf("expected matches only in source files, got %+v", results)
+	}
+	first := matches[0]
+	if first.Path != "src/components/UserCard.tsx" || first.Line != 3 || first.Column != 10 {
+		t.Fatalf("unexpected match position %+v", first)
+	}
+	if len(first.Before) != 1 || first.Before[0] != "export function UserCard() {" || len(first.After) != 1 || first.After[0] != "}" {
+		t.Fatalf("unexpected context %+v", first)
+	}
+
+	_, results = runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"TODO_marker"}, "mode": {"content"}, "case": {"1"}})
+	if len(results) != 2 {
+		t.Fatalf("expected one case-sensitive match, got %+v", results)
+	}
+
+	_, results = runSearch(t, h, url.Values{"workspace": {"root"}, "q": {`export (function|const)`}, "mode": {"content"}, "match": {"regex"}, "limit": {"1"}})
+	if done := results[len(results)-1]; len(results) != 2 || !done.Truncated {
+		t.Fatalf("expected truncated result set, got %+v", results)
+	}
+}
+
+func TestSearchFiles_TimesOutAndRejectsBadInput(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	writeSearchFixture(t, h.config.ResolvedUploadCwd)
+
+	previous := searchTimeout
+	searchTimeout = 0
+	defer func() { searchTimeout = previous }()
+
+	_, results := runSearch(t, h, url.Values{"workspace": {"root"}, "q": {"x"}})
+	if len(results) != 1 || !results[0].TimedOut {
+		t.Fatalf("expected timed out done line, got %+v", results)
+	}
+
+	for _, params := range []url.Values{
+		{"workspace": {"root"}},
+		{"workspace": {"root"}, "q": {"x"}, "path": {"../"}},
+		{"workspace": {"root"}, "q": {"("}, "mode": {"content"}, "match": {"regex"}},
+		{"workspace": {"root"}, "q": {"[x"}, "match": {"glob"}},
+		{"workspace": {"root"}, "q": {"x"}, "mode": {"everything"}},
+	} {
+		if code, _ := runSearch(t, h, params); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d", params, code)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	"shell-server-go/internal/snapshot"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// snapshotStore returns the snapshot store and workspace base of a site
+// workspace. The store is server state outside the site, so site users can
+// neither see nor tamper with it.
+func (h *Handler) snapshotStore(w http.ResponseWriter, workspaceID string) (dir, base string, ok bool) {
+	if !strings.HasPrefix(workspaceID, "site:") {
+		response.Error(w, http.StatusBadRequest, "Snapshots are only available for site workspaces")
+		return "", "", false
+	}
+	base, err := h.resolver.ResolveWorkspaceBase(workspaceID)
+	if err != nil {
+		h.handlePathError(w, err)
+		return "", "", false
+	}
+	dir, err = h.snapshots.Store(filepath.Base(filepath.Dir(base)))
+	if err != nil {
+		handleSnapshotError(w, err)
+		return "", "", false
+	}
+	return dir, base, true
+}
+
+// handleSnapshotError maps snapshot errors to responses.
+func handleSnapshotError(w http.ResponseWriter, err error) {
+	var exceeded *quota.ExceededError
+	switch {
+	case errors.As(err, &exceeded):
+		quota.HandleError(w, err)
+	case errors.Is(err, snapshot.ErrNotFound):
+		response.Error(w, http.StatusNotFound, "Snapshot not found")
+	case errors.Is(err, snapshot.ErrPathNotFound):
+		response.Error(w, http.StatusNotFound, "Path not found in snapshot")
+	case errors.Is(err, snapshot.ErrTooMany):
+		response.Error(w, http.StatusConflict, fmt.Sprintf("Snapshot limit reached (%d per site) - delete old snapshots first", snapshot.MaxSnapshots))
+	case errors.Is(err, snapshot.ErrTooLarge):
+		response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Workspace has more than %d entries", snapshot.MaxEntries))
+	default:
+		filesLog.Error("Snapshot operation failed: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Snapshot operation failed")
+	}
+}
+
+// ListSnapshots handles GET /api/snapshots?workspace=X.
+func (h *Handler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	dir, _, ok := h.snapshotStore(w, workspacepkg.WorkspaceFromQuery(r, h.sessions))
+	if !ok {
+		return
+	}
+
+	infos, storeBytes, err := h.snapshots.List(dir)
+	if err != nil {
+		handleSnapshotError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{
+		"snapshots":  infos,
+		"storeBytes": storeBytes,
+		"limit":      snapshot.MaxSnapshots,
+	})
+}
+
+// CreateSnapshot handles POST /api/snapshots (form: workspace, label).
+func (h *Handler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	dir, base, ok := h.snapshotStore(w, workspacepkg.WorkspaceFromForm(r, h.sessions))
+	if !ok {
+		return
+	}
+	label := strings.TrimSpace(r.FormValue("label"))
+	if len(label) > snapshot.MaxLabelLen {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("Label longer than %d characters", snapshot.MaxLabelLen))
+		return
+	}
+
+	snap, err := h.snapshots.Create(dir, base, snapshot.Options{Label: label})
+	if err != nil {
+		handleSnapshotError(w, err)
+		return
+	}
+	snap.Entries = nil
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "snapshot": snap})
+}
+
+// DiffSnapshots handles GET /api/snapshots/diff?workspace=X&from=ID[&to=ID|live][&path=P].
+// to defaults to the live workspace.
+func (h *Handler) DiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	dir, base, ok := h.snapshotStore(w, workspacepkg.WorkspaceFromQuery(r, h.sessions))
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	from := query.Get("from")
+	if from == "" {
+		response.Error(w, http.StatusBadRequest, "No snapshot provided")
+		return
+	}
+	to := query.Get("to")
+	if to == "" {
+		to = snapshot.Live
+	}
+
+	diff, err := h.snapshots.Diff(dir, base, from, to, snapshot.CleanScope(query.Get("path")))
+	if err != nil {
+		handleSnapshotError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, diff)
+}
+
+// RestoreSnapshot handles POST /api/snapshots/restore (form: workspace, id,
+// path, backup). Without path the whole workspace is restored. Unless backup
+// is "false", the workspace is snapshotted first so the restore can be undone.
+func (h *Handler) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspaceID := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	dir, base, ok := h.snapshotStore(w, workspaceID)
+	if !ok {
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		response.Error(w, http.StatusBadRequest, "No snapshot provided")
+		return
+	}
+	scope := snapshot.CleanScope(r.FormValue("path"))
+	if scope != "" {
+		if _, err := h.resolveEntryPath(workspaceID, r.FormValue("path")); err != nil {
+			h.handlePathError(w, err)
+			return
+		}
+	}
+	backup := r.FormValue("backup") != "false" && r.FormValue("backup") != "0"
+
+	defer h.quotas.Invalidate(base)
+	result, err := h.snapshots.Restore(dir, base, id, scope, snapshot.RestoreOptions{
+		Options: snapshot.Options{Check: h.snapshotQuota(base)},
+		Owner:   h.workspaceOwner(workspaceID),
+		Backup:  backup,
+	})
+	if err != nil {
+		handleSnapshotError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "result": result})
+}
+
+// DeleteSnapshot handles POST /api/snapshots/delete (form: workspace, id).
+// Content no other snapshot shares is removed with it.
+func (h *Handler) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	dir, _, ok := h.snapshotStore(w, workspacepkg.WorkspaceFromForm(r, h.sessions))
+	if !ok {
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		response.Error(w, http.StatusBadRequest, "No snapshot provided")
+		return
+	}
+
+	freed, err := h.snapshots.Delete(dir, id)
+	if err != nil {
+		handleSnapshotError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "freedBytes": freed})
+}
+
+func (h *Handler) snapshotQuota(base string) func(bytes, inodes int64) error {
+	return func(bytes, inodes int64) error {
+		return h.quotas.Check(base, bytes, inodes)
+	}
+}
@@ -0,0 +1,119 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shell-server-go/internal/snapshot"
+)
+
+func TestSnapshots_CreateDiffRestoreDelete(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	siteBase := filepath.Join(h.config.ResolvedSitesPath, "example.com", "user")
+	if err := os.MkdirAll(filepath.Join(siteBase, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(siteBase, "src", "index.ts"), []byte("v1"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	w := postForm(h.CreateSnapshot, "/api/snapshots", "", url.Values{"workspace": {"site:example.com"}, "label": {"before deploy"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Snapshot snapshot.Snapshot `json:"snapshot"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	id := created.Snapshot.ID
+	if id == "" || created.Snapshot.Label != "before deploy" || created.Snapshot.Files != 1 || created.Snapshot.Entries != nil {
+		t.Fatalf("unexpected snapshot %+v", created.Snapshot)
+	}
+	// The store is server state outside the site.
+	store, _ := h.snapshots.Dir("example.com")
+	if _, err := os.Stat(filepath.Join(store, "manifests", id+".json")); err != nil {
+		t.Fatalf("manifest not in site store: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(siteBase, "..", ".snapshots")); !os.IsNotExist(err) {
+		t.Fatalf("store created inside the site: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(siteBase, "src", "index.ts"), []byte("v2!"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/snapshots/diff?workspace=site:example.com&from="+id, nil)
+	w = httptest.NewRecorder()
+	h.DiffSnapshots(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("diff: %d %s", w.Code, w.Body.String())
+	}
+	var diff snapshot.DiffResult
+	if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if diff.To != snapshot.Live || len(diff.Changes) != 1 || diff.Changes[0].Path != "src/index.ts" || diff.Changes[0].Status != snapshot.StatusModified {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	w = postForm(h.RestoreSnapshot, "/api/snapshots/restore", "", url.Values{"workspace": {"site:example.com"}, "id": {id}, "path": {"src/index.ts"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", w.Code, w.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(siteBase, "src", "index.ts")); string(data) != "v1" {
+		t.Fatalf("file not restored: %q", data)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/snapshots?workspace=site:example.com", nil)
+	w = httptest.NewRecorder()
+	h.ListSnapshots(w, req)
+	var listed struct {
+		Snapshots []snapshot.Info `json:"snapshots"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// The restore took a backup of the state it replaced.
+	if len(listed.Snapshots) != 2 {
+		t.Fatalf("expected snapshot and backup, got %+v", listed.Snapshots)
+	}
+
+	w = postForm(h.DeleteSnapshot, "/api/snapshots/delete", "", url.Values{"workspace": {"site:example.com"}, "id": {id}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	w = postForm(h.DeleteSnapshot, "/api/snapshots/delete", "", url.Values{"workspace": {"site:example.com"}, "id": {id}})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted snapshot, got %d", w.Code)
+	}
+}
+
+func TestSnapshots_RejectsInvalidRequests(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	if err := os.MkdirAll(filepath.Join(h.config.ResolvedSitesPath, "example.com", "user"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	w := postForm(h.CreateSnapshot, "/api/snapshots", "", url.Values{"workspace": {"root"}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-site workspace, got %d", w.Code)
+	}
+	w = postForm(h.RestoreSnapshot, "/api/snapshots/restore", "", url.Values{"workspace": {"site:example.com"}, "id": {"20990101T000000-00000000"}, "path": {"../../etc"}})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for traversal, got %d body=%s", w.Code, w.Body.String())
+	}
+	w = postForm(h.RestoreSnapshot, "/api/snapshots/restore", "", url.Values{"workspace": {"site:example.com"}, "id": {"20990101T000000-00000000"}})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown snapshot, got %d", w.Code)
+	}
+}
@@ -0,0 +1,425 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+const (
+	// DefaultTailLines is how many trailing lines are sent when a tail starts.
+	DefaultTailLines = 100
+
+	// MaxTailLines caps the initial backlog of a tail.
+	MaxTailLines = 5000
+
+	// MaxTailLineLength truncates very long lines (e.g. minified output).
+	MaxTailLineLength = 16 * 1024
+
+	// MaxTailFilterLength limits the size of a filter expression.
+	MaxTailFilterLength = 512
+
+	// maxTailBacklogBytes bounds how much of the file is scanned for the initial lines.
+	maxTailBacklogBytes = 4 << 20
+
+	// maxTailReadBytes bounds how much new data is read per poll.
+	maxTailReadBytes = 1 << 20
+)
+
+// Tail timing; variables so tests can shorten them.
+var (
+	tailPollInterval      = 500 * time.Millisecond
+	tailSessionInterval   = 5 * time.Second
+	tailHeartbeatInterval = 15 * time.Second
+)
+
+// tailFilter decides which lines are sent to the client.
+type tailFilter struct {
+	substring string
+	pattern   *regexp.Regexp
+}
+
+func newTailFilter(expr string, isRegex bool) (*tailFilter, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	if len(expr) > MaxTailFilterLength {
+		return nil, fmt.Errorf("filter too long (max %d)", MaxTailFilterLength)
+	}
+	if !isRegex {
+		return &tailFilter{substring: expr}, nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return &tailFilter{pattern: pattern}, nil
+}
+
+func (f *tailFilter) match(line string) bool {
+	if f == nil {
+		return true
+	}
+	if f.pattern != nil {
+		return f.pattern.MatchString(line)
+	}
+	return strings.Contains(line, f.substring)
+}
+
+// tailEvent is the payload of a "line" event.
+type tailEvent struct {
+	Line string `json:"line"`
+}
+
+// TailFile handles GET /api/files/tail?workspace=X&path=Y&lines=N&filter=F&regex=1.
+// It streams the file as Server-Sent Events until the client disconnects or the
+// session that opened the stream is no longer valid.
+func (h *Handler) TailFile(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	filePath := query.Get("path")
+	if filePath == "" {
+		response.Error(w, http.StatusBadRequest, "No file path provided")
+		return
+	}
+
+	lines := DefaultTailLines
+	if raw := query.Get("lines"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid lines parameter")
+			return
+		}
+		lines = min(parsed, MaxTailLines)
+	}
+
+	isRegex := query.Get("regex") == "1" || query.Get("regex") == "true"
+	filter, err := newTailFilter(query.Get("filter"), isRegex)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, filePath)
+	if err != nil {
+		filesLog.Warn("Tail path resolution failed for %s: %v", filePath, err)
+		h.handlePathError(w, err)
+		return
+	}
+
+	file, info, err := openTailFile(resolvedPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			response.Error(w, http.StatusNotFound, "File not found")
+			return
+		}
+		if errors.Is(err, errNotRegularFile) {
+			response.Error(w, http.StatusBadRequest, "Only regular files can be tailed")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to open file")
+		return
+	}
+
+	t := &tailer{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		path:   resolvedPath,
+		file:   file,
+		inode:  inodeOf(info),
+		filter: filter,
+		// A rotated-in file is resolved again, so swapping the log for a
+		// symlink cannot redirect the stream outside the workspace.
+		reopen: func() (*os.File, os.FileInfo, error) {
+			_, current, err := h.resolver.ResolveForWorkspace(workspaceID, filePath)
+			if err != nil && os.IsNotExist(err) {
+				return nil, nil, err
+			}
+			if err != nil || current != resolvedPath {
+				return nil, nil, errTailPathChanged
+			}
+			return openTailFile(current)
+		},
+	}
+	defer func() { t.file.Close() }()
+
+	// The stream outlives the server's write timeout.
+	if err := t.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		filesLog.Debug("Failed to clear write deadline for tail: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	backlog, offset, err := readLastLines(file, info.Size(), lines)
+	if err != nil {
+		t.sendEvent("error", map[string]string{"message": "Failed to read file"})
+		return
+	}
+	t.offset = offset
+	for _, line := range backlog {
+		t.sendLine(line)
+	}
+	if err := t.flush(); err != nil {
+		return
+	}
+
+	token := httpxmiddleware.GetSessionToken(r)
+	filesLog.Info("Tail started: %s (workspace=%s)", resolvedPath, workspaceID)
+	defer filesLog.Info("Tail stopped: %s", resolvedPath)
+
+	poll := time.NewTicker(tailPollInterval)
+	defer poll.Stop()
+	sessionCheck := time.NewTicker(tailSessionInterval)
+	defer sessionCheck.Stop()
+	heartbeat := time.NewTicker(tailHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sessionCheck.C:
+			if token == "" || !h.sessions.Valid(token) {
+				t.sendEvent("end", map[string]string{"reason": "session_expired"})
+				t.flush()
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := t.flush(); err != nil {
+				return
+			}
+		case <-poll.C:
+			if err := t.poll(); errors.Is(err, errTailPathChanged) {
+				filesLog.Warn("Tail of %s stopped: path now resolves elsewhere", resolvedPath)
+				t.sendEvent("end", map[string]string{"reason": "path_changed"})
+				t.flush()
+				return
+			} else if err != nil {
+				filesLog.Warn("Tail of %s failed: %v", resolvedPath, err)
+				t.sendEvent("error", map[string]string{"message": "Failed to read file"})
+				t.flush()
+				return
+			}
+			if err := t.flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+var (
+	errNotRegularFile  = errors.New("not a regular file")
+	errTailPathChanged = errors.New("tailed path resolves elsewhere")
+)
+
+// openTailFile opens a resolved path without following a symlink put there
+// since it was resolved.
+func openTailFile(path string) (*os.File, os.FileInfo, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if errors.Is(err, syscall.ELOOP) {
+		return nil, nil, errNotRegularFile
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, errNotRegularFile
+	}
+	return file, info, nil
+}
+
+func inodeOf(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Ino
+	}
+	return 0
+}
+
+// tailer follows one open file and writes SSE events for it.
+type tailer struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	path    string
+	file    *os.File
+	inode   uint64
+	offset  int64
+	partial []byte
+	filter  *tailFilter
+	reopen  func() (*os.File, os.FileInfo, error)
+}
+
+// poll reads newly appended data and handles truncation and rotation.
+func (t *tailer) poll() error {
+	info, err := os.Lstat(t.path)
+	switch {
+	case os.IsNotExist(err):
+		// Rotated away and not recreated yet; keep draining the old handle.
+		return t.readNew()
+	case err != nil:
+		return err
+	}
+
+	if inode := inodeOf(info); inode != t.inode {
+		// Drain what was written to the old file before switching over.
+		if err := t.readNew(); err != nil {
+			return err
+		}
+		t.flushPartial()
+
+		file, newInfo, err := t.reopen()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if errors.Is(err, errNotRegularFile) {
+				return errTailPathChanged
+			}
+			return err
+		}
+		t.file.Close()
+		t.file = file
+		t.inode = inodeOf(newInfo)
+		t.offset = 0
+		t.sendEvent("rotated", map[string]string{})
+		return t.readNew()
+	}
+
+	if info.Size() < t.offset {
+		t.partial = nil
+		t.offset = 0
+		t.sendEvent("truncated", map[string]string{})
+	}
+	return t.readNew()
+}
+
+func (t *tailer) readNew() error {
+	buf := make([]byte, 32*1024)
+	read := 0
+	for read < maxTailReadBytes {
+		n, err := t.file.ReadAt(buf, t.offset)
+		if n > 0 {
+			t.offset += int64(n)
+			read += n
+			t.consume(buf[:n])
+		}
+		if err == io.EOF || n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tailer) consume(data []byte) {
+	for len(data) > 0 {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			t.partial = append(t.partial, data...)
+			if len(t.partial) >= MaxTailLineLength {
+				t.flushPartial()
+			}
+			return
+		}
+		t.partial = append(t.partial, data[:idx]...)
+		t.flushPartial()
+		data = data[idx+1:]
+	}
+}
+
+func (t *tailer) flushPartial() {
+	if len(t.partial) == 0 {
+		return
+	}
+	t.sendLine(string(t.partial))
+	t.partial = t.partial[:0]
+}
+
+func (t *tailer) sendLine(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	if len(line) > MaxTailLineLength {
+		line = line[:MaxTailLineLength]
+	}
+	if !t.filter.match(line) {
+		return
+	}
+	t.sendEvent("line", tailEvent{Line: line})
+}
+
+func (t *tailer) sendEvent(event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(t.w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+func (t *tailer) flush() error {
+	return t.rc.Flush()
+}
+
+// readLastLines returns up to n complete trailing lines and the offset to continue from.
+func readLastLines(file *os.File, size int64, n int) ([]string, int64, error) {
+	if n == 0 || size == 0 {
+		return nil, size, nil
+	}
+
+	start := max(size-maxTailBacklogBytes, 0)
+	data := make([]byte, size-start)
+	if _, err := file.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+
+	// An unterminated last line is left for the follow loop to complete.
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, start, nil
+	}
+	offset := start + int64(end) + 1
+	data = data[:end]
+	if start > 0 {
+		// Drop the partial first line.
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			data = data[idx+1:]
+		} else {
+			return nil, offset, nil
+		}
+	}
+
+	parts := bytes.Split(data, []byte("\n"))
+	if len(parts) > n {
+		parts = parts[len(parts)-n:]
+	}
+	lines := make([]string, len(parts))
+	for i, part := range parts {
+		lines[i] = string(part)
+	}
+	return lines, offset, nil
+}
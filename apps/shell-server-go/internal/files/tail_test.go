@@ -0,0 +1,222 @@
+package files
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents parses SSE events from a stream and forwards them on a channel.
+func readEvents(t *testing.T, resp *http.Response) <-chan sseEvent {
+	t.Helper()
+	events := make(chan sseEvent, 64)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				current.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				current.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && current.name != "":
+				events <- current
+				current = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatalf("stream closed unexpectedly")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+	return sseEvent{}
+}
+
+func startTail(t *testing.T, h *Handler, token, query string) *http.Response {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(h.TailFile))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/files/tail?"+query, nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("tail request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func shortenTailIntervals(t *testing.T) {
+	t.Helper()
+	poll, sessionCheck := tailPollInterval, tailSessionInterval
+	tailPollInterval = 10 * time.Millisecond
+	tailSessionInterval = 20 * time.Millisecond
+	t.Cleanup(func() {
+		tailPollInterval, tailSessionInterval = poll, sessionCheck
+	})
+}
+
+func TestTailFile_FollowsAppendsTruncationAndRotation(t *testing.T) {
+	shortenTailIntervals(t)
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	logPath := filepath.Join(h.config.ResolvedUploadCwd, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{})
+	resp := startTail(t, h, token, "workspace=root&path=app.log&lines=2")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+	events := readEvents(t, resp)
+
+	for _, want := range []string{"two", "three"} {
+		if ev := nextEvent(t, events); ev.name != "line" || ev.data != `{"line":"`+want+`"}` {
+			t.Fatalf("expected line %q, got %+v", want, ev)
+		}
+	}
+
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	f.WriteString("fou")
+	f.Sync()
+	time.Sleep(30 * time.Millisecond)
+	f.WriteString("r\n")
+	f.Close()
+	if ev := nextEvent(t, events); ev.data != `{"line":"four"}` {
+		t.Fatalf("expected appended line, got %+v", ev)
+	}
+
+	if err := os.WriteFile(logPath, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("truncate log: %v", err)
+	}
+	if ev := nextEvent(t, events); ev.name != "truncated" {
+		t.Fatalf("expected truncated event, got %+v", ev)
+	}
+	if ev := nextEvent(t, events); ev.data != `{"line":"x"}` {
+		t.Fatalf("expected line after truncation, got %+v", ev)
+	}
+
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatalf("rotate log: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte("fresh\n"), 0644); err != nil {
+		t.Fatalf("recreate log: %v", err)
+	}
+	if ev := nextEvent(t, events); ev.name != "rotated" {
+		t.Fatalf("expected rotated event, got %+v", ev)
+	}
+	if ev := nextEvent(t, events); ev.data != `{"line":"fresh"}` {
+		t.Fatalf("expected line from new file, got %+v", ev)
+	}
+
+	sessions.Delete(token)
+	if ev := nextEvent(t, events); ev.name != "end" {
+		t.Fatalf("expected end event after session removal, got %+v", ev)
+	}
+}
+
+func TestTailFile_StopsWhenRotatedIntoSymlinkOutsideWorkspace(t *testing.T) {
+	shortenTailIntervals(t)
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	logPath := filepath.Join(h.config.ResolvedUploadCwd, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("root:secret\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{})
+	events := readEvents(t, startTail(t, h, token, "workspace=root&path=app.log"))
+	if ev := nextEvent(t, events); ev.data != `{"line":"one"}` {
+		t.Fatalf("expected backlog line, got %+v", ev)
+	}
+
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatalf("rotate log: %v", err)
+	}
+	if err := os.Symlink(secret, logPath); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	ev := nextEvent(t, events)
+	if ev.name != "end" || !strings.Contains(ev.data, "path_changed") {
+		t.Fatalf("expected end event, got %+v", ev)
+	}
+	for ev := range events {
+		if strings.Contains(ev.data, "secret") {
+			t.Fatalf("streamed file outside the workspace: %+v", ev)
+		}
+	}
+}
+
+func TestTailFile_AppliesFilter(t *testing.T) {
+	shortenTailIntervals(t)
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	logPath := filepath.Join(h.config.ResolvedUploadCwd, "app.log")
+	if err := os.WriteFile(logPath, []byte("INFO a\nERROR b\nINFO c\nERROR d\n"), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{})
+	events := readEvents(t, startTail(t, h, token, "workspace=root&path=app.log&filter=%5EERROR&regex=1"))
+
+	for _, want := range []string{"ERROR b", "ERROR d"} {
+		if ev := nextEvent(t, events); ev.data != `{"line":"`+want+`"}` {
+			t.Fatalf("expected %q, got %+v", want, ev)
+		}
+	}
+}
+
+func TestTailFile_RejectsTraversalAndBadFilter(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	for _, query := range []string{"workspace=root&path=../secret.log", "workspace=root&path=app.log&filter=(&regex=1"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/tail?"+query, nil)
+		w := httptest.NewRecorder()
+		h.TailFile(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d body=%s", query, w.Code, w.Body.String())
+		}
+	}
+}
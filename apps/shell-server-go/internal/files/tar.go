@@ -0,0 +1,405 @@
+package files
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"shell-server-go/internal/fsutil"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/scan"
+)
+
+// Upload archive kinds recognised by extension.
+const (
+	archiveKindZip   = "zip"
+	archiveKindTar   = "tar"
+	archiveKindTarGz = "tar.gz"
+	archiveKindTarZs = "tar.zst"
+)
+
+// uploadArchiveKind returns the archive kind for an uploaded filename, or "" for
+// files that are stored as-is.
+func uploadArchiveKind(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveKindZip
+	case strings.HasSuffix(name, ".tar"):
+		return archiveKindTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveKindTarGz
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return archiveKindTarZs
+	}
+	return ""
+}
+
+// errUnsafeTarEntry marks entries that must never be extracted.
+var errUnsafeTarEntry = errors.New("unsafe tar entry")
+
+// tarArchive opens a tar stream over a plain, gzip or zstd compressed file.
+type tarArchive struct {
+	file   *os.File
+	closer io.Closer
+	reader *tar.Reader
+}
+
+func openTarArchive(tempPath, kind string) (*tarArchive, error) {
+	file, err := os.Open(tempPath)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &tarArchive{file: file}
+	var src io.Reader = file
+	switch kind {
+	case archiveKindTarGz:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		archive.closer = gz
+		src = gz
+	case archiveKindTarZs:
+		zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		archive.closer = zr.IOReadCloser()
+		src = zr
+	}
+	archive.reader = tar.NewReader(src)
+	return archive, nil
+}
+
+func (a *tarArchive) Close() {
+	if a.closer != nil {
+		a.closer.Close()
+	}
+	a.file.Close()
+}
+
+// tarEntryName normalises an entry name; "" means the entry is the archive root.
+func tarEntryName(name string) string {
+	cleaned := path.Clean("/" + strings.TrimPrefix(name, "./"))
+	return strings.TrimPrefix(cleaned, "/")
+}
+
+// checkTarEntryType rejects hardlinks, devices, FIFOs and symlinks that point
+// outside the extraction directory.
+func checkTarEntryType(hdr *tar.Header, name string) error {
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeDir:
+		return nil
+	case tar.TypeSymlink:
+		if hdr.Linkname == "" || path.IsAbs(hdr.Linkname) {
+			return fmt.Errorf("%w: absolute symlink %s -> %s", errUnsafeTarEntry, name, hdr.Linkname)
+		}
+		target := path.Join(path.Dir(name), hdr.Linkname)
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return fmt.Errorf("%w: symlink %s escapes target -> %s", errUnsafeTarEntry, name, hdr.Linkname)
+		}
+		return nil
+	case tar.TypeLink:
+		return fmt.Errorf("%w: hardlink %s", errUnsafeTarEntry, name)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return fmt.Errorf("%w: device or fifo %s", errUnsafeTarEntry, name)
+	default:
+		return fmt.Errorf("%w: unsupported entry type %q for %s", errUnsafeTarEntry, hdr.Typeflag, name)
+	}
+}
+
+func (h *Handler) handleTarUpload(ctx context.Context, w http.ResponseWriter, resolvedTarget, targetDir, tempPath, kind string, opts uploadOptions, owner *fsutil.Owner) {
+	compressedInfo, err := os.Stat(tempPath)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	archive, err := openTarArchive(tempPath, kind)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tar archive")
+		return
+	}
+
+	// First pass: validate every entry before anything touches the target.
+	var entryCount int
+	var totalSize int64
+	var entries []planEntry
+	for {
+		hdr, err := archive.reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			archive.Close()
+			response.Error(w, http.StatusBadRequest, "Invalid tar archive")
+			return
+		}
+
+		name := tarEntryName(hdr.Name)
+		if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		entryCount++
+		if entryCount > MaxZipEntries {
+			archive.Close()
+			filesLog.Warn("Tar rejected: too many entries (> %d)", MaxZipEntries)
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Archive has too many files (max %d)", MaxZipEntries))
+			return
+		}
+
+		if _, err := h.resolver.ResolveSafePath(resolvedTarget, hdr.Name); err != nil {
+			archive.Close()
+			filesLog.Warn("Tar rejected: path security issue in archive: %s: %v", hdr.Name, err)
+			response.Error(w, http.StatusBadRequest, "Malicious archive detected (path traversal in archive)")
+			return
+		}
+		if err := checkTarEntryType(hdr, name); err != nil {
+			archive.Close()
+			filesLog.Warn("Tar rejected: %v", err)
+			response.Error(w, http.StatusBadRequest, "Malicious archive detected (link or device entry)")
+			return
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			totalSize += hdr.Size
+			if totalSize > MaxZipTotalSize {
+				archive.Close()
+				filesLog.Warn("Tar rejected: total size exceeds limit (%d > %d)", totalSize, MaxZipTotalSize)
+				response.Error(w, http.StatusBadRequest, fmt.Sprintf("Archive uncompressed size too large (max %dMB)", MaxZipTotalSize>>20))
+				return
+			}
+		}
+
+		entry := planEntry{name: name, isDir: hdr.Typeflag == tar.TypeDir}
+		if hdr.Typeflag == tar.TypeReg {
+			entry.size = hdr.Size
+		}
+		entries = append(entries, entry)
+	}
+	archive.Close()
+
+	// Tar has no per-entry compressed size, so the ratio is checked for the whole stream.
+	if kind != archiveKindTar && compressedInfo.Size() > 0 {
+		if ratio := totalSize / compressedInfo.Size(); ratio > MaxZipCompressionRatio {
+			filesLog.Warn("Tar rejected: suspicious compression ratio (%d:1)", ratio)
+			response.Error(w, http.StatusBadRequest, "Malicious archive detected (suspicious compression ratio)")
+			return
+		}
+	}
+
+	plan := planExtraction(resolvedTarget, entries, opts.conflict)
+	plan.owner = owner
+	if opts.dryRun {
+		plan.writeDryRun(w, targetDir)
+		return
+	}
+	if len(plan.existing) > 0 {
+		plan.writeConflict(w, "Cannot extract - items already exist in target", "Delete existing items first, remove them from the archive or choose a conflict mode")
+		return
+	}
+
+	if h.scanner != nil {
+		findings, err := h.scanTarEntries(ctx, tempPath, kind, plan)
+		if !h.checkFindings(w, findings, err, &opts) {
+			return
+		}
+	}
+
+	reservation, ok := h.reserveQuota(w, plan)
+	if !ok {
+		return
+	}
+	if err := owner.MkdirAll(resolvedTarget); err != nil {
+		reservation.Release()
+		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
+		return
+	}
+	if err := plan.setAside(); err != nil {
+		reservation.Release()
+		filesLog.Error("Failed to set aside replaced items in %s: %v", resolvedTarget, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to replace existing items")
+		return
+	}
+
+	fileCount, err := h.extractTar(resolvedTarget, tempPath, kind, plan)
+	if err != nil {
+		filesLog.Error("Tar extraction into %s failed: %v", resolvedTarget, err)
+		plan.rollback()
+		// Whatever was extracted before the failure stays; recount it.
+		reservation.Release()
+		h.quotas.Invalidate(resolvedTarget)
+		if errors.Is(err, errUnsafeTarEntry) {
+			response.Error(w, http.StatusBadRequest, "Malicious archive detected (path traversal in archive)")
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to extract file")
+		return
+	}
+	plan.commit()
+	h.commitQuota(reservation, plan)
+
+	response.JSON(w, http.StatusOK, withFindings(map[string]any{
+		"success":     true,
+		"message":     fmt.Sprintf("Extracted %d files to %s", fileCount, targetDir),
+		"extractedTo": resolvedTarget,
+		"fileCount":   fileCount,
+		"summary":     plan.summary(),
+	}, opts))
+}
+
+// scanTarEntries scans every regular file the plan extracts. A tar stream
+// cannot be reopened at an entry, so each one is spooled to a temp file first.
+func (h *Handler) scanTarEntries(ctx context.Context, tempPath, kind string, plan *extractionPlan) ([]scan.Finding, error) {
+	archive, err := openTarArchive(tempPath, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var findings []scan.Finding
+	for {
+		hdr, err := archive.reader.Next()
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return findings, err
+		}
+		name := tarEntryName(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || name == "" {
+			continue
+		}
+		if _, ok := plan.destination(name); !ok {
+			continue
+		}
+
+		found, err := h.scanSpooled(ctx, name, hdr.Size, archive.reader)
+		findings = append(findings, found...)
+		if err != nil {
+			return findings, err
+		}
+	}
+}
+
+// scanSpooled copies src to a temp file and scans it under name.
+func (h *Handler) scanSpooled(ctx context.Context, name string, size int64, src io.Reader) ([]scan.Finding, error) {
+	spool, err := os.CreateTemp("", "upload-scan-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	_, err = io.Copy(spool, src)
+	spool.Close()
+	if err != nil {
+		return nil, err
+	}
+	return h.scanTarget(ctx, scan.FileTarget(name, spool.Name(), size))
+}
+
+// extractTar is the second pass. Symlinks are created only after every file is
+// written, so no write can pass through a link from the same archive, and each
+// link is checked again once the whole tree exists.
+func (h *Handler) extractTar(resolvedTarget, tempPath, kind string, plan *extractionPlan) (int, error) {
+	archive, err := openTarArchive(tempPath, kind)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	type pendingLink struct{ path, target string }
+	var links []pendingLink
+	fileCount := 0
+	for {
+		hdr, err := archive.reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fileCount, err
+		}
+
+		name := tarEntryName(hdr.Name)
+		if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		if err := checkTarEntryType(hdr, name); err != nil {
+			return fileCount, err
+		}
+		planned, ok := plan.destination(name)
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(resolvedTarget, planned)
+		if err != nil {
+			return fileCount, fmt.Errorf("%w: %v", errUnsafeTarEntry, err)
+		}
+		destPath, err := h.resolver.ResolveSafePath(resolvedTarget, rel)
+		if err != nil {
+			return fileCount, fmt.Errorf("%w: %v", errUnsafeTarEntry, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := plan.owner.MkdirAll(destPath); err != nil {
+				return fileCount, err
+			}
+		case tar.TypeSymlink:
+			links = append(links, pendingLink{path: destPath, target: hdr.Linkname})
+		case tar.TypeReg:
+			if err := plan.owner.MkdirAll(filepath.Dir(destPath)); err != nil {
+				return fileCount, err
+			}
+			if err := plan.owner.CreateFile(destPath, archive.reader, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return fileCount, err
+			}
+		}
+		fileCount++
+	}
+
+	for _, link := range links {
+		if err := plan.owner.MkdirAll(filepath.Dir(link.path)); err != nil {
+			return fileCount, err
+		}
+		if err := os.Symlink(link.target, link.path); err != nil {
+			return fileCount, err
+		}
+		if err := plan.owner.Apply(link.path, 0); err != nil {
+			return fileCount, err
+		}
+	}
+
+	// A link that is lexically inside can still escape through another link
+	// (a -> b/../.. where b is itself a link), so check the real targets.
+	realTarget, err := filepath.EvalSymlinks(resolvedTarget)
+	if err != nil {
+		return fileCount, err
+	}
+	var escaped error
+	for _, link := range links {
+		real, err := filepath.EvalSymlinks(link.path)
+		if err != nil {
+			continue // dangling links cannot be written through
+		}
+		if !isWithinDir(real, realTarget) {
+			os.Remove(link.path)
+			escaped = fmt.Errorf("%w: symlink %s resolves outside target", errUnsafeTarEntry, link.path)
+		}
+	}
+	return fileCount, escaped
+}
@@ -0,0 +1,165 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type tarFixtureEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+func buildTar(t *testing.T, entries []tarFixtureEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644, Size: int64(len(e.body))}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+		if _, err := io.WriteString(tw, e.body); err != nil {
+			t.Fatalf("write body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func uploadArchive(t *testing.T, h *Handler, filename string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadWithFields(t, h, filename, data, nil)
+}
+
+func uploadWithFields(t *testing.T, h *Handler, filename string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if _, ok := fields["workspace"]; !ok {
+		_ = writer.WriteField("workspace", "root")
+	}
+	for key, value := range fields {
+		_ = writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		t.Fatalf("write multipart content: %v", err)
+	}
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.Upload(w, req)
+	return w
+}
+
+func TestUpload_ExtractsCompressedTarballs(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	raw := buildTar(t, []tarFixtureEntry{
+		{name: "./", typeflag: tar.TypeDir},
+		{name: "./site/", typeflag: tar.TypeDir},
+		{name: "./site/index.html", typeflag: tar.TypeReg, body: "<h1>export</h1>"},
+		{name: "./site/current", typeflag: tar.TypeSymlink, linkname: "index.html"},
+	})
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(raw)
+	gw.Close()
+
+	w := uploadArchive(t, h, "export.tgz", gz.Bytes())
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"fileCount":3`) {
+		t.Fatalf("expected tar.gz extraction, got %d body=%s", w.Code, w.Body.String())
+	}
+	content, err := os.ReadFile(filepath.Join(h.config.ResolvedUploadCwd, "site", "current"))
+	if err != nil || string(content) != "<h1>export</h1>" {
+		t.Fatalf("unexpected extracted content %q err=%v", content, err)
+	}
+
+	// The same root item now exists, so a second upload conflicts.
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd writer: %v", err)
+	}
+	w = uploadArchive(t, h, "export.tar.zst", zw.EncodeAll(raw, nil))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"existingItems":["site"]`) {
+		t.Fatalf("expected 409 conflict, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpload_RejectsUnsafeTarEntries(t *testing.T) {
+	cases := map[string][]tarFixtureEntry{
+		"traversal": {{name: "../evil.txt", typeflag: tar.TypeReg, body: "x"}},
+		"hardlink":  {{name: "passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+		"device":    {{name: "null", typeflag: tar.TypeChar}},
+		"absolute":  {{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc"}},
+		"escaping":  {{name: "a/link", typeflag: tar.TypeSymlink, linkname: "../../outside"}},
+		"chained": {
+			{name: "c/d/", typeflag: tar.TypeDir},
+			{name: "c/d/e", typeflag: tar.TypeSymlink, linkname: "../.."},
+			{name: "c/f", typeflag: tar.TypeSymlink, linkname: "d/e/.."},
+		},
+	}
+
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			h, sessions := setupFilesHandler(t)
+			defer sessions.Stop()
+
+			w := uploadArchive(t, h, "bad.tar", buildTar(t, entries))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Malicious archive") {
+				t.Fatalf("expected 400 malicious archive, got %d body=%s", w.Code, w.Body.String())
+			}
+			if _, err := os.Lstat(filepath.Join(h.config.ResolvedUploadCwd, "c", "f")); !os.IsNotExist(err) {
+				t.Fatalf("escaping symlink left behind")
+			}
+		})
+	}
+}
+
+func TestUpload_RejectsTarCompressionBomb(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	raw := buildTar(t, []tarFixtureEntry{{name: "zeros.bin", typeflag: tar.TypeReg, body: strings.Repeat("\x00", 4<<20)}})
+	var gz bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	gw.Write(raw)
+	gw.Close()
+
+	w := uploadArchive(t, h, "bomb.tar.gz", gz.Bytes())
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "compression ratio") {
+		t.Fatalf("expected compression ratio rejection, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(h.config.ResolvedUploadCwd, "zeros.bin")); !os.IsNotExist(err) {
+		t.Fatalf("bomb content was extracted")
+	}
+}
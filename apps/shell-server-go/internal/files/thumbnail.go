@@ -0,0 +1,39 @@
+package files
+
+import (
+	"net/http"
+
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/thumbnail"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// Thumbnail handles GET /api/files/thumbnail?workspace=X&path=Y[&size=N][&v=M].
+// size is rounded up to one of thumbnail.Sizes; passing the file's mtime as v
+// lets the browser cache the response for good.
+func (h *Handler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	filePath := query.Get("path")
+	if workspaceID == "" {
+		response.Error(w, http.StatusBadRequest, "No workspace provided")
+		return
+	}
+	if filePath == "" {
+		response.Error(w, http.StatusBadRequest, "No file path provided")
+		return
+	}
+	size, err := thumbnail.ParseSize(query.Get("size"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, resolvedPath, err := h.resolver.ResolveForWorkspace(workspaceID, filePath)
+	if err != nil {
+		filesLog.Warn("Thumbnail path resolution failed for %s: %v", filePath, err)
+		h.handlePathError(w, err)
+		return
+	}
+	h.thumbs.Serve(w, r, resolvedPath, size)
+}
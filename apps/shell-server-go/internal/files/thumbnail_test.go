@@ -0,0 +1,86 @@
+package files
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shell-server-go/internal/thumbnail"
+)
+
+func TestThumbnail_ServesCachedImageWithValidators(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.thumbs = thumbnail.NewCache(t.TempDir(), thumbnail.DefaultCacheBytes)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 300, 150))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "photo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/files/thumbnail?workspace=root&path=photo.png&size=100", nil)
+	w := httptest.NewRecorder()
+	h.Thumbnail(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Fatalf("unexpected Cache-Control %q", got)
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 64 {
+		t.Fatalf("expected 128x64, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/files/thumbnail?workspace=root&path=photo.png&size=100&v=1", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.Thumbnail(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, max-age=31536000, immutable" {
+		t.Fatalf("versioned request should be immutable, got %q", got)
+	}
+}
+
+func TestThumbnail_RejectsNonImagesAndTraversal(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.thumbs = thumbnail.NewCache(t.TempDir(), thumbnail.DefaultCacheBytes)
+
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "notes.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	cases := []struct {
+		query string
+		code  int
+	}{
+		{"workspace=root&path=notes.txt", http.StatusUnsupportedMediaType},
+		{"workspace=root&path=missing.png", http.StatusNotFound},
+		{"workspace=root&path=notes.txt&size=5000", http.StatusBadRequest},
+		{"workspace=root&path=../../etc/passwd", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		h.Thumbnail(w, httptest.NewRequest(http.MethodGet, "/api/files/thumbnail?"+tc.query, nil))
+		if w.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d body=%s", tc.query, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	"shell-server-go/internal/trash"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// handleTrashError maps trash store errors to responses.
+func handleTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		response.Error(w, http.StatusNotFound, "Trash item not found")
+	case errors.Is(err, trash.ErrInvalidWorkspace):
+		response.Error(w, http.StatusBadRequest, "Invalid workspace")
+	default:
+		filesLog.Error("Trash operation failed: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Trash operation failed")
+	}
+}
+
+// ListTrash handles GET /api/trash?workspace=X.
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	if _, err := h.resolver.ResolveWorkspaceBase(workspaceID); err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	items, err := h.trash.List(workspaceID)
+	if err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{
+		"items":         items,
+		"retentionDays": int(h.trash.Retention().Hours() / 24),
+	})
+}
+
+// RestoreTrash handles POST /api/trash/restore (form: workspace, id).
+// The item goes back to its original path, which is validated like a new write.
+func (h *Handler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspaceID := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	id := r.FormValue("id")
+	if id == "" {
+		response.Error(w, http.StatusBadRequest, "No trash item provided")
+		return
+	}
+
+	item, err := h.trash.Get(workspaceID, id)
+	if err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	dest, err := h.resolveEntryPath(workspaceID, item.OriginalPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	var reservation *quota.Reservation
+	if h.trashLeavesSite(workspaceID, dest) {
+		if reservation, err = h.quotas.Reserve(dest, item.Size, 1); err != nil {
+			quota.HandleError(w, err)
+			return
+		}
+	}
+
+	// Parents that no longer exist are recreated for the workspace owner.
+	if err := h.workspaceOwner(workspaceID).MkdirAll(filepath.Dir(dest)); err != nil {
+		reservation.Release()
+		response.Error(w, http.StatusInternalServerError, "Failed to create directory")
+		return
+	}
+	if _, err := h.trash.Restore(workspaceID, id, dest); err != nil {
+		reservation.Release()
+		if errors.Is(err, trash.ErrExists) {
+			response.JSON(w, http.StatusConflict, map[string]any{
+				"error":         "Cannot restore - original path already exists",
+				"existingItems": []string{item.OriginalPath},
+				"hint":          "Move or delete the existing item first",
+			})
+			return
+		}
+		handleTrashError(w, err)
+		return
+	}
+
+	reservation.Commit()
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"success":      true,
+		"message":      "Restored " + item.OriginalPath,
+		"restoredPath": item.OriginalPath,
+		"type":         item.Type,
+	})
+}
+
+// PurgeTrash handles POST /api/trash/purge (form: workspace, id or all=true).
+func (h *Handler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspaceID := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	if _, err := h.resolver.ResolveWorkspaceBase(workspaceID); err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	if r.FormValue("all") == "true" || r.FormValue("all") == "1" {
+		purged, err := h.trash.PurgeAll(workspaceID)
+		if err != nil {
+			handleTrashError(w, err)
+			return
+		}
+		response.JSON(w, http.StatusOK, map[string]any{"success": true, "purged": purged})
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		response.Error(w, http.StatusBadRequest, "No trash item provided")
+		return
+	}
+	if err := h.trash.Purge(workspaceID, id); err != nil {
+		handleTrashError(w, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "purged": 1})
+}
@@ -0,0 +1,139 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+	"shell-server-go/internal/trash"
+)
+
+func postForm(handler http.HandlerFunc, target, token string, values url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func listTrashItems(t *testing.T, h *Handler, token, workspace string) []trash.Item {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/trash?workspace="+url.QueryEscape(workspace), nil)
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	}
+	w := httptest.NewRecorder()
+	h.ListTrash(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list trash: %d %s", w.Code, w.Body.String())
+	}
+	var payload struct {
+		Items []trash.Item `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return payload.Items
+}
+
+func TestDeleteFolder_MovesToTrashAndRestores(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	siteBase := filepath.Join(h.config.ResolvedSitesPath, "example.com", "user")
+	if err := os.MkdirAll(filepath.Join(siteBase, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(siteBase, "src", "index.ts"), []byte("code"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	token := sessions.GenerateWithInfo(session.SessionInfo{Workspace: "site:example.com"})
+
+	w := postForm(h.DeleteFolder, "/api/delete-folder", token, url.Values{"path": {"src"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"trashId"`) {
+		t.Fatalf("expected trashed delete, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(siteBase, "src")); !os.IsNotExist(err) {
+		t.Fatalf("deleted folder still present")
+	}
+
+	items := listTrashItems(t, h, token, "site:example.com")
+	if len(items) != 1 || items[0].OriginalPath != "src" || items[0].Session != trash.SessionHash(token) {
+		t.Fatalf("unexpected trash items %+v", items)
+	}
+	// A scoped session only ever sees its own site's trash.
+	if other := listTrashItems(t, h, token, "root"); len(other) != 1 {
+		t.Fatalf("scoped session was not pinned to its workspace: %+v", other)
+	}
+	if root := listTrashItems(t, h, "", "root"); len(root) != 0 {
+		t.Fatalf("site item visible in root trash: %+v", root)
+	}
+
+	if err := os.MkdirAll(filepath.Join(siteBase, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	w = postForm(h.RestoreTrash, "/api/trash/restore", token, url.Values{"id": {items[0].ID}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while original path exists, got %d", w.Code)
+	}
+	os.Remove(filepath.Join(siteBase, "src"))
+
+	w = postForm(h.RestoreTrash, "/api/trash/restore", token, url.Values{"id": {items[0].ID}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected restore, got %d body=%s", w.Code, w.Body.String())
+	}
+	if content, err := os.ReadFile(filepath.Join(siteBase, "src", "index.ts")); err != nil || string(content) != "code" {
+		t.Fatalf("restored content %q err=%v", content, err)
+	}
+}
+
+func TestDeleteFolder_PermanentAndPurge(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	root := h.config.ResolvedUploadCwd
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	w := postForm(h.DeleteFolder, "/api/delete-folder", "", url.Values{"workspace": {"root"}, "path": {"a.txt"}, "permanent": {"true"}})
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "trashId") {
+		t.Fatalf("expected permanent delete, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	for _, name := range []string{"b.txt", "c.txt"} {
+		if w := postForm(h.DeleteFolder, "/api/delete-folder", "", url.Values{"workspace": {"root"}, "path": {name}}); w.Code != http.StatusOK {
+			t.Fatalf("delete %s: %d", name, w.Code)
+		}
+	}
+	items := listTrashItems(t, h, "", "root")
+	if len(items) != 2 {
+		t.Fatalf("expected 2 trash items, got %+v", items)
+	}
+
+	if w := postForm(h.PurgeTrash, "/api/trash/purge", "", url.Values{"workspace": {"root"}, "id": {items[0].ID}}); w.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", w.Code, w.Body.String())
+	}
+	if w := postForm(h.PurgeTrash, "/api/trash/purge", "", url.Values{"workspace": {"root"}, "id": {"../../x"}}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for bad id, got %d", w.Code)
+	}
+	w = postForm(h.PurgeTrash, "/api/trash/purge", "", url.Values{"workspace": {"root"}, "all": {"true"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"purged":1`) {
+		t.Fatalf("purge all: %d %s", w.Code, w.Body.String())
+	}
+	if items := listTrashItems(t, h, "", "root"); len(items) != 0 {
+		t.Fatalf("trash not empty: %+v", items)
+	}
+}
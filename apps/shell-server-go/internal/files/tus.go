@@ -0,0 +1,608 @@
+package files
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"shell-server-go/internal/fsutil"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/quota"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+const (
+	// TusVersion is the only tus protocol version supported.
+	TusVersion = "1.0.0"
+
+	// TusExtensions lists the tus extensions implemented by the upload endpoints.
+	TusExtensions = "creation,expiration,checksum,termination"
+
+	// TusChecksumAlgorithms lists the accepted Upload-Checksum algorithms.
+	TusChecksumAlgorithms = "sha1,sha256,md5"
+
+	// MaxResumableUploadSize caps the declared Upload-Length of one upload.
+	MaxResumableUploadSize = 2 << 30
+
+	// ResumableUploadExpiry is how long an upload survives without a PATCH.
+	ResumableUploadExpiry = 24 * time.Hour
+
+	// StatusChecksumMismatch is the tus status for a failed Upload-Checksum.
+	StatusChecksumMismatch = 460
+
+	maxUploadMetadataLength = 4096
+)
+
+var uploadIDRegex = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// Resumable upload errors.
+var (
+	errUploadNotFound = errors.New("upload not found")
+	errUploadLocked   = errors.New("upload is locked by another request")
+)
+
+// resumableUpload is the persisted state of one tus upload. The bytes received
+// so far live in a sibling data file whose size is the current offset.
+type resumableUpload struct {
+	ID          string            `json:"id"`
+	Length      int64             `json:"length"`
+	Metadata    map[string]string `json:"metadata"`
+	Workspace   string            `json:"workspace"`
+	TargetDir   string            `json:"targetDir"`
+	Filename    string            `json:"filename"`
+	Name        string            `json:"name,omitempty"`
+	SessionHash string            `json:"sessionHash"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	ExpiresAt   time.Time         `json:"expiresAt"`
+}
+
+// resumableStore keeps tus uploads on disk so they survive server restarts.
+// The directory is server state: a file someone else could edit there would
+// choose the workspace and target of a finished upload.
+type resumableStore struct {
+	dir string
+
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func newResumableStore(dir string) *resumableStore {
+	return &resumableStore{dir: dir, locked: make(map[string]bool)}
+}
+
+func (s *resumableStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *resumableStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *resumableStore) create(upload *resumableUpload) error {
+	if err := fsutil.PrivateDir(s.dir); err != nil {
+		return fmt.Errorf("create upload dir: %w", err)
+	}
+	data, err := os.OpenFile(s.dataPath(upload.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return fmt.Errorf("create upload data: %w", err)
+	}
+	data.Close()
+	return s.save(upload)
+}
+
+func (s *resumableStore) save(upload *resumableUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(upload.ID))
+}
+
+func (s *resumableStore) load(id string) (*resumableUpload, error) {
+	if !uploadIDRegex.MatchString(id) {
+		return nil, errUploadNotFound
+	}
+	data, err := readNoFollow(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	var upload resumableUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		s.remove(id)
+		return nil, errUploadNotFound
+	}
+	return &upload, nil
+}
+
+func (s *resumableStore) offset(id string) (int64, error) {
+	info, err := os.Lstat(s.dataPath(id))
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, errNotRegularFile
+	}
+	return info.Size(), nil
+}
+
+// openData opens the data file of an upload for appending. Symlinks are not
+// followed.
+func (s *resumableStore) openData(id string) (*os.File, error) {
+	return os.OpenFile(s.dataPath(id), os.O_WRONLY|os.O_APPEND|syscall.O_NOFOLLOW, 0600)
+}
+
+func readNoFollow(path string) ([]byte, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (s *resumableStore) remove(id string) {
+	os.Remove(s.dataPath(id))
+	os.Remove(s.infoPath(id))
+}
+
+// lock serializes PATCH/DELETE requests for one upload.
+func (s *resumableStore) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked[id] {
+		return false
+	}
+	s.locked[id] = true
+	return true
+}
+
+func (s *resumableStore) unlock(id string) {
+	s.mu.Lock()
+	delete(s.locked, id)
+	s.mu.Unlock()
+}
+
+// pruneExpired removes uploads whose expiry has passed. It is called
+// opportunistically when new uploads are created.
+func (s *resumableStore) pruneExpired(now time.Time) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".info")
+		if !ok || !uploadIDRegex.MatchString(id) {
+			continue
+		}
+		data, err := readNoFollow(s.infoPath(id))
+		if err != nil {
+			continue
+		}
+		var upload resumableUpload
+		if json.Unmarshal(data, &upload) != nil || now.After(upload.ExpiresAt) {
+			if s.lock(id) {
+				s.remove(id)
+				s.unlock(id)
+				filesLog.Info("Removed expired resumable upload %s", id)
+			}
+		}
+	}
+}
+
+func generateUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header
+// ("key base64value,key2 base64value2").
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	if len(header) > maxUploadMetadataLength {
+		return nil, errors.New("metadata too large")
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func encodeUploadMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseUploadChecksum decodes an Upload-Checksum header into a hash and expected digest.
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, nil, errors.New("malformed Upload-Checksum")
+	}
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("malformed Upload-Checksum")
+	}
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	case "md5":
+		return md5.New(), expected, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+}
+
+func setTusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// checkTusVersion rejects requests that do not speak tus 1.0.0.
+func checkTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Tus-Resumable") == TusVersion {
+		return true
+	}
+	w.Header().Set("Tus-Version", TusVersion)
+	response.Error(w, http.StatusPreconditionFailed, "Unsupported tus version")
+	return false
+}
+
+// loadOwnedUpload returns an upload only if it belongs to the requesting session.
+// Uploads of other sessions are reported as missing.
+func (h *Handler) loadOwnedUpload(w http.ResponseWriter, r *http.Request) (*resumableUpload, bool) {
+	upload, err := h.uploads.load(r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, errUploadNotFound) {
+			filesLog.Error("Failed to load upload %s: %v", r.PathValue("id"), err)
+		}
+		response.Error(w, http.StatusNotFound, "Upload not found")
+		return nil, false
+	}
+	if upload.SessionHash != hashSessionToken(httpxmiddleware.GetSessionToken(r)) {
+		response.Error(w, http.StatusNotFound, "Upload not found")
+		return nil, false
+	}
+	return upload, true
+}
+
+// TusOptions handles OPTIONS /api/uploads/.
+func (h *Handler) TusOptions(w http.ResponseWriter, _ *http.Request) {
+	setTusHeaders(w)
+	w.Header().Set("Tus-Version", TusVersion)
+	w.Header().Set("Tus-Extension", TusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(MaxResumableUploadSize, 10))
+	w.Header().Set("Tus-Checksum-Algorithm", TusChecksumAlgorithms)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TusCreate handles POST /api/uploads/.
+// Metadata keys: filename (required), workspace, targetDir, name, conflict.
+func (h *Handler) TusCreate(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		response.Error(w, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid Upload-Length")
+		return
+	}
+	if length > MaxResumableUploadSize {
+		response.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload too large (max %dMB)", MaxResumableUploadSize>>20))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid Upload-Metadata")
+		return
+	}
+	filename := metadata["filename"]
+	if !workspacepkg.IsValidFilename(filename) {
+		response.Error(w, http.StatusBadRequest, "Invalid filename")
+		return
+	}
+
+	if _, err := parseConflictMode(metadata["conflict"]); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workspaceID := workspacepkg.SessionWorkspace(r, h.sessions)
+	if workspaceID == "" {
+		workspaceID = metadata["workspace"]
+	}
+	if workspaceID == "" {
+		workspaceID = "root"
+	}
+	targetDir := metadata["targetDir"]
+	if targetDir == "" {
+		targetDir = "./"
+	}
+
+	// Fail early on bad targets and full sites instead of after the whole file was sent.
+	_, resolvedTarget, err := h.resolver.ResolveForWorkspace(workspaceID, targetDir)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if err := h.quotas.Check(resolvedTarget, length, 1); err != nil {
+		quota.HandleError(w, err)
+		return
+	}
+
+	h.uploads.pruneExpired(time.Now())
+
+	id, err := generateUploadID()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+	now := time.Now()
+	upload := &resumableUpload{
+		ID:          id,
+		Length:      length,
+		Metadata:    metadata,
+		Workspace:   workspaceID,
+		TargetDir:   targetDir,
+		Filename:    filename,
+		Name:        metadata["name"],
+		SessionHash: hashSessionToken(httpxmiddleware.GetSessionToken(r)),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ResumableUploadExpiry),
+	}
+	if err := h.uploads.create(upload); err != nil {
+		filesLog.Error("Failed to create resumable upload: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	filesLog.Info("Resumable upload created: %s (%s, %d bytes, workspace=%s)", id, filename, length, workspaceID)
+	w.Header().Set("Location", "/api/uploads/"+id)
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Upload-Offset", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// TusHead handles HEAD /api/uploads/{id}.
+func (h *Handler) TusHead(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	upload, ok := h.loadOwnedUpload(w, r)
+	if !ok {
+		return
+	}
+	offset, err := h.uploads.offset(upload.ID)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if len(upload.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", encodeUploadMetadata(upload.Metadata))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// TusPatch handles PATCH /api/uploads/{id}. When the last byte arrives the file
+// is handed to the regular or ZIP upload path and that JSON response is returned.
+func (h *Handler) TusPatch(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	if !checkTusVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		response.Error(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+
+	upload, ok := h.loadOwnedUpload(w, r)
+	if !ok {
+		return
+	}
+	if !h.uploads.lock(upload.ID) {
+		response.Error(w, http.StatusLocked, errUploadLocked.Error())
+		return
+	}
+	defer h.uploads.unlock(upload.ID)
+
+	offset, err := h.uploads.offset(upload.ID)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+	clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid Upload-Offset")
+		return
+	}
+	if clientOffset != offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		response.Error(w, http.StatusConflict, "Upload-Offset does not match")
+		return
+	}
+
+	var checksum hash.Hash
+	var expected []byte
+	if header := r.Header.Get("Upload-Checksum"); header != "" {
+		checksum, expected, err = parseUploadChecksum(header)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	dataFile, err := h.uploads.openData(upload.ID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to open upload")
+		return
+	}
+
+	remaining := upload.Length - offset
+	var dst io.Writer = dataFile
+	if checksum != nil {
+		dst = io.MultiWriter(dataFile, checksum)
+	}
+	// Read one extra byte so bodies longer than the declared length are detected.
+	written, copyErr := io.Copy(dst, io.LimitReader(r.Body, remaining+1))
+
+	rollback := func() {
+		if err := dataFile.Truncate(offset); err != nil {
+			filesLog.Error("Failed to roll back upload %s: %v", upload.ID, err)
+		}
+	}
+
+	switch {
+	case written > remaining:
+		rollback()
+		dataFile.Close()
+		response.Error(w, http.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+		return
+	case checksum != nil && copyErr == nil && !bytes.Equal(checksum.Sum(nil), expected):
+		rollback()
+		dataFile.Close()
+		response.Error(w, StatusChecksumMismatch, "Checksum mismatch")
+		return
+	case checksum != nil && copyErr != nil:
+		// A partial chunk cannot be verified; discard it.
+		rollback()
+		dataFile.Close()
+		filesLog.Warn("Upload %s chunk interrupted: %v", upload.ID, copyErr)
+		return
+	}
+	// Without a checksum, keep whatever arrived so the client can resume from there.
+	if err := dataFile.Close(); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to save chunk")
+		return
+	}
+
+	newOffset := offset + written
+	upload.ExpiresAt = time.Now().Add(ResumableUploadExpiry)
+	if err := h.uploads.save(upload); err != nil {
+		filesLog.Warn("Failed to extend upload %s expiry: %v", upload.ID, err)
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	if copyErr != nil {
+		filesLog.Warn("Upload %s chunk interrupted at offset %d: %v", upload.ID, newOffset, copyErr)
+		return
+	}
+	if newOffset < upload.Length {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.finishResumableUpload(r, w, upload)
+}
+
+// finishResumableUpload moves a completed upload through the regular upload paths.
+// The upload is removed afterwards whatever the outcome. The stored workspace
+// is checked against the session again, as the session may have been bound to
+// another workspace since the upload was created.
+func (h *Handler) finishResumableUpload(r *http.Request, w http.ResponseWriter, upload *resumableUpload) {
+	defer h.uploads.remove(upload.ID)
+
+	if scope := workspacepkg.SessionWorkspace(r, h.sessions); scope != "" && scope != upload.Workspace {
+		filesLog.Warn("Resumable upload %s targets workspace %s outside session scope %s", upload.ID, upload.Workspace, scope)
+		response.Error(w, http.StatusForbidden, "Upload is outside the session's workspace")
+		return
+	}
+	basePath, resolvedTarget, err := h.resolver.ResolveForWorkspace(upload.Workspace, upload.TargetDir)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+
+	// Validated when the upload was created.
+	mode, _ := parseConflictMode(upload.Metadata["conflict"])
+
+	filesLog.Info("Resumable upload complete: %s (%s)", upload.ID, upload.Filename)
+	h.handleUploadedFile(r.Context(), w, basePath, resolvedTarget, upload.TargetDir, h.uploads.dataPath(upload.ID), upload.Filename, upload.Name, uploadOptions{conflict: mode})
+}
+
+// TusDelete handles DELETE /api/uploads/{id} (termination extension).
+func (h *Handler) TusDelete(w http.ResponseWriter, r *http.Request) {
+	setTusHeaders(w)
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	upload, ok := h.loadOwnedUpload(w, r)
+	if !ok {
+		return
+	}
+	if !h.uploads.lock(upload.ID) {
+		response.Error(w, http.StatusLocked, errUploadLocked.Error())
+		return
+	}
+	defer h.uploads.unlock(upload.ID)
+
+	h.uploads.remove(upload.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+package files
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+)
+
+func tusRequest(method, target, token string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", TusVersion)
+	req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	return req
+}
+
+func tusPatch(h *Handler, id, token, offset, chunk, checksum string) *httptest.ResponseRecorder {
+	req := tusRequest(http.MethodPatch, "/api/uploads/"+id, token, chunk)
+	req.SetPathValue("id", id)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", offset)
+	if checksum != "" {
+		req.Header.Set("Upload-Checksum", checksum)
+	}
+	w := httptest.NewRecorder()
+	h.TusPatch(w, req)
+	return w
+}
+
+func sha1Checksum(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestTus_ResumableUploadLifecycle(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.uploads = newResumableStore(t.TempDir())
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{})
+	other := sessions.GenerateWithInfo(session.SessionInfo{})
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	req := tusRequest(http.MethodPost, "/api/uploads/", token, "")
+	req.Header.Set("Upload-Length", "11")
+	req.Header.Set("Upload-Metadata", "filename "+encode("hello.txt")+",workspace "+encode("root")+",targetDir "+encode("assets"))
+	w := httptest.NewRecorder()
+	h.TusCreate(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d body=%s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	id := strings.TrimPrefix(location, "/api/uploads/")
+	if !uploadIDRegex.MatchString(id) || w.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("unexpected creation headers: %v", w.Header())
+	}
+
+	if w := tusPatch(h, id, token, "0", "hello", sha1Checksum("hello")); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("expected 204 at offset 5, got %d %v body=%s", w.Code, w.Header(), w.Body.String())
+	}
+
+	// A corrupted chunk is rejected and discarded.
+	if w := tusPatch(h, id, token, "5", " WORLD", sha1Checksum(" world")); w.Code != StatusChecksumMismatch {
+		t.Fatalf("expected 460, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := tusPatch(h, id, token, "0", "again", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for stale offset, got %d", w.Code)
+	}
+
+	head := tusRequest(http.MethodHead, location, token, "")
+	head.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	h.TusHead(w, head)
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "5" || w.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("unexpected HEAD response %d %v", w.Code, w.Header())
+	}
+
+	// Uploads are bound to the session that created them.
+	head = tusRequest(http.MethodHead, location, other, "")
+	head.SetPathValue("id", id)
+	w = httptest.NewRecorder()
+	h.TusHead(w, head)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other session, got %d", w.Code)
+	}
+
+	w = tusPatch(h, id, token, "5", " world", sha1Checksum(" world"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"filename":"hello.txt"`) {
+		t.Fatalf("expected upload JSON on completion, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(h.config.ResolvedUploadCwd, "assets", "hello.txt"))
+	if err != nil || string(content) != "hello world" {
+		t.Fatalf("unexpected uploaded content %q err=%v", content, err)
+	}
+	if _, err := os.Stat(h.uploads.dataPath(id)); !os.IsNotExist(err) {
+		t.Fatalf("expected upload data to be removed after completion")
+	}
+}
+
+func TestTus_RejectsMissingVersionAndTraversal(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.uploads = newResumableStore(t.TempDir())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/uploads/", nil)
+	req.Header.Set("Upload-Length", "1")
+	w := httptest.NewRecorder()
+	h.TusCreate(w, req)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("Tus-Version") != TusVersion {
+		t.Fatalf("expected 412 with Tus-Version, got %d %v", w.Code, w.Header())
+	}
+
+	req = tusRequest(http.MethodPost, "/api/uploads/", "", "")
+	req.Header.Set("Upload-Length", "1")
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("x.txt"))+",targetDir "+base64.StdEncoding.EncodeToString([]byte("../outside")))
+	w = httptest.NewRecorder()
+	h.TusCreate(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for traversal target, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestTus_RefusesTamperedUploadState(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	h.uploads = newResumableStore(filepath.Join(t.TempDir(), "uploads"))
+
+	siteDir := filepath.Join(h.config.ResolvedSitesPath, "example.com", "user")
+	if err := os.MkdirAll(siteDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	token := sessions.GenerateWithInfo(session.SessionInfo{Workspace: "site:example.com"})
+
+	create := func() string {
+		t.Helper()
+		req := tusRequest(http.MethodPost, "/api/uploads/", token, "")
+		req.Header.Set("Upload-Length", "5")
+		req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("x.txt")))
+		w := httptest.NewRecorder()
+		h.TusCreate(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d body=%s", w.Code, w.Body.String())
+		}
+		return strings.TrimPrefix(w.Header().Get("Location"), "/api/uploads/")
+	}
+
+	// An edited workspace no longer matches the session and is refused.
+	id := create()
+	if info, err := os.Stat(h.uploads.dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("upload dir is not private: %v err=%v", info, err)
+	}
+	upload, err := h.uploads.load(id)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	upload.Workspace = "root"
+	if err := h.uploads.save(upload); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if w := tusPatch(h, id, token, "0", "hello", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for tampered workspace, got %d body=%s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(h.config.ResolvedUploadCwd, "x.txt")); !os.IsNotExist(err) {
+		t.Fatalf("tampered upload was written to the root workspace")
+	}
+
+	// A data file swapped for a symlink is not written through.
+	id = create()
+	victim := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(victim, []byte("keep"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	os.Remove(h.uploads.dataPath(id))
+	if err := os.Symlink(victim, h.uploads.dataPath(id)); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if w := tusPatch(h, id, token, "0", "hello", ""); w.Code == http.StatusNoContent || w.Code == http.StatusOK {
+		t.Fatalf("expected the symlinked data file to be refused, got %d", w.Code)
+	}
+	if content, _ := os.ReadFile(victim); string(content) != "keep" {
+		t.Fatalf("symlink target was modified: %q", content)
+	}
+}
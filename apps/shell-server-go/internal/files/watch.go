@@ -0,0 +1,56 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/watch"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// WatchFiles handles GET /api/files/watch?workspace=X&path=Y[&showIgnored=1]. It
+// streams changes below the directory as Server-Sent Events (see watch.Serve),
+// so clients can refresh listings without polling list-files. Ignored paths are
+// left out as in listings.
+func (h *Handler) WatchFiles(w http.ResponseWriter, r *http.Request) {
+	workspaceID := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	dirPath := r.URL.Query().Get("path")
+	if dirPath == "" {
+		dirPath = "."
+	}
+
+	basePath, resolvedDir, err := h.resolver.ResolveForWorkspace(workspaceID, dirPath)
+	if err != nil {
+		h.handlePathError(w, err)
+		return
+	}
+	if info, err := os.Stat(resolvedDir); err != nil || !info.IsDir() {
+		response.Error(w, http.StatusNotFound, "Directory not found")
+		return
+	}
+
+	token := httpxmiddleware.GetSessionToken(r)
+	sub, err := h.watches.Subscribe(resolvedDir, watch.Options{
+		IgnoreBase:  basePath,
+		ShowIgnored: isShowIgnored(r.URL.Query().Get),
+		Key:         token,
+	})
+	if errors.Is(err, watch.ErrTooManySubscriptions) {
+		response.Error(w, http.StatusTooManyRequests, "Too many open watches - close some before watching more")
+		return
+	}
+	if err != nil {
+		filesLog.Error("Failed to watch %s: %v", resolvedDir, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to watch directory")
+		return
+	}
+	defer sub.Close()
+
+	filesLog.Info("Watch started: %s (workspace=%s, mode=%s)", resolvedDir, workspaceID, sub.Mode())
+	defer filesLog.Info("Watch stopped: %s", resolvedDir)
+
+	watch.Serve(w, r, sub, func() bool { return token != "" && h.sessions.Valid(token) })
+}
@@ -0,0 +1,59 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+)
+
+func TestWatchFiles_StreamsChanges(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+	if err := os.MkdirAll(filepath.Join(h.config.ResolvedUploadCwd, "site"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(h.WatchFiles))
+	defer server.Close()
+	token := sessions.GenerateWithInfo(session.SessionInfo{})
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/files/watch?workspace=root&path=site", nil)
+	req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("watch request: %v", err)
+	}
+	defer resp.Body.Close()
+	events := readEvents(t, resp)
+
+	if ev := nextEvent(t, events); ev.name != "ready" || !strings.Contains(ev.data, `"mode"`) {
+		t.Fatalf("expected ready event, got %+v", ev)
+	}
+	if err := os.WriteFile(filepath.Join(h.config.ResolvedUploadCwd, "site", "index.html"), []byte("hi"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if ev := nextEvent(t, events); ev.name != "changes" || !strings.Contains(ev.data, `{"type":"create","path":"index.html"}`) {
+		t.Fatalf("expected create event, got %+v", ev)
+	}
+}
+
+func TestWatchFiles_RejectsMissingAndEscapingPaths(t *testing.T) {
+	h, sessions := setupFilesHandler(t)
+	defer sessions.Stop()
+
+	for query, want := range map[string]int{
+		"workspace=root&path=missing": http.StatusNotFound,
+		"workspace=root&path=../..":   http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.WatchFiles(w, httptest.NewRequest(http.MethodGet, "/api/files/watch?"+query, nil))
+		if w.Code != want {
+			t.Fatalf("%s: expected %d, got %d body=%s", query, want, w.Code, w.Body.String())
+		}
+	}
+}
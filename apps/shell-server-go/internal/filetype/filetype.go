@@ -0,0 +1,242 @@
+// Package filetype detects what a file holds from its first bytes and its name.
+// It is shared by the file and editor APIs so both classify files the same way.
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// SniffLen is how many leading bytes Detect looks at.
+const SniffLen = 512
+
+// Kind is the broad class of a file.
+type Kind string
+
+const (
+	KindText   Kind = "text"
+	KindImage  Kind = "image"
+	KindAudio  Kind = "audio"
+	KindVideo  Kind = "video"
+	KindFont   Kind = "font"
+	KindBinary Kind = "binary"
+)
+
+// Info describes a detected file type. Text reports whether the content is
+// UTF-8 text, which is independent of Kind: an SVG is an image and text.
+type Info struct {
+	MIMEType string `json:"mimeType"`
+	Kind     Kind   `json:"kind"`
+	Text     bool   `json:"text"`
+}
+
+// extensionTypes fixes types that mime.TypeByExtension gets wrong or leaves to
+// the system tables (.ts is MPEG transport stream there).
+var extensionTypes = map[string]string{
+	".ts":    "text/x-typescript; charset=utf-8",
+	".tsx":   "text/x-typescript; charset=utf-8",
+	".mts":   "text/x-typescript; charset=utf-8",
+	".jsx":   "text/javascript; charset=utf-8",
+	".mjs":   "text/javascript; charset=utf-8",
+	".cjs":   "text/javascript; charset=utf-8",
+	".md":    "text/markdown; charset=utf-8",
+	".yaml":  "application/yaml",
+	".yml":   "application/yaml",
+	".toml":  "application/toml",
+	".go":    "text/x-go; charset=utf-8",
+	".py":    "text/x-python; charset=utf-8",
+	".rs":    "text/x-rust; charset=utf-8",
+	".sh":    "text/x-shellscript; charset=utf-8",
+	".sql":   "application/sql",
+	".csv":   "text/csv; charset=utf-8",
+	".svg":   "image/svg+xml",
+	".ico":   "image/x-icon",
+	".webp":  "image/webp",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".eot":   "application/vnd.ms-fontobject",
+	".ogg":   "audio/ogg",
+	".m4a":   "audio/mp4",
+	".webm":  "video/webm",
+	".mkv":   "video/x-matroska",
+	".7z":    "application/x-7z-compressed",
+	".rar":   "application/vnd.rar",
+	".gz":    "application/gzip",
+	".tgz":   "application/gzip",
+	".zst":   "application/zstd",
+	".wasm":  "application/wasm",
+	".node":  "application/octet-stream",
+	".so":    "application/octet-stream",
+	".dylib": "application/octet-stream",
+	".dll":   "application/octet-stream",
+	".exe":   "application/octet-stream",
+}
+
+// signatures covers formats http.DetectContentType does not know.
+var signatures = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xca\xfe\xba\xbe"), "application/x-mach-binary"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{[]byte("\x28\xb5\x2f\xfd"), "application/zstd"},
+	{[]byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{[]byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+}
+
+// textualTypes are non-text/* MIME types whose content is text.
+var textualTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"application/yaml":       true,
+	"application/toml":       true,
+	"application/sql":        true,
+	"application/x-sh":       true,
+	"image/svg+xml":          true,
+}
+
+// ByName guesses the type from the file name alone. It is for listings, where
+// reading every file is too expensive; unknown extensions count as text.
+func ByName(name string) Info {
+	mimeType := typeByExtension(name)
+	if mimeType == "" {
+		return Info{Kind: KindText, Text: true}
+	}
+	return Info{MIMEType: mimeType, Kind: kindOf(mimeType), Text: isTextual(mimeType)}
+}
+
+// Detect classifies content from its leading bytes, using the name to refine
+// the MIME type. Magic bytes win over the extension; content without a known
+// signature is text if it is valid UTF-8 without NUL bytes.
+func Detect(name string, head []byte) Info {
+	head = head[:min(len(head), SniffLen)]
+	byExt := typeByExtension(name)
+
+	if sniffed := sniff(head); sniffed != "" {
+		return Info{MIMEType: sniffed, Kind: kindOf(sniffed), Text: false}
+	}
+
+	if isText(head) {
+		mimeType := byExt
+		if mimeType == "" || !isTextual(mimeType) {
+			mimeType = http.DetectContentType(head)
+		}
+		return Info{MIMEType: mimeType, Kind: kindOf(mimeType), Text: true}
+	}
+
+	mimeType := byExt
+	if mimeType == "" || isTextual(mimeType) {
+		mimeType = "application/octet-stream"
+	}
+	return Info{MIMEType: mimeType, Kind: kindOf(mimeType), Text: false}
+}
+
+// DetectFile reads the first SniffLen bytes of path and calls Detect.
+func DetectFile(path string) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+	return DetectReader(file, filepath.Base(path))
+}
+
+// DetectReader sniffs r and rewinds it when it is an io.Seeker.
+func DetectReader(r io.Reader, name string) (Info, error) {
+	var buf [SniffLen]byte
+	n, err := io.ReadFull(r, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Info{}, err
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+	}
+	return Detect(name, buf[:n]), nil
+}
+
+// sniff returns the MIME type of a recognised binary signature, or "".
+func sniff(head []byte) string {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.mimeType
+		}
+	}
+	detected := http.DetectContentType(head)
+	if detected == "application/octet-stream" || isTextual(detected) {
+		return ""
+	}
+	return detected
+}
+
+// isText reports whether head is UTF-8 without NUL bytes. A rune cut off at
+// the end of the sniffed prefix does not count against it.
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	if len(head) == SniffLen {
+		for i := 0; i < utf8.UTFMax-1 && len(head) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(head); r != utf8.RuneError {
+				break
+			}
+			head = head[:len(head)-1]
+		}
+	}
+	return utf8.Valid(head)
+}
+
+func typeByExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if mimeType, ok := extensionTypes[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+func mediaType(mimeType string) string {
+	if parsed, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return parsed
+	}
+	return mimeType
+}
+
+func isTextual(mimeType string) bool {
+	mt := mediaType(mimeType)
+	return strings.HasPrefix(mt, "text/") || textualTypes[mt] ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+func kindOf(mimeType string) Kind {
+	mt := mediaType(mimeType)
+	switch {
+	case strings.HasPrefix(mt, "image/"):
+		return KindImage
+	case strings.HasPrefix(mt, "audio/"), mt == "application/ogg":
+		return KindAudio
+	case strings.HasPrefix(mt, "video/"):
+		return KindVideo
+	case strings.HasPrefix(mt, "font/"), mt == "application/vnd.ms-fontobject":
+		return KindFont
+	case isTextual(mt):
+		return KindText
+	default:
+		return KindBinary
+	}
+}
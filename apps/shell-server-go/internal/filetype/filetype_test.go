@@ -0,0 +1,67 @@
+package filetype
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	cases := []struct {
+		name     string
+		head     []byte
+		mimeType string
+		kind     Kind
+		text     bool
+	}{
+		{"photo.png", png, "image/png", KindImage, false},
+		{"no-extension", png, "image/png", KindImage, false},
+		{"image.png", []byte("actually text"), "text/plain; charset=utf-8", KindText, true},
+		{"index.ts", []byte("export const x = 1\n"), "text/x-typescript; charset=utf-8", KindText, true},
+		{"logo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "image/svg+xml", KindImage, true},
+		{"page.txt", []byte("<html><body>hi</body></html>"), "text/plain; charset=utf-8", KindText, true},
+		{"page", []byte("<html><body>hi</body></html>"), "text/html; charset=utf-8", KindText, true},
+		{"server", []byte("\x7fELF\x02\x01\x01\x00"), "application/x-executable", KindBinary, false},
+		{"clip.ogg", []byte("OggS\x00\x02\x00\x00"), "application/ogg", KindAudio, false},
+		{"clip.webm", []byte("\x1a\x45\xdf\xa3\x01\x00\x00\x00"), "video/webm", KindVideo, false},
+		{"blob", []byte("abc\x00def"), "application/octet-stream", KindBinary, false},
+		{"latin1.txt", []byte("caf\xe9"), "application/octet-stream", KindBinary, false},
+		{"empty", nil, "text/plain; charset=utf-8", KindText, true},
+	}
+	for _, tc := range cases {
+		got := Detect(tc.name, tc.head)
+		if got.MIMEType != tc.mimeType || got.Kind != tc.kind || got.Text != tc.text {
+			t.Errorf("%s: got %+v, want %s/%s/%v", tc.name, got, tc.mimeType, tc.kind, tc.text)
+		}
+	}
+}
+
+func TestDetect_IgnoresRuneCutAtSniffLimit(t *testing.T) {
+	head := []byte(strings.Repeat("a", SniffLen-1) + "é")[:SniffLen]
+	if info := Detect("notes", head); !info.Text {
+		t.Fatalf("expected text, got %+v", info)
+	}
+}
+
+func TestDetectReader_Rewinds(t *testing.T) {
+	r := bytes.NewReader([]byte("hello"))
+	if _, err := DetectReader(r, "a.txt"); err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+	if r.Len() != 5 {
+		t.Fatalf("reader not rewound")
+	}
+}
+
+func TestByName(t *testing.T) {
+	if info := ByName("Makefile"); !info.Text {
+		t.Fatalf("unknown extension should count as text: %+v", info)
+	}
+	if info := ByName("font.woff2"); info.Text || info.Kind != KindFont {
+		t.Fatalf("unexpected %+v", info)
+	}
+	if info := ByName("app.ts"); !info.Text {
+		t.Fatalf(".ts should be text: %+v", info)
+	}
+}
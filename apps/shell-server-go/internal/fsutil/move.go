@@ -0,0 +1,97 @@
+// Package fsutil holds filesystem helpers shared by the file, editor and trash APIs.
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// rename is os.Rename, replaceable in tests to simulate cross-device moves.
+var rename = os.Rename
+
+// Move renames src to dst, copying and deleting when they are on different
+// filesystems. A partial copy is removed if the fallback fails.
+func Move(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := CopyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// CopyTree copies files, directories and symlinks, preserving permissions and,
+// when running as root, ownership. Symlinks are copied as links and never
+// followed. dst must not exist.
+func CopyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			err = os.Symlink(link, target)
+		case info.IsDir():
+			err = os.Mkdir(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			err = copyFile(path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("cannot copy special file %s", path)
+		}
+		if err != nil {
+			return err
+		}
+		return preserveOwner(target, info)
+	})
+}
+
+// preserveOwner gives a copied entry the uid/gid of its source. Only root can
+// do that, so for anyone else copies keep the copying user as owner.
+func preserveOwner(target string, info fs.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(target, int(stat.Uid), int(stat.Gid))
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return err
+	}
+	return dstFile.Close()
+}
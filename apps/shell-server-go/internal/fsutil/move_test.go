@@ -0,0 +1,76 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestMove_FallsBackToCopyAcrossDevices(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "lib"), 0750); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "lib", "util.js"), []byte("util"), 0600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Symlink("lib/util.js", filepath.Join(src, "current")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	defer func() { rename = os.Rename }()
+
+	dst := filepath.Join(root, "archive")
+	if err := Move(src, dst); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source not removed after copy")
+	}
+	info, err := os.Stat(filepath.Join(dst, "lib", "util.js"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("copied file missing or mode changed: %v %v", info, err)
+	}
+	if dirInfo, err := os.Stat(filepath.Join(dst, "lib")); err != nil || dirInfo.Mode().Perm() != 0750 {
+		t.Fatalf("directory mode not preserved: %v %v", dirInfo, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "current")); err != nil || link != "lib/util.js" {
+		t.Fatalf("symlink not preserved: %q %v", link, err)
+	}
+}
+
+func TestMove_RemovesPartialCopyOnFailure(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	fifo := filepath.Join(src, "pipe")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo unsupported: %v", err)
+	}
+
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	defer func() { rename = os.Rename }()
+
+	dst := filepath.Join(root, "dst")
+	if err := Move(src, dst); err == nil {
+		t.Fatalf("expected error for special file")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("partial copy left behind")
+	}
+	if _, err := os.Stat(filepath.Join(src, "a.txt")); err != nil {
+		t.Fatalf("source removed after failed move: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Modes that created entries start from before the umask, as with open(2)
+// and mkdir(2).
+const (
+	FilePerm os.FileMode = 0666
+	DirPerm  os.FileMode = 0777
+)
+
+// Owner is who entries created in a workspace belong to. The server runs as
+// root, so without it uploads and editor writes would be owned by root and the
+// site's own user could not change them.
+//
+// A nil Owner leaves ownership and modes as the process creates them.
+type Owner struct {
+	UID int
+	GID int
+	// Chown is false when entries already get the right owner, or when the
+	// server cannot change ownership because it does not run as root.
+	Chown bool
+	// Umask is cleared from the mode of every created entry.
+	Umask os.FileMode
+}
+
+// Perm returns perm with the umask applied.
+func (o *Owner) Perm(perm os.FileMode) os.FileMode {
+	if o == nil {
+		return perm
+	}
+	return perm.Perm() &^ o.Umask
+}
+
+// Apply gives a newly created file or directory to the owner and sets its mode
+// to perm minus the umask. Symlinks are only chowned. A symlink at path is
+// never followed, even if it replaced the entry after it was created.
+func (o *Owner) Apply(path string, perm os.FileMode) error {
+	if o == nil {
+		return nil
+	}
+	uid, gid := -1, -1
+	if o.Chown {
+		uid, gid = o.UID, o.GID
+	}
+	return changeNoFollow(path, uid, gid, o.Perm(perm))
+}
+
+// Lchmod sets the mode of path without following a final symlink. Symlinks are
+// left alone, as their mode is not used.
+func Lchmod(path string, mode os.FileMode) error {
+	return changeNoFollow(path, -1, -1, mode)
+}
+
+// MkdirAll creates path and any missing parents with DirPerm, applying the
+// owner to each directory it creates. Existing directories are left alone.
+func (o *Owner) MkdirAll(path string) error {
+	var missing []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	if err := os.MkdirAll(path, o.Perm(DirPerm)); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := o.Apply(missing[i], DirPerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateFile writes src to a new or truncated file at path and applies the
+// owner to it through the open descriptor. A symlink at path is refused.
+func (o *Owner) CreateFile(path string, src io.Reader, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, o.Perm(perm))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, src)
+	if err == nil && o != nil {
+		err = o.applyFile(file, perm)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (o *Owner) applyFile(file *os.File, perm os.FileMode) error {
+	if o.Chown {
+		if err := file.Chown(o.UID, o.GID); err != nil {
+			return err
+		}
+	}
+	return file.Chmod(o.Perm(perm))
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package fsutil
+
+import "os"
+
+// changeNoFollow chowns (unless uid is -1) and chmods (unless it is a symlink)
+// the entry at path. Without O_PATH the mode is set by path, so a link swapped
+// in after the check is followed.
+func changeNoFollow(path string, uid, gid int, mode os.FileMode) error {
+	if uid >= 0 {
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chmod(path, mode)
+}
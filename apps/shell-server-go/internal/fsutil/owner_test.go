@@ -0,0 +1,90 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOwner_AppliesUmaskToCreatedEntries(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing")
+	if err := os.Mkdir(existing, 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	owner := &Owner{UID: os.Getuid(), GID: os.Getgid(), Umask: 0002}
+
+	dir := filepath.Join(existing, "a", "b")
+	if err := owner.MkdirAll(dir); err != nil {
+		t.Fatalf("mkdir all: %v", err)
+	}
+	for path, want := range map[string]os.FileMode{
+		existing:                     0700, // left alone
+		filepath.Join(existing, "a"): 0775,
+		dir:                          0775,
+	} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Fatalf("%s: mode %v err=%v, want %o", path, info.Mode().Perm(), err, want)
+		}
+	}
+
+	file := filepath.Join(dir, "index.html")
+	if err := owner.CreateFile(file, strings.NewReader("hi"), FilePerm); err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0664 {
+		t.Fatalf("unexpected file mode %v err=%v", info.Mode().Perm(), err)
+	}
+
+	var none *Owner
+	if err := none.Apply(file, 0600); err != nil {
+		t.Fatalf("nil owner apply: %v", err)
+	}
+	if none.Perm(0644) != 0644 {
+		t.Fatalf("nil owner changed perm")
+	}
+}
+
+func TestOwner_DoesNotFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.WriteFile(outside, []byte("keep"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	owner := &Owner{UID: os.Getuid(), GID: os.Getgid(), Chown: os.Geteuid() == 0}
+
+	// An entry swapped for a link after it was created keeps the target as it was.
+	if err := owner.Apply(link, 0777); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := Lchmod(link, 0777); err != nil {
+		t.Fatalf("lchmod: %v", err)
+	}
+	if err := owner.CreateFile(link, strings.NewReader("overwritten"), 0777); err == nil {
+		t.Fatalf("expected CreateFile to refuse a symlink")
+	}
+	info, err := os.Stat(outside)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("symlink target mode changed: %v err=%v", info.Mode().Perm(), err)
+	}
+	if content, _ := os.ReadFile(outside); string(content) != "keep" {
+		t.Fatalf("symlink target was written: %q", content)
+	}
+
+	// Regular entries are still changed.
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := Lchmod(file, 0640); err != nil {
+		t.Fatalf("lchmod: %v", err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("unexpected file mode %v err=%v", info.Mode().Perm(), err)
+	}
+}
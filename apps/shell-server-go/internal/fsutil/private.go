@@ -0,0 +1,35 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// PrivateDir creates path with mode 0700 if it is missing and makes sure the
+// server alone controls it. A symlink, a non-directory or a directory owned by
+// another user is refused rather than reused, since whoever created it could
+// plant or swap the files the server keeps there.
+func PrivateDir(path string) error {
+	if err := os.Mkdir(path, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: stat does not expose the owner", path)
+	}
+	if int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is owned by uid %d, not the server", path, stat.Uid)
+	}
+	if info.Mode().Perm() != 0700 {
+		return Lchmod(path, 0700)
+	}
+	return nil
+}
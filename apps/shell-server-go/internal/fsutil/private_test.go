@@ -0,0 +1,46 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrivateDir_CreatesAndTightensDirectory(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "state")
+	if err := PrivateDir(dir); err != nil {
+		t.Fatalf("private dir: %v", err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	// An existing directory of the server is reused with its mode tightened.
+	if err := PrivateDir(dir); err != nil {
+		t.Fatalf("reuse private dir: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("mode %v err=%v, want 0700", info.Mode().Perm(), err)
+	}
+}
+
+func TestPrivateDir_RefusesSymlinksAndFiles(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "target")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, path := range []string{link, file} {
+		if err := PrivateDir(path); err == nil {
+			t.Fatalf("%s: expected an error", path)
+		}
+	}
+}
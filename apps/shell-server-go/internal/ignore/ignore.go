@@ -0,0 +1,217 @@
+// Package ignore decides which paths of a workspace are hidden from trees,
+// listings, search, archives and watchers, using gitignore rules.
+//
+// Rules come from DefaultPatterns, then from the .gitignore and .aliveignore
+// files of every directory from the matcher's root down to the path, then from
+// patterns given by the caller. As in git, the last matching rule wins, so a
+// deeper file can re-include what a shallower one ignored, and .aliveignore
+// can override .gitignore (for example "!dist/" to show build output that git
+// ignores).
+package ignore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// Ignore file names, read in this order in each directory.
+const (
+	GitignoreFile   = ".gitignore"
+	AliveignoreFile = ".aliveignore"
+)
+
+// maxFileBytes bounds how much of an ignore file is read.
+const maxFileBytes = 256 << 10
+
+// DefaultPatterns hide workspacepkg.DefaultExcludedDirs at any depth.
+var DefaultPatterns = func() []string {
+	patterns := make([]string, 0, len(workspacepkg.DefaultExcludedDirs))
+	for name := range workspacepkg.DefaultExcludedDirs {
+		patterns = append(patterns, name)
+	}
+	sort.Strings(patterns)
+	return patterns
+}()
+
+// IsIgnoreFile reports whether name is one of the files rules are read from.
+func IsIgnoreFile(name string) bool {
+	return name == GitignoreFile || name == AliveignoreFile
+}
+
+// Matcher answers whether paths below root are ignored. Ignore files are read
+// once per directory, on first use. A nil Matcher ignores nothing, which is
+// what callers use to show ignored files.
+type Matcher struct {
+	root     string
+	defaults []rule
+	extra    []rule
+	useFiles bool
+
+	mu   sync.Mutex
+	dirs map[string][]rule // slash path relative to root -> rules of its files
+}
+
+// New returns a matcher applying DefaultPatterns, the ignore files below root
+// and then extra patterns, which are gitignore lines relative to root.
+func New(root string, extra ...string) *Matcher {
+	return &Matcher{
+		root:     filepath.Clean(root),
+		defaults: parse(strings.Join(DefaultPatterns, "\n")),
+		extra:    parse(strings.Join(extra, "\n")),
+		useFiles: true,
+		dirs:     make(map[string][]rule),
+	}
+}
+
+// Only returns a matcher applying just patterns, or nil when there are none.
+func Only(root string, patterns ...string) *Matcher {
+	extra := parse(strings.Join(patterns, "\n"))
+	if len(extra) == 0 {
+		return nil
+	}
+	return &Matcher{root: filepath.Clean(root), extra: extra}
+}
+
+// Root returns the directory the matcher's rules are relative to.
+func (m *Matcher) Root() string {
+	if m == nil {
+		return ""
+	}
+	return m.root
+}
+
+// Ignored reports whether the rules ignore path itself. Directories above it
+// are not considered: walkers skip ignored directories without descending, and
+// a listing the user opened inside an ignored directory shows its contents.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel, ok := m.rel(path)
+	if !ok {
+		return false
+	}
+
+	ignored := false
+	apply := func(rules []rule, rel string) {
+		for _, r := range rules {
+			if r.match(rel, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	apply(m.defaults, rel)
+	if m.useFiles {
+		dir := ""
+		for {
+			sub := rel
+			if dir != "" {
+				sub = rel[len(dir)+1:]
+			}
+			apply(m.rulesOf(dir), sub)
+			next := strings.IndexByte(sub, '/')
+			if next < 0 {
+				break
+			}
+			if dir == "" {
+				dir = sub[:next]
+			} else {
+				dir = dir + "/" + sub[:next]
+			}
+		}
+	}
+	apply(m.extra, rel)
+	return ignored
+}
+
+// Excludes reports whether path is ignored or lies in an ignored directory
+// below top. Events from a watcher rooted at top are filtered with it.
+func (m *Matcher) Excludes(top, path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel, err := filepath.Rel(top, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := top
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if m.Ignored(dir, true) {
+			return true
+		}
+	}
+	return m.Ignored(path, isDir)
+}
+
+// Invalidate drops the cached rules of dir after one of its ignore files changed.
+func (m *Matcher) Invalidate(dir string) {
+	if m == nil {
+		return
+	}
+	rel, ok := m.rel(dir)
+	if !ok {
+		if filepath.Clean(dir) != m.root {
+			return
+		}
+		rel = ""
+	}
+	m.mu.Lock()
+	delete(m.dirs, rel)
+	m.mu.Unlock()
+}
+
+// rel returns path relative to root in slash form, and false for root itself
+// and paths outside it.
+func (m *Matcher) rel(path string) (string, bool) {
+	rel, err := filepath.Rel(m.root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// rulesOf returns the rules of the ignore files in dir, relative to root.
+func (m *Matcher) rulesOf(dir string) []rule {
+	m.mu.Lock()
+	rules, ok := m.dirs[dir]
+	m.mu.Unlock()
+	if ok {
+		return rules
+	}
+
+	abs := filepath.Join(m.root, filepath.FromSlash(dir))
+	for _, name := range []string{GitignoreFile, AliveignoreFile} {
+		rules = append(rules, parse(readIgnoreFile(filepath.Join(abs, name)))...)
+	}
+	m.mu.Lock()
+	m.dirs[dir] = rules
+	m.mu.Unlock()
+	return rules
+}
+
+// readIgnoreFile returns the start of a regular ignore file. Symlinks are not
+// followed, so a link cannot make rules out of a file elsewhere.
+func readIgnoreFile(path string) string {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxFileBytes))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,138 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompile_GitignoreSemantics(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/deep/debug.log", false, true},
+		{"*.log", "debug.log.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "app/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "app/build", true, false},
+		{"doc/*.txt", "doc/notes.txt", false, true},
+		{"doc/*.txt", "doc/server/arch.txt", false, false},
+		{"doc/*.txt", "app/doc/notes.txt", false, false},
+		{"**/cache", "a/b/cache", true, true},
+		{"**/cache", "cache", true, true},
+		{"a/**/b", "a/b", true, true},
+		{"a/**/b", "a/x/y/b", true, true},
+		{"out/**", "out/x/y.js", false, true},
+		{"out/**", "out", true, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file?.txt", "file/.txt", false, false},
+		{"[a-c]at", "bat", false, true},
+		{"[!a-c]at", "rat", false, true},
+		{"[!a-c]at", "bat", false, false},
+		{`\#notes`, "#notes", false, true},
+		{`\!important`, "!important", false, true},
+		{`name\ `, "name ", false, true},
+		{"trailing   ", "trailing", false, true},
+		{".env*", ".env.local", false, true},
+	}
+	for _, tc := range cases {
+		r, ok := compile(tc.pattern)
+		if !ok {
+			t.Errorf("%q did not compile", tc.pattern)
+			continue
+		}
+		if got := r.match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("%q vs %q (dir=%v): got %v, want %v (re %s)", tc.pattern, tc.path, tc.isDir, got, tc.want, r.re)
+		}
+	}
+	for _, skipped := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := compile(skipped); ok {
+			t.Errorf("%q should not produce a rule", skipped)
+		}
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestMatcher_NestedFilesNegationAndOverrides(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, ".gitignore"), "*.log\n/build\ndist\n.next/\n")
+	write(t, filepath.Join(root, ".aliveignore"), "!dist/\ncoverage/\n")
+	write(t, filepath.Join(root, "app", ".gitignore"), "!keep.log\n/generated\n")
+
+	m := New(root, "secret.txt")
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"error.log", false, true},
+		{"app/error.log", false, true},
+		{"app/keep.log", false, false},
+		{"keep.log", false, true},
+		{"build", true, true},
+		{"app/build", true, false},
+		{"dist", true, false}, // re-included by .aliveignore
+		{"dist", false, true}, // only directories were re-included
+		{"app/.next", true, true},
+		{"coverage", true, true},
+		{"app/generated", true, true},
+		{"generated", true, false},
+		{"node_modules", true, true}, // default pattern
+		{"deep/secret.txt", false, true},
+		{"src/main.go", false, false},
+	}
+	for _, tc := range cases {
+		if got := m.Ignored(filepath.Join(root, tc.path), tc.isDir); got != tc.want {
+			t.Errorf("%s (dir=%v): got %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+
+	if m.Ignored(root, true) || m.Ignored(filepath.Dir(root), true) {
+		t.Fatal("root and paths outside it are never ignored")
+	}
+	if !m.Excludes(root, filepath.Join(root, "build", "x", "y.js"), false) {
+		t.Fatal("Excludes should see the ignored directory above the path")
+	}
+	if m.Excludes(filepath.Join(root, "build"), filepath.Join(root, "build", "y.js"), false) {
+		t.Fatal("Excludes should not look above top")
+	}
+
+	// Rules are cached until invalidated.
+	write(t, filepath.Join(root, ".gitignore"), "")
+	if !m.Ignored(filepath.Join(root, "error.log"), false) {
+		t.Fatal("rules should be cached")
+	}
+	m.Invalidate(root)
+	if m.Ignored(filepath.Join(root, "error.log"), false) {
+		t.Fatal("invalidated rules should be re-read")
+	}
+}
+
+func TestMatcher_NilAndOnly(t *testing.T) {
+	var m *Matcher
+	if m.Ignored("/x/node_modules", true) || m.Excludes("/x", "/x/node_modules/a", false) {
+		t.Fatal("a nil matcher ignores nothing")
+	}
+	if Only("/x") != nil {
+		t.Fatal("Only without patterns should be nil")
+	}
+	only := Only("/x", "tmp")
+	if !only.Ignored("/x/a/tmp", true) || only.Ignored("/x/node_modules", true) {
+		t.Fatal("Only should apply just its patterns")
+	}
+}
@@ -0,0 +1,180 @@
+package ignore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rule is one compiled pattern line. re matches a slash-separated path
+// relative to the directory of the file the rule came from.
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func (r rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(rel)
+}
+
+// parse compiles the lines of an ignore file, skipping blanks, comments and
+// patterns that fail to compile.
+func parse(content string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(content, "\n") {
+		if r, ok := compile(line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// compile turns one gitignore line into a rule:
+//
+//   - "#" starts a comment and "!" negates, unless escaped with a backslash
+//   - trailing spaces are dropped unless escaped
+//   - a trailing "/" matches directories only
+//   - a pattern with a "/" before its end is anchored to the file's directory;
+//     otherwise it matches a name at any depth
+//   - "*", "?" and "[...]" never match "/"; "**/", "/**/" and a trailing "/**"
+//     match any number of directories
+func compile(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return rule{}, false
+	}
+
+	var r rule
+	switch {
+	case line[0] == '!':
+		r.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		expr.WriteString("(?:.*/)?")
+	}
+	translate(&expr, line)
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// translate appends the regular expression for a glob.
+func translate(expr *strings.Builder, glob string) {
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				atStart := i == 0 || glob[i-1] == '/'
+				rest := glob[i+2:]
+				switch {
+				case atStart && rest == "":
+					expr.WriteString(".*")
+					i++
+					continue
+				case atStart && rest[0] == '/':
+					expr.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+				// Any other run of asterisks is a plain "*".
+				for i+1 < len(glob) && glob[i+1] == '*' {
+					i++
+				}
+			}
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			if end := classEnd(glob, i); end > 0 {
+				writeClass(expr, glob[i+1:end])
+				i = end
+				continue
+			}
+			expr.WriteString(`\[`)
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				c = glob[i]
+			}
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+}
+
+// classEnd returns the index of the "]" closing the bracket expression that
+// opens at start, or -1. A "]" right after the opening (or its negation) is a
+// member, not the end.
+func classEnd(glob string, start int) int {
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		i++
+	}
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func writeClass(expr *strings.Builder, class string) {
+	expr.WriteString("[")
+	if class != "" && (class[0] == '!' || class[0] == '^') {
+		expr.WriteString("^/")
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		c := class[i]
+		escaped := false
+		if c == '\\' && i+1 < len(class) {
+			i++
+			c = class[i]
+			escaped = true
+		}
+		// An escaped "-" is a member, an unescaped one keeps forming a range.
+		if strings.IndexByte(`\[]^`, c) >= 0 || escaped && isPunct(c) {
+			expr.WriteByte('\\')
+		}
+		expr.WriteByte(c)
+	}
+	expr.WriteString("]")
+}
+
+func isPunct(c byte) bool {
+	return c < 0x80 && !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9')
+}
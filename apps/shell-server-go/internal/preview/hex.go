@@ -0,0 +1,67 @@
+package preview
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Hex dump limits. A dump covers at most MaxHexLength bytes starting anywhere
+// in the file, so files of any size can be inspected page by page.
+const (
+	HexBytesPerRow   = 16
+	DefaultHexLength = 4096
+	MaxHexLength     = 64 << 10
+)
+
+// HexRow is one line of a hex dump.
+type HexRow struct {
+	Offset int64  `json:"offset"`
+	Hex    string `json:"hex"`   // space-separated byte pairs
+	ASCII  string `json:"ascii"` // printable ASCII, '.' for anything else
+}
+
+// Hex dumps up to length bytes of r starting at offset. It returns the rows
+// and the number of bytes read, which is short at the end of the file.
+func Hex(r io.ReaderAt, offset int64, length int) ([]HexRow, int, error) {
+	buf := make([]byte, length)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+	buf = buf[:n]
+
+	rows := make([]HexRow, 0, (n+HexBytesPerRow-1)/HexBytesPerRow)
+	for start := 0; start < n; start += HexBytesPerRow {
+		chunk := buf[start:min(start+HexBytesPerRow, n)]
+		rows = append(rows, HexRow{
+			Offset: offset + int64(start),
+			Hex:    hexPairs(chunk),
+			ASCII:  printableASCII(chunk),
+		})
+	}
+	return rows, n, nil
+}
+
+func hexPairs(chunk []byte) string {
+	out := make([]byte, 0, len(chunk)*3)
+	for i, b := range chunk {
+		if i > 0 {
+			out = append(out, ' ')
+		}
+		out = hex.AppendEncode(out, []byte{b})
+	}
+	return string(out)
+}
+
+func printableASCII(chunk []byte) string {
+	out := make([]byte, len(chunk))
+	for i, b := range chunk {
+		if b >= 0x20 && b < 0x7f {
+			out[i] = b
+		} else {
+			out[i] = '.'
+		}
+	}
+	return string(out)
+}
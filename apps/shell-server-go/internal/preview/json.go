@@ -0,0 +1,71 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MaxJSONOutput bounds pretty-printed JSON. Indentation grows with nesting,
+// so a small deeply nested document can expand far beyond its input size.
+const MaxJSONOutput = 4 << 20
+
+const jsonIndent = "  "
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// JSON pretty-prints src with two-space indentation. Key order and number
+// formatting are kept as written. The output size is computed before
+// indenting, so ErrTooLarge is returned without building the output.
+func JSON(src []byte) ([]byte, error) {
+	src = bytes.TrimPrefix(src, utf8BOM)
+	if !json.Valid(src) {
+		var v any
+		err := json.Unmarshal(src, &v)
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if indentedSize(src) > MaxJSONOutput {
+		return nil, ErrTooLarge
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, src, "", jsonIndent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return out.Bytes(), nil
+}
+
+// indentedSize is an upper bound on the size of valid JSON src after
+// json.Indent: every opening bracket, comma and closing bracket may start a
+// new line indented to the current depth, and colons gain a space.
+func indentedSize(src []byte) int {
+	size, depth := len(src), 0
+	inString, escaped := false, false
+	for _, c := range src {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			size += 1 + depth*len(jsonIndent)
+		case '}', ']':
+			depth--
+			size += 1 + depth*len(jsonIndent)
+		case ',':
+			size += 1 + depth*len(jsonIndent)
+		case ':':
+			size++
+		}
+	}
+	return size
+}
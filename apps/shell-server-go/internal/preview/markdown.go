@@ -0,0 +1,28 @@
+package preview
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders GitHub Flavored Markdown. It is left in goldmark's safe
+// mode: raw HTML blocks and inline tags are replaced by a comment, and links
+// and images with javascript:, vbscript:, file: or non-image data: URLs are
+// emptied, so the output can be inserted into the page without running
+// script.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithXHTML()),
+)
+
+// Markdown renders src to sanitized HTML.
+func Markdown(src []byte) (string, error) {
+	var out bytes.Buffer
+	if err := markdown.Convert(src, &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
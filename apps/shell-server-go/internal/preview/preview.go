@@ -0,0 +1,30 @@
+// Package preview turns file contents into structured previews: Markdown as
+// sanitized HTML, delimited text as a table, JSON pretty-printed and any
+// bytes as a hex/ASCII dump. Callers read and bound the input; these
+// functions only transform it.
+package preview
+
+import "errors"
+
+// Modes accepted by the read-file API. ModeText returns the content as is.
+const (
+	ModeText     = "text"
+	ModeMarkdown = "markdown"
+	ModeTable    = "table"
+	ModeJSON     = "json"
+	ModeHex      = "hex"
+)
+
+// ValidMode reports whether mode is a known preview mode.
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeText, ModeMarkdown, ModeTable, ModeJSON, ModeHex:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrInvalid  = errors.New("content cannot be parsed for this preview")
+	ErrTooLarge = errors.New("preview output too large")
+)
@@ -0,0 +1,108 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown_RendersGFMAndDropsActiveContent(t *testing.T) {
+	src := "# Title\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n<script>alert(1)</script>\n\n" +
+		"[click](javascript:alert(1)) <img src=x onerror=alert(1)> ~~old~~\n"
+	html, err := Markdown([]byte(src))
+	if err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	for _, want := range []string{"<h1>Title</h1>", "<table>", "<td>1</td>", "<del>old</del>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %q in:\n%s", want, html)
+		}
+	}
+	for _, banned := range []string{"<script", "javascript:", "onerror", "<img"} {
+		if strings.Contains(html, banned) {
+			t.Errorf("unsafe %q in:\n%s", banned, html)
+		}
+	}
+}
+
+func TestJSON_PrettyPrintsAndGuardsSize(t *testing.T) {
+	out, err := JSON([]byte("\xef\xbb\xbf{\"b\":1.50,\"a\":[true,null]}"))
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	want := "{\n  \"b\": 1.50,\n  \"a\": [\n    true,\n    null\n  ]\n}"
+	if string(out) != want {
+		t.Fatalf("got\n%s\nwant\n%s", out, want)
+	}
+	if len(out) > indentedSize([]byte(`{"b":1.50,"a":[true,null]}`)) {
+		t.Fatalf("indentedSize underestimates %d", len(out))
+	}
+
+	if _, err := JSON([]byte(`{"a":`)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+
+	deep := strings.Repeat("[", 5000) + strings.Repeat("]", 5000)
+	if _, err := JSON([]byte(deep)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge for deep nesting, got %v", err)
+	}
+}
+
+func TestTable_PaginatesWithHeader(t *testing.T) {
+	src := []byte("name,qty\n\"a, b\",1\nc,2\nd,3\ne\n")
+	page, err := Table(src, TableOptions{Delimiter: ',', Header: true, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if strings.Join(page.Columns, "|") != "name|qty" {
+		t.Fatalf("unexpected columns %v", page.Columns)
+	}
+	if page.TotalRows != 4 || !page.HasMore || len(page.Rows) != 2 || page.Rows[0][0] != "c" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	page, err = Table(src, TableOptions{Delimiter: ',', Limit: 10})
+	if err != nil || page.Rows[1][0] != "a, b" || page.HasMore || len(page.Rows[4]) != 1 {
+		t.Fatalf("unexpected page %+v, %v", page, err)
+	}
+}
+
+func TestDetectAndParseDelimiter(t *testing.T) {
+	if d := DetectDelimiter("data.tsv", []byte("a,b,c")); d != '\t' {
+		t.Errorf("tsv extension: got %q", d)
+	}
+	if d := DetectDelimiter("data.csv", []byte("a;b;c,d\n1;2;3")); d != ';' {
+		t.Errorf("semicolons: got %q", d)
+	}
+	if d, err := ParseDelimiter("tab"); err != nil || d != '\t' {
+		t.Errorf("tab: got %q, %v", d, err)
+	}
+	for _, bad := range []string{`"`, "ab", "\n"} {
+		if _, err := ParseDelimiter(bad); err == nil {
+			t.Errorf("ParseDelimiter(%q) should fail", bad)
+		}
+	}
+}
+
+func TestHex_DumpsRange(t *testing.T) {
+	data := []byte("\x89PNG\r\n\x1a\n0123456789abcdefXYZ")
+	rows, n, err := Hex(bytes.NewReader(data), 2, 100)
+	if err != nil {
+		t.Fatalf("Hex: %v", err)
+	}
+	if n != len(data)-2 || len(rows) != 2 {
+		t.Fatalf("got %d bytes in %d rows", n, len(rows))
+	}
+	if rows[0].Offset != 2 || !strings.HasPrefix(rows[0].Hex, "4e 47 0d 0a 1a 0a 30") || rows[0].ASCII != "NG....0123456789" {
+		t.Fatalf("unexpected first row %+v", rows[0])
+	}
+	if rows[1].Offset != 18 || rows[1].Hex != "61 62 63 64 65 66 58 59 5a" {
+		t.Fatalf("unexpected last row %+v", rows[1])
+	}
+
+	rows, n, err = Hex(bytes.NewReader(data), 100, 16)
+	if err != nil || n != 0 || len(rows) != 0 {
+		t.Fatalf("past EOF: %v %d %v", rows, n, err)
+	}
+}
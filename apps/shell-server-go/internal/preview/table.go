@@ -0,0 +1,143 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table page limits.
+const (
+	DefaultTableRows = 100
+	MaxTableRows     = 1000
+	MaxTableCell     = 4096
+)
+
+// TableOptions selects a page of a delimited file. Offset counts data rows,
+// after the header when Header is set.
+type TableOptions struct {
+	Delimiter rune
+	Header    bool
+	Offset    int
+	Limit     int
+}
+
+// TablePage is one page of a delimited file. Cells longer than MaxTableCell
+// are cut and reported in Truncated.
+type TablePage struct {
+	Delimiter string     `json:"delimiter"`
+	Columns   []string   `json:"columns,omitempty"`
+	Rows      [][]string `json:"rows"`
+	Offset    int        `json:"offset"`
+	TotalRows int        `json:"totalRows"`
+	HasMore   bool       `json:"hasMore"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// ParseDelimiter reads the delimiter parameter: comma, tab, semicolon, pipe
+// or a single character. Empty returns 0, meaning detect.
+func ParseDelimiter(value string) (rune, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return 0, nil
+	case "comma":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		return ';', nil
+	case "pipe":
+		return '|', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || !validDelimiter(r) {
+		return 0, fmt.Errorf("invalid delimiter %q", value)
+	}
+	return r, nil
+}
+
+func validDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError
+}
+
+// DetectDelimiter picks the delimiter of a file from its extension, or else
+// from whichever of tab, semicolon, pipe and comma is most frequent in the
+// first line.
+func DetectDelimiter(name string, src []byte) rune {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tsv", ".tab":
+		return '\t'
+	}
+	line := src
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	best, count := ',', bytes.Count(line, []byte{','})
+	for _, candidate := range []rune{'\t', ';', '|'} {
+		if n := bytes.Count(line, []byte{byte(candidate)}); n > count {
+			best, count = candidate, n
+		}
+	}
+	return best
+}
+
+// Table parses src and returns the requested page. All rows are read so
+// TotalRows is exact; callers bound src.
+func Table(src []byte, opts TableOptions) (*TablePage, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(src, utf8BOM)))
+	reader.Comma = opts.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+
+	page := &TablePage{Delimiter: string(opts.Delimiter), Rows: [][]string{}, Offset: opts.Offset}
+	first := true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if first && opts.Header {
+			first = false
+			page.Columns = page.cells(record)
+			continue
+		}
+		first = false
+		row := page.TotalRows
+		page.TotalRows++
+		if row < opts.Offset {
+			continue
+		}
+		if len(page.Rows) < opts.Limit {
+			page.Rows = append(page.Rows, page.cells(record))
+		} else {
+			page.HasMore = true
+		}
+	}
+	return page, nil
+}
+
+// cells copies a record, cutting long cells.
+func (p *TablePage) cells(record []string) []string {
+	out := make([]string, len(record))
+	for i, cell := range record {
+		if len(cell) > MaxTableCell {
+			cut := MaxTableCell
+			for cut > 0 && !utf8.RuneStart(cell[cut]) {
+				cut--
+			}
+			cell = cell[:cut]
+			p.Truncated = true
+		}
+		out[i] = cell
+	}
+	return out
+}
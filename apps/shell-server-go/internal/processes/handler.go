@@ -0,0 +1,202 @@
+package processes
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"shell-server-go/internal/config"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/logger"
+	"shell-server-go/internal/session"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+var log = logger.WithComponent("PROCESSES")
+
+// Process API errors.
+var (
+	ErrSiteOnly      = errors.New("processes are only available for site workspaces")
+	ErrRootOwned     = errors.New("workspace is owned by root")
+	ErrInvalidSignal = errors.New("signal not allowed")
+	ErrNotOwned      = errors.New("process not owned by workspace")
+	ErrNoWorkspace   = errors.New("workspace not found")
+)
+
+// AllowedSignals are the signals that may be sent to workspace processes.
+var AllowedSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+}
+
+// Handler exposes processes owned by a site workspace's owner.
+type Handler struct {
+	config   *config.AppConfig
+	sessions *session.Store
+	resolver *workspacepkg.Resolver
+	procRoot string
+}
+
+// NewHandler creates a new process handler.
+func NewHandler(cfg *config.AppConfig, sessions *session.Store) *Handler {
+	return &Handler{
+		config:   cfg,
+		sessions: sessions,
+		resolver: workspacepkg.NewResolver(cfg),
+		procRoot: "/proc",
+	}
+}
+
+// ownerUID resolves the UID that owns a site workspace.
+func (h *Handler) ownerUID(workspace string) (uint32, error) {
+	if !strings.HasPrefix(workspace, "site:") {
+		return 0, ErrSiteOnly
+	}
+
+	cwd, err := h.resolver.ResolveWorkspaceBase(workspace)
+	if err != nil {
+		return 0, err
+	}
+	if err := workspacepkg.ValidateSiteWorkspaceBoundary(cwd, h.config.ResolvedSitesPath); err != nil {
+		return 0, err
+	}
+
+	credential, err := workspacepkg.ResolveWorkspaceCredential(cwd, true)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNoWorkspace
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	uid := uint32(os.Geteuid())
+	if credential != nil {
+		uid = credential.Uid
+	}
+	// A root-owned site would expose every system process.
+	if uid == 0 {
+		return 0, ErrRootOwned
+	}
+	return uid, nil
+}
+
+// List handles GET /api/processes?workspace=X.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	workspace := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+
+	uid, err := h.ownerUID(workspace)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	procs, err := List(h.procRoot, uid, os.Getpid())
+	if err != nil {
+		log.Error("Failed to list processes for %s: %v", workspace, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to list processes")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"workspace": workspace,
+		"uid":       uid,
+		"processes": procs,
+	})
+}
+
+// Signal handles POST /api/processes/signal.
+func (h *Handler) Signal(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	pid, err := strconv.Atoi(r.FormValue("pid"))
+	if err != nil || pid <= 1 {
+		response.Error(w, http.StatusBadRequest, "Invalid pid")
+		return
+	}
+
+	signalName := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(r.FormValue("signal"))), "SIG")
+	if signalName == "" {
+		signalName = "TERM"
+	}
+	sig, ok := AllowedSignals[signalName]
+	if !ok {
+		h.handleError(w, ErrInvalidSignal)
+		return
+	}
+
+	uid, err := h.ownerUID(workspace)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	if err := h.signal(pid, uid, sig); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	log.Info("Sent SIG%s to pid %d | workspace=%s", signalName, pid, workspace)
+	response.JSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"pid":     pid,
+		"signal":  signalName,
+	})
+}
+
+// signal sends sig to pid after re-checking that the process belongs to uid.
+// The process is pinned with a pidfd before its owner is read, so a PID reused
+// in between is never signalled (see sendSignal).
+func (h *Handler) signal(pid int, uid uint32, sig syscall.Signal) error {
+	if pid == os.Getpid() {
+		return ErrNotOwned
+	}
+	return sendSignal(pid, sig, func() error {
+		owner, err := readUID(h.procRoot, pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return os.ErrNotExist
+			}
+			return err
+		}
+		if owner != uid {
+			return ErrNotOwned
+		}
+		return nil
+	})
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, err error) {
+	var pathErr *workspacepkg.PathSecurityError
+	switch {
+	case errors.As(err, &pathErr):
+		workspacepkg.HandlePathSecurityError(w, err)
+	case errors.Is(err, ErrSiteOnly):
+		response.Error(w, http.StatusBadRequest, "Processes are only available for site workspaces")
+	case errors.Is(err, ErrInvalidSignal):
+		response.Error(w, http.StatusBadRequest, "Signal not allowed")
+	case errors.Is(err, ErrNoWorkspace):
+		response.Error(w, http.StatusNotFound, "Workspace not found")
+	case errors.Is(err, ErrRootOwned):
+		response.Error(w, http.StatusForbidden, "Workspace is owned by root")
+	// Foreign processes are reported as missing so PIDs of other users cannot be probed.
+	case errors.Is(err, ErrNotOwned), errors.Is(err, os.ErrNotExist):
+		response.Error(w, http.StatusNotFound, "Process not found")
+	default:
+		log.Error("Process operation failed: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Process operation failed")
+	}
+}
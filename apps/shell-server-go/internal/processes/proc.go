@@ -0,0 +1,308 @@
+package processes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of utime/stime/starttime in /proc/<pid>/stat.
+// It is 100 on every Linux architecture Go supports.
+const clockTicks = 100
+
+// tcpListenState is TCP_LISTEN as it appears in /proc/net/tcp.
+const tcpListenState = "0A"
+
+// ErrInvalidStat is returned when a /proc/<pid>/stat line cannot be parsed.
+var ErrInvalidStat = errors.New("invalid proc stat")
+
+// Process describes one process owned by a workspace owner.
+type Process struct {
+	PID        int     `json:"pid"`
+	PPID       int     `json:"ppid"`
+	Name       string  `json:"name"`
+	Cmdline    string  `json:"cmdline"`
+	State      string  `json:"state"`
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// CPUPercent is averaged over the process lifetime, like ps(1) %CPU.
+	CPUPercent float64   `json:"cpuPercent"`
+	RSSBytes   int64     `json:"rssBytes"`
+	StartedAt  time.Time `json:"startedAt"`
+	Ports      []Port    `json:"ports"`
+}
+
+// Port is a listening socket held by a process.
+type Port struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+}
+
+// procStat holds the fields of /proc/<pid>/stat that the listing uses.
+type procStat struct {
+	pid       int
+	name      string
+	state     string
+	ppid      int
+	utime     uint64
+	stime     uint64
+	starttime uint64
+	rssPages  int64
+}
+
+// parseStat parses the contents of /proc/<pid>/stat.
+// The command name is enclosed in parentheses and may itself contain spaces or ')'.
+func parseStat(data []byte) (procStat, error) {
+	open := bytes.IndexByte(data, '(')
+	closing := bytes.LastIndexByte(data, ')')
+	if open < 0 || closing < open {
+		return procStat{}, ErrInvalidStat
+	}
+
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data[:open])))
+	if err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+
+	// Fields after the name, starting at field 3 (state).
+	fields := strings.Fields(string(data[closing+1:]))
+	if len(fields) < 22 {
+		return procStat{}, ErrInvalidStat
+	}
+
+	st := procStat{pid: pid, name: string(data[open+1 : closing]), state: fields[0]}
+	if st.ppid, err = strconv.Atoi(fields[1]); err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+	if st.utime, err = strconv.ParseUint(fields[11], 10, 64); err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+	if st.stime, err = strconv.ParseUint(fields[12], 10, 64); err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+	if st.starttime, err = strconv.ParseUint(fields[19], 10, 64); err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+	if st.rssPages, err = strconv.ParseInt(fields[21], 10, 64); err != nil {
+		return procStat{}, ErrInvalidStat
+	}
+	return st, nil
+}
+
+// readBootTime returns the system boot time from /proc/stat.
+func readBootTime(procRoot string) (time.Time, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "btime ") {
+			continue
+		}
+		secs, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse btime: %w", err)
+		}
+		return time.Unix(secs, 0), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.New("btime not found in stat")
+}
+
+// readUID returns the effective UID from /proc/<pid>/status.
+func readUID(procRoot string, pid int) (uint32, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		// Uid: real effective saved filesystem
+		fields := strings.Fields(strings.TrimPrefix(line, "Uid:"))
+		if len(fields) < 2 {
+			break
+		}
+		uid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			break
+		}
+		return uint32(uid), nil
+	}
+	return 0, fmt.Errorf("no Uid line for pid %d", pid)
+}
+
+// readCmdline returns the NUL-separated command line joined with spaces.
+func readCmdline(procRoot string, pid int) string {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return ""
+	}
+	data = bytes.TrimRight(data, "\x00")
+	return string(bytes.ReplaceAll(data, []byte{0}, []byte{' '}))
+}
+
+// socketInodes returns the socket inodes referenced by a process's open file descriptors.
+func socketInodes(procRoot string, pid int) []uint64 {
+	fdDir := filepath.Join(procRoot, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []uint64
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+		if err == nil {
+			inodes = append(inodes, inode)
+		}
+	}
+	return inodes
+}
+
+// listeningSockets maps socket inode to the listening TCP port it represents.
+func listeningSockets(procRoot string) map[uint64]Port {
+	sockets := make(map[uint64]Port)
+	for _, proto := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile(filepath.Join(procRoot, "net", proto))
+		if err != nil {
+			continue
+		}
+		parseNetTCP(data, proto, sockets)
+	}
+	return sockets
+}
+
+// parseNetTCP adds the listening sockets of a /proc/net/tcp{,6} table to sockets.
+func parseNetTCP(data []byte, proto string, sockets map[uint64]Port) {
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+
+		host, portHex, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(portHex, 16, 16)
+		if err != nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+
+		sockets[inode] = Port{Protocol: proto, Address: decodeProcAddress(host), Port: int(port)}
+	}
+}
+
+// decodeProcAddress converts a hex address from /proc/net/tcp{,6}.
+// The kernel prints each 32-bit word in host (little-endian) byte order.
+func decodeProcAddress(hexAddr string) string {
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || len(raw)%4 != 0 {
+		return ""
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return net.IP(raw).String()
+}
+
+// List returns all processes whose effective UID is uid, excluding excludePID.
+func List(procRoot string, uid uint32, excludePID int) ([]Process, error) {
+	bootTime, err := readBootTime(procRoot)
+	if err != nil {
+		return nil, fmt.Errorf("read boot time: %w", err)
+	}
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pageSize := int64(os.Getpagesize())
+	var sockets map[uint64]Port
+
+	processes := make([]Process, 0)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() || pid == excludePID {
+			continue
+		}
+
+		// Processes may exit while we walk /proc; skip anything that disappears.
+		owner, err := readUID(procRoot, pid)
+		if err != nil || owner != uid {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		st, err := parseStat(data)
+		if err != nil {
+			continue
+		}
+
+		startedAt := bootTime.Add(time.Duration(st.starttime) * time.Second / clockTicks)
+		cpuSeconds := float64(st.utime+st.stime) / clockTicks
+		var cpuPercent float64
+		if elapsed := now.Sub(startedAt).Seconds(); elapsed > 0 {
+			cpuPercent = cpuSeconds / elapsed * 100
+		}
+
+		proc := Process{
+			PID:        pid,
+			PPID:       st.ppid,
+			Name:       st.name,
+			Cmdline:    readCmdline(procRoot, pid),
+			State:      st.state,
+			CPUSeconds: cpuSeconds,
+			CPUPercent: cpuPercent,
+			RSSBytes:   st.rssPages * pageSize,
+			StartedAt:  startedAt,
+			Ports:      []Port{},
+		}
+
+		if inodes := socketInodes(procRoot, pid); len(inodes) > 0 {
+			if sockets == nil {
+				sockets = listeningSockets(procRoot)
+			}
+			for _, inode := range inodes {
+				if port, ok := sockets[inode]; ok {
+					proc.Ports = append(proc.Ports, port)
+				}
+			}
+		}
+
+		processes = append(processes, proc)
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
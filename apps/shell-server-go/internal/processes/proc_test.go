@@ -0,0 +1,199 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"shell-server-go/internal/config"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+)
+
+const testBootTime = 1700000000
+
+func writeProcFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// fakeProcess writes the /proc entries List reads for one process.
+func fakeProcess(t *testing.T, root string, pid, uid int, name string, sockets ...int) {
+	t.Helper()
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	// utime=150 stime=50 starttime=1000 (10s after boot) rss=256 pages
+	stat := fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 1 0 1000 1000000 256 18446744073709551615", pid, name, pid, pid)
+	writeProcFile(t, filepath.Join(dir, "stat"), stat)
+	writeProcFile(t, filepath.Join(dir, "status"), fmt.Sprintf("Name:\t%s\nUid:\t%d\t%d\t%d\t%d\n", name, uid, uid, uid, uid))
+	writeProcFile(t, filepath.Join(dir, "cmdline"), name+"\x00--port\x003000\x00")
+
+	fdDir := filepath.Join(dir, "fd")
+	if err := os.MkdirAll(fdDir, 0755); err != nil {
+		t.Fatalf("mkdir fd: %v", err)
+	}
+	for i, inode := range sockets {
+		if err := os.Symlink(fmt.Sprintf("socket:[%d]", inode), filepath.Join(fdDir, fmt.Sprint(i+3))); err != nil {
+			t.Fatalf("symlink: %v", err)
+		}
+	}
+}
+
+func TestParseStat_NameWithSpacesAndParens(t *testing.T) {
+	st, err := parseStat([]byte("42 (my (weird) proc) R 7 42 42 0 -1 0 0 0 0 0 12 3 0 0 20 0 1 0 500 0 9 0\n"))
+	if err != nil {
+		t.Fatalf("parseStat: %v", err)
+	}
+	if st.pid != 42 || st.name != "my (weird) proc" || st.state != "R" || st.ppid != 7 {
+		t.Fatalf("unexpected header fields: %+v", st)
+	}
+	if st.utime != 12 || st.stime != 3 || st.starttime != 500 || st.rssPages != 9 {
+		t.Fatalf("unexpected counters: %+v", st)
+	}
+
+	if _, err := parseStat([]byte("42 (short) R 1")); err == nil {
+		t.Fatalf("expected error for truncated stat line")
+	}
+}
+
+func TestParseNetTCP_ListeningSockets(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 111 1 0 100 0 0 10 0
+   1: 0100007F:0BB8 0100007F:D2A4 01 00000000:00000000 00:00000000 00000000  1000        0 112 1 0 20 4 30 10 -1
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 222 1 0 100 0 0 10 0
+`
+	sockets := make(map[uint64]Port)
+	parseNetTCP([]byte(tcp), "tcp", sockets)
+	parseNetTCP([]byte(tcp6), "tcp6", sockets)
+
+	if len(sockets) != 2 {
+		t.Fatalf("expected only listening sockets, got %+v", sockets)
+	}
+	if got := sockets[111]; got != (Port{Protocol: "tcp", Address: "127.0.0.1", Port: 3000}) {
+		t.Fatalf("unexpected tcp socket: %+v", got)
+	}
+	if got := sockets[222]; got != (Port{Protocol: "tcp6", Address: "::1", Port: 8080}) {
+		t.Fatalf("unexpected tcp6 socket: %+v", got)
+	}
+}
+
+func TestList_FiltersByUIDAndResolvesPorts(t *testing.T) {
+	root := t.TempDir()
+	writeProcFile(t, filepath.Join(root, "stat"), fmt.Sprintf("cpu  1 2 3 4\nbtime %d\n", testBootTime))
+	writeProcFile(t, filepath.Join(root, "net", "tcp"), "header\n   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 5555 1 0 100 0 0 10 0\n")
+
+	fakeProcess(t, root, 100, 1000, "node", 5555, 6666)
+	fakeProcess(t, root, 101, 1000, "self")
+	fakeProcess(t, root, 200, 2000, "other")
+
+	procs, err := List(root, 1000, 101)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(procs) != 1 {
+		t.Fatalf("expected one process, got %+v", procs)
+	}
+
+	p := procs[0]
+	if p.PID != 100 || p.Name != "node" || p.Cmdline != "node --port 3000" {
+		t.Fatalf("unexpected process: %+v", p)
+	}
+	if p.CPUSeconds != 2 {
+		t.Fatalf("expected 2 CPU seconds, got %v", p.CPUSeconds)
+	}
+	if want := time.Unix(testBootTime+10, 0); !p.StartedAt.Equal(want) {
+		t.Fatalf("expected start %v, got %v", want, p.StartedAt)
+	}
+	if p.RSSBytes != 256*int64(os.Getpagesize()) {
+		t.Fatalf("unexpected rss %d", p.RSSBytes)
+	}
+	if len(p.Ports) != 1 || p.Ports[0].Port != 3000 || p.Ports[0].Address != "0.0.0.0" {
+		t.Fatalf("expected port 3000, got %+v", p.Ports)
+	}
+}
+
+func TestHandler_SignalRejectsForeignAndDisallowed(t *testing.T) {
+	tmp := t.TempDir()
+	sitesPath := filepath.Join(tmp, "sites")
+	siteDir := filepath.Join(sitesPath, "example.com", "user")
+	if err := os.MkdirAll(siteDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if os.Geteuid() == 0 {
+		if err := os.Chown(siteDir, 1000, 1000); err != nil {
+			t.Skipf("cannot chown site dir: %v", err)
+		}
+	}
+
+	cfg := &config.AppConfig{ResolvedSitesPath: sitesPath, ShellPassword: "testpassword123"}
+	sessions := session.NewStore(filepath.Join(tmp, ".sessions.json"))
+	defer sessions.Stop()
+
+	h := NewHandler(cfg, sessions)
+	h.procRoot = filepath.Join(tmp, "proc")
+	ownerUID, err := h.ownerUID("site:example.com")
+	if err != nil {
+		t.Fatalf("ownerUID: %v", err)
+	}
+	fakeProcess(t, h.procRoot, 4242, int(ownerUID)+1, "foreign")
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{Workspace: "site:example.com"})
+	send := func(values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/processes/signal", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+		w := httptest.NewRecorder()
+		h.Signal(w, req)
+		return w
+	}
+
+	if w := send(url.Values{"pid": {"4242"}, "signal": {"KILL"}}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for foreign process, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send(url.Values{"pid": {"4242"}, "signal": {"SEGV"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for disallowed signal, got %d body=%s", w.Code, w.Body.String())
+	}
+	if w := send(url.Values{"pid": {"1"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for pid 1, got %d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_SignalChecksOwnerOfPinnedProcess(t *testing.T) {
+	h := &Handler{procRoot: "/proc"}
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+	uid := uint32(os.Getuid())
+
+	if err := h.signal(pid, uid+1, syscall.SIGTERM); !errors.Is(err, ErrNotOwned) {
+		t.Fatalf("expected ErrNotOwned, got %v", err)
+	}
+	if err := h.signal(pid, uid, syscall.SIGTERM); err != nil {
+		t.Fatalf("signal: %v", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected the process to be terminated")
+	}
+	// Once reaped the process is gone.
+	if err := h.signal(pid, uid, syscall.SIGTERM); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
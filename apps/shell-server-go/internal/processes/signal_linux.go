@@ -0,0 +1,37 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendSignal opens a pidfd for pid, runs check and signals through the pidfd
+// only if check passes. The pidfd keeps referring to the process it was opened
+// for: if that process exits and its PID is reused while check reads /proc,
+// the signal fails with ESRCH instead of reaching the new process. A
+// successful signal therefore means check looked at the same process.
+func sendSignal(pid int, sig syscall.Signal, check func() error) error {
+	pidfd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return os.ErrNotExist
+		}
+		return fmt.Errorf("pidfd_open %d: %w", pid, err)
+	}
+	defer unix.Close(pidfd)
+
+	if err := check(); err != nil {
+		return err
+	}
+	if err := unix.PidfdSendSignal(pidfd, sig, nil, 0); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return os.ErrNotExist
+		}
+		return fmt.Errorf("pidfd_send_signal %d: %w", pid, err)
+	}
+	return nil
+}
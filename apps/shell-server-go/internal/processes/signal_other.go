@@ -0,0 +1,25 @@
+//go:build !linux
+
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// sendSignal runs check and signals pid. Without pidfds a PID reused between
+// the two cannot be detected.
+func sendSignal(pid int, sig syscall.Signal, check func() error) error {
+	if err := check(); err != nil {
+		return err
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrNotExist
+		}
+		return fmt.Errorf("kill %d: %w", pid, err)
+	}
+	return nil
+}
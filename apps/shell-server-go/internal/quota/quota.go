@@ -0,0 +1,432 @@
+// Package quota tracks and enforces per-site disk usage.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/logger"
+)
+
+// RescanInterval is how often cached usage is recounted from disk. Writes made
+// through the API update the cache right away; the rescan picks up everything
+// else (terminals, dev servers) and corrects drift.
+const RescanInterval = 10 * time.Minute
+
+const (
+	ResourceBytes  = "bytes"
+	ResourceInodes = "inodes"
+)
+
+var log = logger.WithComponent("QUOTA")
+
+// ErrExceeded is wrapped by every ExceededError.
+var ErrExceeded = errors.New("site quota exceeded")
+
+// ExceededError reports which limit a write would have crossed.
+type ExceededError struct {
+	Site      string
+	Resource  string
+	Used      int64
+	Requested int64
+	Limit     int64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("site %s: %s quota exceeded (%d used + %d requested > %d)", e.Site, e.Resource, e.Used, e.Requested, e.Limit)
+}
+
+func (e *ExceededError) Unwrap() error {
+	return ErrExceeded
+}
+
+// Usage is the usage and limits of one site. Limits of zero mean unlimited.
+type Usage struct {
+	Site        string    `json:"site"`
+	Bytes       int64     `json:"bytes"`
+	Inodes      int64     `json:"inodes"`
+	LimitBytes  int64     `json:"limitBytes,omitempty"`
+	LimitInodes int64     `json:"limitInodes,omitempty"`
+	ScannedAt   time.Time `json:"scannedAt"`
+}
+
+// siteUsage is the cached state of one site. reservedBytes and reservedInodes
+// belong to writes in flight and count against the limits until committed.
+type siteUsage struct {
+	bytes          int64
+	inodes         int64
+	reservedBytes  int64
+	reservedInodes int64
+	scannedAt      time.Time
+	stale          bool
+}
+
+// Manager keeps an incrementally updated usage cache for every site below the
+// sites path. A site's usage covers its whole directory, including its trash.
+type Manager struct {
+	cfg       *config.AppConfig
+	sitesPath string
+
+	mu       sync.Mutex
+	sites    map[string]*siteUsage
+	started  bool
+	stopScan chan struct{}
+	scanDone chan struct{}
+}
+
+// NewManager creates a quota manager from the application config.
+func NewManager(cfg *config.AppConfig) *Manager {
+	return &Manager{
+		cfg:       cfg,
+		sitesPath: cfg.ResolvedSitesPath,
+		sites:     make(map[string]*siteUsage),
+		stopScan:  make(chan struct{}),
+		scanDone:  make(chan struct{}),
+	}
+}
+
+// SiteFor returns the site a path belongs to, or "" outside the sites path.
+func (m *Manager) SiteFor(path string) string {
+	if m == nil || m.sitesPath == "" {
+		return ""
+	}
+	bases := []string{m.sitesPath}
+	if real, err := filepath.EvalSymlinks(m.sitesPath); err == nil && real != m.sitesPath {
+		bases = append(bases, real)
+	}
+	for _, base := range bases {
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		site := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+		if site == "" || strings.HasPrefix(site, ".") {
+			return ""
+		}
+		return site
+	}
+	return ""
+}
+
+// limited reports whether a site has any limit.
+func (m *Manager) limited(site string) bool {
+	limit := m.cfg.QuotaFor(site)
+	return limit.Bytes > 0 || limit.Inodes > 0
+}
+
+// DirUsage counts the bytes of regular files and the entries (inodes) below
+// path, path itself included. Symlinks are counted, not followed.
+func DirUsage(path string) (bytes, inodes int64, err error) {
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrNotExist) || errors.Is(walkErr, fs.ErrPermission) {
+				return nil
+			}
+			return walkErr
+		}
+		inodes++
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				bytes += info.Size()
+			}
+		}
+		return nil
+	})
+	return bytes, inodes, err
+}
+
+// scan recounts a site from disk. It runs without the lock held.
+func (m *Manager) scan(site string) (*siteUsage, error) {
+	dir := filepath.Join(m.sitesPath, site)
+	bytes, inodes, err := DirUsage(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inodes > 0 {
+		inodes-- // the site directory itself
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.sites[site]
+	if !ok {
+		entry = &siteUsage{}
+		m.sites[site] = entry
+	}
+	entry.bytes = bytes
+	entry.inodes = inodes
+	entry.scannedAt = time.Now()
+	entry.stale = false
+	return entry, nil
+}
+
+// current returns the cached entry, rescanning it when missing or out of date.
+// The caller must not hold the lock.
+func (m *Manager) current(site string) error {
+	m.mu.Lock()
+	entry, ok := m.sites[site]
+	fresh := ok && !entry.stale && time.Since(entry.scannedAt) < RescanInterval
+	m.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	_, err := m.scan(site)
+	return err
+}
+
+func (m *Manager) usageLocked(site string, entry *siteUsage) Usage {
+	limit := m.cfg.QuotaFor(site)
+	return Usage{
+		Site:        site,
+		Bytes:       entry.bytes,
+		Inodes:      entry.inodes,
+		LimitBytes:  limit.Bytes,
+		LimitInodes: limit.Inodes,
+		ScannedAt:   entry.scannedAt,
+	}
+}
+
+// Usage returns a site's usage, scanning it if the cache is missing or old.
+func (m *Manager) Usage(site string) (Usage, error) {
+	if err := m.current(site); err != nil {
+		return Usage{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usageLocked(site, m.sites[site]), nil
+}
+
+// Refresh recounts a site from disk and returns its usage.
+func (m *Manager) Refresh(site string) (Usage, error) {
+	if _, err := m.scan(site); err != nil {
+		return Usage{}, err
+	}
+	return m.Usage(site)
+}
+
+// Cached returns a site's usage without touching the disk, if it is known.
+func (m *Manager) Cached(site string) (Usage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.sites[site]
+	if !ok {
+		return Usage{}, false
+	}
+	return m.usageLocked(site, entry), true
+}
+
+// checkLocked returns an ExceededError if adding bytes and inodes would cross
+// a limit. Only growth is checked, so shrinking writes always pass.
+func (m *Manager) checkLocked(site string, entry *siteUsage, bytes, inodes int64) error {
+	limit := m.cfg.QuotaFor(site)
+	if used := entry.bytes + entry.reservedBytes; limit.Bytes > 0 && bytes > 0 && used+bytes > limit.Bytes {
+		return &ExceededError{Site: site, Resource: ResourceBytes, Used: used, Requested: bytes, Limit: limit.Bytes}
+	}
+	if used := entry.inodes + entry.reservedInodes; limit.Inodes > 0 && inodes > 0 && used+inodes > limit.Inodes {
+		return &ExceededError{Site: site, Resource: ResourceInodes, Used: used, Requested: inodes, Limit: limit.Inodes}
+	}
+	return nil
+}
+
+// Check reports whether writing bytes and inodes below path would fit, without
+// reserving anything. It is for early rejection before data is even received.
+func (m *Manager) Check(path string, bytes, inodes int64) error {
+	site := m.SiteFor(path)
+	if site == "" || !m.limited(site) {
+		return nil
+	}
+	if err := m.current(site); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkLocked(site, m.sites[site], bytes, inodes)
+}
+
+// Reservation holds quota for a write in flight. Commit it once the data is on
+// disk, or Release it if the write failed. A nil Reservation is a no-op.
+type Reservation struct {
+	m      *Manager
+	site   string
+	bytes  int64
+	inodes int64
+	done   bool
+}
+
+// Reserve checks that writing bytes and inodes below path keeps its site
+// within quota and holds them, so concurrent writes cannot overshoot together.
+// Paths outside any site get a no-op reservation.
+func (m *Manager) Reserve(path string, bytes, inodes int64) (*Reservation, error) {
+	site := m.SiteFor(path)
+	if site == "" {
+		return nil, nil
+	}
+	if !m.limited(site) {
+		// Nothing to enforce; keep a cached entry up to date on commit.
+		return &Reservation{m: m, site: site, bytes: bytes, inodes: inodes}, nil
+	}
+	if err := m.current(site); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.sites[site]
+	if err := m.checkLocked(site, entry, bytes, inodes); err != nil {
+		log.Warn("Rejected write to %s: %v", path, err)
+		return nil, err
+	}
+	entry.reservedBytes += bytes
+	entry.reservedInodes += inodes
+	return &Reservation{m: m, site: site, bytes: bytes, inodes: inodes}, nil
+}
+
+func (r *Reservation) finish(commit bool) {
+	if r == nil || r.done {
+		return
+	}
+	r.done = true
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	entry, ok := r.m.sites[r.site]
+	if !ok {
+		return
+	}
+	if r.m.limited(r.site) {
+		entry.reservedBytes -= r.bytes
+		entry.reservedInodes -= r.inodes
+	}
+	if commit {
+		entry.bytes += r.bytes
+		entry.inodes += r.inodes
+	}
+}
+
+// Commit moves the reserved amounts into the site's usage.
+func (r *Reservation) Commit() {
+	r.finish(true)
+}
+
+// Release gives the reserved amounts back.
+func (r *Reservation) Release() {
+	r.finish(false)
+}
+
+// Add records a change made outside a reservation, typically a negative one
+// after a delete. It only updates a cached entry.
+func (m *Manager) Add(path string, bytes, inodes int64) {
+	site := m.SiteFor(path)
+	if site == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.sites[site]; ok {
+		entry.bytes = max(entry.bytes+bytes, 0)
+		entry.inodes = max(entry.inodes+inodes, 0)
+	}
+}
+
+// Invalidate marks the site of path for a rescan when its change is not known.
+func (m *Manager) Invalidate(path string) {
+	site := m.SiteFor(path)
+	if site == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.sites[site]; ok {
+		entry.stale = true
+	}
+}
+
+// ScanAll recounts every site directory.
+func (m *Manager) ScanAll() {
+	entries, err := os.ReadDir(m.sitesPath)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if _, err := m.scan(e.Name()); err != nil {
+			log.Warn("Failed to scan usage of %s: %v", e.Name(), err)
+		}
+	}
+}
+
+// Start rescans all sites in the background until Stop is called.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+	go m.scanLoop()
+}
+
+// scanLoop periodically recounts all sites
+func (m *Manager) scanLoop() {
+	defer close(m.scanDone)
+
+	m.ScanAll()
+	ticker := time.NewTicker(RescanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.ScanAll()
+		case <-m.stopScan:
+			return
+		}
+	}
+}
+
+// Stop stops the background rescan.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	started := m.started
+	m.started = false
+	m.mu.Unlock()
+	if !started {
+		return
+	}
+	close(m.stopScan)
+	<-m.scanDone
+	log.Debug("Quota scanner stopped")
+}
+
+// HandleError writes 507 Insufficient Storage with the exceeded limit, or 500
+// when usage could not be determined.
+func HandleError(w http.ResponseWriter, err error) {
+	var exceeded *ExceededError
+	if errors.As(err, &exceeded) {
+		response.JSON(w, http.StatusInsufficientStorage, map[string]any{
+			"error":     fmt.Sprintf("Site quota exceeded (%s)", exceeded.Resource),
+			"code":      "QUOTA_EXCEEDED",
+			"site":      exceeded.Site,
+			"resource":  exceeded.Resource,
+			"used":      exceeded.Used,
+			"requested": exceeded.Requested,
+			"limit":     exceeded.Limit,
+		})
+		return
+	}
+	log.Error("Quota check failed: %v", err)
+	response.Error(w, http.StatusInternalServerError, "Failed to check site quota")
+}
@@ -0,0 +1,129 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shell-server-go/internal/config"
+)
+
+func newTestManager(t *testing.T, limit config.QuotaLimit) (*Manager, string) {
+	t.Helper()
+	sites := filepath.Join(t.TempDir(), "sites")
+	base := filepath.Join(sites, "example.com", "user")
+	if err := os.MkdirAll(base, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "index.html"), []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg := &config.AppConfig{ResolvedSitesPath: sites, SiteQuota: limit}
+	return NewManager(cfg), base
+}
+
+func TestManager_SiteFor(t *testing.T) {
+	m, base := newTestManager(t, config.QuotaLimit{})
+
+	if site := m.SiteFor(filepath.Join(base, "a", "b.txt")); site != "example.com" {
+		t.Fatalf("expected example.com, got %q", site)
+	}
+	for _, path := range []string{m.sitesPath, filepath.Dir(m.sitesPath), filepath.Join(m.sitesPath, ".trash", "x")} {
+		if site := m.SiteFor(path); site != "" {
+			t.Fatalf("expected no site for %s, got %q", path, site)
+		}
+	}
+	var nilManager *Manager
+	if site := nilManager.SiteFor(base); site != "" {
+		t.Fatalf("nil manager returned %q", site)
+	}
+}
+
+func TestManager_ReserveCommitRelease(t *testing.T) {
+	// The site holds user/ and index.html: 10 bytes, 2 inodes.
+	m, base := newTestManager(t, config.QuotaLimit{Bytes: 100, Inodes: 5})
+	target := filepath.Join(base, "new.txt")
+
+	first, err := m.Reserve(target, 60, 1)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	// The first reservation is still held, so a second one cannot overshoot.
+	_, err = m.Reserve(target, 40, 1)
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrExceeded) || exceeded.Resource != ResourceBytes || exceeded.Used != 70 {
+		t.Fatalf("expected bytes quota error, got %v", err)
+	}
+
+	first.Release()
+	second, err := m.Reserve(target, 40, 1)
+	if err != nil {
+		t.Fatalf("reserve after release: %v", err)
+	}
+	second.Commit()
+	second.Commit() // finishing twice is a no-op
+
+	usage, err := m.Usage("example.com")
+	if err != nil || usage.Bytes != 50 || usage.Inodes != 3 || usage.LimitBytes != 100 {
+		t.Fatalf("unexpected usage %+v err=%v", usage, err)
+	}
+
+	if _, err := m.Reserve(target, 0, 3); !errors.As(err, &exceeded) || exceeded.Resource != ResourceInodes {
+		t.Fatalf("expected inodes quota error, got %v", err)
+	}
+	// Shrinking writes always pass.
+	if err := m.Check(target, -50, 0); err != nil {
+		t.Fatalf("shrinking write rejected: %v", err)
+	}
+
+	m.Add(target, -40, -1)
+	if usage, _ := m.Cached("example.com"); usage.Bytes != 10 || usage.Inodes != 2 {
+		t.Fatalf("unexpected usage after delete %+v", usage)
+	}
+}
+
+func TestManager_OutsideSitesIsUnlimited(t *testing.T) {
+	m, _ := newTestManager(t, config.QuotaLimit{Bytes: 1})
+
+	reservation, err := m.Reserve(filepath.Join(t.TempDir(), "big.bin"), 1<<30, 1)
+	if err != nil || reservation != nil {
+		t.Fatalf("expected no reservation outside sites, got %v err=%v", reservation, err)
+	}
+	reservation.Commit()
+}
+
+func TestManager_InvalidateRescans(t *testing.T) {
+	m, base := newTestManager(t, config.QuotaLimit{Bytes: 100})
+	if _, err := m.Usage("example.com"); err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "terminal.log"), []byte("12345"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if usage, _ := m.Usage("example.com"); usage.Bytes != 10 {
+		t.Fatalf("expected cached usage, got %+v", usage)
+	}
+	m.Invalidate(base)
+	if usage, _ := m.Usage("example.com"); usage.Bytes != 15 || usage.Inodes != 3 {
+		t.Fatalf("expected rescanned usage, got %+v", usage)
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleError(w, &ExceededError{Site: "example.com", Resource: ResourceBytes, Used: 90, Requested: 20, Limit: 100})
+
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507, got %d", w.Code)
+	}
+	for _, want := range []string{`"code":"QUOTA_EXCEEDED"`, `"resource":"bytes"`, `"limit":100`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("missing %s in %s", want, w.Body.String())
+		}
+	}
+}
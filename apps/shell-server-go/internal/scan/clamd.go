@@ -0,0 +1,115 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the INSTREAM chunks sent to clamd.
+const clamdChunkSize = 64 * 1024
+
+// Clamd scans content with a clamd-compatible daemon over its INSTREAM
+// command, so the daemon needs no access to the file itself.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a clamd scanner for "unix:///path", a bare socket path or
+// "tcp://host:port".
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	c := &Clamd{network: "unix", address: address, timeout: timeout}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		c.network, c.address = "tcp", strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		c.address = strings.TrimPrefix(address, "unix://")
+	}
+	return c
+}
+
+func (c *Clamd) Name() string {
+	return "clamd"
+}
+
+func (c *Clamd) Scan(ctx context.Context, target Target) ([]Finding, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	file, err := target.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := c.stream(conn, file); err != nil {
+		return nil, fmt.Errorf("stream to clamd: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return c.parseReply(target.Name, strings.TrimRight(reply, "\x00\n"))
+}
+
+// stream sends content as length-prefixed chunks, ended by a zero length.
+func (c *Clamd) stream(conn net.Conn, src io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := conn.Write(size[:]); werr != nil {
+				return werr
+			}
+			if _, werr := conn.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	_, err := conn.Write(size[:])
+	return err
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR".
+func (c *Clamd) parseReply(name, reply string) ([]Finding, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return nil, nil
+	case strings.HasSuffix(result, " FOUND"):
+		signature := strings.TrimSuffix(result, " FOUND")
+		return []Finding{{Path: name, Scanner: c.Name(), Rule: RuleMalware, Detail: signature}}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
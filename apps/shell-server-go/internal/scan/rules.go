@@ -0,0 +1,195 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/filetype"
+)
+
+// Rules is the built-in scanner. Extensions are matched case-insensitively
+// against every extension of a name, so "shell.php.jpg" matches ".php"; MIME
+// types are sniffed from the content and matched exactly or as "type/*".
+type Rules struct {
+	denyExtensions []string
+	denyMIMETypes  []string
+	maxBytes       int64
+	sizeRules      []sizeRule
+}
+
+type sizeRule struct {
+	extensions []string
+	mimeTypes  []string
+	maxBytes   int64
+}
+
+// NewRules builds the rule scanner from cfg.
+func NewRules(cfg config.UploadScanConfig) *Rules {
+	r := &Rules{
+		denyExtensions: normalizeExtensions(cfg.DenyExtensions),
+		denyMIMETypes:  normalizeMIMETypes(cfg.DenyMIMETypes),
+		maxBytes:       cfg.MaxFileBytes,
+	}
+	for _, rule := range cfg.SizeRules {
+		r.sizeRules = append(r.sizeRules, sizeRule{
+			extensions: normalizeExtensions(rule.Extensions),
+			mimeTypes:  normalizeMIMETypes(rule.MIMETypes),
+			maxBytes:   rule.MaxBytes,
+		})
+	}
+	return r
+}
+
+func (r *Rules) enabled() bool {
+	return len(r.denyExtensions) > 0 || len(r.denyMIMETypes) > 0 || r.maxBytes > 0 || len(r.sizeRules) > 0
+}
+
+// needsMIMEType reports whether any rule depends on the sniffed type, so
+// content is only read when it matters.
+func (r *Rules) needsMIMEType() bool {
+	if len(r.denyMIMETypes) > 0 {
+		return true
+	}
+	for _, rule := range r.sizeRules {
+		if len(rule.mimeTypes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rules) Name() string {
+	return "rules"
+}
+
+func (r *Rules) Scan(_ context.Context, target Target) ([]Finding, error) {
+	var findings []Finding
+	add := func(rule, detail string) {
+		findings = append(findings, Finding{Path: target.Name, Scanner: r.Name(), Rule: rule, Detail: detail})
+	}
+
+	extensions := nameExtensions(target.Name)
+	if ext, ok := matchExtension(extensions, r.denyExtensions); ok {
+		add(RuleExtension, fmt.Sprintf("extension %s is not allowed", ext))
+	}
+
+	mimeType := ""
+	if r.needsMIMEType() {
+		file, err := target.Open()
+		if err != nil {
+			return nil, err
+		}
+		info, err := filetype.DetectReader(file, path.Base(target.Name))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		mimeType = mediaType(info.MIMEType)
+		if matchMIMEType(mimeType, r.denyMIMETypes) {
+			add(RuleMIMEType, fmt.Sprintf("content type %s is not allowed", mimeType))
+		}
+	}
+
+	if r.maxBytes > 0 && target.Size > r.maxBytes {
+		add(RuleSize, fmt.Sprintf("%d bytes exceeds the limit of %d", target.Size, r.maxBytes))
+	}
+	for _, rule := range r.sizeRules {
+		ext, extOK := matchExtension(extensions, rule.extensions)
+		if !extOK && !matchMIMEType(mimeType, rule.mimeTypes) {
+			continue
+		}
+		if target.Size > rule.maxBytes {
+			what := mimeType
+			if extOK {
+				what = ext
+			}
+			add(RuleSize, fmt.Sprintf("%d bytes exceeds the %s limit of %d", target.Size, what, rule.maxBytes))
+		}
+	}
+	return findings, nil
+}
+
+// nameExtensions returns every run of extensions of a name's base, lower
+// case: "Site.tar.GZ" gives ".tar", ".tar.gz" and ".gz". The name of a dotfile
+// counts as an extension too, so ".htaccess" can be denied.
+func nameExtensions(name string) []string {
+	base := strings.ToLower(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	trimmed := strings.TrimLeft(base, ".")
+	if trimmed == "" {
+		return nil
+	}
+	parts := strings.Split(trimmed, ".")
+	first := 1
+	if trimmed != base {
+		first = 0
+	}
+	var extensions []string
+	for i := first; i < len(parts); i++ {
+		for j := i + 1; j <= len(parts); j++ {
+			extensions = append(extensions, "."+strings.Join(parts[i:j], "."))
+		}
+	}
+	return extensions
+}
+
+func matchExtension(extensions, rules []string) (string, bool) {
+	for _, rule := range rules {
+		for _, ext := range extensions {
+			if ext == rule {
+				return rule, true
+			}
+		}
+	}
+	return "", false
+}
+
+func matchMIMEType(mimeType string, rules []string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, rule := range rules {
+		if rule == mimeType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(rule, "/*"); ok && strings.HasPrefix(mimeType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeExtensions(extensions []string) []string {
+	normalized := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized
+}
+
+func normalizeMIMETypes(mimeTypes []string) []string {
+	normalized := make([]string, 0, len(mimeTypes))
+	for _, mimeType := range mimeTypes {
+		if mimeType = strings.ToLower(strings.TrimSpace(mimeType)); mimeType != "" {
+			normalized = append(normalized, mimeType)
+		}
+	}
+	return normalized
+}
+
+func mediaType(mimeType string) string {
+	if parsed, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return parsed
+	}
+	return mimeType
+}
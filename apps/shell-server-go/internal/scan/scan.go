@@ -0,0 +1,108 @@
+// Package scan checks uploaded files before they are written to a workspace.
+//
+// A Scanner looks at one file at a time: a whole upload, or one entry of an
+// uploaded archive. The built-in Rules scanner applies extension, MIME type
+// and size rules; Clamd streams content to a clamd-compatible daemon.
+package scan
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"shell-server-go/internal/config"
+)
+
+// Modes of config.UploadScanConfig.
+const (
+	ModeBlock  = "block"
+	ModeReport = "report"
+)
+
+// Rules reported in findings.
+const (
+	RuleExtension = "extension"
+	RuleMIMEType  = "mime"
+	RuleSize      = "size"
+	RuleMalware   = "malware"
+)
+
+// DefaultClamdTimeout bounds one clamd scan when the config sets none.
+const DefaultClamdTimeout = 30 * time.Second
+
+// Finding is one problem a scanner reported. Path is the upload name or the
+// archive entry name.
+type Finding struct {
+	Path    string `json:"path"`
+	Scanner string `json:"scanner"`
+	Rule    string `json:"rule"`
+	Detail  string `json:"detail"`
+}
+
+// Target is one file to scan. Open may be called more than once.
+type Target struct {
+	Name string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// FileTarget scans the file at path under the given name.
+func FileTarget(name, path string, size int64) Target {
+	return Target{
+		Name: name,
+		Size: size,
+		Open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}
+}
+
+// Scanner checks one target. An error means the target could not be checked,
+// which is different from a clean result.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, target Target) ([]Finding, error)
+}
+
+// Chain runs scanners in order and returns every finding.
+type Chain []Scanner
+
+func (c Chain) Name() string {
+	return "chain"
+}
+
+func (c Chain) Scan(ctx context.Context, target Target) ([]Finding, error) {
+	var findings []Finding
+	for _, s := range c {
+		found, err := s.Scan(ctx, target)
+		if err != nil {
+			return findings, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// New builds the scanners cfg enables: Rules when any rule is set, then
+// Clamd when an address is set. It returns nil when nothing is enabled.
+func New(cfg config.UploadScanConfig) Scanner {
+	var chain Chain
+	if rules := NewRules(cfg); rules.enabled() {
+		chain = append(chain, rules)
+	}
+	if cfg.ClamdAddress != "" {
+		timeout := DefaultClamdTimeout
+		if cfg.ClamdTimeoutSeconds > 0 {
+			timeout = time.Duration(cfg.ClamdTimeoutSeconds) * time.Second
+		}
+		chain = append(chain, NewClamd(cfg.ClamdAddress, timeout))
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain
+}
+
+// Blocks reports whether findings reject an upload in the given mode.
+func Blocks(mode string, findings []Finding) bool {
+	return len(findings) > 0 && mode != ModeReport
+}
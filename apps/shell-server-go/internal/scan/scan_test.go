@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shell-server-go/internal/config"
+)
+
+func bytesTarget(name, content string) Target {
+	return Target{
+		Name: name,
+		Size: int64(len(content)),
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(content)), nil },
+	}
+}
+
+func rulesOf(findings []Finding) []string {
+	rules := make([]string, len(findings))
+	for i, f := range findings {
+		rules[i] = f.Rule
+	}
+	return rules
+}
+
+func TestRules_Extensions(t *testing.T) {
+	rules := NewRules(config.UploadScanConfig{DenyExtensions: []string{"PHP", ".htaccess", ".tar.gz"}})
+
+	for name, denied := range map[string]bool{
+		"index.php":          true,
+		"shell.PHP.jpg":      true,
+		"dir/.htaccess":      true,
+		"backup.tar.gz":      true,
+		"photo.jpg":          false,
+		"phpinfo.txt":        false,
+		"notes.tar":          false,
+		"archive.tar.gz.sig": true,
+	} {
+		findings, err := rules.Scan(context.Background(), bytesTarget(name, "x"))
+		if err != nil {
+			t.Fatalf("scan %s: %v", name, err)
+		}
+		if got := len(findings) > 0; got != denied {
+			t.Fatalf("%s: denied=%v, want %v (%+v)", name, got, denied, findings)
+		}
+	}
+}
+
+func TestRules_MIMETypesAndSizes(t *testing.T) {
+	rules := NewRules(config.UploadScanConfig{
+		DenyMIMETypes: []string{"application/x-executable"},
+		MaxFileBytes:  100,
+		SizeRules: []config.ScanSizeRule{
+			{MIMETypes: []string{"image/*"}, MaxBytes: 10},
+			{Extensions: []string{".log"}, MaxBytes: 4},
+		},
+	})
+
+	findings, _ := rules.Scan(context.Background(), bytesTarget("tool.txt", "\x7fELF\x02\x01\x01"))
+	if got := strings.Join(rulesOf(findings), ","); got != RuleMIMEType {
+		t.Fatalf("executable renamed to .txt: got %q", got)
+	}
+
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 20)
+	findings, _ = rules.Scan(context.Background(), bytesTarget("logo.png", png))
+	if got := strings.Join(rulesOf(findings), ","); got != RuleSize {
+		t.Fatalf("oversized image: got %q", got)
+	}
+
+	findings, _ = rules.Scan(context.Background(), bytesTarget("app.log", strings.Repeat("a", 101)))
+	if got := strings.Join(rulesOf(findings), ","); got != "size,size" {
+		t.Fatalf("oversized log: got %q", got)
+	}
+
+	findings, _ = rules.Scan(context.Background(), bytesTarget("notes.txt", "hello"))
+	if len(findings) != 0 {
+		t.Fatalf("clean file flagged: %+v", findings)
+	}
+}
+
+// fakeClamd answers INSTREAM requests, reporting content containing "EICAR"
+// as infected.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					io.WriteString(conn, "UNKNOWN COMMAND\x00")
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if bytes.Contains(content.Bytes(), []byte("EICAR")) {
+					io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+					return
+				}
+				io.WriteString(conn, "stream: OK\x00")
+			}(conn)
+		}
+	}()
+	return socket
+}
+
+func TestClamd_StreamsContent(t *testing.T) {
+	clamd := NewClamd("unix://"+fakeClamd(t), time.Second)
+
+	findings, err := clamd.Scan(context.Background(), bytesTarget("clean.txt", strings.Repeat("a", 3*clamdChunkSize)))
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("clean content: %+v %v", findings, err)
+	}
+
+	content := strings.Repeat("a", clamdChunkSize) + "EICAR"
+	findings, err = clamd.Scan(context.Background(), bytesTarget("virus.com", content))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Rule != RuleMalware || findings[0].Detail != "Eicar-Test-Signature" {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+}
+
+func TestClamd_ReportsErrors(t *testing.T) {
+	clamd := NewClamd(filepath.Join(t.TempDir(), "missing.sock"), time.Second)
+	if _, err := clamd.Scan(context.Background(), bytesTarget("a.txt", "a")); err == nil {
+		t.Fatal("expected an error without a daemon")
+	}
+	if _, err := clamd.parseReply("a.txt", "INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Fatal("expected an error for an ERROR reply")
+	}
+}
+
+func TestNew_BuildsConfiguredChain(t *testing.T) {
+	if New(config.UploadScanConfig{Mode: ModeReport}) != nil {
+		t.Fatal("expected no scanner without rules or clamd")
+	}
+	chain, ok := New(config.UploadScanConfig{DenyExtensions: []string{".exe"}, ClamdAddress: "tcp://127.0.0.1:3310"}).(Chain)
+	if !ok || len(chain) != 2 || chain[0].Name() != "rules" || chain[1].Name() != "clamd" {
+		t.Fatalf("unexpected chain: %#v", chain)
+	}
+	if Blocks(ModeReport, []Finding{{}}) || !Blocks("", []Finding{{}}) || Blocks(ModeBlock, nil) {
+		t.Fatal("Blocks disagrees with the mode")
+	}
+}
@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Options tune Create and Restore.
+type Options struct {
+	// Label describes the snapshot.
+	Label string
+	// Check is called with an upper bound of the bytes and inodes the
+	// operation adds before anything is written, so the caller can enforce a
+	// quota. A nil Check allows everything.
+	Check func(bytes, inodes int64) error
+}
+
+func (o Options) check(bytes, inodes int64) error {
+	if o.Check == nil || (bytes <= 0 && inodes <= 0) {
+		return nil
+	}
+	return o.Check(max(bytes, 0), max(inodes, 0))
+}
+
+// Create snapshots the workspace at base into the store at dir. Files whose
+// size and modification time match the latest snapshot reuse its hash without
+// being read again.
+func (m *Manager) Create(dir, base string, opts Options) (*Snapshot, error) {
+	unlock := m.lock(dir)
+	defer unlock()
+	return m.create(dir, base, opts)
+}
+
+func (m *Manager) create(dir, base string, opts Options) (*Snapshot, error) {
+	existing, err := m.loadAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxSnapshots {
+		return nil, ErrTooMany
+	}
+
+	entries, err := scan(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous map[string]Entry
+	if len(existing) > 0 {
+		previous = make(map[string]Entry, len(existing[0].Entries))
+		for _, e := range existing[0].Entries {
+			previous[e.Path] = e
+		}
+	}
+
+	// Everything not known from the previous snapshot may become a new object.
+	var newBytes, newObjects int64
+	for i, e := range entries {
+		if e.Type != TypeFile {
+			continue
+		}
+		if prev, ok := previous[e.Path]; ok && unchanged(prev, e) && hasObject(dir, prev.Hash) {
+			entries[i].Hash = prev.Hash
+			continue
+		}
+		newBytes += e.Size
+		newObjects++
+	}
+	if err := opts.check(newBytes, newObjects+1); err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		ID:        newID(),
+		Label:     opts.Label,
+		CreatedAt: time.Now().UTC(),
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Type == TypeFile && e.Hash == "" {
+			hash, size, added, err := storeFile(dir, filepath.Join(base, filepath.FromSlash(e.Path)))
+			if os.IsNotExist(err) {
+				continue // removed since the scan
+			}
+			if err != nil {
+				return nil, err
+			}
+			e.Hash, e.Size = hash, size
+			snap.AddedBytes += added
+		}
+		switch e.Type {
+		case TypeFile:
+			snap.Files++
+			snap.Bytes += e.Size
+		case TypeDirectory:
+			snap.Dirs++
+		}
+		kept = append(kept, e)
+	}
+	snap.Entries = kept
+
+	if err := writeManifest(dir, snap); err != nil {
+		return nil, err
+	}
+	log.Info("Created snapshot %s of %s (%d files, %d bytes, %d new)", snap.ID, base, snap.Files, snap.Bytes, snap.AddedBytes)
+	return snap, nil
+}
@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"path/filepath"
+)
+
+// Change statuses, from the older side to the newer one.
+const (
+	StatusAdded       = "added"
+	StatusRemoved     = "removed"
+	StatusModified    = "modified"
+	StatusTypeChanged = "typeChanged"
+)
+
+// MaxChanges bounds the changes a diff returns.
+const MaxChanges = 10000
+
+// Live names the current workspace as a side of a diff.
+const Live = "live"
+
+// Change is one path that differs between two sides of a diff. Type is the
+// entry type on the newer side, or on the older one for removed paths.
+type Change struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Type    string `json:"type"`
+	OldSize int64  `json:"oldSize,omitempty"`
+	NewSize int64  `json:"newSize,omitempty"`
+}
+
+// DiffResult lists the changes from one side to the other, in path order.
+type DiffResult struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Path      string   `json:"path,omitempty"`
+	Changes   []Change `json:"changes"`
+	Truncated bool     `json:"truncated"`
+}
+
+// Diff compares snapshot from with snapshot to, or with the live workspace at
+// base when to is Live, limited to scope (a workspace-relative path, or ""
+// for everything). Live files whose size and modification time match the
+// snapshot count as unchanged; others are hashed.
+func (m *Manager) Diff(dir, base, from, to, scope string) (*DiffResult, error) {
+	older, err := m.Get(dir, from)
+	if err != nil {
+		return nil, err
+	}
+	var newer []Entry
+	if to == Live {
+		if newer, err = scan(base); err != nil {
+			return nil, err
+		}
+	} else {
+		snap, err := m.Get(dir, to)
+		if err != nil {
+			return nil, err
+		}
+		newer = snap.Entries
+	}
+
+	result := &DiffResult{From: from, To: to, Path: scope, Changes: []Change{}}
+	add := func(c Change) bool {
+		if len(result.Changes) >= MaxChanges {
+			result.Truncated = true
+			return false
+		}
+		result.Changes = append(result.Changes, c)
+		return true
+	}
+
+	hashLive := func(e *Entry) {
+		if to == Live && e.Type == TypeFile && e.Hash == "" {
+			e.Hash, _ = hashFile(filepath.Join(base, filepath.FromSlash(e.Path)))
+		}
+	}
+	compare(scoped(older.Entries, scope), scoped(newer, scope), func(a, b *Entry) bool {
+		switch {
+		case a == nil:
+			return add(Change{Path: b.Path, Status: StatusAdded, Type: b.Type, NewSize: b.Size})
+		case b == nil:
+			return add(Change{Path: a.Path, Status: StatusRemoved, Type: a.Type, OldSize: a.Size})
+		case a.Type != b.Type:
+			return add(Change{Path: b.Path, Status: StatusTypeChanged, Type: b.Type, OldSize: a.Size, NewSize: b.Size})
+		}
+		if !unchanged(*a, *b) {
+			hashLive(b)
+		}
+		if !sameContent(*a, *b) {
+			return add(Change{Path: b.Path, Status: StatusModified, Type: b.Type, OldSize: a.Size, NewSize: b.Size})
+		}
+		return true
+	})
+	return result, nil
+}
+
+// sameContent reports whether two entries of the same type hold the same
+// content and mode. A live file that was not hashed is compared by size and
+// modification time.
+func sameContent(a, b Entry) bool {
+	if a.Mode != b.Mode {
+		return false
+	}
+	switch a.Type {
+	case TypeFile:
+		if a.Hash != "" && b.Hash != "" {
+			return a.Hash == b.Hash
+		}
+		return unchanged(a, b)
+	case TypeSymlink:
+		return a.Target == b.Target
+	}
+	return true
+}
+
+// scoped returns the entries of a sorted list within scope.
+func scoped(entries []Entry, scope string) []Entry {
+	if scope == "" {
+		return entries
+	}
+	var out []Entry
+	for _, e := range entries {
+		if inScope(e.Path, scope) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// compare walks two sorted entry lists together, calling fn with the entry of
+// each path on either side (nil where it is missing) until fn returns false.
+func compare(older, newer []Entry, fn func(a, b *Entry) bool) {
+	i, j := 0, 0
+	for i < len(older) || j < len(newer) {
+		var a, b *Entry
+		switch {
+		case j >= len(newer) || i < len(older) && pathLess(older[i].Path, newer[j].Path):
+			a = &older[i]
+			i++
+		case i >= len(older) || pathLess(newer[j].Path, older[i].Path):
+			b = &newer[j]
+			j++
+		default:
+			a, b = &older[i], &newer[j]
+			i++
+			j++
+		}
+		if !fn(a, b) {
+			return
+		}
+	}
+}
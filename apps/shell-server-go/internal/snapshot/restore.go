@@ -0,0 +1,221 @@
+package snapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shell-server-go/internal/fsutil"
+)
+
+// RestoreOptions tune Restore.
+type RestoreOptions struct {
+	Options
+	// Owner receives restored entries. Modes are the recorded ones minus its
+	// umask; a nil Owner sets them as recorded.
+	Owner *fsutil.Owner
+	// Backup snapshots the whole workspace first, so the restore can itself
+	// be undone.
+	Backup bool
+}
+
+// RestoreResult tells what a restore changed.
+type RestoreResult struct {
+	ID       string `json:"id"`
+	Path     string `json:"path,omitempty"`
+	Backup   string `json:"backup,omitempty"`
+	Restored int    `json:"restored"`
+	Removed  int    `json:"removed"`
+}
+
+var errOutside = errors.New("restore target leaves the workspace")
+
+// Restore makes the workspace at base match snapshot id within scope (a
+// workspace-relative path, or "" for everything): entries missing from the
+// snapshot are deleted, and missing or changed ones are written back with
+// their recorded mode and modification time. Ignored paths are left alone,
+// including ones inside a directory the snapshot lacks.
+// A failure part way leaves the workspace partly restored; the backup
+// snapshot, when taken, holds the state before.
+func (m *Manager) Restore(dir, base, id, scope string, opts RestoreOptions) (*RestoreResult, error) {
+	unlock := m.lock(dir)
+	defer unlock()
+
+	snap, err := m.Get(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	target := scoped(snap.Entries, scope)
+	if scope != "" && (len(target) == 0 || target[0].Path != scope) {
+		return nil, ErrPathNotFound
+	}
+	live, err := scan(base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plan the removals and writes, and what they do to the workspace size.
+	var remove, write []Entry
+	var bytes, inodes int64
+	compare(scoped(live, scope), target, func(cur, want *Entry) bool {
+		if cur != nil && want != nil && cur.Type == want.Type {
+			if !unchanged(*want, *cur) && cur.Type == TypeFile {
+				cur.Hash, _ = hashFile(filepath.Join(base, filepath.FromSlash(cur.Path)))
+			}
+			if !sameContent(*want, *cur) {
+				write = append(write, *want)
+				bytes += want.Size - cur.Size
+			}
+			return true
+		}
+		if cur != nil {
+			remove = append(remove, *cur)
+			bytes -= cur.Size
+			inodes--
+		}
+		if want != nil {
+			write = append(write, *want)
+			bytes += want.Size
+			inodes++
+		}
+		return true
+	})
+	if err := opts.check(bytes, inodes); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{ID: id, Path: scope}
+	if opts.Backup {
+		backup, err := m.create(dir, base, Options{Label: "Before restoring " + id})
+		if err != nil {
+			return nil, fmt.Errorf("backup snapshot: %w", err)
+		}
+		result.Backup = backup.ID
+	}
+
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return nil, err
+	}
+	if scope != "" {
+		// Directories above the scope may be gone.
+		parent := filepath.Dir(filepath.Join(realBase, filepath.FromSlash(scope)))
+		if err := opts.Owner.MkdirAll(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	// Children go before their parents when removing, after them when writing.
+	// A removed directory still holding ignored entries stays, unless the
+	// snapshot has something else at its path.
+	replaced := make(map[string]bool, len(write))
+	for _, e := range write {
+		replaced[e.Path] = true
+	}
+	for i := len(remove) - 1; i >= 0; i-- {
+		e := remove[i]
+		path := filepath.Join(realBase, filepath.FromSlash(e.Path))
+		err := os.Remove(path)
+		if err != nil && e.Type == TypeDirectory && replaced[e.Path] {
+			err = os.RemoveAll(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			if e.Type == TypeDirectory && !replaced[e.Path] {
+				continue
+			}
+			return nil, err
+		}
+		result.Removed++
+	}
+	for _, e := range write {
+		if err := restoreEntry(dir, realBase, e, opts.Owner); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", e.Path, err)
+		}
+		result.Restored++
+	}
+
+	log.Info("Restored snapshot %s into %s (path=%q, %d written, %d removed)", id, base, scope, result.Restored, result.Removed)
+	return result, nil
+}
+
+// restoreEntry writes one snapshot entry below realBase. Files and symlinks
+// are written next to their destination and renamed over it, so a symlink at
+// the destination is replaced rather than written through.
+func restoreEntry(dir, realBase string, e Entry, owner *fsutil.Owner) error {
+	path := filepath.Join(realBase, filepath.FromSlash(e.Path))
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if parent != realBase && !strings.HasPrefix(parent, realBase+string(filepath.Separator)) {
+		return errOutside
+	}
+	path = filepath.Join(parent, filepath.Base(path))
+
+	switch e.Type {
+	case TypeDirectory:
+		if info, err := os.Lstat(path); err != nil || !info.IsDir() {
+			if err := os.Mkdir(path, 0700); err != nil {
+				return err
+			}
+		}
+		return setMode(owner, path, e.Mode)
+
+	case TypeSymlink:
+		tmp := tempName(parent)
+		if err := os.Symlink(e.Target, tmp); err != nil {
+			return err
+		}
+		if err := owner.Apply(tmp, 0); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return renameOver(tmp, path)
+
+	case TypeFile:
+		object, err := openObject(dir, e.Hash)
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+		tmp := tempName(parent)
+		err = owner.CreateFile(tmp, object, e.Mode)
+		if err == nil {
+			err = setMode(owner, tmp, e.Mode)
+		}
+		if err == nil {
+			err = os.Chtimes(tmp, e.ModTime, e.ModTime)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return renameOver(tmp, path)
+	}
+	return nil
+}
+
+func setMode(owner *fsutil.Owner, path string, mode os.FileMode) error {
+	if owner == nil {
+		return fsutil.Lchmod(path, mode)
+	}
+	return owner.Apply(path, mode)
+}
+
+func tempName(dir string) string {
+	var b [6]byte
+	rand.Read(b[:])
+	return filepath.Join(dir, ".snapshot-restore-"+hex.EncodeToString(b[:]))
+}
+
+func renameOver(tmp, path string) error {
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
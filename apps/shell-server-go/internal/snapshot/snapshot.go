@@ -0,0 +1,337 @@
+// Package snapshot keeps restorable checkpoints of site workspaces.
+//
+// A snapshot is a manifest listing every entry of the workspace with its
+// mode, size and modification time, and for files the SHA-256 of their
+// content. Contents live once in a shared object store, so unchanged files
+// cost nothing in later snapshots. Each site's store lives in server state
+// private to the server, since restores run with its privileges and must not
+// read objects a site user has swapped for symlinks. Paths hidden by the
+// workspace's ignore rules (see package ignore) are neither captured nor
+// touched by a restore.
+package snapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shell-server-go/internal/fsutil"
+	"shell-server-go/internal/logger"
+)
+
+// Limits per site.
+const (
+	MaxSnapshots = 50
+	MaxEntries   = 100000
+	MaxLabelLen  = 200
+)
+
+// Entry types.
+const (
+	TypeFile      = "file"
+	TypeDirectory = "directory"
+	TypeSymlink   = "symlink"
+)
+
+const (
+	manifestsDir = "manifests"
+	objectsDir   = "objects"
+	tmpDir       = "tmp"
+)
+
+var log = logger.WithComponent("SNAPSHOT")
+
+var (
+	ErrNotFound     = errors.New("snapshot not found")
+	ErrPathNotFound = errors.New("path not in snapshot")
+	ErrTooMany      = errors.New("too many snapshots")
+	ErrTooLarge     = errors.New("workspace has too many entries to snapshot")
+	ErrCorrupt      = errors.New("snapshot manifest is corrupt")
+	ErrInvalidSite  = errors.New("invalid snapshot site")
+)
+
+var (
+	idRegex   = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}-[0-9a-f]{8}$`)
+	hashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Entry is one path of a snapshot. Size and Hash are set for files, Target
+// for symlinks.
+type Entry struct {
+	Path    string      `json:"path"`
+	Type    string      `json:"type"`
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mtime"`
+	Hash    string      `json:"hash,omitempty"`
+	Target  string      `json:"target,omitempty"`
+}
+
+// Snapshot is a manifest. Bytes is the total size of its files; AddedBytes is
+// what its creation added to the object store.
+type Snapshot struct {
+	ID         string    `json:"id"`
+	Label      string    `json:"label,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Files      int       `json:"files"`
+	Dirs       int       `json:"dirs"`
+	Bytes      int64     `json:"bytes"`
+	AddedBytes int64     `json:"addedBytes"`
+	Entries    []Entry   `json:"entries,omitempty"`
+}
+
+// Info describes a listed snapshot. UniqueBytes is the content no other
+// snapshot shares, which deleting it would free.
+type Info struct {
+	Snapshot
+	UniqueBytes int64 `json:"uniqueBytes"`
+}
+
+// Manager serializes changes per snapshot store: creating, restoring and
+// deleting in one site wait for each other, listing and diffing do not.
+type Manager struct {
+	root  string
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewManager creates a snapshot manager keeping one store per site under
+// root, which must be server state outside every workspace.
+func NewManager(root string) *Manager {
+	return &Manager{root: root, locks: make(map[string]*sync.Mutex)}
+}
+
+// Dir returns the store directory of a site without creating it.
+func (m *Manager) Dir(site string) (string, error) {
+	if site == "" || site == "." || site == ".." || strings.ContainsAny(site, `/\`) {
+		return "", ErrInvalidSite
+	}
+	return filepath.Join(m.root, site), nil
+}
+
+// Store returns the store directory of a site, creating it and the root with
+// mode 0700 when missing. A store that is a symlink or not owned by the
+// server is refused.
+func (m *Manager) Store(site string) (string, error) {
+	dir, err := m.Dir(site)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.root), 0755); err != nil {
+		return "", err
+	}
+	if err := fsutil.PrivateDir(m.root); err != nil {
+		return "", err
+	}
+	if err := fsutil.PrivateDir(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func (m *Manager) lock(dir string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[dir] = l
+	}
+	m.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func newID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b[:])
+}
+
+func manifestPath(dir, id string) string {
+	return filepath.Join(dir, manifestsDir, id+".json")
+}
+
+func objectPath(dir, hash string) string {
+	return filepath.Join(dir, objectsDir, hash[:2], hash)
+}
+
+// Get loads a snapshot with its entries.
+func (m *Manager) Get(dir, id string) (*Snapshot, error) {
+	if !idRegex.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(manifestPath(dir, id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	// Hashes name object files, so a malformed one could point outside the store.
+	for _, e := range snap.Entries {
+		if (e.Type == TypeFile || e.Hash != "") && !hashRegex.MatchString(e.Hash) {
+			return nil, fmt.Errorf("%w: %s has an invalid hash", ErrCorrupt, e.Path)
+		}
+	}
+	return &snap, nil
+}
+
+// loadAll reads every manifest of a store, newest first.
+func (m *Manager) loadAll(dir string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, manifestsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []*Snapshot
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !idRegex.MatchString(id) {
+			continue
+		}
+		snap, err := m.Get(dir, id)
+		if err != nil {
+			log.Warn("Skipping unreadable snapshot %s: %v", id, err)
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// List returns the snapshots of a store, newest first, and the size of its
+// object store.
+func (m *Manager) List(dir string) ([]Info, int64, error) {
+	snaps, err := m.loadAll(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count which snapshots reference each object.
+	refs := make(map[string]int)
+	sizes := make(map[string]int64)
+	for _, snap := range snaps {
+		seen := make(map[string]bool)
+		for _, e := range snap.Entries {
+			if e.Hash != "" && !seen[e.Hash] {
+				seen[e.Hash] = true
+				refs[e.Hash]++
+				sizes[e.Hash] = e.Size
+			}
+		}
+	}
+	var storeBytes int64
+	for _, size := range sizes {
+		storeBytes += size
+	}
+
+	infos := make([]Info, 0, len(snaps))
+	for _, snap := range snaps {
+		info := Info{Snapshot: *snap}
+		info.Entries = nil
+		seen := make(map[string]bool)
+		for _, e := range snap.Entries {
+			if e.Hash != "" && !seen[e.Hash] && refs[e.Hash] == 1 {
+				seen[e.Hash] = true
+				info.UniqueBytes += e.Size
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, storeBytes, nil
+}
+
+// Delete removes a snapshot and the objects no other snapshot references.
+// It returns the bytes freed.
+func (m *Manager) Delete(dir, id string) (int64, error) {
+	unlock := m.lock(dir)
+	defer unlock()
+
+	if _, err := m.Get(dir, id); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(manifestPath(dir, id)); err != nil {
+		return 0, err
+	}
+	freed := m.collectGarbage(dir)
+	log.Info("Deleted snapshot %s from %s (%d bytes freed)", id, dir, freed)
+	return freed, nil
+}
+
+// collectGarbage removes objects no manifest references. The caller holds
+// the store lock, so no snapshot is being created meanwhile.
+func (m *Manager) collectGarbage(dir string) int64 {
+	snaps, err := m.loadAll(dir)
+	if err != nil {
+		log.Warn("Skipping garbage collection of %s: %v", dir, err)
+		return 0
+	}
+	live := make(map[string]bool)
+	for _, snap := range snaps {
+		for _, e := range snap.Entries {
+			if e.Hash != "" {
+				live[e.Hash] = true
+			}
+		}
+	}
+
+	var freed int64
+	root := filepath.Join(dir, objectsDir)
+	prefixes, _ := os.ReadDir(root)
+	for _, prefix := range prefixes {
+		objects, _ := os.ReadDir(filepath.Join(root, prefix.Name()))
+		for _, obj := range objects {
+			if live[obj.Name()] {
+				continue
+			}
+			path := filepath.Join(root, prefix.Name(), obj.Name())
+			if info, err := obj.Info(); err == nil && os.Remove(path) == nil {
+				freed += info.Size()
+			}
+		}
+		os.Remove(filepath.Join(root, prefix.Name())) // only succeeds when empty
+	}
+	return freed
+}
+
+// writeManifest stores a snapshot atomically.
+func writeManifest(dir string, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, manifestsDir), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(dir, manifestsDir), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), manifestPath(dir, snap.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
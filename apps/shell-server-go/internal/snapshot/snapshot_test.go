@@ -0,0 +1,343 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestSite(t *testing.T) (m *Manager, dir, base string) {
+	t.Helper()
+	tmp := t.TempDir()
+	base = filepath.Join(tmp, "sites", "example.com", "user")
+	writeFile(t, filepath.Join(base, "index.html"), "<h1>hi</h1>")
+	writeFile(t, filepath.Join(base, "src", "app.js"), "console.log(1)")
+	writeFile(t, filepath.Join(base, "node_modules", "dep", "index.js"), "module.exports = 1")
+	if err := os.Symlink("index.html", filepath.Join(base, "home.html")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	m = NewManager(filepath.Join(tmp, "snapshots"))
+	dir, err := m.Store("example.com")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	return m, dir, base
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(data)
+}
+
+func TestCreate_DeduplicatesAndSkipsIgnored(t *testing.T) {
+	m, dir, base := newTestSite(t)
+
+	first, err := m.Create(dir, base, Options{Label: "first"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if first.Files != 2 || first.Dirs != 1 || first.AddedBytes != first.Bytes {
+		t.Fatalf("unexpected snapshot %+v", first)
+	}
+	for _, e := range first.Entries {
+		if e.Path == "node_modules" || e.Path == "node_modules/dep/index.js" {
+			t.Fatalf("ignored path captured: %s", e.Path)
+		}
+		if e.Path == "home.html" && (e.Type != TypeSymlink || e.Target != "index.html") {
+			t.Fatalf("unexpected symlink entry %+v", e)
+		}
+	}
+
+	// The same content is stored once, even under another name.
+	writeFile(t, filepath.Join(base, "copy.js"), "console.log(1)")
+	second, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if second.Files != 3 || second.AddedBytes != 0 {
+		t.Fatalf("expected deduplicated snapshot, got %+v", second)
+	}
+
+	infos, storeBytes, err := m.List(dir)
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("unexpected list %+v err=%v", infos, err)
+	}
+	if infos[0].ID != second.ID || infos[0].Entries != nil || storeBytes != first.Bytes {
+		t.Fatalf("unexpected list %+v store=%d", infos, storeBytes)
+	}
+	if infos[0].UniqueBytes != 0 || infos[1].UniqueBytes != 0 {
+		t.Fatalf("expected shared content, got %+v", infos)
+	}
+}
+
+func TestCreate_CheckAndLimit(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	denied := errors.New("quota")
+
+	if _, err := m.Create(dir, base, Options{Check: func(bytes, inodes int64) error { return denied }}); !errors.Is(err, denied) {
+		t.Fatalf("expected check error, got %v", err)
+	}
+	if infos, _, _ := m.List(dir); len(infos) != 0 {
+		t.Fatalf("snapshot created despite check: %+v", infos)
+	}
+
+	for i := 0; i < MaxSnapshots; i++ {
+		if _, err := m.Create(dir, base, Options{}); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if _, err := m.Create(dir, base, Options{}); !errors.Is(err, ErrTooMany) {
+		t.Fatalf("expected ErrTooMany, got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	first, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	writeFile(t, filepath.Join(base, "src", "app.js"), "console.log(2)")
+	writeFile(t, filepath.Join(base, "src", "new.js"), "new")
+	os.Remove(filepath.Join(base, "index.html"))
+	writeFile(t, filepath.Join(base, "node_modules", "dep", "other.js"), "ignored")
+
+	live, err := m.Diff(dir, base, first.ID, Live, "")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []Change{
+		{Path: "index.html", Status: StatusRemoved, Type: TypeFile, OldSize: 11},
+		{Path: "src/app.js", Status: StatusModified, Type: TypeFile, OldSize: 14, NewSize: 14},
+		{Path: "src/new.js", Status: StatusAdded, Type: TypeFile, NewSize: 3},
+	}
+	if len(live.Changes) != len(want) {
+		t.Fatalf("unexpected changes %+v", live.Changes)
+	}
+	for i := range want {
+		if live.Changes[i] != want[i] {
+			t.Fatalf("change %d: got %+v, want %+v", i, live.Changes[i], want[i])
+		}
+	}
+
+	second, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	between, err := m.Diff(dir, base, first.ID, second.ID, "src")
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(between.Changes) != 2 || between.Changes[0].Path != "src/app.js" || between.Changes[1].Path != "src/new.js" {
+		t.Fatalf("unexpected scoped changes %+v", between.Changes)
+	}
+
+	if clean, _ := m.Diff(dir, base, second.ID, Live, ""); len(clean.Changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", clean.Changes)
+	}
+	if _, err := m.Diff(dir, base, "20990101T000000-00000000", Live, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	first, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	writeFile(t, filepath.Join(base, "src", "app.js"), "broken")
+	writeFile(t, filepath.Join(base, "src", "extra.js"), "extra")
+	writeFile(t, filepath.Join(base, "index.html"), "changed")
+	os.Remove(filepath.Join(base, "home.html"))
+	os.Mkdir(filepath.Join(base, "home.html"), 0755)
+
+	// A single path leaves the rest alone.
+	result, err := m.Restore(dir, base, first.ID, "src/app.js", RestoreOptions{})
+	if err != nil {
+		t.Fatalf("restore path: %v", err)
+	}
+	if result.Restored != 1 || result.Removed != 0 || result.Backup != "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if got := readFile(t, filepath.Join(base, "src", "app.js")); got != "console.log(1)" {
+		t.Fatalf("file not restored: %q", got)
+	}
+	if got := readFile(t, filepath.Join(base, "index.html")); got != "changed" {
+		t.Fatalf("file outside scope restored: %q", got)
+	}
+
+	if _, err := m.Restore(dir, base, first.ID, "missing.txt", RestoreOptions{}); !errors.Is(err, ErrPathNotFound) {
+		t.Fatalf("expected ErrPathNotFound, got %v", err)
+	}
+
+	// The whole workspace, with a backup of the state before.
+	result, err = m.Restore(dir, base, first.ID, "", RestoreOptions{Backup: true})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if result.Backup == "" || result.Removed != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if got := readFile(t, filepath.Join(base, "index.html")); got != "<h1>hi</h1>" {
+		t.Fatalf("file not restored: %q", got)
+	}
+	if target, err := os.Readlink(filepath.Join(base, "home.html")); err != nil || target != "index.html" {
+		t.Fatalf("symlink not restored: %q %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "src", "extra.js")); !os.IsNotExist(err) {
+		t.Fatalf("extra file not removed")
+	}
+	if _, err := os.Stat(filepath.Join(base, "node_modules", "dep", "index.js")); err != nil {
+		t.Fatalf("ignored file touched: %v", err)
+	}
+	if clean, _ := m.Diff(dir, base, first.ID, Live, ""); len(clean.Changes) != 0 {
+		t.Fatalf("expected restored tree, got %+v", clean.Changes)
+	}
+
+	// The backup undoes the restore.
+	if _, err := m.Restore(dir, base, result.Backup, "", RestoreOptions{}); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got := readFile(t, filepath.Join(base, "src", "extra.js")); got != "extra" {
+		t.Fatalf("backup not restored: %q", got)
+	}
+}
+
+func TestRestore_DoesNotWriteThroughSymlinks(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	snap, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, outside, "secret")
+	os.Remove(filepath.Join(base, "index.html"))
+	if err := os.Symlink(outside, filepath.Join(base, "index.html")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	if _, err := m.Restore(dir, base, snap.ID, "index.html", RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := readFile(t, outside); got != "secret" {
+		t.Fatalf("wrote through symlink: %q", got)
+	}
+	if info, err := os.Lstat(filepath.Join(base, "index.html")); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("symlink not replaced: %v", err)
+	}
+}
+
+func TestDelete_CollectsGarbage(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	first, _ := m.Create(dir, base, Options{})
+	writeFile(t, filepath.Join(base, "src", "app.js"), "console.log(2)")
+	second, _ := m.Create(dir, base, Options{})
+
+	freed, err := m.Delete(dir, first.ID)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if freed != 14 {
+		t.Fatalf("expected the old app.js to be freed, got %d", freed)
+	}
+	if _, err := m.Get(dir, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, e := range second.Entries {
+		if e.Hash != "" && !hasObject(dir, e.Hash) {
+			t.Fatalf("object of %s collected", e.Path)
+		}
+	}
+	if _, err := m.Delete(dir, "../../etc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_IsPrivate(t *testing.T) {
+	m, dir, _ := newTestSite(t)
+	if info, err := os.Lstat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("store mode %v err=%v, want 0700", info.Mode().Perm(), err)
+	}
+	for _, site := range []string{"", "..", "a/b"} {
+		if _, err := m.Store(site); !errors.Is(err, ErrInvalidSite) {
+			t.Errorf("%q: expected ErrInvalidSite, got %v", site, err)
+		}
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(m.root, "other.com")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if _, err := m.Store("other.com"); err == nil {
+		t.Fatalf("expected a symlinked store to be refused")
+	}
+}
+
+func TestGet_RejectsInvalidHashes(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	snap, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, hash := range []string{"", "a", "../../../../etc/shadow", strings.Repeat("A", 64)} {
+		forged := *snap
+		forged.Entries = []Entry{{Path: "index.html", Type: TypeFile, Hash: hash}}
+		data, _ := json.Marshal(&forged)
+		if err := os.WriteFile(manifestPath(dir, snap.ID), data, 0600); err != nil {
+			t.Fatalf("write manifest: %v", err)
+		}
+		if _, err := m.Get(dir, snap.ID); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%q: expected ErrCorrupt, got %v", hash, err)
+		}
+		if _, err := m.Restore(dir, base, snap.ID, "", RestoreOptions{}); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%q: restore expected ErrCorrupt, got %v", hash, err)
+		}
+	}
+}
+
+func TestRestore_RefusesSymlinkedObjects(t *testing.T) {
+	m, dir, base := newTestSite(t)
+	snap, err := m.Create(dir, base, Options{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var hash string
+	for _, e := range snap.Entries {
+		if e.Path == "index.html" {
+			hash = e.Hash
+		}
+	}
+	outside := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, outside, "secret")
+	os.Remove(objectPath(dir, hash))
+	if err := os.Symlink(outside, objectPath(dir, hash)); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	writeFile(t, filepath.Join(base, "index.html"), "changed")
+
+	if _, err := m.Restore(dir, base, snap.ID, "index.html", RestoreOptions{}); err == nil {
+		t.Fatalf("expected restore through a symlinked object to fail")
+	}
+	if got := readFile(t, filepath.Join(base, "index.html")); got != "changed" {
+		t.Fatalf("restored from a symlinked object: %q", got)
+	}
+}
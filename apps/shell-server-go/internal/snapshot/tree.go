@@ -0,0 +1,194 @@
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"shell-server-go/internal/ignore"
+)
+
+// scan lists the live entries below base that the ignore rules keep, sorted by
+// path so parents come before their children. File hashes are left empty;
+// sockets, devices and other special files are skipped.
+func scan(base string) ([]Entry, error) {
+	rules := ignore.New(base)
+	var entries []Entry
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base {
+				return err
+			}
+			return nil
+		}
+		if path == base {
+			return nil
+		}
+		if rules.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(entries) >= MaxEntries {
+			return ErrTooLarge
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(base, path)
+		entry := Entry{
+			Path:    filepath.ToSlash(rel),
+			Mode:    info.Mode().Perm(),
+			ModTime: info.ModTime().UTC(),
+		}
+		switch {
+		case info.IsDir():
+			entry.Type = TypeDirectory
+		case info.Mode().IsRegular():
+			entry.Type = TypeFile
+			entry.Size = info.Size()
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return nil
+			}
+			entry.Type = TypeSymlink
+			entry.Target = target
+		default:
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// sortEntries orders entries by path component, so a directory is directly
+// followed by everything below it ("a", "a/b", "a.txt").
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return pathLess(entries[i].Path, entries[j].Path)
+	})
+}
+
+func pathLess(a, b string) bool {
+	return strings.ReplaceAll(a, "/", "\x00") < strings.ReplaceAll(b, "/", "\x00")
+}
+
+// unchanged reports whether a live file still matches a recorded entry by
+// size and modification time, so its recorded hash can be trusted.
+func unchanged(recorded, live Entry) bool {
+	return recorded.Type == TypeFile && live.Type == TypeFile && recorded.Hash != "" &&
+		recorded.Size == live.Size && recorded.ModTime.Equal(live.ModTime)
+}
+
+// openFile opens a regular file without following a symlink swapped in since
+// the scan.
+func openFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+}
+
+// openObject opens a stored object, refusing anything but a regular file. It
+// opens without blocking so a FIFO in its place cannot stall a restore.
+func openObject(dir, hash string) (*os.File, error) {
+	file, err := os.OpenFile(objectPath(dir, hash), os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("object %s is not a regular file", hash)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// hashFile returns the SHA-256 of a live file.
+func hashFile(path string) (string, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// storeFile copies a live file into the object store. It returns the content
+// hash, the size read and how many bytes the store grew by, which is zero when
+// the content was already there.
+func storeFile(dir, path string) (hash string, size, added int64, err error) {
+	file, err := openFile(path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer file.Close()
+
+	if err := os.MkdirAll(filepath.Join(dir, tmpDir), 0700); err != nil {
+		return "", 0, 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(dir, tmpDir), "object-*")
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, 0, err
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+
+	dest := objectPath(dir, hash)
+	if _, err := os.Stat(dest); err == nil {
+		return hash, size, 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return "", 0, 0, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", 0, 0, err
+	}
+	return hash, size, size, nil
+}
+
+// hasObject reports whether the store holds content with the given hash.
+func hasObject(dir, hash string) bool {
+	_, err := os.Stat(objectPath(dir, hash))
+	return err == nil
+}
+
+// inScope reports whether a snapshot path is scope or lies below it. An empty
+// scope covers everything.
+func inScope(path, scope string) bool {
+	return scope == "" || path == scope || strings.HasPrefix(path, scope+"/")
+}
+
+// CleanScope turns a workspace-relative path into the form Diff and Restore
+// take as scope. "" and "." mean the whole workspace.
+func CleanScope(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
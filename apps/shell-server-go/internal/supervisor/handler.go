@@ -0,0 +1,163 @@
+package supervisor
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/httpx/response"
+	"shell-server-go/internal/session"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// Handler exposes the supervisor over the authenticated API.
+type Handler struct {
+	sessions *session.Store
+	manager  *Manager
+}
+
+// NewHandler creates a new supervisor handler.
+func NewHandler(sessions *session.Store, manager *Manager) *Handler {
+	return &Handler{
+		sessions: sessions,
+		manager:  manager,
+	}
+}
+
+// ListServices handles GET /api/supervisor/services?workspace=X.
+func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
+	workspace := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	if !strings.HasPrefix(workspace, "site:") {
+		h.handleError(w, ErrSiteOnly)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"workspace": workspace,
+		"services":  h.manager.List(workspace),
+	})
+}
+
+// StartService handles POST /api/supervisor/start.
+func (h *Handler) StartService(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	status, err := h.manager.Start(workspace, r.FormValue("name"), r.FormValue("command"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "service": status})
+}
+
+// StopService handles POST /api/supervisor/stop.
+func (h *Handler) StopService(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	status, err := h.manager.Stop(workspace, r.FormValue("name"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "service": status})
+}
+
+// RestartService handles POST /api/supervisor/restart.
+func (h *Handler) RestartService(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	status, err := h.manager.Restart(workspace, r.FormValue("name"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{"success": true, "service": status})
+}
+
+// RemoveService handles POST /api/supervisor/remove. Only stopped services
+// can be removed.
+func (h *Handler) RemoveService(w http.ResponseWriter, r *http.Request) {
+	if !httpxmiddleware.ParseFormRequest(w, r) {
+		return
+	}
+
+	workspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
+	if err := h.manager.Remove(workspace, r.FormValue("name")); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{"success": true})
+}
+
+// ServiceLogs handles GET /api/supervisor/logs?workspace=X&name=Y&lines=N.
+func (h *Handler) ServiceLogs(w http.ResponseWriter, r *http.Request) {
+	workspace := workspacepkg.WorkspaceFromQuery(r, h.sessions)
+	name := r.URL.Query().Get("name")
+
+	lines := 0
+	if raw := r.URL.Query().Get("lines"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid lines parameter")
+			return
+		}
+		lines = parsed
+	}
+
+	logLines, err := h.manager.Logs(workspace, name, lines)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logLines = []string{}
+		} else {
+			h.handleError(w, err)
+			return
+		}
+	}
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"name":  name,
+		"lines": logLines,
+	})
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, err error) {
+	var pathErr *workspacepkg.PathSecurityError
+	var restartErr *RestartError
+	switch {
+	case errors.As(err, &restartErr):
+		response.Error(w, http.StatusInternalServerError, "Restart failed and the service is now stopped: "+describeError(restartErr.Err))
+	case errors.As(err, &pathErr):
+		workspacepkg.HandlePathSecurityError(w, err)
+	case os.IsNotExist(err):
+		response.Error(w, http.StatusNotFound, "Workspace not found")
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidCommand), errors.Is(err, ErrSiteOnly):
+		response.Error(w, http.StatusBadRequest, describeError(err))
+	case errors.Is(err, ErrNotFound):
+		response.Error(w, http.StatusNotFound, describeError(err))
+	case errors.Is(err, ErrAlreadyRunning), errors.Is(err, ErrNotStopped), errors.Is(err, ErrTooManyServices):
+		response.Error(w, http.StatusConflict, describeError(err))
+	case errors.Is(err, ErrRootOwned):
+		response.Error(w, http.StatusForbidden, describeError(err))
+	case errors.Is(err, ErrManagerShutDown):
+		response.Error(w, http.StatusServiceUnavailable, describeError(err))
+	default:
+		log.Error("Supervisor operation failed: %v", err)
+		response.Error(w, http.StatusInternalServerError, describeError(err))
+	}
+}
@@ -0,0 +1,164 @@
+package supervisor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogMaxBytes is the size at which a service log is rotated.
+	DefaultLogMaxBytes = 5 << 20
+
+	// DefaultLogBackups is how many rotated log files are kept per service.
+	DefaultLogBackups = 3
+
+	// MaxTailLines limits how many log lines one request can read.
+	MaxTailLines = 5000
+
+	// maxTailBytes bounds how much of the log file is scanned when tailing.
+	maxTailBytes = 1 << 20
+)
+
+// rotatingLog is an io.Writer that appends to a file and rotates it by size.
+type rotatingLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingLog(path string, maxBytes int64, backups int) (*rotatingLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+
+	l := &rotatingLog{path: path, maxBytes: maxBytes, backups: backups}
+	if err := l.openLocked(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) openLocked() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log: %w", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && l.size+int64(len(p)) > l.maxBytes {
+		if err := l.rotateLocked(); err != nil {
+			log.Warn("Failed to rotate log %s: %v", l.path, err)
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Event writes a supervisor status line into the service log.
+func (l *rotatingLog) Event(format string, args ...any) {
+	line := fmt.Sprintf("[supervisor %s] %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	l.Write([]byte(line))
+}
+
+func (l *rotatingLog) rotateLocked() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	for i := l.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.backups > 0 {
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(l.path, 0); err != nil {
+		return err
+	}
+
+	return l.openLocked()
+}
+
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// tailFile returns up to n trailing lines of the file at path.
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	start := info.Size() - maxTailBytes
+	if start < 0 {
+		start = 0
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if start > 0 {
+		// Drop the partial first line.
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	parts := bytes.Split(data, []byte("\n"))
+	if len(parts) > n {
+		parts = parts[len(parts)-n:]
+	}
+
+	lines := make([]string, len(parts))
+	for i, part := range parts {
+		lines[i] = string(part)
+	}
+	return lines, nil
+}
@@ -0,0 +1,411 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/logger"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+var log = logger.WithComponent("SUPERVISOR")
+
+const (
+	// MaxServicesPerWorkspace limits how many named services one site can define.
+	MaxServicesPerWorkspace = 5
+
+	// MaxCommandLength limits the length of a service command.
+	MaxCommandLength = 4096
+
+	// InitialBackoff is the delay before the first restart after a crash.
+	InitialBackoff = time.Second
+
+	// MaxBackoff caps the exponential restart delay.
+	MaxBackoff = time.Minute
+
+	// StableRunTime resets the backoff when a process stayed up at least this long.
+	StableRunTime = 30 * time.Second
+
+	// StopGracePeriod is how long a process group gets between SIGTERM and SIGKILL.
+	StopGracePeriod = 10 * time.Second
+)
+
+// Supervisor errors.
+var (
+	ErrInvalidName       = errors.New("invalid service name")
+	ErrInvalidCommand    = errors.New("invalid service command")
+	ErrSiteOnly          = errors.New("services are only available for site workspaces")
+	ErrRootOwned         = errors.New("refusing to run services as root")
+	ErrNotFound          = errors.New("service not found")
+	ErrAlreadyRunning    = errors.New("service already running")
+	ErrNotStopped        = errors.New("service must be stopped first")
+	ErrTooManyServices   = errors.New("too many services for workspace")
+	ErrManagerShutDown   = errors.New("supervisor is shutting down")
+	serviceNameRegex     = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+	supervisedShellFlags = []string{"--noprofile", "--norc", "--restricted", "-c"}
+)
+
+// RestartError is returned by Restart when the service was stopped but could
+// not be started again; it stays stopped.
+type RestartError struct {
+	Err error
+}
+
+func (e *RestartError) Error() string {
+	return "restart failed, service is now stopped: " + e.Err.Error()
+}
+
+func (e *RestartError) Unwrap() error {
+	return e.Err
+}
+
+// Service states.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateBackoff  = "backoff"
+	StateStopping = "stopping"
+	StateStopped  = "stopped"
+)
+
+// Status is the externally visible state of a supervised service.
+type Status struct {
+	Name          string     `json:"name"`
+	Workspace     string     `json:"workspace"`
+	Command       string     `json:"command"`
+	State         string     `json:"state"`
+	PID           int        `json:"pid,omitempty"`
+	Restarts      int        `json:"restarts"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	LastExitCode  *int       `json:"lastExitCode,omitempty"`
+	LastExitAt    *time.Time `json:"lastExitAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextRestartAt *time.Time `json:"nextRestartAt,omitempty"`
+}
+
+// Manager starts, stops and restarts named long-running commands per site workspace.
+type Manager struct {
+	config         *config.AppConfig
+	resolver       *workspacepkg.Resolver
+	logDir         string
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	gracePeriod    time.Duration
+
+	mu       sync.Mutex
+	services map[string]*service
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a supervisor that writes service logs below logDir.
+func NewManager(cfg *config.AppConfig, logDir string) *Manager {
+	return &Manager{
+		config:         cfg,
+		resolver:       workspacepkg.NewResolver(cfg),
+		logDir:         logDir,
+		initialBackoff: InitialBackoff,
+		maxBackoff:     MaxBackoff,
+		gracePeriod:    StopGracePeriod,
+		services:       make(map[string]*service),
+	}
+}
+
+func serviceKey(workspace, name string) string {
+	return workspace + "/" + name
+}
+
+// ValidateName checks a service name.
+func ValidateName(name string) error {
+	if !serviceNameRegex.MatchString(name) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+func validateCommand(command string) error {
+	if command == "" || len(command) > MaxCommandLength {
+		return ErrInvalidCommand
+	}
+	for _, r := range command {
+		if unicode.IsControl(r) && r != '\t' {
+			return ErrInvalidCommand
+		}
+	}
+	return nil
+}
+
+// resolveSite maps a site workspace to its directory and owner credential.
+func (m *Manager) resolveSite(workspace string) (string, *syscall.Credential, error) {
+	if !strings.HasPrefix(workspace, "site:") {
+		return "", nil, ErrSiteOnly
+	}
+
+	cwd, err := m.resolver.ResolveWorkspaceBase(workspace)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := workspacepkg.ValidateSiteWorkspaceBoundary(cwd, m.config.ResolvedSitesPath); err != nil {
+		return "", nil, err
+	}
+
+	info, err := os.Stat(cwd)
+	if err != nil {
+		return "", nil, err
+	}
+	if !info.IsDir() {
+		return "", nil, &workspacepkg.PathSecurityError{Op: "supervisor_workspace_not_dir", Path: cwd, Wrapped: workspacepkg.ErrInvalidPath}
+	}
+
+	credential, err := workspacepkg.ResolveWorkspaceCredential(cwd, true)
+	if err != nil {
+		return "", nil, err
+	}
+	if credential == nil && os.Geteuid() == 0 {
+		return "", nil, ErrRootOwned
+	}
+	return cwd, credential, nil
+}
+
+// Start defines (or redefines) a service and starts it.
+func (m *Manager) Start(workspace, name, command string) (Status, error) {
+	if err := ValidateName(name); err != nil {
+		return Status{}, err
+	}
+	command = strings.TrimSpace(command)
+	if err := validateCommand(command); err != nil {
+		return Status{}, err
+	}
+
+	cwd, credential, err := m.resolveSite(workspace)
+	if err != nil {
+		return Status{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return Status{}, ErrManagerShutDown
+	}
+
+	key := serviceKey(workspace, name)
+	if existing, ok := m.services[key]; ok {
+		if !existing.isStopped() {
+			return Status{}, ErrAlreadyRunning
+		}
+	} else if m.countLocked(workspace) >= MaxServicesPerWorkspace {
+		return Status{}, ErrTooManyServices
+	}
+
+	site := strings.TrimPrefix(workspace, "site:")
+	logPath := filepath.Join(m.logDir, site, name+".log")
+	logFile, err := openRotatingLog(logPath, DefaultLogMaxBytes, DefaultLogBackups)
+	if err != nil {
+		return Status{}, err
+	}
+
+	svc := &service{
+		workspace:      workspace,
+		name:           name,
+		command:        command,
+		cwd:            cwd,
+		credential:     credential,
+		logPath:        logPath,
+		logs:           logFile,
+		initialBackoff: m.initialBackoff,
+		maxBackoff:     m.maxBackoff,
+		gracePeriod:    m.gracePeriod,
+		state:          StateStarting,
+		stopCh:         make(chan struct{}),
+		doneCh:         make(chan struct{}),
+	}
+	m.services[key] = svc
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		svc.run()
+	}()
+
+	log.Info("Service started | workspace=%s name=%s", workspace, name)
+	return svc.status(), nil
+}
+
+func (m *Manager) countLocked(workspace string) int {
+	count := 0
+	for _, svc := range m.services {
+		if svc.workspace == workspace {
+			count++
+		}
+	}
+	return count
+}
+
+// Stop stops a service and waits for its process group to exit.
+func (m *Manager) Stop(workspace, name string) (Status, error) {
+	m.mu.Lock()
+	svc, ok := m.services[serviceKey(workspace, name)]
+	m.mu.Unlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+
+	svc.stop()
+	log.Info("Service stopped | workspace=%s name=%s", workspace, name)
+	return svc.status(), nil
+}
+
+// Remove forgets a stopped service and deletes its logs, so its name no
+// longer counts towards MaxServicesPerWorkspace.
+func (m *Manager) Remove(workspace, name string) error {
+	m.mu.Lock()
+	key := serviceKey(workspace, name)
+	svc, ok := m.services[key]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if !svc.isStopped() {
+		m.mu.Unlock()
+		return ErrNotStopped
+	}
+	delete(m.services, key)
+	m.mu.Unlock()
+
+	os.Remove(svc.logPath)
+	for i := 1; i <= DefaultLogBackups; i++ {
+		os.Remove(fmt.Sprintf("%s.%d", svc.logPath, i))
+	}
+	log.Info("Service removed | workspace=%s name=%s", workspace, name)
+	return nil
+}
+
+// Restart stops a service (if running) and starts it again with the same
+// command. What Start checks is checked before stopping, so a restart that
+// cannot succeed leaves the service as it was. If Start still fails, the
+// error is a *RestartError.
+func (m *Manager) Restart(workspace, name string) (Status, error) {
+	m.mu.Lock()
+	svc, ok := m.services[serviceKey(workspace, name)]
+	closed := m.closed
+	m.mu.Unlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	if closed {
+		return Status{}, ErrManagerShutDown
+	}
+	if err := validateCommand(svc.command); err != nil {
+		return Status{}, err
+	}
+	if _, _, err := m.resolveSite(workspace); err != nil {
+		return Status{}, err
+	}
+
+	svc.stop()
+	status, err := m.Start(workspace, name, svc.command)
+	if err != nil {
+		log.Error("Service restart failed, left stopped | workspace=%s name=%s: %v", workspace, name, err)
+		return svc.status(), &RestartError{Err: err}
+	}
+	return status, nil
+}
+
+// List returns the status of all services in a workspace, sorted by name.
+func (m *Manager) List(workspace string) []Status {
+	m.mu.Lock()
+	statuses := make([]Status, 0)
+	for _, svc := range m.services {
+		if svc.workspace == workspace {
+			statuses = append(statuses, svc.status())
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Logs returns the trailing lines of a service log.
+func (m *Manager) Logs(workspace, name string, lines int) ([]string, error) {
+	m.mu.Lock()
+	svc, ok := m.services[serviceKey(workspace, name)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if lines <= 0 {
+		lines = 200
+	}
+	if lines > MaxTailLines {
+		lines = MaxTailLines
+	}
+	return tailFile(svc.logPath, lines)
+}
+
+// Shutdown stops all services and waits until they exit or ctx is done.
+func (m *Manager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	m.closed = true
+	services := make([]*service, 0, len(m.services))
+	for _, svc := range m.services {
+		services = append(services, svc)
+	}
+	m.mu.Unlock()
+
+	for _, svc := range services {
+		go svc.stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info("All supervised services stopped")
+	case <-ctx.Done():
+		log.Warn("Shutdown timeout, some supervised services may still be running")
+	}
+}
+
+// describeError maps supervisor errors to a short client-facing message.
+func describeError(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidName):
+		return "Invalid service name (lowercase letters, digits, '-' and '_', max 32)"
+	case errors.Is(err, ErrInvalidCommand):
+		return fmt.Sprintf("Invalid command (single line, max %d bytes)", MaxCommandLength)
+	case errors.Is(err, ErrSiteOnly):
+		return "Services are only available for site workspaces"
+	case errors.Is(err, ErrRootOwned):
+		return "Workspace is owned by root; refusing to run services as root"
+	case errors.Is(err, ErrNotFound):
+		return "Service not found"
+	case errors.Is(err, ErrAlreadyRunning):
+		return "Service is already running"
+	case errors.Is(err, ErrNotStopped):
+		return "Service is still running; stop it first"
+	case errors.Is(err, ErrTooManyServices):
+		return fmt.Sprintf("Too many services for this workspace (max %d); remove a stopped one first", MaxServicesPerWorkspace)
+	case errors.Is(err, ErrManagerShutDown):
+		return "Server is shutting down"
+	default:
+		return "Service operation failed"
+	}
+}
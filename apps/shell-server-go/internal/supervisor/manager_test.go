@@ -0,0 +1,219 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shell-server-go/internal/config"
+)
+
+const testSiteUID = 65534
+
+// newTestManager creates a manager with one site workspace owned by an unprivileged user.
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("supervisor tests need root to run services as the site owner")
+	}
+
+	tmp := t.TempDir()
+	if err := os.Chmod(tmp, 0755); err != nil {
+		t.Fatalf("chmod temp dir: %v", err)
+	}
+	if err := os.Chmod(filepath.Dir(tmp), 0755); err != nil {
+		t.Fatalf("chmod temp parent: %v", err)
+	}
+
+	sitesPath := filepath.Join(tmp, "sites")
+	siteDir := filepath.Join(sitesPath, "example.com", "user")
+	if err := os.MkdirAll(siteDir, 0755); err != nil {
+		t.Fatalf("create site dir: %v", err)
+	}
+	if err := os.Chown(siteDir, testSiteUID, testSiteUID); err != nil {
+		t.Skipf("cannot chown site dir: %v", err)
+	}
+
+	cfg := &config.AppConfig{ResolvedDefaultCwd: tmp, ResolvedSitesPath: sitesPath, ShellPassword: "testpassword123"}
+	m := NewManager(cfg, filepath.Join(tmp, ".supervisor"))
+	m.initialBackoff = 20 * time.Millisecond
+	m.maxBackoff = 50 * time.Millisecond
+	m.gracePeriod = time.Second
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.Shutdown(ctx)
+	})
+	return m
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestManager_StartLogsAndStop(t *testing.T) {
+	m := newTestManager(t)
+	ws := "site:example.com"
+
+	if _, err := m.Start(ws, "web", "echo hello; id -u; sleep 30"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := m.Start(ws, "web", "sleep 1"); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("expected ErrAlreadyRunning, got %v", err)
+	}
+
+	waitFor(t, "log output", func() bool {
+		lines, _ := m.Logs(ws, "web", 50)
+		return strings.Contains(strings.Join(lines, "\n"), "65534")
+	})
+
+	lines, err := m.Logs(ws, "web", 50)
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "hello") {
+		t.Fatalf("expected command output in logs, got %v", lines)
+	}
+
+	statuses := m.List(ws)
+	if len(statuses) != 1 || statuses[0].State != StateRunning || statuses[0].PID == 0 {
+		t.Fatalf("expected one running service, got %+v", statuses)
+	}
+
+	status, err := m.Stop(ws, "web")
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if status.State != StateStopped || status.PID != 0 {
+		t.Fatalf("expected stopped service, got %+v", status)
+	}
+}
+
+func TestManager_RestartsCrashingServiceWithBackoff(t *testing.T) {
+	m := newTestManager(t)
+	ws := "site:example.com"
+
+	if _, err := m.Start(ws, "crashy", "exit 3"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	waitFor(t, "restarts", func() bool {
+		statuses := m.List(ws)
+		return len(statuses) == 1 && statuses[0].Restarts >= 3
+	})
+
+	status := m.List(ws)[0]
+	if status.LastExitCode == nil || *status.LastExitCode != 3 {
+		t.Fatalf("expected last exit code 3, got %+v", status)
+	}
+}
+
+func TestManager_RejectsInvalidRequests(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, err := m.Start("site:example.com", "Bad Name", "true"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if _, err := m.Start("site:example.com", "web", "echo a\necho b"); !errors.Is(err, ErrInvalidCommand) {
+		t.Fatalf("expected ErrInvalidCommand, got %v", err)
+	}
+	if _, err := m.Start("root", "web", "true"); !errors.Is(err, ErrSiteOnly) {
+		t.Fatalf("expected ErrSiteOnly, got %v", err)
+	}
+	if _, err := m.Stop("site:example.com", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestManager_RemoveFreesServiceSlot(t *testing.T) {
+	m := newTestManager(t)
+	ws := "site:example.com"
+
+	for i := 0; i < MaxServicesPerWorkspace; i++ {
+		if _, err := m.Start(ws, fmt.Sprintf("svc%d", i), "sleep 30"); err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+	}
+	if _, err := m.Start(ws, "extra", "sleep 30"); !errors.Is(err, ErrTooManyServices) {
+		t.Fatalf("expected ErrTooManyServices, got %v", err)
+	}
+	if err := m.Remove(ws, "svc0"); !errors.Is(err, ErrNotStopped) {
+		t.Fatalf("expected ErrNotStopped for a running service, got %v", err)
+	}
+
+	if _, err := m.Stop(ws, "svc0"); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	// A stopped service still holds its slot until it is removed.
+	if _, err := m.Start(ws, "extra", "sleep 30"); !errors.Is(err, ErrTooManyServices) {
+		t.Fatalf("expected ErrTooManyServices, got %v", err)
+	}
+	if err := m.Remove(ws, "svc0"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.logDir, "example.com", "svc0.log")); !os.IsNotExist(err) {
+		t.Fatalf("expected log removed, got %v", err)
+	}
+	if _, err := m.Start(ws, "extra", "sleep 30"); err != nil {
+		t.Fatalf("start after remove: %v", err)
+	}
+	if err := m.Remove(ws, "svc0"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestManager_RestartChecksBeforeStopping(t *testing.T) {
+	m := newTestManager(t)
+	ws := "site:example.com"
+
+	if _, err := m.Start(ws, "web", "sleep 30"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	siteDir := filepath.Join(m.config.ResolvedSitesPath, "example.com", "user")
+
+	// A restart that cannot start again leaves the service running.
+	if err := os.Chown(siteDir, 0, 0); err != nil {
+		t.Fatalf("chown: %v", err)
+	}
+	if _, err := m.Restart(ws, "web"); !errors.Is(err, ErrRootOwned) {
+		t.Fatalf("expected ErrRootOwned, got %v", err)
+	}
+	if statuses := m.List(ws); statuses[0].State == StateStopped {
+		t.Fatalf("failed restart stopped the service: %+v", statuses[0])
+	}
+	if err := os.Chown(siteDir, testSiteUID, testSiteUID); err != nil {
+		t.Fatalf("chown: %v", err)
+	}
+
+	// A failure after stopping says the service is now stopped.
+	logDir := filepath.Join(m.logDir, "example.com")
+	if err := os.RemoveAll(logDir); err != nil {
+		t.Fatalf("remove logs: %v", err)
+	}
+	if err := os.WriteFile(logDir, nil, 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	status, err := m.Restart(ws, "web")
+	var restartErr *RestartError
+	if !errors.As(err, &restartErr) {
+		t.Fatalf("expected RestartError, got %v", err)
+	}
+	if status.State != StateStopped {
+		t.Fatalf("expected the stopped status, got %+v", status)
+	}
+}
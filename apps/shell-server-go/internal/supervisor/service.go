@@ -0,0 +1,219 @@
+package supervisor
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// service is one supervised command and its restart loop.
+type service struct {
+	workspace      string
+	name           string
+	command        string
+	cwd            string
+	credential     *syscall.Credential
+	logPath        string
+	logs           *rotatingLog
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	gracePeriod    time.Duration
+
+	mu            sync.Mutex
+	state         string
+	pid           int
+	restarts      int
+	startedAt     time.Time
+	lastExitCode  *int
+	lastExitAt    time.Time
+	lastError     string
+	nextRestartAt time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// run starts the command and restarts it with exponential backoff until stopped.
+func (s *service) run() {
+	defer close(s.doneCh)
+	defer s.logs.Close()
+
+	backoff := s.initialBackoff
+	for {
+		s.setState(StateStarting)
+		cmd := s.buildCommand()
+
+		if err := cmd.Start(); err != nil {
+			s.mu.Lock()
+			s.lastError = err.Error()
+			s.mu.Unlock()
+			s.logs.Event("failed to start: %v", err)
+			log.Warn("Service failed to start | workspace=%s name=%s err=%v", s.workspace, s.name, err)
+		} else {
+			started := time.Now()
+			s.mu.Lock()
+			s.state = StateRunning
+			s.pid = cmd.Process.Pid
+			s.startedAt = started
+			s.lastError = ""
+			s.mu.Unlock()
+			s.logs.Event("started pid=%d command=%q", cmd.Process.Pid, s.command)
+
+			waitDone := make(chan error, 1)
+			go func() { waitDone <- cmd.Wait() }()
+
+			var waitErr error
+			select {
+			case waitErr = <-waitDone:
+			case <-s.stopCh:
+				s.setState(StateStopping)
+				s.terminate(cmd, waitDone)
+				s.finish(StateStopped)
+				s.logs.Event("stopped")
+				return
+			}
+
+			exitCode := exitCodeOf(waitErr)
+			s.mu.Lock()
+			s.pid = 0
+			s.lastExitCode = &exitCode
+			s.lastExitAt = time.Now()
+			s.mu.Unlock()
+			s.logs.Event("exited code=%d after %s", exitCode, time.Since(started).Round(time.Millisecond))
+
+			if time.Since(started) >= StableRunTime {
+				backoff = s.initialBackoff
+			}
+		}
+
+		s.mu.Lock()
+		s.state = StateBackoff
+		s.restarts++
+		s.nextRestartAt = time.Now().Add(backoff)
+		s.mu.Unlock()
+		log.Warn("Service exited, restarting in %v | workspace=%s name=%s", backoff, s.workspace, s.name)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-s.stopCh:
+			timer.Stop()
+			s.finish(StateStopped)
+			s.logs.Event("stopped")
+			return
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+func (s *service) buildCommand() *exec.Cmd {
+	args := append(append([]string{}, supervisedShellFlags...), s.command)
+	cmd := exec.Command("/bin/bash", args...)
+	cmd.Dir = s.cwd
+	cmd.Env = []string{
+		"HOME=" + s.cwd,
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"LANG=C.UTF-8",
+	}
+	cmd.Stdout = s.logs
+	cmd.Stderr = s.logs
+	// Don't let a daemonized child holding the output pipe block Wait forever.
+	cmd.WaitDelay = 5 * time.Second
+	// A process group lets stop() signal the command and everything it spawned.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: s.credential}
+	return cmd
+}
+
+// terminate sends SIGTERM to the process group, then SIGKILL after the grace period.
+func (s *service) terminate(cmd *exec.Cmd, waitDone <-chan error) {
+	pgid := cmd.Process.Pid
+	syscall.Kill(-pgid, syscall.SIGTERM)
+
+	timer := time.NewTimer(s.gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-waitDone:
+	case <-timer.C:
+		s.logs.Event("did not exit within %v, killing", s.gracePeriod)
+		syscall.Kill(-pgid, syscall.SIGKILL)
+		<-waitDone
+	}
+	// Reap stragglers that ignored SIGTERM but left the group leader.
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+func (s *service) stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+	<-s.doneCh
+}
+
+func (s *service) isStopped() bool {
+	select {
+	case <-s.doneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *service) setState(state string) {
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+func (s *service) finish(state string) {
+	s.mu.Lock()
+	s.state = state
+	s.pid = 0
+	s.nextRestartAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func (s *service) status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{
+		Name:         s.name,
+		Workspace:    s.workspace,
+		Command:      s.command,
+		State:        s.state,
+		PID:          s.pid,
+		Restarts:     s.restarts,
+		LastExitCode: s.lastExitCode,
+		LastError:    s.lastError,
+	}
+	if !s.startedAt.IsZero() {
+		startedAt := s.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !s.lastExitAt.IsZero() {
+		lastExitAt := s.lastExitAt
+		status.LastExitAt = &lastExitAt
+	}
+	if s.state == StateBackoff && !s.nextRestartAt.IsZero() {
+		nextRestartAt := s.nextRestartAt
+		status.NextRestartAt = &nextRestartAt
+	}
+	return status
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
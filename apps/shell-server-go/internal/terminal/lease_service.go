@@ -1,14 +1,114 @@
 package terminal
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
 
 var (
-	ErrMissingLease       = errors.New("missing lease token")
-	ErrInvalidLease       = errors.New("invalid lease token")
-	ErrExpiredLease       = errors.New("expired lease token")
-	ErrLeaseSessionDenied = errors.New("lease session mismatch")
+	ErrMissingLease        = errors.New("missing lease token")
+	ErrInvalidLease        = errors.New("invalid lease token")
+	ErrExpiredLease        = errors.New("expired lease token")
+	ErrLeaseSessionDenied  = errors.New("lease session mismatch")
+	ErrInvalidLeaseOptions = errors.New("invalid lease options")
+)
+
+const (
+	// DefaultTerminalCols and DefaultTerminalRows are used when a lease does not specify a size.
+	DefaultTerminalCols = 80
+	DefaultTerminalRows = 24
+
+	// Bounds for the initial terminal size carried in a lease.
+	MinTerminalCols = 20
+	MaxTerminalCols = 500
+	MinTerminalRows = 5
+	MaxTerminalRows = 200
+
+	// MaxStartupCommandLength limits the startup command typed into a new shell.
+	MaxStartupCommandLength = 4096
 )
 
+// localeRegex accepts POSIX locale names such as C, C.UTF-8, en_US.UTF-8 or de_DE@euro.
+var localeRegex = regexp.MustCompile(`^(C|POSIX|[a-z]{2,3}(_[A-Z]{2})?)(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+
+// LeaseOptions describes how the shell behind a lease should start.
+type LeaseOptions struct {
+	Cols    int
+	Rows    int
+	Command string
+	Locale  string
+	TZ      string
+}
+
+// LeaseOptionsError describes which lease option failed validation.
+type LeaseOptionsError struct {
+	Field   string
+	Message string
+}
+
+func (e *LeaseOptionsError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrInvalidLeaseOptions, e.Field, e.Message)
+}
+
+func (e *LeaseOptionsError) Unwrap() error {
+	return ErrInvalidLeaseOptions
+}
+
+// Normalize validates the options and fills in defaults.
+func (o LeaseOptions) Normalize() (LeaseOptions, error) {
+	if o.Cols == 0 && o.Rows == 0 {
+		o.Cols = DefaultTerminalCols
+		o.Rows = DefaultTerminalRows
+	}
+	if o.Cols < MinTerminalCols || o.Cols > MaxTerminalCols {
+		return LeaseOptions{}, &LeaseOptionsError{
+			Field:   "cols",
+			Message: fmt.Sprintf("must be between %d and %d", MinTerminalCols, MaxTerminalCols),
+		}
+	}
+	if o.Rows < MinTerminalRows || o.Rows > MaxTerminalRows {
+		return LeaseOptions{}, &LeaseOptionsError{
+			Field:   "rows",
+			Message: fmt.Sprintf("must be between %d and %d", MinTerminalRows, MaxTerminalRows),
+		}
+	}
+
+	o.Command = strings.TrimSpace(o.Command)
+	if len(o.Command) > MaxStartupCommandLength {
+		return LeaseOptions{}, &LeaseOptionsError{
+			Field:   "command",
+			Message: fmt.Sprintf("must be at most %d bytes", MaxStartupCommandLength),
+		}
+	}
+	// The command is typed into the shell, so it must be a single printable line.
+	for _, r := range o.Command {
+		if unicode.IsControl(r) {
+			return LeaseOptions{}, &LeaseOptionsError{Field: "command", Message: "must not contain control characters"}
+		}
+	}
+
+	o.Locale = strings.TrimSpace(o.Locale)
+	if o.Locale != "" && !localeRegex.MatchString(o.Locale) {
+		return LeaseOptions{}, &LeaseOptionsError{Field: "locale", Message: "invalid locale name"}
+	}
+
+	o.TZ = strings.TrimSpace(o.TZ)
+	if o.TZ != "" {
+		if strings.HasPrefix(o.TZ, ":") || strings.HasPrefix(o.TZ, "/") {
+			return LeaseOptions{}, &LeaseOptionsError{Field: "tz", Message: "invalid time zone"}
+		}
+		if _, err := time.LoadLocation(o.TZ); err != nil {
+			return LeaseOptions{}, &LeaseOptionsError{Field: "tz", Message: "unknown time zone"}
+		}
+	}
+
+	return o, nil
+}
+
 // IsLeaseError checks if err is one of the known lease errors.
 func IsLeaseError(err error) bool {
 	return errors.Is(err, ErrMissingLease) ||
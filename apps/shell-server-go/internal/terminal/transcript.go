@@ -0,0 +1,418 @@
+package terminal
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTranscriptMaxBytes caps the plain-text output kept per terminal session.
+	DefaultTranscriptMaxBytes = 1 << 20
+
+	// DefaultTranscriptRetention is how long a closed session's transcript stays available.
+	DefaultTranscriptRetention = 30 * time.Minute
+
+	// TranscriptCleanupInterval is how often expired transcripts are removed.
+	TranscriptCleanupInterval = time.Minute
+
+	// DefaultMaxTranscriptsPerWorkspace and DefaultMaxTranscripts cap how many
+	// transcripts are kept, so opening and closing terminals in a loop cannot
+	// grow memory without bound. The oldest are evicted first, closed ones
+	// before live ones.
+	DefaultMaxTranscriptsPerWorkspace = 20
+	DefaultMaxTranscripts             = 200
+
+	// maxTranscriptLineBytes forces a line break for output that never emits a newline.
+	maxTranscriptLineBytes = 16 << 10
+)
+
+// TranscriptLine is one line of plain-text terminal output.
+type TranscriptLine struct {
+	Time time.Time `json:"ts"`
+	Text string    `json:"line"`
+}
+
+// TranscriptInfo summarizes a transcript without its content.
+type TranscriptInfo struct {
+	ID        string     `json:"id"`
+	Workspace string     `json:"workspace"`
+	StartedAt time.Time  `json:"startedAt"`
+	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+	Bytes     int        `json:"bytes"`
+	Lines     int        `json:"lines"`
+	Truncated bool       `json:"truncated"`
+}
+
+// transcript is a byte-bounded ring of plain-text lines for one connection.
+type transcript struct {
+	mu        sync.Mutex
+	id        string
+	workspace string
+	startedAt time.Time
+	closedAt  time.Time
+	maxBytes  int
+	lines     []TranscriptLine
+	size      int
+	truncated bool
+	partial   []byte
+	partialAt time.Time
+	pendingCR bool
+	stripper  ansiStripper
+}
+
+func newTranscript(id, workspace string, maxBytes int) *transcript {
+	return &transcript{
+		id:        id,
+		workspace: workspace,
+		startedAt: time.Now(),
+		maxBytes:  maxBytes,
+	}
+}
+
+// Write records raw PTY output. It never fails so it can sit on the hot path.
+func (t *transcript) Write(p []byte) (int, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, b := range t.stripper.strip(p) {
+		if t.pendingCR {
+			t.pendingCR = false
+			if b != '\n' {
+				// A bare carriage return rewinds the line (progress bars, prompts).
+				t.partial = t.partial[:0]
+			}
+		}
+
+		switch b {
+		case '\n':
+			t.flushLocked(now)
+		case '\r':
+			t.pendingCR = true
+		case '\b':
+			if len(t.partial) > 0 {
+				_, size := utf8.DecodeLastRune(t.partial)
+				t.partial = t.partial[:len(t.partial)-size]
+			}
+		default:
+			if b < 0x20 && b != '\t' {
+				continue
+			}
+			if len(t.partial) == 0 {
+				t.partialAt = now
+			}
+			t.partial = append(t.partial, b)
+			if len(t.partial) >= maxTranscriptLineBytes {
+				t.flushLocked(now)
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+func (t *transcript) flushLocked(now time.Time) {
+	ts := t.partialAt
+	if len(t.partial) == 0 {
+		ts = now
+	}
+	line := TranscriptLine{Time: ts, Text: string(t.partial)}
+	t.partial = t.partial[:0]
+
+	t.lines = append(t.lines, line)
+	t.size += len(line.Text) + 1
+
+	dropped := 0
+	for t.size > t.maxBytes && dropped < len(t.lines) {
+		t.size -= len(t.lines[dropped].Text) + 1
+		dropped++
+	}
+	if dropped > 0 {
+		t.lines = append(t.lines[:0], t.lines[dropped:]...)
+		t.truncated = true
+	}
+}
+
+func (t *transcript) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closedAt.IsZero() {
+		t.closedAt = time.Now()
+	}
+}
+
+// snapshot returns a copy of the recorded lines, including an unterminated last line.
+func (t *transcript) snapshot() []TranscriptLine {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := make([]TranscriptLine, len(t.lines), len(t.lines)+1)
+	copy(lines, t.lines)
+	if len(t.partial) > 0 {
+		lines = append(lines, TranscriptLine{Time: t.partialAt, Text: string(t.partial)})
+	}
+	return lines
+}
+
+func (t *transcript) info() TranscriptInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info := TranscriptInfo{
+		ID:        t.id,
+		Workspace: t.workspace,
+		StartedAt: t.startedAt,
+		Bytes:     t.size + len(t.partial),
+		Lines:     len(t.lines),
+		Truncated: t.truncated,
+	}
+	if !t.closedAt.IsZero() {
+		closedAt := t.closedAt
+		info.ClosedAt = &closedAt
+	}
+	return info
+}
+
+func (t *transcript) expired(now time.Time, retention time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.closedAt.IsZero() && now.Sub(t.closedAt) > retention
+}
+
+// transcriptStore keeps transcripts of live and recently closed sessions.
+type transcriptStore struct {
+	mu          sync.RWMutex
+	items       map[string]*transcript
+	maxBytes    int
+	retention   time.Duration
+	maxPerWS    int
+	maxTotal    int
+	stopCleanup chan struct{}
+	cleanupDone chan struct{}
+	stopOnce    sync.Once
+}
+
+func newTranscriptStore(maxBytes int, retention time.Duration, maxPerWS, maxTotal int) *transcriptStore {
+	if maxBytes <= 0 {
+		maxBytes = DefaultTranscriptMaxBytes
+	}
+	if retention <= 0 {
+		retention = DefaultTranscriptRetention
+	}
+	if maxPerWS <= 0 {
+		maxPerWS = DefaultMaxTranscriptsPerWorkspace
+	}
+	if maxTotal <= 0 {
+		maxTotal = DefaultMaxTranscripts
+	}
+
+	s := &transcriptStore{
+		items:       make(map[string]*transcript),
+		maxBytes:    maxBytes,
+		retention:   retention,
+		maxPerWS:    maxPerWS,
+		maxTotal:    maxTotal,
+		stopCleanup: make(chan struct{}),
+		cleanupDone: make(chan struct{}),
+	}
+	go s.cleanupLoop()
+	return s
+}
+
+func (s *transcriptStore) start(id, workspace string) *transcript {
+	t := newTranscript(id, workspace, s.maxBytes)
+	s.mu.Lock()
+	s.items[id] = t
+	s.evictLocked(t)
+	s.mu.Unlock()
+	return t
+}
+
+// evictLocked drops the oldest transcripts beyond the per-workspace and
+// overall caps, never the one just started.
+func (s *transcriptStore) evictLocked(keep *transcript) {
+	var inWorkspace, all []*transcript
+	for _, t := range s.items {
+		if t == keep {
+			continue
+		}
+		all = append(all, t)
+		if t.workspace == keep.workspace {
+			inWorkspace = append(inWorkspace, t)
+		}
+	}
+
+	evicted := 0
+	evict := func(candidates []*transcript, excess int) {
+		if excess <= 0 {
+			return
+		}
+		sortForEviction(candidates)
+		for _, t := range candidates {
+			if excess == 0 {
+				return
+			}
+			if s.items[t.id] == t { // not evicted by the first pass
+				delete(s.items, t.id)
+				excess--
+				evicted++
+			}
+		}
+	}
+	evict(inWorkspace, len(inWorkspace)+1-s.maxPerWS)
+	evict(all, len(s.items)-s.maxTotal)
+	if evicted > 0 {
+		wsLog.Debug("Evicted %d transcripts over the retention cap", evicted)
+	}
+}
+
+// sortForEviction orders closed transcripts before live ones, oldest first.
+func sortForEviction(list []*transcript) {
+	type key struct {
+		live    bool
+		started time.Time
+	}
+	keys := make(map[*transcript]key, len(list))
+	for _, t := range list {
+		t.mu.Lock()
+		keys[t] = key{live: t.closedAt.IsZero(), started: t.startedAt}
+		t.mu.Unlock()
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := keys[list[i]], keys[list[j]]
+		if a.live != b.live {
+			return !a.live
+		}
+		return a.started.Before(b.started)
+	})
+}
+
+func (s *transcriptStore) get(id string) (*transcript, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.items[id]
+	return t, ok
+}
+
+// list returns transcripts visible to a workspace filter ("" means all), newest first.
+func (s *transcriptStore) list(workspace string) []TranscriptInfo {
+	s.mu.RLock()
+	infos := make([]TranscriptInfo, 0, len(s.items))
+	for _, t := range s.items {
+		if workspace != "" && t.workspace != workspace {
+			continue
+		}
+		infos = append(infos, t.info())
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt.After(infos[j].StartedAt) })
+	return infos
+}
+
+// cleanupLoop periodically removes transcripts past their retention
+func (s *transcriptStore) cleanupLoop() {
+	defer close(s.cleanupDone)
+
+	ticker := time.NewTicker(TranscriptCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.cleanup(time.Now())
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+func (s *transcriptStore) cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	for id, t := range s.items {
+		if t.expired(now, s.retention) {
+			delete(s.items, id)
+			expired++
+		}
+	}
+	if expired > 0 {
+		wsLog.Debug("Cleaned up %d expired transcripts", expired)
+	}
+}
+
+// stop stops the cleanup goroutine and waits for it to finish
+func (s *transcriptStore) stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCleanup)
+		<-s.cleanupDone
+	})
+}
+
+// ansiStripper removes terminal escape sequences from a byte stream.
+// It keeps state between calls so sequences split across PTY reads are handled.
+type ansiStripper struct {
+	state int
+}
+
+const (
+	ansiGround = iota
+	ansiEscape
+	ansiEscapeIntermediate
+	ansiCSI
+	ansiString
+	ansiStringEscape
+)
+
+func (s *ansiStripper) strip(p []byte) []byte {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch s.state {
+		case ansiGround:
+			if b == 0x1b {
+				s.state = ansiEscape
+				continue
+			}
+			out = append(out, b)
+		case ansiEscape:
+			switch {
+			case b == '[':
+				s.state = ansiCSI
+			case b == ']' || b == 'P' || b == 'X' || b == '^' || b == '_':
+				// OSC, DCS, SOS, PM and APC run until BEL or ST.
+				s.state = ansiString
+			case b >= 0x20 && b <= 0x2f:
+				s.state = ansiEscapeIntermediate
+			default:
+				s.state = ansiGround
+			}
+		case ansiEscapeIntermediate:
+			if b < 0x20 || b > 0x2f {
+				s.state = ansiGround
+			}
+		case ansiCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = ansiGround
+			}
+		case ansiString:
+			switch b {
+			case 0x07:
+				s.state = ansiGround
+			case 0x1b:
+				s.state = ansiStringEscape
+			}
+		case ansiStringEscape:
+			if b == '\\' {
+				s.state = ansiGround
+			} else {
+				s.state = ansiString
+			}
+		}
+	}
+	return out
+}
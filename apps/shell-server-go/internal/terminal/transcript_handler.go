@@ -0,0 +1,80 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"shell-server-go/internal/httpx/response"
+	workspacepkg "shell-server-go/internal/workspace"
+)
+
+// ListTranscripts handles GET /api/terminal/transcripts.
+// Workspace-scoped sessions only see transcripts of their own workspace.
+func (h *WSHandler) ListTranscripts(w http.ResponseWriter, r *http.Request) {
+	scope := workspacepkg.SessionWorkspace(r, h.sessions)
+
+	response.JSON(w, http.StatusOK, map[string]any{
+		"transcripts": h.transcripts.list(scope),
+	})
+}
+
+// DownloadTranscript handles GET /api/terminal/transcript?id=X&format=text|jsonl.
+func (h *WSHandler) DownloadTranscript(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		response.Error(w, http.StatusBadRequest, "No session id provided")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "jsonl" {
+		response.Error(w, http.StatusBadRequest, "Invalid format (expected text or jsonl)")
+		return
+	}
+
+	t, ok := h.transcripts.get(id)
+	// Report out-of-scope transcripts as missing so ids cannot be probed across workspaces.
+	if scope := workspacepkg.SessionWorkspace(r, h.sessions); ok && scope != "" && t.workspace != scope {
+		ok = false
+	}
+	if !ok {
+		response.Error(w, http.StatusNotFound, "Transcript not found")
+		return
+	}
+
+	lines := t.snapshot()
+
+	ext, contentType := "txt", "text/plain; charset=utf-8"
+	if format == "jsonl" {
+		ext, contentType = "jsonl", "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transcript-%s.%s"`, id, ext))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	if format == "jsonl" {
+		enc := json.NewEncoder(bw)
+		for _, line := range lines {
+			if err := enc.Encode(line); err != nil {
+				wsLog.Debug("Failed to encode transcript line: %v", err)
+				return
+			}
+		}
+	} else {
+		for _, line := range lines {
+			bw.WriteString(line.Text)
+			bw.WriteByte('\n')
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		wsLog.Debug("Failed to stream transcript %s: %v", id, err)
+	}
+}
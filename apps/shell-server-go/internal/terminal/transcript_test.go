@@ -0,0 +1,153 @@
+package terminal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shell-server-go/internal/config"
+	httpxmiddleware "shell-server-go/internal/httpx/middleware"
+	"shell-server-go/internal/session"
+)
+
+func transcriptText(t *transcript) string {
+	var parts []string
+	for _, line := range t.snapshot() {
+		parts = append(parts, line.Text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func TestTranscript_StripsEscapeSequences(t *testing.T) {
+	tr := newTranscript("id", "root", DefaultTranscriptMaxBytes)
+
+	// Colors, a window-title OSC, a charset switch and a CSI split across two reads.
+	tr.Write([]byte("\x1b[1;32muser@host\x1b[0m:~$ ls\r\n\x1b]0;title\x07"))
+	tr.Write([]byte("\x1b(Bfile.txt  \x1b[3"))
+	tr.Write([]byte("4mdir\x1b[0m\r\n"))
+	tr.Write([]byte("progress 10%\rprogress 100%\r\n"))
+	tr.Write([]byte("typo\b\bpo fixed"))
+
+	want := "user@host:~$ ls\nfile.txt  dir\nprogress 100%\ntypo fixed"
+	if got := transcriptText(tr); got != want {
+		t.Fatalf("unexpected transcript:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestTranscript_DropsOldestLinesWhenFull(t *testing.T) {
+	tr := newTranscript("id", "root", 32)
+
+	for i := 0; i < 10; i++ {
+		tr.Write([]byte("0123456789\n"))
+	}
+	tr.Write([]byte("last\n"))
+
+	info := tr.info()
+	if !info.Truncated {
+		t.Fatalf("expected transcript to be marked truncated")
+	}
+	if info.Bytes > 32 {
+		t.Fatalf("expected at most 32 bytes, got %d", info.Bytes)
+	}
+	lines := tr.snapshot()
+	if lines[len(lines)-1].Text != "last" {
+		t.Fatalf("expected newest line to be kept, got %+v", lines)
+	}
+}
+
+func TestTranscriptStore_ExpiresClosedTranscripts(t *testing.T) {
+	store := newTranscriptStore(0, time.Minute, 0, 0)
+	defer store.stop()
+
+	live := store.start("live", "root")
+	closed := store.start("closed", "root")
+	closed.close()
+	_ = live
+
+	store.cleanup(time.Now().Add(2 * time.Minute))
+
+	if _, ok := store.get("closed"); ok {
+		t.Fatalf("expected closed transcript to expire")
+	}
+	if _, ok := store.get("live"); !ok {
+		t.Fatalf("expected live transcript to be kept")
+	}
+}
+
+func TestDownloadTranscript_ScopedSessionCannotReadOtherWorkspace(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := &config.AppConfig{ResolvedDefaultCwd: tmp, ResolvedSitesPath: tmp, ShellPassword: "testpassword123"}
+	sessions := session.NewStore(filepath.Join(tmp, ".sessions.json"))
+	defer sessions.Stop()
+
+	h := NewWSHandler(cfg, sessions)
+	defer h.transcripts.stop()
+
+	tr := h.transcripts.start("abc123", "site:other.example")
+	tr.Write([]byte("secret output\r\n"))
+
+	token := sessions.GenerateWithInfo(session.SessionInfo{Workspace: "site:example.com"})
+	req := httptest.NewRequest(http.MethodGet, "/api/terminal/transcript?id=abc123", nil)
+	req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: token})
+	w := httptest.NewRecorder()
+
+	h.DownloadTranscript(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d body=%s", w.Code, w.Body.String())
+	}
+
+	unscoped := sessions.GenerateWithInfo(session.SessionInfo{})
+	req = httptest.NewRequest(http.MethodGet, "/api/terminal/transcript?id=abc123&format=jsonl", nil)
+	req.AddCookie(&http.Cookie{Name: httpxmiddleware.CookieName, Value: unscoped})
+	w = httptest.NewRecorder()
+
+	h.DownloadTranscript(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"line":"secret output"`) {
+		t.Fatalf("expected jsonl transcript line, got %s", w.Body.String())
+	}
+}
+
+func TestTranscriptStore_EvictsOldestBeyondCaps(t *testing.T) {
+	store := newTranscriptStore(0, time.Minute, 2, 3)
+	defer store.stop()
+
+	first := store.start("a1", "site:a")
+	first.close()
+	time.Sleep(time.Millisecond)
+	store.start("a2", "site:a")
+	time.Sleep(time.Millisecond)
+	store.start("a3", "site:a")
+
+	// The closed transcript goes first, even though a live one is as old.
+	if _, ok := store.get("a1"); ok {
+		t.Fatalf("expected oldest closed transcript to be evicted")
+	}
+	if _, ok := store.get("a2"); !ok {
+		t.Fatalf("expected live transcript to be kept")
+	}
+
+	time.Sleep(time.Millisecond)
+	store.start("b1", "site:b")
+	time.Sleep(time.Millisecond)
+	store.start("b2", "site:b")
+
+	if got := len(store.list("")); got != 3 {
+		t.Fatalf("expected 3 transcripts overall, got %d", got)
+	}
+	if _, ok := store.get("a2"); ok {
+		t.Fatalf("expected oldest transcript to be evicted over the overall cap")
+	}
+	for _, id := range []string{"a3", "b1", "b2"} {
+		if _, ok := store.get(id); !ok {
+			t.Fatalf("expected %s to be kept", id)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -63,6 +64,7 @@ type WSHandler struct {
 	resolver         *workspacepkg.Resolver
 	leaseMu          sync.Mutex
 	leases           map[string]WSLease
+	transcripts      *transcriptStore
 	upgrader         websocket.Upgrader
 	activeConns      int32
 	connections      sync.Map // map[*websocket.Conn]*connInfo
@@ -72,34 +74,43 @@ type WSHandler struct {
 
 // connInfo tracks information about a connection
 type connInfo struct {
+	id         string
 	workspace  string
 	pid        int
 	startTime  time.Time
 	cancelFunc context.CancelFunc
 	latency    *wsLatencyTracker
+	transcript *transcript
 	writeMu    sync.Mutex // Protects concurrent writes to websocket
 }
 
 // WSMessage represents a WebSocket message
 type WSMessage struct {
-	Type     string `json:"type"`
-	Data     string `json:"data,omitempty"`
-	Cols     int    `json:"cols,omitempty"`
-	Rows     int    `json:"rows,omitempty"`
-	ExitCode int    `json:"exitCode,omitempty"`
-	Message  string `json:"message,omitempty"`
-	P50Ms    int64  `json:"p50Ms,omitempty"`
-	P95Ms    int64  `json:"p95Ms,omitempty"`
-	Samples  int    `json:"samples,omitempty"`
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId,omitempty"`
+	Data      string `json:"data,omitempty"`
+	Cols      int    `json:"cols,omitempty"`
+	Rows      int    `json:"rows,omitempty"`
+	ExitCode  int    `json:"exitCode,omitempty"`
+	Message   string `json:"message,omitempty"`
+	P50Ms     int64  `json:"p50Ms,omitempty"`
+	P95Ms     int64  `json:"p95Ms,omitempty"`
+	Samples   int    `json:"samples,omitempty"`
 }
 
 // WSLease is a one-time token that authorizes one WebSocket terminal upgrade.
+// Cols, Rows, Command, Locale and TZ are applied before the shell starts.
 type WSLease struct {
 	SessionToken string
 	Workspace    string
 	Cwd          string
 	RunAsOwner   bool
 	ExpiresAt    time.Time
+	Cols         int
+	Rows         int
+	Command      string
+	Locale       string
+	TZ           string
 }
 
 type latencySummary struct {
@@ -196,10 +207,11 @@ func percentileDuration(sorted []time.Duration, percentile int) time.Duration {
 // NewWSHandler creates a new WebSocket handler
 func NewWSHandler(cfg *config.AppConfig, sessions *session.Store) *WSHandler {
 	return &WSHandler{
-		config:   cfg,
-		sessions: sessions,
-		resolver: workspacepkg.NewResolver(cfg),
-		leases:   make(map[string]WSLease),
+		config:      cfg,
+		sessions:    sessions,
+		resolver:    workspacepkg.NewResolver(cfg),
+		leases:      make(map[string]WSLease),
+		transcripts: newTranscriptStore(cfg.TranscriptMaxBytes, cfg.TranscriptRetention, cfg.TranscriptMaxPerWS, cfg.TranscriptMaxTotal),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:    1024,
 			WriteBufferSize:   1024,
@@ -258,24 +270,38 @@ func (h *WSHandler) CreateInternalLease(w http.ResponseWriter, r *http.Request)
 
 	var body struct {
 		Workspace string `json:"workspace"`
+		Cols      int    `json:"cols"`
+		Rows      int    `json:"rows"`
+		Command   string `json:"command"`
+		Locale    string `json:"locale"`
+		TZ        string `json:"tz"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid JSON body")
 		return
 	}
 
-	leaseToken, lease, err := h.createLease(internalLeaseSessionToken, body.Workspace)
+	opts := LeaseOptions{
+		Cols:    body.Cols,
+		Rows:    body.Rows,
+		Command: body.Command,
+		Locale:  body.Locale,
+		TZ:      body.TZ,
+	}
+
+	leaseToken, lease, err := h.createLease(internalLeaseSessionToken, body.Workspace, opts)
 	if err != nil {
+		var optsErr *LeaseOptionsError
+		if errors.As(err, &optsErr) {
+			response.Error(w, http.StatusBadRequest, optsErr.Error())
+			return
+		}
 		wsLog.Error("Failed to create internal lease | workspace=%s err=%v", body.Workspace, err)
 		response.Error(w, http.StatusInternalServerError, "Failed to create terminal lease")
 		return
 	}
 
-	response.JSON(w, http.StatusOK, map[string]interface{}{
-		"lease":     leaseToken,
-		"workspace": lease.Workspace,
-		"expiresAt": lease.ExpiresAt.UnixMilli(),
-	})
+	writeLeaseResponse(w, leaseToken, lease)
 }
 
 // CreateLease issues a short-lived single-use token for a terminal WebSocket connection.
@@ -292,10 +318,19 @@ func (h *WSHandler) CreateLease(w http.ResponseWriter, r *http.Request) {
 
 	requestedWorkspace := workspacepkg.WorkspaceFromForm(r, h.sessions)
 
-	leaseToken, lease, err := h.createLease(sessionToken, requestedWorkspace)
+	opts, err := leaseOptionsFromForm(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	leaseToken, lease, err := h.createLease(sessionToken, requestedWorkspace, opts)
 	if err != nil {
 		var pathErr *workspacepkg.PathSecurityError
+		var optsErr *LeaseOptionsError
 		switch {
+		case errors.As(err, &optsErr):
+			response.Error(w, http.StatusBadRequest, optsErr.Error())
 		case errors.As(err, &pathErr):
 			workspacepkg.HandlePathSecurityError(w, err)
 		case os.IsNotExist(err):
@@ -307,10 +342,45 @@ func (h *WSHandler) CreateLease(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeLeaseResponse(w, leaseToken, lease)
+}
+
+// leaseOptionsFromForm reads the optional terminal startup options from a lease form.
+func leaseOptionsFromForm(r *http.Request) (LeaseOptions, error) {
+	opts := LeaseOptions{
+		Command: r.FormValue("command"),
+		Locale:  r.FormValue("locale"),
+		TZ:      r.FormValue("tz"),
+	}
+
+	for _, field := range []struct {
+		name string
+		dst  *int
+	}{
+		{name: "cols", dst: &opts.Cols},
+		{name: "rows", dst: &opts.Rows},
+	} {
+		raw := strings.TrimSpace(r.FormValue(field.name))
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return LeaseOptions{}, &LeaseOptionsError{Field: field.name, Message: "must be an integer"}
+		}
+		*field.dst = value
+	}
+
+	return opts, nil
+}
+
+func writeLeaseResponse(w http.ResponseWriter, leaseToken string, lease WSLease) {
 	response.JSON(w, http.StatusOK, map[string]interface{}{
 		"lease":     leaseToken,
 		"workspace": lease.Workspace,
 		"expiresAt": lease.ExpiresAt.UnixMilli(),
+		"cols":      lease.Cols,
+		"rows":      lease.Rows,
 	})
 }
 
@@ -341,12 +411,13 @@ func (h *WSHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	leaseToken := strings.TrimSpace(r.URL.Query().Get("lease"))
-	workspace, cwd, runAsOwner, err := h.consumeLease(sessionToken, leaseToken)
+	lease, err := h.consumeLease(sessionToken, leaseToken)
 	if err != nil {
 		wsLog.Warn("Lease rejected: %v", err)
 		response.Error(w, http.StatusUnauthorized, "Invalid or expired lease")
 		return
 	}
+	workspace, cwd, runAsOwner := lease.Workspace, lease.Cwd, lease.RunAsOwner
 
 	// Re-validate workspace boundary (defense-in-depth) for site workspaces.
 	// Root workspace is intentionally outside sitesPath and should not be validated here.
@@ -404,31 +475,42 @@ func (h *WSHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	sessionID, err := generateSessionID()
+	if err != nil {
+		wsLog.Error("Failed to generate terminal session id: %v", err)
+		conn.Close()
+		return
+	}
+
 	// Store connection info
 	info := &connInfo{
+		id:         sessionID,
 		workspace:  workspace,
 		startTime:  time.Now(),
 		cancelFunc: cancel,
 		latency:    newWSLatencyTracker(),
+		transcript: h.transcripts.start(sessionID, workspace),
 	}
 	h.connections.Store(conn, info)
 	defer h.connections.Delete(conn)
+	defer info.transcript.close()
 
-	wsLog.Info("Connection opened | workspace=%s cwd=%s remoteAddr=%s", workspace, cwd, r.RemoteAddr)
+	wsLog.Info("Connection opened | session=%s workspace=%s cwd=%s remoteAddr=%s", sessionID, workspace, cwd, r.RemoteAddr)
 
 	// Run the PTY session
-	h.runPTYSession(ctx, conn, cwd, credential, runAsOwner, info)
+	h.runPTYSession(ctx, conn, lease, credential, info)
 }
 
 // runPTYSession manages a PTY session over WebSocket
 func (h *WSHandler) runPTYSession(
 	ctx context.Context,
 	conn *websocket.Conn,
-	cwd string,
+	lease WSLease,
 	credential *syscall.Credential,
-	runAsOwner bool,
 	info *connInfo,
 ) {
+	cwd, runAsOwner := lease.Cwd, lease.RunAsOwner
+
 	// Ensure connection is closed when we exit
 	defer func() {
 		conn.Close()
@@ -462,10 +544,14 @@ func (h *WSHandler) runPTYSession(
 	}
 
 	// Set environment with TERM color support and defensive filtering.
-	cmd.Env = buildTerminalEnv(os.Environ(), cwd, runAsOwner)
+	cmd.Env = applyLocaleEnv(buildTerminalEnv(os.Environ(), cwd, runAsOwner), lease.Locale, lease.TZ)
 
-	// Start PTY
-	ptmx, err := pty.Start(cmd)
+	// Start PTY at the leased size so the first prompt renders correctly.
+	cols, rows := lease.Cols, lease.Rows
+	if cols <= 0 || rows <= 0 {
+		cols, rows = DefaultTerminalCols, DefaultTerminalRows
+	}
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
 	if err != nil {
 		wsLog.Error("Failed to start PTY: %v", err)
 		h.sendMessage(conn, info, WSMessage{Type: "error", Message: "Failed to start shell"})
@@ -473,15 +559,18 @@ func (h *WSHandler) runPTYSession(
 	}
 
 	info.pid = cmd.Process.Pid
-	wsLog.Debug("PTY spawned | pid=%d workspace=%s", info.pid, info.workspace)
+	wsLog.Debug("PTY spawned | pid=%d workspace=%s cols=%d rows=%d", info.pid, info.workspace, cols, rows)
 
-	// Set initial size
-	if err := pty.Setsize(ptmx, &pty.Winsize{Rows: 24, Cols: 80}); err != nil {
-		wsLog.Warn("Failed to set initial PTY size: %v", err)
+	// Type the startup command into the shell; the line discipline buffers it
+	// until bash reads its first line, and restricted mode still applies.
+	if lease.Command != "" {
+		if _, err := ptmx.Write([]byte(lease.Command + "\n")); err != nil {
+			wsLog.Warn("Failed to write startup command: %v | pid=%d", err, info.pid)
+		}
 	}
 
 	// Send connected message
-	h.sendMessage(conn, info, WSMessage{Type: "connected"})
+	h.sendMessage(conn, info, WSMessage{Type: "connected", SessionID: info.id})
 
 	// Create channels for coordination
 	ptyClosed := make(chan struct{})
@@ -509,6 +598,7 @@ func (h *WSHandler) runPTYSession(
 			}
 			if n > 0 {
 				info.latency.noteOutput(time.Now())
+				info.transcript.Write(buf[:n])
 				if err := h.sendBinary(conn, info, buf[:n]); err != nil {
 					wsLog.Debug("WebSocket write failed: %v | pid=%d", err, info.pid)
 					return
@@ -693,6 +783,32 @@ func buildTerminalEnv(baseEnv []string, cwd string, runAsOwner bool) []string {
 	return filteredEnv
 }
 
+// applyLocaleEnv overrides LANG/LC_ALL and TZ when the lease requested them.
+func applyLocaleEnv(env []string, locale, tz string) []string {
+	if locale == "" && tz == "" {
+		return env
+	}
+
+	filtered := make([]string, 0, len(env)+3)
+	for _, e := range env {
+		switch {
+		case locale != "" && (strings.HasPrefix(e, "LANG=") || strings.HasPrefix(e, "LC_ALL=")):
+			continue
+		case tz != "" && strings.HasPrefix(e, "TZ="):
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	if locale != "" {
+		filtered = append(filtered, "LANG="+locale, "LC_ALL="+locale)
+	}
+	if tz != "" {
+		filtered = append(filtered, "TZ="+tz)
+	}
+	return filtered
+}
+
 // sendMessage sends a WebSocket message with proper error handling (thread-safe)
 func (h *WSHandler) sendMessage(conn *websocket.Conn, info *connInfo, msg WSMessage) error {
 	data, err := json.Marshal(msg)
@@ -729,6 +845,7 @@ func (h *WSHandler) ActiveConnections() int {
 // Shutdown gracefully shuts down all WebSocket connections
 func (h *WSHandler) Shutdown(ctx context.Context) {
 	close(h.shutdownChan)
+	defer h.transcripts.stop()
 
 	h.leaseMu.Lock()
 	h.leases = make(map[string]WSLease)
@@ -769,6 +886,7 @@ type ConnectionStats struct {
 
 // ConnectionDetail contains details about a single connection
 type ConnectionDetail struct {
+	SessionID      string `json:"sessionId"`
 	Workspace      string `json:"workspace"`
 	PID            int    `json:"pid"`
 	Duration       string `json:"duration"`
@@ -790,6 +908,7 @@ func (h *WSHandler) GetStats(includeDetails bool) ConnectionStats {
 			if info, ok := value.(*connInfo); ok {
 				summary := info.latency.summary()
 				details = append(details, ConnectionDetail{
+					SessionID:      info.id,
 					Workspace:      info.workspace,
 					PID:            info.pid,
 					Duration:       time.Since(info.startTime).Round(time.Second).String(),
@@ -806,7 +925,12 @@ func (h *WSHandler) GetStats(includeDetails bool) ConnectionStats {
 	return stats
 }
 
-func (h *WSHandler) createLease(sessionToken, workspaceQuery string) (string, WSLease, error) {
+func (h *WSHandler) createLease(sessionToken, workspaceQuery string, opts LeaseOptions) (string, WSLease, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return "", WSLease{}, err
+	}
+
 	workspace, cwd, runAsOwner, err := h.resolveShellWorkspace(workspaceQuery)
 	if err != nil {
 		return "", WSLease{}, err
@@ -841,6 +965,11 @@ func (h *WSHandler) createLease(sessionToken, workspaceQuery string) (string, WS
 		Cwd:          cwd,
 		RunAsOwner:   runAsOwner,
 		ExpiresAt:    time.Now().Add(WSLeaseTTL),
+		Cols:         opts.Cols,
+		Rows:         opts.Rows,
+		Command:      opts.Command,
+		Locale:       opts.Locale,
+		TZ:           opts.TZ,
 	}
 
 	h.leaseMu.Lock()
@@ -851,9 +980,9 @@ func (h *WSHandler) createLease(sessionToken, workspaceQuery string) (string, WS
 	return token, lease, nil
 }
 
-func (h *WSHandler) consumeLease(sessionToken, token string) (workspace string, cwd string, runAsOwner bool, err error) {
+func (h *WSHandler) consumeLease(sessionToken, token string) (WSLease, error) {
 	if strings.TrimSpace(token) == "" {
-		return "", "", false, ErrMissingLease
+		return WSLease{}, ErrMissingLease
 	}
 
 	now := time.Now()
@@ -868,16 +997,16 @@ func (h *WSHandler) consumeLease(sessionToken, token string) (workspace string,
 	h.leaseMu.Unlock()
 
 	if !ok {
-		return "", "", false, ErrInvalidLease
+		return WSLease{}, ErrInvalidLease
 	}
 	if now.After(lease.ExpiresAt) {
-		return "", "", false, ErrExpiredLease
+		return WSLease{}, ErrExpiredLease
 	}
 	if lease.SessionToken != sessionToken {
-		return "", "", false, ErrLeaseSessionDenied
+		return WSLease{}, ErrLeaseSessionDenied
 	}
 
-	return lease.Workspace, lease.Cwd, lease.RunAsOwner, nil
+	return lease, nil
 }
 
 func (h *WSHandler) pruneExpiredLeasesLocked(now time.Time) {
@@ -896,6 +1025,16 @@ func generateLeaseToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// generateSessionID returns an identifier for a terminal connection. It is not a
+// credential; transcript access is still checked against the caller's workspace scope.
+func generateSessionID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
 // extractBaseDomain returns the registrable base domain from a host string.
 // e.g. "go.sonno.tech" → "sonno.tech", "go.alive.best:8443" → "alive.best".
 // Returns "" if the host has fewer than 2 labels.
@@ -938,36 +1077,5 @@ func (h *WSHandler) resolveShellWorkspace(workspaceQuery string) (workspace stri
 }
 
 func (h *WSHandler) resolveWorkspaceCredential(cwd string, runAsOwner bool) (*syscall.Credential, error) {
-	if !runAsOwner {
-		return nil, nil
-	}
-
-	info, err := os.Stat(cwd)
-	if err != nil {
-		return nil, fmt.Errorf("stat workspace: %w", err)
-	}
-
-	stat, ok := info.Sys().(*syscall.Stat_t)
-	if !ok {
-		return nil, fmt.Errorf("workspace stat does not expose uid/gid")
-	}
-
-	currentUID := os.Geteuid()
-	currentGID := os.Getegid()
-	targetUID := int(stat.Uid)
-	targetGID := int(stat.Gid)
-
-	if currentUID == targetUID && currentGID == targetGID {
-		return nil, nil
-	}
-
-	if currentUID != 0 {
-		return nil, fmt.Errorf("cannot switch uid/gid without root (current=%d:%d target=%d:%d)", currentUID, currentGID, targetUID, targetGID)
-	}
-
-	return &syscall.Credential{
-		Uid:         stat.Uid,
-		Gid:         stat.Gid,
-		NoSetGroups: true,
-	}, nil
+	return workspacepkg.ResolveWorkspaceCredential(cwd, runAsOwner)
 }
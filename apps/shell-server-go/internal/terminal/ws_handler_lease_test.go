@@ -0,0 +1,139 @@
+package terminal
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/session"
+)
+
+func TestLeaseOptionsNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    LeaseOptions
+		want    LeaseOptions
+		wantErr string
+	}{
+		{
+			name: "defaults size when omitted",
+			opts: LeaseOptions{},
+			want: LeaseOptions{Cols: DefaultTerminalCols, Rows: DefaultTerminalRows},
+		},
+		{
+			name: "keeps valid options",
+			opts: LeaseOptions{Cols: 132, Rows: 43, Command: "  npm run dev ", Locale: "en_US.UTF-8", TZ: "Europe/Amsterdam"},
+			want: LeaseOptions{Cols: 132, Rows: 43, Command: "npm run dev", Locale: "en_US.UTF-8", TZ: "Europe/Amsterdam"},
+		},
+		{
+			name:    "rejects missing rows",
+			opts:    LeaseOptions{Cols: 120},
+			wantErr: "rows",
+		},
+		{
+			name:    "rejects oversized cols",
+			opts:    LeaseOptions{Cols: MaxTerminalCols + 1, Rows: 40},
+			wantErr: "cols",
+		},
+		{
+			name:    "rejects multi-line command",
+			opts:    LeaseOptions{Command: "ls\nrm -rf ~"},
+			wantErr: "command",
+		},
+		{
+			name:    "rejects escape sequences in command",
+			opts:    LeaseOptions{Command: "echo \x1b[2J"},
+			wantErr: "command",
+		},
+		{
+			name:    "rejects malformed locale",
+			opts:    LeaseOptions{Locale: "en_US; rm"},
+			wantErr: "locale",
+		},
+		{
+			name:    "rejects unknown time zone",
+			opts:    LeaseOptions{TZ: "Mars/Olympus"},
+			wantErr: "tz",
+		},
+		{
+			name:    "rejects time zone file paths",
+			opts:    LeaseOptions{TZ: ":/etc/shadow"},
+			wantErr: "tz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Normalize()
+			if tt.wantErr != "" {
+				var optsErr *LeaseOptionsError
+				if !errors.As(err, &optsErr) || optsErr.Field != tt.wantErr {
+					t.Fatalf("expected %s error, got %v", tt.wantErr, err)
+				}
+				if !errors.Is(err, ErrInvalidLeaseOptions) {
+					t.Fatalf("expected error to wrap ErrInvalidLeaseOptions, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyLocaleEnv(t *testing.T) {
+	base := []string{"LANG=C", "LC_ALL=C", "TZ=UTC", "KEEP=1"}
+
+	got := applyLocaleEnv(base, "nl_NL.UTF-8", "Europe/Amsterdam")
+	joined := strings.Join(got, "\n")
+
+	for _, banned := range []string{"LANG=C\n", "LC_ALL=C\n", "TZ=UTC"} {
+		if strings.Contains(joined+"\n", banned) {
+			t.Fatalf("expected %q to be replaced, got env: %v", banned, got)
+		}
+	}
+	for _, required := range []string{"LANG=nl_NL.UTF-8", "LC_ALL=nl_NL.UTF-8", "TZ=Europe/Amsterdam", "KEEP=1"} {
+		if !strings.Contains(joined, required) {
+			t.Fatalf("expected %q in env, got: %v", required, got)
+		}
+	}
+
+	if untouched := applyLocaleEnv(base, "", ""); strings.Join(untouched, "\n") != strings.Join(base, "\n") {
+		t.Fatalf("expected env unchanged without overrides, got %v", untouched)
+	}
+}
+
+func TestCreateLeaseCarriesStartupOptions(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := &config.AppConfig{
+		ResolvedDefaultCwd: tmp,
+		ResolvedSitesPath:  filepath.Join(tmp, "sites"),
+		ShellPassword:      "testpassword123",
+	}
+	sessions := session.NewStore(filepath.Join(tmp, ".sessions.json"))
+	defer sessions.Stop()
+
+	h := NewWSHandler(cfg, sessions)
+	token, _, err := h.createLease("session-a", "root", LeaseOptions{Cols: 160, Rows: 50, Command: "htop", TZ: "UTC"})
+	if err != nil {
+		t.Fatalf("createLease: %v", err)
+	}
+
+	lease, err := h.consumeLease("session-a", token)
+	if err != nil {
+		t.Fatalf("consumeLease: %v", err)
+	}
+	if lease.Cols != 160 || lease.Rows != 50 || lease.Command != "htop" || lease.TZ != "UTC" {
+		t.Fatalf("unexpected lease options: %+v", lease)
+	}
+
+	if _, _, err := h.createLease("session-a", "root", LeaseOptions{Cols: 5, Rows: 5}); !errors.Is(err, ErrInvalidLeaseOptions) {
+		t.Fatalf("expected invalid options error, got %v", err)
+	}
+}
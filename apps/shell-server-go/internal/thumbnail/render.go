@@ -0,0 +1,123 @@
+package thumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Source MIME types and the decoders for the raster ones.
+const mimeSVG = "image/svg+xml"
+
+var decoders = map[string]func(io.Reader) (image.Image, error){
+	"image/png":  png.Decode,
+	"image/jpeg": jpeg.Decode,
+	"image/gif":  gif.Decode,
+	"image/webp": webp.Decode,
+}
+
+var configDecoders = map[string]func(io.Reader) (image.Config, error){
+	"image/png":  png.DecodeConfig,
+	"image/jpeg": jpeg.DecodeConfig,
+	"image/gif":  gif.DecodeConfig,
+	"image/webp": webp.DecodeConfig,
+}
+
+// Supported reports whether a sniffed MIME type can be thumbnailed.
+func Supported(mimeType string) bool {
+	_, ok := decoders[mimeType]
+	return ok || mimeType == mimeSVG
+}
+
+// jpegQuality is used for thumbnails without transparency.
+const jpegQuality = 80
+
+// renderFile produces the thumbnail bytes and their content type.
+func renderFile(path string, info os.FileInfo, mimeType string, size int) ([]byte, string, error) {
+	if mimeType == mimeSVG {
+		if info.Size() > MaxSVGBytes {
+			return nil, "", ErrTooLarge
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		data, err := SanitizeSVG(src)
+		return data, mimeSVG, err
+	}
+
+	decode, ok := decoders[mimeType]
+	if !ok {
+		return nil, "", ErrUnsupported
+	}
+	if info.Size() > MaxSourceBytes {
+		return nil, "", ErrTooLarge
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg, err := configDecoders[mimeType](bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrInvalid
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxSourcePixels {
+		return nil, "", ErrTooLarge
+	}
+	img, err := decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	thumb := scale(img, size)
+	if mimeType == "image/jpeg" {
+		thumb = orient(thumb, jpegOrientation(src))
+	}
+	return encode(thumb)
+}
+
+// scale fits img into a size x size box, keeping its aspect ratio. Smaller
+// images are not enlarged.
+func scale(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		if w >= h {
+			h = max(1, h*size/w)
+			w = size
+		} else {
+			w = max(1, w*size/h)
+			h = size
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// encode writes opaque thumbnails as JPEG and the rest as PNG.
+func encode(img *image.NRGBA) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
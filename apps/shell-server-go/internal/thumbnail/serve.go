@@ -0,0 +1,109 @@
+package thumbnail
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"shell-server-go/internal/filetype"
+	"shell-server-go/internal/httpx/response"
+)
+
+// svgCSP keeps a sanitized SVG inert even when opened directly.
+const svgCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox"
+
+// Serve writes the thumbnail of the image at path, which the caller has
+// resolved and validated. The ETag is derived from the cache key, so a
+// revalidation is answered without rendering. Responses are private and must
+// be revalidated, unless the request carries a v= cache buster (typically the
+// file's mtime), in which case they are immutable.
+func (c *Cache) Serve(w http.ResponseWriter, r *http.Request, path string, size int) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		response.Error(w, http.StatusNotFound, "File not found")
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to stat file")
+		return
+	}
+	if !info.Mode().IsRegular() {
+		response.Error(w, http.StatusBadRequest, "Path is not a file")
+		return
+	}
+
+	detected, err := filetype.DetectFile(path)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	mimeType, _, _ := strings.Cut(detected.MIMEType, ";")
+	if !Supported(mimeType) {
+		response.JSON(w, http.StatusUnsupportedMediaType, map[string]any{
+			"error":    "No thumbnail for this file type",
+			"mimeType": detected.MIMEType,
+		})
+		return
+	}
+
+	etag := `"` + Key(path, info, size)[:32] + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	thumb, err := c.Get(path, info, mimeType, size)
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		handleError(w, path, err)
+		return
+	}
+	file, err := os.Open(thumb.Path)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to read thumbnail")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", thumb.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if thumb.ContentType == mimeSVG {
+		w.Header().Set("Content-Security-Policy", svgCSP)
+	}
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+func handleError(w http.ResponseWriter, path string, err error) {
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		response.Error(w, http.StatusRequestEntityTooLarge, "Image too large for a thumbnail")
+	case errors.Is(err, ErrUnsupported):
+		response.Error(w, http.StatusUnsupportedMediaType, "No thumbnail for this file type")
+	case errors.Is(err, ErrInvalid):
+		log.Warn("Cannot thumbnail %s: %v", path, err)
+		response.Error(w, http.StatusUnprocessableEntity, "Invalid or corrupt image")
+	default:
+		log.Error("Failed to render thumbnail of %s: %v", path, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to render thumbnail")
+	}
+}
+
+// etagMatches implements the weak comparison of If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
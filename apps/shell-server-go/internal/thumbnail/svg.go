@@ -0,0 +1,189 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// svgElements are the elements SanitizeSVG keeps, by lower-case local name.
+// Anything else is dropped with its content: script, foreignObject, iframe,
+// metadata and editor-specific elements among them.
+var svgElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true, "a": true,
+	"switch": true, "view": true, "title": true, "desc": true, "style": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true, "line": true,
+	"polyline": true, "polygon": true, "text": true, "tspan": true, "textpath": true,
+	"image": true, "marker": true, "pattern": true, "clippath": true, "mask": true,
+	"lineargradient": true, "radialgradient": true, "stop": true,
+	"animate": true, "animatetransform": true, "animatemotion": true, "mpath": true, "set": true,
+	"filter": true, "feblend": true, "fecolormatrix": true, "fecomponenttransfer": true,
+	"fecomposite": true, "feconvolvematrix": true, "fediffuselighting": true,
+	"fedisplacementmap": true, "fedistantlight": true, "fedropshadow": true,
+	"feflood": true, "fefunca": true, "fefuncb": true, "fefuncg": true, "fefuncr": true,
+	"fegaussianblur": true, "feimage": true, "femerge": true, "femergenode": true,
+	"femorphology": true, "feoffset": true, "fepointlight": true,
+	"fespecularlighting": true, "fespotlight": true, "fetile": true, "feturbulence": true,
+}
+
+// safeDataURL matches inline raster images, the only non-fragment references kept.
+var safeDataURL = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp);`)
+
+// cssURL finds url(...) references in style sheets and style attributes.
+var cssURL = regexp.MustCompile(`(?i)url\(\s*['"]?\s*([^'")\s]*)`)
+
+var errNotSVG = fmt.Errorf("%w: not an SVG document", ErrInvalid)
+
+// SanitizeSVG re-serializes an SVG keeping only drawing elements and safe
+// attributes. Event handlers, scripts, external references (href other than a
+// fragment or an inline raster image, url() in CSS, @import) and XML
+// directives such as DOCTYPE are removed, so the result can neither run script
+// nor load anything from elsewhere.
+func SanitizeSVG(src []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	var out bytes.Buffer
+	var stack []xml.Name // open elements that are written
+	skip := 0            // depth inside a dropped element
+	inStyle := false
+	sawRoot := false
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			if !sawRoot {
+				if !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, errNotSVG
+				}
+				sawRoot = true
+			}
+			if !keepElement(t.Name) {
+				skip = 1
+				continue
+			}
+			out.WriteString("<" + qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				if !keepAttr(attr) {
+					continue
+				}
+				out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+				xml.EscapeText(&out, []byte(attr.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+			stack = append(stack, t.Name)
+			inStyle = strings.EqualFold(t.Name.Local, "style")
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name {
+				return nil, fmt.Errorf("%w: unexpected </%s>", ErrInvalid, qualifiedName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+			inStyle = false
+		case xml.CharData:
+			if skip > 0 || len(stack) == 0 {
+				continue
+			}
+			if inStyle && unsafeCSS(string(t)) {
+				continue
+			}
+			xml.EscapeText(&out, t)
+		}
+		// Comments, processing instructions and directives are dropped.
+	}
+	if !sawRoot || len(stack) != 0 {
+		return nil, errNotSVG
+	}
+	return out.Bytes(), nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// keepElement allows SVG elements without a prefix or with the svg prefix.
+func keepElement(name xml.Name) bool {
+	if name.Space != "" && name.Space != "svg" {
+		return false
+	}
+	return svgElements[strings.ToLower(name.Local)]
+}
+
+func keepAttr(attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	switch attr.Name.Space {
+	case "", "xlink", "xml", "xmlns":
+	default:
+		return false // editor metadata such as inkscape:label
+	}
+	if attr.Name.Space == "" && local == "xmlns" || attr.Name.Space == "xmlns" {
+		return true
+	}
+	if strings.HasPrefix(local, "on") {
+		return false
+	}
+
+	value := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, attr.Value))
+	if strings.Contains(value, "javascript:") || strings.Contains(value, "vbscript:") {
+		return false
+	}
+	// Presentation attributes and animation values can hold url() too.
+	if strings.Contains(value, "url(") && unsafeCSS(attr.Value) {
+		return false
+	}
+	switch local {
+	case "href":
+		return strings.HasPrefix(value, "#") || safeDataURL.MatchString(value)
+	case "style":
+		return !unsafeCSS(attr.Value)
+	case "attributename":
+		// Animations must not rewrite links or event handlers.
+		target := value[strings.LastIndex(value, ":")+1:]
+		return target != "href" && !strings.HasPrefix(target, "on")
+	}
+	return true
+}
+
+// unsafeCSS reports whether CSS imports, scripts or references anything other
+// than a fragment or an inline raster image. CSS escapes could hide any of
+// these, so CSS with a backslash is rejected too.
+func unsafeCSS(css string) bool {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") ||
+		strings.Contains(lower, "javascript:") || strings.Contains(css, "\\") {
+		return true
+	}
+	for _, match := range cssURL.FindAllStringSubmatch(css, -1) {
+		ref := strings.ToLower(match[1])
+		if !strings.HasPrefix(ref, "#") && !safeDataURL.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
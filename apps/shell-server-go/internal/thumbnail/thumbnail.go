@@ -0,0 +1,307 @@
+// Package thumbnail renders bounded previews of workspace images and caches
+// them on disk, keyed by the source path, modification time and size, so an
+// edited image gets a new thumbnail and an unchanged one is rendered once.
+// The cache directory is server state: entries are served as the type their
+// name records, which only a render sets.
+//
+// PNG, JPEG, GIF (first frame) and WebP are decoded and scaled down; SVG is
+// sanitized and passed through, since it scales by itself.
+package thumbnail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"shell-server-go/internal/fsutil"
+	"shell-server-go/internal/logger"
+)
+
+// Size limits. Requested sizes are rounded up to one of Sizes so clients
+// asking for similar sizes share cache entries.
+const (
+	DefaultSize = 256
+	MinSize     = 32
+	MaxSize     = 1024
+)
+
+// Sizes are the bounding boxes thumbnails are rendered at.
+var Sizes = []int{32, 64, 128, 256, 512, 1024}
+
+// Source limits, checked before decoding.
+const (
+	MaxSourceBytes  = 64 << 20
+	MaxSourcePixels = 64_000_000
+	MaxSVGBytes     = 2 << 20
+)
+
+// DefaultCacheBytes caps the disk cache; the least recently used entries are
+// pruned beyond it.
+const DefaultCacheBytes = 256 << 20
+
+// renderVersion is part of every key; bump it when rendering changes.
+const renderVersion = 1
+
+var log = logger.WithComponent("THUMBNAIL")
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image too large for a thumbnail")
+	ErrInvalid     = errors.New("invalid image")
+)
+
+// ParseSize turns the size query parameter into a bounding box from Sizes.
+// Empty means DefaultSize.
+func ParseSize(value string) (int, error) {
+	if value == "" {
+		return DefaultSize, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 || size > MaxSize {
+		return 0, fmt.Errorf("size must be between 1 and %d", MaxSize)
+	}
+	for _, bucket := range Sizes {
+		if size <= bucket {
+			return bucket, nil
+		}
+	}
+	return MaxSize, nil
+}
+
+// Thumbnail is a rendered entry in the cache.
+type Thumbnail struct {
+	Path        string // cache file
+	ContentType string
+}
+
+// entryTypes maps the extension of a cache entry to the content type its
+// render produced.
+var entryTypes = map[string]string{
+	".png": "image/png",
+	".jpg": "image/jpeg",
+	".svg": mimeSVG,
+}
+
+// Cache renders thumbnails and keeps them in a directory. Renders are limited
+// to one per CPU, and concurrent requests for the same key share one render.
+type Cache struct {
+	dir      string
+	maxBytes int64
+	slots    chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]*render
+	writes   int
+	pruning  bool
+	dirReady bool
+}
+
+type render struct {
+	done  chan struct{}
+	thumb *Thumbnail
+	err   error
+}
+
+// NewCache creates a cache in dir holding up to maxBytes. dir is created with
+// mode 0700 on first use and refused if someone else owns it (see
+// fsutil.PrivateDir).
+func NewCache(dir string, maxBytes int64) *Cache {
+	return &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		slots:    make(chan struct{}, runtime.NumCPU()),
+		inflight: make(map[string]*render),
+	}
+}
+
+// Key identifies the thumbnail of a source file at one size.
+func Key(path string, info os.FileInfo, size int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%d\x00%d\x00%d", renderVersion, path, info.ModTime().UnixNano(), info.Size(), size)))
+	return hex.EncodeToString(sum[:])
+}
+
+// entryPath returns where the entry of key with extension ext is kept.
+func (c *Cache) entryPath(key, ext string) string {
+	return filepath.Join(c.dir, key[:2], key+ext)
+}
+
+// checkDir makes sure the cache directory is private to the server before it
+// is first read or written.
+func (c *Cache) checkDir() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dirReady {
+		return nil
+	}
+	if err := fsutil.PrivateDir(c.dir); err != nil {
+		return err
+	}
+	c.dirReady = true
+	return nil
+}
+
+// Get returns the thumbnail of the image at path with the given MIME type,
+// rendering it on a miss.
+func (c *Cache) Get(path string, info os.FileInfo, mimeType string, size int) (*Thumbnail, error) {
+	key := Key(path, info, size)
+	if thumb, ok := c.lookup(key, mimeType); ok {
+		return thumb, nil
+	}
+
+	c.mu.Lock()
+	if r, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-r.done
+		return r.thumb, r.err
+	}
+	r := &render{done: make(chan struct{})}
+	c.inflight[key] = r
+	c.mu.Unlock()
+
+	r.thumb, r.err = c.render(key, path, info, mimeType, size)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(r.done)
+	return r.thumb, r.err
+}
+
+// lookup returns a cached entry and marks it as recently used. Its content
+// type comes from the entry's extension, not from its bytes.
+func (c *Cache) lookup(key, mimeType string) (*Thumbnail, bool) {
+	if c.checkDir() != nil {
+		return nil, false
+	}
+	exts := []string{".jpg", ".png"}
+	if mimeType == mimeSVG {
+		exts = []string{".svg"}
+	}
+	for _, ext := range exts {
+		entry := c.entryPath(key, ext)
+		info, err := os.Lstat(entry)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if time.Since(info.ModTime()) > time.Hour {
+			now := time.Now()
+			os.Chtimes(entry, now, now)
+		}
+		return &Thumbnail{Path: entry, ContentType: entryTypes[ext]}, true
+	}
+	return nil, false
+}
+
+// entryExt returns the extension recording a rendered content type.
+func entryExt(contentType string) (string, error) {
+	for ext, t := range entryTypes {
+		if t == contentType {
+			return ext, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected thumbnail type %s", contentType)
+}
+
+func (c *Cache) render(key, path string, info os.FileInfo, mimeType string, size int) (*Thumbnail, error) {
+	c.slots <- struct{}{}
+	data, contentType, err := renderFile(path, info, mimeType, size)
+	<-c.slots
+	if err != nil {
+		return nil, err
+	}
+	ext, err := entryExt(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkDir(); err != nil {
+		return nil, err
+	}
+
+	entry := c.entryPath(key, ext)
+	if err := os.MkdirAll(filepath.Dir(entry), 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(entry), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), entry)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.writes++
+	prune := c.writes%64 == 0 && !c.pruning
+	if prune {
+		c.pruning = true
+	}
+	c.mu.Unlock()
+	if prune {
+		go c.prune()
+	}
+	return &Thumbnail{Path: entry, ContentType: contentType}, nil
+}
+
+// prune removes the least recently used entries once the cache exceeds
+// maxBytes, down to 90% of it.
+func (c *Cache) prune() {
+	defer func() {
+		c.mu.Lock()
+		c.pruning = false
+		c.mu.Unlock()
+	}()
+
+	type entry struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+	var entries []entry
+	var total int64
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), mtime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if total <= c.maxBytes {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].mtime.Before(entries[j].mtime) })
+	target := c.maxBytes / 10 * 9
+	removed := 0
+	for _, e := range entries {
+		if total <= target {
+			break
+		}
+		if os.Remove(e.path) == nil {
+			total -= e.size
+			removed++
+		}
+	}
+	log.Info("Pruned %d thumbnails from %s", removed, c.dir)
+}
@@ -0,0 +1,261 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeImage(t *testing.T, path string, img image.Image, asJPEG bool) os.FileInfo {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	return info
+}
+
+func decodeThumb(t *testing.T, thumb *Thumbnail) image.Image {
+	t.Helper()
+	file, err := os.Open(thumb.Path)
+	if err != nil {
+		t.Fatalf("open thumbnail: %v", err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	return img
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int{"": DefaultSize, "1": 32, "32": 32, "100": 128, "256": 256, "700": 1024, "1024": 1024}
+	for value, want := range cases {
+		got, err := ParseSize(value)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"0", "-5", "2048", "big"} {
+		if _, err := ParseSize(value); err == nil {
+			t.Errorf("ParseSize(%q) should fail", value)
+		}
+	}
+}
+
+func TestCache_ScalesAndKeepsTransparency(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "wide.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 128})
+	info := writeImage(t, src, img, false)
+
+	cache := NewCache(filepath.Join(dir, "cache"), DefaultCacheBytes)
+	thumb, err := cache.Get(src, info, "image/png", 128)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if thumb.ContentType != "image/png" {
+		t.Fatalf("transparent image should stay PNG, got %s", thumb.ContentType)
+	}
+	if b := decodeThumb(t, thumb).Bounds(); b.Dx() != 128 || b.Dy() != 32 {
+		t.Fatalf("expected 128x32, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	// A second request is served from disk without rendering.
+	again, err := cache.Get(src, info, "image/png", 128)
+	if err != nil || again.Path != thumb.Path || again.ContentType != "image/png" {
+		t.Fatalf("expected cache hit, got %+v, %v", again, err)
+	}
+
+	// A new mtime is a new key.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(src, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	changed, _ := os.Stat(src)
+	if Key(src, changed, 128) == Key(src, info, 128) {
+		t.Fatal("key should change with the modification time")
+	}
+}
+
+func TestCache_ServesOnlyTheRenderedType(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "logo.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	info := writeImage(t, src, img, false)
+
+	cacheDir := filepath.Join(dir, "cache")
+	cache := NewCache(cacheDir, DefaultCacheBytes)
+	thumb, err := cache.Get(src, info, "image/png", 64)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if info, err := os.Stat(cacheDir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("cache dir is not private: %v err=%v", info, err)
+	}
+
+	// Whatever the entry holds, it is served as the type its render produced.
+	if err := os.WriteFile(thumb.Path, []byte("<html><script>alert(1)</script>"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	again, err := cache.Get(src, info, "image/png", 64)
+	if err != nil || again.ContentType != "image/png" {
+		t.Fatalf("expected image/png from the cache, got %+v, %v", again, err)
+	}
+}
+
+func TestCache_RefusesForeignCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "logo.png")
+	info := writeImage(t, src, image.NewNRGBA(image.Rect(0, 0, 10, 10)), false)
+
+	// A directory squatted as a symlink is not used.
+	planted := filepath.Join(dir, "planted")
+	if err := os.Mkdir(planted, 0777); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	if err := os.Symlink(planted, cacheDir); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if _, err := NewCache(cacheDir, DefaultCacheBytes).Get(src, info, "image/png", 64); err == nil {
+		t.Fatal("expected a symlinked cache dir to be refused")
+	}
+	if entries, _ := os.ReadDir(planted); len(entries) != 0 {
+		t.Fatalf("thumbnail written into the planted dir: %v", entries)
+	}
+}
+
+func TestCache_OpaqueImagesBecomeJPEGAndAreNotEnlarged(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "small.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	info := writeImage(t, src, img, false)
+
+	thumb, err := NewCache(filepath.Join(dir, "cache"), DefaultCacheBytes).Get(src, info, "image/png", 256)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if thumb.ContentType != "image/jpeg" {
+		t.Fatalf("expected JPEG, got %s", thumb.ContentType)
+	}
+	if b := decodeThumb(t, thumb).Bounds(); b.Dx() != 20 || b.Dy() != 10 {
+		t.Fatalf("expected original 20x10, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestCache_RejectsCorruptImages(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "broken.png")
+	if err := os.WriteFile(src, []byte("\x89PNG\r\n\x1a\nnot really"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, _ := os.Stat(src)
+	if _, err := NewCache(filepath.Join(dir, "cache"), DefaultCacheBytes).Get(src, info, "image/png", 64); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying an orientation tag.
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append([]byte{0xFF, 0xD8}, segment...), jpg[2:]...)
+}
+
+func TestCache_AppliesEXIFOrientation(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	src := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(src, withOrientation(buf.Bytes(), 6), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	info, _ := os.Stat(src)
+
+	thumb, err := NewCache(filepath.Join(dir, "cache"), DefaultCacheBytes).Get(src, info, "image/jpeg", 64)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if b := decodeThumb(t, thumb).Bounds(); b.Dx() != 32 || b.Dy() != 64 {
+		t.Fatalf("expected rotated 32x64, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	src := `<?xml version="1.0"?>
+<!DOCTYPE svg [<!ENTITY x "boom">]>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:inkscape="http://www.inkscape.org" onload="alert(1)" width="10">
+  <!-- comment -->
+  <script>alert(1)</script>
+  <foreignObject><div>html</div></foreignObject>
+  <style>@import url(http://evil/x.css);</style>
+  <rect width="5" height="5" fill="url(#grad)" inkscape:label="r" onclick="x()"/>
+  <a href="javascript:alert(1)"><circle r="1" style="fill: url(https://evil/track)"/></a>
+  <use xlink:href="#shape"/>
+  <image href="https://evil/pixel.png"/>
+  <set attributeName="href" to="javascript:alert(1)"/>
+</svg>`
+	out, err := SanitizeSVG([]byte(src))
+	if err != nil {
+		t.Fatalf("SanitizeSVG: %v", err)
+	}
+	got := string(out)
+	for _, banned := range []string{"script", "onload", "onclick", "foreignObject", "html", "@import", "javascript", "evil", "inkscape:label", "DOCTYPE", "comment", `attributeName="href"`} {
+		if strings.Contains(got, banned) {
+			t.Errorf("sanitized SVG still contains %q:\n%s", banned, got)
+		}
+	}
+	for _, kept := range []string{`<rect width="5" height="5" fill="url(#grad)">`, `xlink:href="#shape"`, `width="10"`, "<circle"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("sanitized SVG lost %q:\n%s", kept, got)
+		}
+	}
+
+	if _, err := SanitizeSVG([]byte(`<html><body/></html>`)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for a non-SVG root, got %v", err)
+	}
+}
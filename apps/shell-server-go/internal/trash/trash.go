@@ -0,0 +1,448 @@
+// Package trash keeps deleted files restorable for a retention period.
+package trash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/internal/fsutil"
+	"shell-server-go/internal/logger"
+)
+
+const (
+	// DefaultRetention is how long deleted items are kept when not configured.
+	DefaultRetention = 7 * 24 * time.Hour
+	// SweepInterval is how often expired items are purged.
+	SweepInterval = time.Hour
+
+	metaFile = "meta.json"
+	itemName = "item"
+	sitesDir = "sites"
+)
+
+var log = logger.WithComponent("TRASH")
+
+var (
+	ErrNotFound         = errors.New("trash item not found")
+	ErrExists           = errors.New("restore destination already exists")
+	ErrInvalidWorkspace = errors.New("invalid trash workspace")
+)
+
+var (
+	idRegex       = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}-[0-9a-f]{8}$`)
+	siteNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*$`)
+	keyCleaner    = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+)
+
+// Item is the metadata stored next to each deleted entry.
+type Item struct {
+	ID           string    `json:"id"`
+	Workspace    string    `json:"workspace"`
+	OriginalPath string    `json:"originalPath"`
+	Type         string    `json:"type"`
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Session      string    `json:"session,omitempty"`
+}
+
+// Store moves deleted entries into per-workspace trash directories under the
+// configured trash root. Site workspaces use <root>/sites/<site>: the site user
+// owns everything under sites/<site>, so trash kept there could be swapped for
+// symlinks before the server restores or purges it.
+type Store struct {
+	root      string
+	retention time.Duration
+
+	mu        sync.Mutex
+	started   bool
+	stopSweep chan struct{}
+	sweepDone chan struct{}
+}
+
+// NewStore creates a trash store from the application config.
+func NewStore(cfg *config.AppConfig) *Store {
+	retention := cfg.TrashRetention
+	if retention == 0 {
+		retention = DefaultRetention
+	}
+	root := cfg.ResolvedTrashPath
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "shell-server-trash")
+	}
+	return &Store{
+		root:      root,
+		retention: retention,
+		stopSweep: make(chan struct{}),
+		sweepDone: make(chan struct{}),
+	}
+}
+
+// Retention returns how long items stay restorable.
+func (s *Store) Retention() time.Duration {
+	return s.retention
+}
+
+// SessionHash identifies the deleting session without storing its token.
+func SessionHash(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Dir returns the trash directory for a workspace key such as "root",
+// "site:example.com" or "editor:docs".
+func (s *Store) Dir(workspace string) (string, error) {
+	if site, ok := strings.CutPrefix(workspace, "site:"); ok {
+		if !siteNameRegex.MatchString(site) || strings.Contains(site, "..") {
+			return "", ErrInvalidWorkspace
+		}
+		return filepath.Join(s.root, sitesDir, site), nil
+	}
+	key := strings.Trim(keyCleaner.ReplaceAllString(workspace, "-"), "-.")
+	if key == "" || key == sitesDir {
+		return "", ErrInvalidWorkspace
+	}
+	return filepath.Join(s.root, key), nil
+}
+
+func newID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b[:])
+}
+
+func entrySize(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// Trash moves path into the workspace trash. basePath is the workspace root the
+// original path is recorded relative to.
+func (s *Store) Trash(workspace, basePath, path, sessionToken string) (*Item, error) {
+	dir, err := s.Dir(workspace)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(basePath, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("path %s is not inside %s", path, basePath)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	typeStr := "file"
+	if info.IsDir() {
+		typeStr = "directory"
+	}
+
+	now := time.Now()
+	item := &Item{
+		ID:           newID(),
+		Workspace:    workspace,
+		OriginalPath: filepath.ToSlash(rel),
+		Type:         typeStr,
+		Size:         entrySize(path),
+		DeletedAt:    now,
+		ExpiresAt:    now.Add(s.retention),
+		Session:      SessionHash(sessionToken),
+	}
+
+	if err := s.makeDir(dir); err != nil {
+		return nil, err
+	}
+	itemDir := filepath.Join(dir, item.ID)
+	if err := os.Mkdir(itemDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := fsutil.Move(path, filepath.Join(itemDir, itemName)); err != nil {
+		os.RemoveAll(itemDir)
+		return nil, err
+	}
+	if err := writeMeta(itemDir, item); err != nil {
+		// Without metadata the item could never be restored; put it back.
+		if restoreErr := fsutil.Move(filepath.Join(itemDir, itemName), path); restoreErr != nil {
+			log.Error("Failed to put back %s after metadata error: %v", path, restoreErr)
+		} else {
+			os.RemoveAll(itemDir)
+		}
+		return nil, err
+	}
+
+	log.Info("Moved %s to trash %s (%s)", path, item.ID, workspace)
+	return item, nil
+}
+
+// makeDir creates a workspace trash directory and the directories above it up
+// to the trash root, each private to the server.
+func (s *Store) makeDir(dir string) error {
+	if err := os.MkdirAll(filepath.Dir(s.root), 0755); err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(s.root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ErrInvalidWorkspace
+	}
+	path := s.root
+	if err := fsutil.PrivateDir(path); err != nil {
+		return err
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		if err := fsutil.PrivateDir(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMeta(itemDir string, item *Item) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(itemDir, metaFile), data, 0600)
+}
+
+func readMeta(itemDir string) (*Item, error) {
+	data, err := os.ReadFile(filepath.Join(itemDir, metaFile))
+	if err != nil {
+		return nil, err
+	}
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List returns the workspace's trash items, newest first.
+func (s *Store) List(workspace string) ([]Item, error) {
+	dir, err := s.Dir(workspace)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() || !idRegex.MatchString(e.Name()) {
+			continue
+		}
+		item, err := readMeta(filepath.Join(dir, e.Name()))
+		if err != nil {
+			log.Warn("Skipping unreadable trash item %s: %v", e.Name(), err)
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Get returns one trash item.
+func (s *Store) Get(workspace, id string) (*Item, error) {
+	itemDir, err := s.itemDir(workspace, id)
+	if err != nil {
+		return nil, err
+	}
+	item, err := readMeta(itemDir)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return item, err
+}
+
+func (s *Store) itemDir(workspace, id string) (string, error) {
+	if !idRegex.MatchString(id) {
+		return "", ErrNotFound
+	}
+	dir, err := s.Dir(workspace)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id), nil
+}
+
+// Restore moves an item back to dest, which the caller has resolved and validated
+// for the item's workspace. It refuses to overwrite an existing entry.
+func (s *Store) Restore(workspace, id, dest string) (*Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.Get(workspace, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return item, ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return item, err
+	}
+
+	itemDir, _ := s.itemDir(workspace, id)
+	if err := fsutil.Move(filepath.Join(itemDir, itemName), dest); err != nil {
+		return item, err
+	}
+	if err := os.RemoveAll(itemDir); err != nil {
+		log.Warn("Failed to remove restored trash entry %s: %v", itemDir, err)
+	}
+	log.Info("Restored trash %s to %s", id, dest)
+	return item, nil
+}
+
+// Purge permanently deletes one item.
+func (s *Store) Purge(workspace, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	itemDir, err := s.itemDir(workspace, id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(itemDir); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(itemDir)
+}
+
+// PurgeAll permanently deletes every item of a workspace.
+func (s *Store) PurgeAll(workspace string) (int, error) {
+	items, err := s.List(workspace)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, item := range items {
+		if err := s.Purge(workspace, item.ID); err == nil {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// trashDirs returns every per-workspace trash directory that exists.
+func (s *Store) trashDirs() []string {
+	var dirs []string
+	for _, parent := range []string{s.root, filepath.Join(s.root, sitesDir)} {
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() && !(parent == s.root && e.Name() == sitesDir) {
+				dirs = append(dirs, filepath.Join(parent, e.Name()))
+			}
+		}
+	}
+	return dirs
+}
+
+// Sweep purges items past their expiry and returns how many were removed.
+func (s *Store) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for _, dir := range s.trashDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() || !idRegex.MatchString(e.Name()) {
+				continue
+			}
+			itemDir := filepath.Join(dir, e.Name())
+			item, err := readMeta(itemDir)
+			if err != nil || now.Before(item.ExpiresAt) {
+				continue
+			}
+			if err := os.RemoveAll(itemDir); err != nil {
+				log.Warn("Failed to purge expired trash item %s: %v", itemDir, err)
+				continue
+			}
+			removed++
+		}
+	}
+	if removed > 0 {
+		log.Info("Purged %d expired trash items", removed)
+	}
+	return removed
+}
+
+// Start runs the retention sweep in the background until Stop is called.
+func (s *Store) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	go s.sweepLoop()
+}
+
+// sweepLoop periodically removes expired items
+func (s *Store) sweepLoop() {
+	defer close(s.sweepDone)
+
+	s.Sweep()
+	ticker := time.NewTicker(SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-s.stopSweep:
+			return
+		}
+	}
+}
+
+// Stop stops the background sweep.
+func (s *Store) Stop() {
+	s.mu.Lock()
+	started := s.started
+	s.started = false
+	s.mu.Unlock()
+	if !started {
+		return
+	}
+	close(s.stopSweep)
+	<-s.sweepDone
+	log.Debug("Trash sweeper stopped")
+}
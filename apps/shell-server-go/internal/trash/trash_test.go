@@ -0,0 +1,134 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"shell-server-go/internal/config"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	sites := filepath.Join(tmp, "sites")
+	base := filepath.Join(sites, "example.com", "user")
+	if err := os.MkdirAll(filepath.Join(base, "src", "lib"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "src", "lib", "a.js"), []byte("abc"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	cfg := &config.AppConfig{ResolvedSitesPath: sites, ResolvedTrashPath: filepath.Join(tmp, "trash")}
+	return NewStore(cfg), base
+}
+
+func TestStore_TrashListRestore(t *testing.T) {
+	s, base := newTestStore(t)
+
+	item, err := s.Trash("site:example.com", base, filepath.Join(base, "src"), "token-123")
+	if err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if item.OriginalPath != "src" || item.Type != "directory" || item.Size != 3 || item.Session == "" || item.Session == "token-123" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if _, err := os.Stat(filepath.Join(base, "src")); !os.IsNotExist(err) {
+		t.Fatalf("source still present")
+	}
+	// Site trash lives under the server's trash root, outside the site.
+	siteDir := filepath.Join(s.root, "sites", "example.com")
+	if _, err := os.Stat(filepath.Join(siteDir, item.ID, "item", "lib", "a.js")); err != nil {
+		t.Fatalf("item not in site trash: %v", err)
+	}
+	if info, err := os.Stat(siteDir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("site trash mode %v err=%v, want 0700", info.Mode().Perm(), err)
+	}
+
+	items, err := s.List("site:example.com")
+	if err != nil || len(items) != 1 || items[0].ID != item.ID {
+		t.Fatalf("unexpected list %+v err=%v", items, err)
+	}
+	if other, _ := s.List("root"); len(other) != 0 {
+		t.Fatalf("item leaked into another workspace: %+v", other)
+	}
+
+	if err := os.MkdirAll(filepath.Join(base, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := s.Restore("site:example.com", item.ID, filepath.Join(base, "src")); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	os.Remove(filepath.Join(base, "src"))
+
+	if _, err := s.Restore("site:example.com", item.ID, filepath.Join(base, "src")); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(base, "src", "lib", "a.js")); err != nil || string(content) != "abc" {
+		t.Fatalf("restored content %q err=%v", content, err)
+	}
+	if items, _ := s.List("site:example.com"); len(items) != 0 {
+		t.Fatalf("restored item still listed: %+v", items)
+	}
+}
+
+func TestStore_SweepAndPurge(t *testing.T) {
+	s, base := newTestStore(t)
+	s.retention = time.Millisecond
+
+	if _, err := s.Trash("site:example.com", base, filepath.Join(base, "src", "lib", "a.js"), ""); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if removed := s.Sweep(); removed != 1 {
+		t.Fatalf("expected 1 swept item, got %d", removed)
+	}
+
+	s.retention = time.Hour
+	item, err := s.Trash("site:example.com", base, filepath.Join(base, "src"), "")
+	if err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	if removed := s.Sweep(); removed != 0 {
+		t.Fatalf("unexpired item swept")
+	}
+	if err := s.Purge("site:example.com", item.ID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if err := s.Purge("site:example.com", item.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_RejectsInvalidKeys(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	for _, workspace := range []string{"site:../etc", "site:", "..", "", "sites"} {
+		if _, err := s.Dir(workspace); !errors.Is(err, ErrInvalidWorkspace) {
+			t.Errorf("%q: expected ErrInvalidWorkspace, got %v", workspace, err)
+		}
+	}
+	if _, err := s.Get("root", "../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for traversal id, got %v", err)
+	}
+}
+
+func TestStore_RefusesSymlinkedSiteTrash(t *testing.T) {
+	s, base := newTestStore(t)
+
+	if err := os.MkdirAll(filepath.Join(s.root, "sites"), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	elsewhere := t.TempDir()
+	if err := os.Symlink(elsewhere, filepath.Join(s.root, "sites", "example.com")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if _, err := s.Trash("site:example.com", base, filepath.Join(base, "src"), ""); err == nil {
+		t.Fatalf("expected trash into a symlinked directory to fail")
+	}
+	if _, err := os.Stat(filepath.Join(base, "src", "lib", "a.js")); err != nil {
+		t.Fatalf("source moved despite the error: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"shell-server-go/internal/ignore"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW | syscall.IN_EXCL_UNLINK
+
+// inotifyWatcher watches every directory of a tree with one inotify instance.
+type inotifyWatcher struct {
+	w    *rootWatcher
+	file *os.File
+	fd   int
+
+	mu    sync.Mutex
+	dirs  map[int32]string // watch descriptor -> absolute directory
+	wds   map[string]int32 // absolute directory -> watch descriptor
+	taken int              // watches taken from the manager's budget
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (w *rootWatcher) startInotify() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	iw := &inotifyWatcher{
+		w:    w,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		dirs: make(map[int32]string),
+		wds:  make(map[string]int32),
+		done: make(chan struct{}),
+	}
+	if err := iw.addTree(w.root, false); err != nil {
+		iw.file.Close()
+		iw.releaseAll()
+		return err
+	}
+
+	w.mu.Lock()
+	w.mode = ModeInotify
+	w.backend = iw
+	w.mu.Unlock()
+	go iw.readLoop()
+	return nil
+}
+
+// addTree watches dir and every directory below it. With report set, entries
+// found below dir are reported as created, since they may have appeared
+// before the watch was in place.
+func (iw *inotifyWatcher) addTree(dir string, report bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // vanished meanwhile
+		}
+		if path != dir && report {
+			iw.w.emit(Event{Type: EventCreate, Path: relPath(iw.w.root, path), IsDir: d.IsDir()})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if iw.w.ignored(path) {
+			return filepath.SkipDir
+		}
+		return iw.addWatch(path)
+	})
+}
+
+func (iw *inotifyWatcher) addWatch(dir string) error {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if _, ok := iw.wds[dir]; ok {
+		return nil
+	}
+	if !iw.w.m.takeWatch() {
+		return errWatchLimit
+	}
+	wd, err := syscall.InotifyAddWatch(iw.fd, dir, inotifyMask)
+	if err != nil {
+		iw.w.m.releaseWatches(1)
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.ENOMEM) {
+			return errWatchLimit
+		}
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+			return nil
+		}
+		return err
+	}
+	iw.taken++
+	iw.dirs[int32(wd)] = dir
+	iw.wds[dir] = int32(wd)
+	return nil
+}
+
+// forget drops a watch the kernel removed.
+func (iw *inotifyWatcher) forget(wd int32) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	dir, ok := iw.dirs[wd]
+	if !ok {
+		return
+	}
+	delete(iw.dirs, wd)
+	delete(iw.wds, dir)
+	iw.taken--
+	iw.w.m.releaseWatches(1)
+}
+
+// renameDir updates watched paths after a directory moved within the tree.
+func (iw *inotifyWatcher) renameDir(from, to string) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	for wd, dir := range iw.dirs {
+		if dir == from || strings.HasPrefix(dir, from+string(filepath.Separator)) {
+			moved := to + strings.TrimPrefix(dir, from)
+			delete(iw.wds, dir)
+			iw.dirs[wd] = moved
+			iw.wds[moved] = wd
+		}
+	}
+}
+
+func (iw *inotifyWatcher) dirOf(wd int32) (string, bool) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	dir, ok := iw.dirs[wd]
+	return dir, ok
+}
+
+func (iw *inotifyWatcher) readLoop() {
+	defer close(iw.done)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := iw.file.Read(buf)
+		if err != nil {
+			return // closed
+		}
+		if err := iw.handle(buf[:n]); err != nil {
+			go iw.w.fallbackToPoll(err)
+			return
+		}
+	}
+}
+
+// handle decodes one read. Moves are paired by cookie within the read; a move
+// whose other half is outside the tree is reported as a delete or create.
+func (iw *inotifyWatcher) handle(buf []byte) error {
+	type moveFrom struct {
+		path  string
+		isDir bool
+	}
+	movedFrom := make(map[uint32]moveFrom)
+	var order []uint32
+
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+		offset += syscall.SizeofInotifyEvent + int(raw.Len)
+		name := strings.TrimRight(string(nameBytes), "\x00")
+		mask := raw.Mask
+
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			iw.w.markLost()
+			continue
+		}
+		if mask&syscall.IN_IGNORED != 0 {
+			iw.forget(raw.Wd)
+			continue
+		}
+		dir, ok := iw.dirOf(raw.Wd)
+		if !ok || name == "" {
+			continue // events on the watched directory itself
+		}
+		path := filepath.Join(dir, name)
+		rel := relPath(iw.w.root, path)
+		isDir := mask&syscall.IN_ISDIR != 0
+
+		if !isDir && ignore.IsIgnoreFile(name) && iw.w.rules != nil {
+			// Directories the rules no longer ignore need watches.
+			iw.w.rules.Invalidate(dir)
+			if err := iw.addTree(dir, false); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case mask&syscall.IN_CREATE != 0:
+			iw.w.emit(Event{Type: EventCreate, Path: rel, IsDir: isDir})
+			if isDir && !iw.w.ignored(path) {
+				if err := iw.addTree(path, true); err != nil {
+					return err
+				}
+			}
+		case mask&syscall.IN_MOVED_FROM != 0:
+			movedFrom[raw.Cookie] = moveFrom{path: path, isDir: isDir}
+			order = append(order, raw.Cookie)
+		case mask&syscall.IN_MOVED_TO != 0:
+			if from, ok := movedFrom[raw.Cookie]; ok {
+				delete(movedFrom, raw.Cookie)
+				iw.w.emit(Event{Type: EventRename, Path: rel, OldPath: relPath(iw.w.root, from.path), IsDir: isDir})
+				if isDir {
+					iw.renameDir(from.path, path)
+					// A directory renamed from an ignored name has no watches yet.
+					if !iw.w.ignored(path) {
+						if err := iw.addTree(path, false); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+			iw.w.emit(Event{Type: EventCreate, Path: rel, IsDir: isDir})
+			if isDir && !iw.w.ignored(path) {
+				if err := iw.addTree(path, true); err != nil {
+					return err
+				}
+			}
+		case mask&syscall.IN_DELETE != 0:
+			iw.w.emit(Event{Type: EventDelete, Path: rel, IsDir: isDir})
+		case mask&(syscall.IN_MODIFY|syscall.IN_ATTRIB) != 0 && !isDir:
+			iw.w.emit(Event{Type: EventModify, Path: rel})
+		}
+	}
+
+	// Moved out of the tree: gone as far as subscribers are concerned. Its
+	// watches stay until the kernel drops them with the directory.
+	for _, cookie := range order {
+		if from, ok := movedFrom[cookie]; ok {
+			iw.w.emit(Event{Type: EventDelete, Path: relPath(iw.w.root, from.path), IsDir: from.isDir})
+			if from.isDir {
+				iw.removeTree(from.path)
+			}
+		}
+	}
+	return nil
+}
+
+// removeTree stops watching a directory that left the tree.
+func (iw *inotifyWatcher) removeTree(dir string) {
+	iw.mu.Lock()
+	var wds []int32
+	for wd, path := range iw.dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			wds = append(wds, wd)
+		}
+	}
+	iw.mu.Unlock()
+	for _, wd := range wds {
+		syscall.InotifyRmWatch(iw.fd, uint32(wd))
+		iw.forget(wd)
+	}
+}
+
+func (iw *inotifyWatcher) releaseAll() {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	iw.w.m.releaseWatches(iw.taken)
+	iw.taken = 0
+	iw.dirs = make(map[int32]string)
+	iw.wds = make(map[string]int32)
+}
+
+func (iw *inotifyWatcher) close() {
+	iw.closeOnce.Do(func() {
+		iw.file.Close()
+		<-iw.done
+		iw.releaseAll()
+	})
+}
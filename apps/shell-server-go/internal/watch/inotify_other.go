@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// startInotify is only implemented on Linux; elsewhere roots are polled.
+func (w *rootWatcher) startInotify() error {
+	return errors.New("inotify is not available on this platform")
+}
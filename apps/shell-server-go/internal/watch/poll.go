@@ -0,0 +1,175 @@
+package watch
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"shell-server-go/internal/ignore"
+)
+
+// pollEntry is what a scan remembers about one entry.
+type pollEntry struct {
+	isDir bool
+	size  int64
+	mtime time.Time
+	ino   uint64
+}
+
+// poller finds changes by rescanning the tree every pollInterval and diffing
+// it against the previous scan. Renames are recognised by inode.
+type poller struct {
+	root      string
+	rules     *ignore.Matcher
+	emit      func(Event)
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPoller(root string, rules *ignore.Matcher, emit func(Event)) *poller {
+	return &poller{
+		root:  root,
+		rules: rules,
+		emit:  emit,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (p *poller) start() {
+	go p.loop()
+}
+
+func (p *poller) loop() {
+	defer close(p.done)
+
+	prev := scanTree(p.root, p.rules)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cur := scanTree(p.root, p.rules)
+			for _, ev := range diffScans(prev, cur) {
+				p.emit(ev)
+			}
+			prev = cur
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *poller) close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+// scanTree records every entry below root, skipping ignored entries and
+// stopping at MaxPollEntries.
+func scanTree(root string, rules *ignore.Matcher) map[string]pollEntry {
+	entries := make(map[string]pollEntry)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		if rules.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entry := pollEntry{isDir: d.IsDir(), size: info.Size(), mtime: info.ModTime()}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			entry.ino = stat.Ino
+		}
+		entries[relPath(root, path)] = entry
+		if len(entries) >= MaxPollEntries {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return entries
+}
+
+// diffScans turns two scans into events. A renamed directory is reported once;
+// the entries below it are not reported again.
+func diffScans(prev, cur map[string]pollEntry) []Event {
+	var created, deleted []string
+	for path := range cur {
+		if _, ok := prev[path]; !ok {
+			created = append(created, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+	sort.Strings(created)
+	sort.Strings(deleted)
+
+	deletedByIno := make(map[uint64]string)
+	for _, path := range deleted {
+		if ino := prev[path].ino; ino != 0 {
+			deletedByIno[ino] = path
+		}
+	}
+
+	var events []Event
+	renamedFrom := make(map[string]bool)
+	type dirRename struct{ from, to string }
+	var dirRenames []dirRename
+	isBelowRename := func(path string) bool {
+		for _, r := range dirRenames {
+			if strings.HasPrefix(path, r.to+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, path := range created {
+		entry := cur[path]
+		if isBelowRename(path) {
+			if old, ok := deletedByIno[entry.ino]; ok {
+				renamedFrom[old] = true
+				continue
+			}
+		}
+		if old, ok := deletedByIno[entry.ino]; ok && entry.ino != 0 && prev[old].isDir == entry.isDir {
+			renamedFrom[old] = true
+			events = append(events, Event{Type: EventRename, Path: path, OldPath: old, IsDir: entry.isDir})
+			if entry.isDir {
+				dirRenames = append(dirRenames, dirRename{from: old, to: path})
+			}
+			continue
+		}
+		events = append(events, Event{Type: EventCreate, Path: path, IsDir: entry.isDir})
+	}
+	for _, path := range deleted {
+		if !renamedFrom[path] {
+			events = append(events, Event{Type: EventDelete, Path: path, IsDir: prev[path].isDir})
+		}
+	}
+	for path, entry := range cur {
+		old, ok := prev[path]
+		if ok && !entry.isDir && (old.size != entry.size || !old.mtime.Equal(entry.mtime) || old.ino != entry.ino) {
+			events = append(events, Event{Type: EventModify, Path: path})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
@@ -0,0 +1,85 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Stream timing; variables so tests can shorten them.
+var (
+	sessionCheckInterval = 5 * time.Second
+	heartbeatInterval    = 15 * time.Second
+)
+
+// Serve streams a subscription as Server-Sent Events until the client
+// disconnects or valid reports false. It sends:
+//
+//   - "ready" {mode} once the stream is open
+//   - "changes" Batch for every debounce window with changes
+//   - "mode" {mode} when the root falls back to polling
+//   - "end" {reason} before closing because the session expired
+func Serve(w http.ResponseWriter, r *http.Request, sub *Subscription, valid func() bool) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Debug("Failed to clear write deadline for watch: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	mode := sub.Mode()
+	sendEvent(w, "ready", map[string]string{"mode": mode})
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	sessionCheck := time.NewTicker(sessionCheckInterval)
+	defer sessionCheck.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sessionCheck.C:
+			if !valid() {
+				sendEvent(w, "end", map[string]string{"reason": "session_expired"})
+				rc.Flush()
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case batch := <-sub.Events():
+			if current := sub.Mode(); current != mode {
+				mode = current
+				sendEvent(w, "mode", map[string]string{"mode": mode})
+			}
+			sendEvent(w, "changes", batch)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func sendEvent(w io.Writer, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
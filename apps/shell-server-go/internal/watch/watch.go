@@ -0,0 +1,499 @@
+// Package watch publishes filesystem changes below a directory to subscribers.
+//
+// Each watched root has one backend shared by all of its subscribers: inotify
+// where available, or a polling scanner when inotify is unavailable or its
+// limits are hit. Raw events are debounced, coalesced per path and fanned out
+// as batches.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"shell-server-go/internal/ignore"
+	"shell-server-go/internal/logger"
+)
+
+// Event types.
+const (
+	EventCreate = "create"
+	EventModify = "modify"
+	EventDelete = "delete"
+	EventRename = "rename"
+)
+
+// Backend modes reported to subscribers.
+const (
+	ModeInotify = "inotify"
+	ModePoll    = "poll"
+)
+
+// MaxWatches caps the inotify watches one Manager holds across all roots.
+// Roots that would exceed it are polled instead.
+const MaxWatches = 8192
+
+// Subscription limits of one Manager: per subscriber key (see Options.Key) and
+// in total. Each subscription holds an open stream and, until its root's last
+// subscriber leaves, a backend.
+const (
+	MaxSubscriptionsPerKey = 16
+	MaxSubscriptions       = 256
+)
+
+// MaxPollEntries caps the entries a polling scan tracks per root.
+const MaxPollEntries = 100000
+
+// Timing; variables so tests can shorten them.
+var (
+	debounceInterval = 150 * time.Millisecond
+	pollInterval     = 2 * time.Second
+)
+
+const subscriberBuffer = 16
+
+var log = logger.WithComponent("WATCH")
+
+// errWatchLimit means inotify cannot watch the whole tree.
+var errWatchLimit = errors.New("inotify watch limit reached")
+
+// ErrTooManySubscriptions is returned by Subscribe when a subscription limit
+// is reached.
+var ErrTooManySubscriptions = errors.New("too many watch subscriptions")
+
+// Event is one change, with slash-separated paths relative to the watched root.
+// OldPath is set for renames.
+type Event struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+	IsDir   bool   `json:"isDir,omitempty"`
+}
+
+// Batch is the set of changes of one debounce window. Overflow means events
+// were lost (kernel queue overflow, a slow subscriber or a backend switch), so
+// the subscriber should reload instead of applying the events.
+type Batch struct {
+	Events   []Event `json:"events"`
+	Overflow bool    `json:"overflow,omitempty"`
+}
+
+// Options select what a subscription reports.
+type Options struct {
+	// IgnoreBase is the directory whose ignore files, and those below it, hide
+	// paths (see package ignore); usually the workspace root. Empty means the
+	// watched root.
+	IgnoreBase string
+	// ShowIgnored reports changes to ignored paths too.
+	ShowIgnored bool
+	// Key identifies who subscribes, usually the session, for
+	// MaxSubscriptionsPerKey. Empty subscriptions count only towards
+	// MaxSubscriptions.
+	Key string
+}
+
+// rootKey identifies a shared watcher: subscribers share one only when they
+// watch the same root with the same rules.
+type rootKey struct {
+	root        string
+	ignoreBase  string
+	showIgnored bool
+}
+
+// Manager shares one watcher per root between subscribers.
+type Manager struct {
+	mu         sync.Mutex
+	roots      map[rootKey]*rootWatcher
+	watches    int
+	maxWatches int
+	forcePoll  bool
+
+	subs       int
+	subsByKey  map[string]int
+	maxSubs    int
+	maxSubsKey int
+}
+
+// NewManager creates a watch manager.
+func NewManager() *Manager {
+	return &Manager{
+		roots:      make(map[rootKey]*rootWatcher),
+		maxWatches: MaxWatches,
+		subsByKey:  make(map[string]int),
+		maxSubs:    MaxSubscriptions,
+		maxSubsKey: MaxSubscriptionsPerKey,
+	}
+}
+
+// takeWatch reserves one inotify watch from the manager's budget.
+func (m *Manager) takeWatch() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.watches >= m.maxWatches {
+		return false
+	}
+	m.watches++
+	return true
+}
+
+// releaseWatches returns n watches to the budget.
+func (m *Manager) releaseWatches(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watches = max(m.watches-n, 0)
+}
+
+// Subscribe starts receiving changes below root, which must be a directory.
+// It returns ErrTooManySubscriptions when a subscription limit is reached.
+func (m *Manager) Subscribe(root string, opts Options) (*Subscription, error) {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	key := rootKey{root: real, ignoreBase: real, showIgnored: opts.ShowIgnored}
+	if opts.IgnoreBase != "" && !opts.ShowIgnored {
+		if base, err := filepath.EvalSymlinks(opts.IgnoreBase); err == nil && isWithin(real, base) {
+			key.ignoreBase = base
+		}
+	}
+
+	m.mu.Lock()
+	if m.subs >= m.maxSubs || opts.Key != "" && m.subsByKey[opts.Key] >= m.maxSubsKey {
+		m.mu.Unlock()
+		return nil, ErrTooManySubscriptions
+	}
+	w, ok := m.roots[key]
+	if !ok {
+		w = &rootWatcher{
+			m:    m,
+			key:  key,
+			root: real,
+			subs: make(map[*Subscription]struct{}),
+			raw:  make(chan Event, 1024),
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		if !opts.ShowIgnored {
+			w.rules = ignore.New(key.ignoreBase)
+		}
+		m.roots[key] = w
+	}
+	sub := &Subscription{w: w, key: opts.Key, events: make(chan Batch, subscriberBuffer)}
+	m.subs++
+	if opts.Key != "" {
+		m.subsByKey[opts.Key]++
+	}
+	w.mu.Lock()
+	w.subs[sub] = struct{}{}
+	w.mu.Unlock()
+	m.mu.Unlock()
+
+	if !ok {
+		w.start()
+	}
+	return sub, nil
+}
+
+// Subscription receives the batches of one root until closed.
+type Subscription struct {
+	w          *rootWatcher
+	key        string
+	events     chan Batch
+	overflowed bool
+	closeOnce  sync.Once
+}
+
+// Events returns the channel batches are delivered on.
+func (s *Subscription) Events() <-chan Batch {
+	return s.events
+}
+
+// Mode returns the backend currently watching the root.
+func (s *Subscription) Mode() string {
+	return s.w.currentMode()
+}
+
+// Close stops the subscription. The root's backend stops with its last subscriber.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.w.unsubscribe(s)
+	})
+}
+
+// backend produces raw events for a root until closed.
+type backend interface {
+	close()
+}
+
+// rootWatcher runs the backend and the debounce loop of one root.
+type rootWatcher struct {
+	m     *Manager
+	key   rootKey
+	root  string
+	rules *ignore.Matcher // nil when ignored paths are shown
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	mode    string
+	backend backend
+
+	raw  chan Event
+	lost bool // events were dropped since the last batch
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (w *rootWatcher) start() {
+	if w.m.forcePoll {
+		w.startPoll()
+	} else if err := w.startInotify(); err != nil {
+		log.Warn("Falling back to polling for %s: %v", w.root, err)
+		w.startPoll()
+	}
+	go w.loop()
+}
+
+func (w *rootWatcher) startPoll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stop:
+		return // the last subscriber left meanwhile
+	default:
+	}
+	p := newPoller(w.root, w.rules, w.emit)
+	w.mode = ModePoll
+	w.backend = p
+	p.start()
+}
+
+// fallbackToPoll replaces a failing inotify backend with polling. Events may
+// have been missed during the switch, so subscribers are told to reload.
+func (w *rootWatcher) fallbackToPoll(reason error) {
+	w.mu.Lock()
+	if w.mode != ModeInotify {
+		w.mu.Unlock()
+		return
+	}
+	old := w.backend
+	w.mode = ModePoll
+	w.mu.Unlock()
+
+	log.Warn("Switching %s to polling: %v", w.root, reason)
+	old.close()
+	w.startPoll()
+	w.markLost()
+}
+
+func (w *rootWatcher) currentMode() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mode
+}
+
+// emit queues a raw event; events for ignored paths are dropped. A change to
+// an ignore file reloads its rules and tells subscribers to reload, since
+// entries may have appeared or vanished without events of their own.
+func (w *rootWatcher) emit(ev Event) {
+	if w.rules != nil && (ignore.IsIgnoreFile(path.Base(ev.Path)) || ev.OldPath != "" && ignore.IsIgnoreFile(path.Base(ev.OldPath))) {
+		w.rules.Invalidate(filepath.Join(w.root, filepath.FromSlash(path.Dir(ev.Path))))
+		if ev.OldPath != "" {
+			w.rules.Invalidate(filepath.Join(w.root, filepath.FromSlash(path.Dir(ev.OldPath))))
+		}
+		w.markLost()
+	}
+	if w.excludes(ev.Path, ev.IsDir) && (ev.OldPath == "" || w.excludes(ev.OldPath, ev.IsDir)) {
+		return
+	}
+	select {
+	case w.raw <- ev:
+	case <-w.stop:
+	default:
+		w.markLost()
+	}
+}
+
+// markLost flags the next batch as an overflow.
+func (w *rootWatcher) markLost() {
+	w.mu.Lock()
+	w.lost = true
+	w.mu.Unlock()
+	select {
+	case w.raw <- Event{}:
+	default:
+	}
+}
+
+// loop collects raw events for one debounce window and publishes them.
+func (w *rootWatcher) loop() {
+	defer close(w.done)
+
+	var pending coalescer
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case ev := <-w.raw:
+			if ev.Type != "" {
+				pending.add(ev)
+			}
+			if timer == nil {
+				timer = time.After(debounceInterval)
+			}
+		case <-timer:
+			timer = nil
+			w.mu.Lock()
+			lost := w.lost
+			w.lost = false
+			w.mu.Unlock()
+			if events := pending.flush(); len(events) > 0 || lost {
+				w.publish(Batch{Events: events, Overflow: lost})
+			}
+		}
+	}
+}
+
+// publish fans a batch out. A subscriber whose buffer is full misses it and
+// gets Overflow on its next batch.
+func (w *rootWatcher) publish(batch Batch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for sub := range w.subs {
+		b := batch
+		if sub.overflowed {
+			b.Overflow = true
+		}
+		select {
+		case sub.events <- b:
+			sub.overflowed = false
+		default:
+			sub.overflowed = true
+		}
+	}
+}
+
+func (w *rootWatcher) unsubscribe(sub *Subscription) {
+	w.m.mu.Lock()
+	w.m.subs--
+	if sub.key != "" {
+		if w.m.subsByKey[sub.key]--; w.m.subsByKey[sub.key] <= 0 {
+			delete(w.m.subsByKey, sub.key)
+		}
+	}
+	w.mu.Lock()
+	delete(w.subs, sub)
+	last := len(w.subs) == 0
+	w.mu.Unlock()
+	if last {
+		delete(w.m.roots, w.key)
+	}
+	w.m.mu.Unlock()
+
+	if !last {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.mu.Lock()
+	b := w.backend
+	w.mu.Unlock()
+	if b != nil {
+		b.close()
+	}
+}
+
+// excludes reports whether a path relative to the root is ignored or lies in
+// an ignored directory.
+func (w *rootWatcher) excludes(rel string, isDir bool) bool {
+	return w.rules.Excludes(w.root, filepath.Join(w.root, filepath.FromSlash(rel)), isDir)
+}
+
+// ignored reports whether a directory found while walking is skipped.
+func (w *rootWatcher) ignored(dir string) bool {
+	return dir != w.root && w.rules.Ignored(dir, true)
+}
+
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// relPath returns path relative to root in slash form.
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// coalescer merges the raw events of one debounce window into at most one
+// event per path, in the order paths were first touched.
+type coalescer struct {
+	order  []string
+	byPath map[string]Event
+}
+
+func (c *coalescer) add(ev Event) {
+	if c.byPath == nil {
+		c.byPath = make(map[string]Event)
+	}
+	prev, seen := c.byPath[ev.Path]
+	if !seen {
+		c.order = append(c.order, ev.Path)
+	}
+
+	switch ev.Type {
+	case EventModify:
+		if seen && prev.Type != EventDelete {
+			return // a create, rename or modify already covers it
+		}
+	case EventCreate:
+		if seen && prev.Type == EventDelete {
+			ev.Type = EventModify // replaced within the window
+		}
+	case EventDelete:
+		if seen && prev.Type == EventCreate {
+			delete(c.byPath, ev.Path) // came and went
+			return
+		}
+		if seen && prev.Type == EventRename {
+			// Renamed and then deleted: the old path is what disappeared.
+			delete(c.byPath, ev.Path)
+			ev.Path = prev.OldPath
+			c.add(ev)
+			return
+		}
+	case EventRename:
+		if old, ok := c.byPath[ev.OldPath]; ok && old.Type == EventCreate {
+			// Created and renamed within the window: just a create.
+			delete(c.byPath, ev.OldPath)
+			ev = Event{Type: EventCreate, Path: ev.Path, IsDir: ev.IsDir}
+		}
+	}
+	c.byPath[ev.Path] = ev
+}
+
+func (c *coalescer) flush() []Event {
+	events := make([]Event, 0, len(c.byPath))
+	for _, path := range c.order {
+		if ev, ok := c.byPath[path]; ok {
+			events = append(events, ev)
+			delete(c.byPath, path)
+		}
+	}
+	c.order = c.order[:0]
+	return events
+}
@@ -0,0 +1,268 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shortenIntervals(t *testing.T) {
+	t.Helper()
+	debounce, poll := debounceInterval, pollInterval
+	debounceInterval = 20 * time.Millisecond
+	pollInterval = 30 * time.Millisecond
+	t.Cleanup(func() { debounceInterval, pollInterval = debounce, poll })
+}
+
+// collect gathers events until want is seen or the wait times out.
+func collect(t *testing.T, sub *Subscription, want Event) []Event {
+	t.Helper()
+	var seen []Event
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-sub.Events():
+			for _, ev := range batch.Events {
+				seen = append(seen, ev)
+				if ev == want {
+					return seen
+				}
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for %+v, got %+v", want, seen)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
+
+func exerciseWatcher(t *testing.T, m *Manager, wantMode string) {
+	root := t.TempDir()
+	for _, dir := range []string{"node_modules/pkg", "build"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(root, ".gitignore"), "build/\n*.log\n")
+
+	sub, err := m.Subscribe(root, Options{})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer sub.Close()
+	if sub.Mode() != wantMode {
+		t.Skipf("watching in %s mode, want %s", sub.Mode(), wantMode)
+	}
+	// Give the poller its baseline scan.
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, filepath.Join(root, "node_modules", "pkg", "index.js"), "ignored")
+	writeFile(t, filepath.Join(root, "build", "out.js"), "ignored")
+	writeFile(t, filepath.Join(root, "debug.log"), "ignored")
+	writeFile(t, filepath.Join(root, "index.html"), "v1")
+	for _, ev := range collect(t, sub, Event{Type: EventCreate, Path: "index.html"}) {
+		if strings.HasPrefix(ev.Path, "node_modules") || strings.HasPrefix(ev.Path, "build") || ev.Path == "debug.log" {
+			t.Fatalf("event for ignored path: %+v", ev)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(root, "src", "app.js"), "app")
+	collect(t, sub, Event{Type: EventCreate, Path: "src/app.js"})
+
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(root, "src", "app.js"), "app v2")
+	collect(t, sub, Event{Type: EventModify, Path: "src/app.js"})
+
+	if err := os.Rename(filepath.Join(root, "src"), filepath.Join(root, "lib")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	collect(t, sub, Event{Type: EventRename, Path: "lib", OldPath: "src", IsDir: true})
+
+	// Watches follow the renamed directory.
+	if err := os.Remove(filepath.Join(root, "lib", "app.js")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	collect(t, sub, Event{Type: EventDelete, Path: "lib/app.js"})
+}
+
+func TestWatch_Inotify(t *testing.T) {
+	shortenIntervals(t)
+	exerciseWatcher(t, NewManager(), ModeInotify)
+}
+
+func TestWatch_Polling(t *testing.T) {
+	shortenIntervals(t)
+	m := NewManager()
+	m.forcePoll = true
+	exerciseWatcher(t, m, ModePoll)
+}
+
+func TestWatch_FallsBackToPollingAtWatchLimit(t *testing.T) {
+	shortenIntervals(t)
+	m := NewManager()
+	m.maxWatches = 2
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	sub, err := m.Subscribe(root, Options{})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if sub.Mode() != ModePoll {
+		t.Fatalf("expected polling fallback, got %s", sub.Mode())
+	}
+	if m.watches != 0 {
+		t.Fatalf("watches of the failed attempt were not released: %d", m.watches)
+	}
+
+	// A second subscriber shares the same root watcher.
+	other, err := m.Subscribe(root, Options{})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(root, "c", "new.txt"), "x")
+	collect(t, sub, Event{Type: EventCreate, Path: "c/new.txt"})
+	collect(t, other, Event{Type: EventCreate, Path: "c/new.txt"})
+
+	sub.Close()
+	other.Close()
+	if len(m.roots) != 0 {
+		t.Fatalf("root watcher not stopped with its last subscriber")
+	}
+}
+
+func TestWatch_LimitsSubscriptions(t *testing.T) {
+	shortenIntervals(t)
+	m := NewManager()
+	m.forcePoll = true
+	m.maxSubsKey = 2
+	m.maxSubs = 3
+	root := t.TempDir()
+	subscribe := func(key string) (*Subscription, error) {
+		sub, err := m.Subscribe(root, Options{Key: key})
+		if err == nil {
+			t.Cleanup(sub.Close)
+		}
+		return sub, err
+	}
+
+	first, err := subscribe("a")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := subscribe("a"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := subscribe("a"); !errors.Is(err, ErrTooManySubscriptions) {
+		t.Fatalf("expected the per-key limit, got %v", err)
+	}
+	if _, err := subscribe("b"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := subscribe("c"); !errors.Is(err, ErrTooManySubscriptions) {
+		t.Fatalf("expected the total limit, got %v", err)
+	}
+
+	// Closing a subscription frees its slot, once.
+	first.Close()
+	first.Close()
+	if _, err := subscribe("a"); err != nil {
+		t.Fatalf("subscribe after close: %v", err)
+	}
+	if m.subs != 3 || m.subsByKey["a"] != 2 {
+		t.Fatalf("unexpected counts: total %d, key a %d", m.subs, m.subsByKey["a"])
+	}
+}
+
+func TestWatch_IgnoreRulesAndShowIgnored(t *testing.T) {
+	shortenIntervals(t)
+	m := NewManager()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "dist"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	hidden, err := m.Subscribe(root, Options{})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer hidden.Close()
+	shown, err := m.Subscribe(root, Options{ShowIgnored: true})
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer shown.Close()
+	if len(m.roots) != 2 {
+		t.Fatalf("subscribers with different rules must not share a watcher")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	writeFile(t, filepath.Join(root, "dist", "app.js"), "x")
+	collect(t, shown, Event{Type: EventCreate, Path: "dist/app.js"})
+
+	// Re-including dist makes subscribers reload and reports later changes.
+	writeFile(t, filepath.Join(root, ".aliveignore"), "!dist/\n")
+	deadline := time.After(5 * time.Second)
+	for overflow := false; !overflow; {
+		select {
+		case batch := <-hidden.Events():
+			for _, ev := range batch.Events {
+				if strings.HasPrefix(ev.Path, "dist") {
+					t.Fatalf("event for ignored path before re-including: %+v", ev)
+				}
+			}
+			overflow = batch.Overflow
+		case <-deadline:
+			t.Fatal("timed out waiting for the reload batch")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(root, "dist", "app.js"), "y")
+	collect(t, hidden, Event{Type: EventModify, Path: "dist/app.js"})
+}
+
+func TestCoalescer(t *testing.T) {
+	var c coalescer
+	c.add(Event{Type: EventCreate, Path: "a"})
+	c.add(Event{Type: EventModify, Path: "a"})
+	c.add(Event{Type: EventCreate, Path: "tmp"})
+	c.add(Event{Type: EventDelete, Path: "tmp"})
+	c.add(Event{Type: EventDelete, Path: "b"})
+	c.add(Event{Type: EventCreate, Path: "b"})
+	c.add(Event{Type: EventCreate, Path: "c.tmp"})
+	c.add(Event{Type: EventRename, Path: "c", OldPath: "c.tmp"})
+
+	got := c.flush()
+	want := []Event{
+		{Type: EventCreate, Path: "a"},
+		{Type: EventModify, Path: "b"},
+		{Type: EventCreate, Path: "c"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected events %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("event %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if rest := c.flush(); len(rest) != 0 {
+		t.Fatalf("flush left events behind: %+v", rest)
+	}
+}
@@ -0,0 +1,71 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"shell-server-go/internal/fsutil"
+)
+
+// workspaceOwner returns the stat of the workspace directory, whose uid/gid is
+// the workspace owner.
+func workspaceOwner(cwd string) (*syscall.Stat_t, error) {
+	info, err := os.Stat(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("stat workspace: %w", err)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("workspace stat does not expose uid/gid")
+	}
+	return stat, nil
+}
+
+// ResolveWorkspaceCredential returns the credential processes for a workspace should run with.
+// It returns nil when no switch is needed (not running as owner, or already the owner).
+func ResolveWorkspaceCredential(cwd string, runAsOwner bool) (*syscall.Credential, error) {
+	if !runAsOwner {
+		return nil, nil
+	}
+
+	stat, err := workspaceOwner(cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	currentUID := os.Geteuid()
+	currentGID := os.Getegid()
+	targetUID := int(stat.Uid)
+	targetGID := int(stat.Gid)
+
+	if currentUID == targetUID && currentGID == targetGID {
+		return nil, nil
+	}
+
+	if currentUID != 0 {
+		return nil, fmt.Errorf("cannot switch uid/gid without root (current=%d:%d target=%d:%d)", currentUID, currentGID, targetUID, targetGID)
+	}
+
+	return &syscall.Credential{
+		Uid:         stat.Uid,
+		Gid:         stat.Gid,
+		NoSetGroups: true,
+	}, nil
+}
+
+// ResolveWorkspaceOwner returns the owner for entries the server creates in a
+// workspace: the same uid/gid ResolveWorkspaceCredential switches to, plus the
+// configured umask. Without root the server cannot chown, so entries then keep
+// the server's own uid/gid and only the umask applies.
+func ResolveWorkspaceOwner(cwd string, umask os.FileMode) (*fsutil.Owner, error) {
+	stat, err := workspaceOwner(cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, gid := int(stat.Uid), int(stat.Gid)
+	chown := os.Geteuid() == 0 && (uid != os.Geteuid() || gid != os.Getegid())
+	return &fsutil.Owner{UID: uid, GID: gid, Chown: chown, Umask: umask}, nil
+}
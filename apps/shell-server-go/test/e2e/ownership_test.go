@@ -0,0 +1,130 @@
+package e2e
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"shell-server-go/internal/config"
+	"shell-server-go/test/testutil"
+)
+
+const (
+	siteOwnerUID = 4242
+	siteOwnerGID = 4243
+)
+
+func assertOwnedBySite(t *testing.T, path string, perm os.FileMode) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("stat %s: %v", path, err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if stat.Uid != siteOwnerUID || stat.Gid != siteOwnerGID {
+		t.Fatalf("%s owned by %d:%d, want %d:%d", path, stat.Uid, stat.Gid, siteOwnerUID, siteOwnerGID)
+	}
+	if info.Mode().Perm() != perm {
+		t.Fatalf("%s has mode %o, want %o", path, info.Mode().Perm(), perm)
+	}
+}
+
+func postUpload(t *testing.T, client *http.Client, serverURL, filename string, data []byte, fields map[string]string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		_ = writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(data)
+	_ = writer.Close()
+
+	resp, err := client.Post(serverURL+"/api/upload", writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatalf("upload request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected 200 for %s, got %d body=%s", filename, resp.StatusCode, string(respBody))
+	}
+}
+
+func TestE2E_WritesAreOwnedByWorkspaceOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file ownership requires root")
+	}
+
+	ts := testutil.Setup(t)
+	defer ts.Cleanup()
+	ts.Config.Umask = 0002
+	ts.Config.EditableDirectories = []config.EditableDirectory{{ID: "sites", Label: "Sites", Path: ts.Config.ResolvedSitesPath}}
+
+	userDir := ts.EnsureSiteWorkspace(t, "example.com")
+	if err := os.Chown(userDir, siteOwnerUID, siteOwnerGID); err != nil {
+		t.Fatalf("chown site workspace: %v", err)
+	}
+
+	client := ts.NewHTTPClient(ts.Login(t))
+
+	t.Run("create-directory", func(t *testing.T) {
+		form := url.Values{"workspace": {"site:example.com"}, "targetDir": {"assets/img"}}
+		resp, err := client.Post(ts.Server.URL+"/api/create-directory", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatalf("create-directory request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		assertOwnedBySite(t, filepath.Join(userDir, "assets"), 0775)
+		assertOwnedBySite(t, filepath.Join(userDir, "assets", "img"), 0775)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		postUpload(t, client, ts.Server.URL, "notes.txt", []byte("hello"), map[string]string{"workspace": "site:example.com", "targetDir": "docs"})
+		assertOwnedBySite(t, filepath.Join(userDir, "docs"), 0775)
+		assertOwnedBySite(t, filepath.Join(userDir, "docs", "notes.txt"), 0664)
+	})
+
+	t.Run("zip extraction", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		header := &zip.FileHeader{Name: "dist/js/app.js", Method: zip.Deflate}
+		header.SetMode(0666)
+		fw, _ := zw.CreateHeader(header)
+		fw.Write([]byte("console.log(1)"))
+		zw.Close()
+
+		postUpload(t, client, ts.Server.URL, "build.zip", buf.Bytes(), map[string]string{"workspace": "site:example.com"})
+		assertOwnedBySite(t, filepath.Join(userDir, "dist"), 0775)
+		assertOwnedBySite(t, filepath.Join(userDir, "dist", "js"), 0775)
+		assertOwnedBySite(t, filepath.Join(userDir, "dist", "js", "app.js"), 0664)
+	})
+
+	t.Run("editor write", func(t *testing.T) {
+		payload := `{"directory":"sites","path":"example.com/user/pages/about.html","content":"<h1>About</h1>"}`
+		resp, err := client.Post(ts.Server.URL+"/api/edit/write-file", "application/json", strings.NewReader(payload))
+		if err != nil {
+			t.Fatalf("write-file request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		assertOwnedBySite(t, filepath.Join(userDir, "pages"), 0775)
+		assertOwnedBySite(t, filepath.Join(userDir, "pages", "about.html"), 0664)
+	})
+}
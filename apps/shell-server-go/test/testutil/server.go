@@ -22,20 +22,29 @@ import (
 	"shell-server-go/internal/files"
 	httpxmiddleware "shell-server-go/internal/httpx/middleware"
 	"shell-server-go/internal/logger"
+	"shell-server-go/internal/processes"
+	"shell-server-go/internal/quota"
 	"shell-server-go/internal/ratelimit"
+	"shell-server-go/internal/scan"
 	"shell-server-go/internal/session"
+	"shell-server-go/internal/snapshot"
+	"shell-server-go/internal/supervisor"
 	"shell-server-go/internal/templates"
 	"shell-server-go/internal/terminal"
+	"shell-server-go/internal/thumbnail"
+	"shell-server-go/internal/trash"
+	"shell-server-go/internal/watch"
 )
 
 // TestServer holds the in-memory test server and dependencies.
 type TestServer struct {
-	Server    *httptest.Server
-	Sessions  *session.Store
-	Limiter   *ratelimit.Limiter
-	WSHandler *terminal.WSHandler
-	Config    *config.AppConfig
-	TempDir   string
+	Server     *httptest.Server
+	Sessions   *session.Store
+	Limiter    *ratelimit.Limiter
+	WSHandler  *terminal.WSHandler
+	Supervisor *supervisor.Manager
+	Config     *config.AppConfig
+	TempDir    string
 }
 
 // Setup creates a fully wired test server.
@@ -66,20 +75,29 @@ func Setup(t testing.TB) *TestServer {
 		ResolvedDefaultCwd:      workspaceDir,
 		ResolvedUploadCwd:       uploadsDir,
 		ResolvedSitesPath:       sitesDir,
+		ResolvedTrashPath:       filepath.Join(tempDir, ".trash"),
 		WorkspaceBase:           tempDir,
 		AllowWorkspaceSelection: true,
 		EditableDirectories:     []config.EditableDirectory{},
 		ShellPassword:           "testpassword123",
+		Umask:                   config.DefaultUmask,
 	}
 
 	sessions := session.NewStore(filepath.Join(tempDir, ".sessions.json"))
 	limiter := ratelimit.NewLimiter(filepath.Join(tempDir, ".rate-limit-state.json"))
 
+	trashStore := trash.NewStore(cfg)
+	quotas := quota.NewManager(cfg)
+	watches := watch.NewManager()
+	thumbs := thumbnail.NewCache(filepath.Join(tempDir, ".thumbnails"), thumbnail.DefaultCacheBytes)
 	authHandler := auth.NewHandler(cfg, sessions, limiter)
-	fileHandler := files.NewHandler(cfg, sessions)
-	editorHandler := editor.NewHandler(cfg, sessions)
+	fileHandler := files.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs, scan.New(cfg.UploadScan), snapshot.NewManager(filepath.Join(tempDir, ".snapshots")), filepath.Join(tempDir, ".uploads"))
+	editorHandler := editor.NewHandler(cfg, sessions, trashStore, quotas, watches, thumbs)
 	wsHandler := terminal.NewWSHandler(cfg, sessions)
 	templateHandler := templates.NewHandler(cfg, sessions)
+	services := supervisor.NewManager(cfg, filepath.Join(tempDir, ".supervisor"))
+	serviceHandler := supervisor.NewHandler(sessions, services)
+	processHandler := processes.NewHandler(cfg, sessions)
 
 	clientFS := fstest.MapFS{
 		"index.html": {Data: []byte(`<!DOCTYPE html><html><body><div id="app"></div></body></html>`)},
@@ -96,22 +114,62 @@ func Setup(t testing.TB) *TestServer {
 
 	mux.HandleFunc("/ws", wsHandler.Handle)
 	mux.Handle("POST /api/ws-lease", authAPI(http.HandlerFunc(wsHandler.CreateLease)))
+	mux.Handle("GET /api/terminal/transcripts", authAPI(http.HandlerFunc(wsHandler.ListTranscripts)))
+	mux.Handle("GET /api/terminal/transcript", authAPI(http.HandlerFunc(wsHandler.DownloadTranscript)))
 
 	mux.Handle("POST /api/list-files", authAPI(http.HandlerFunc(fileHandler.ListFiles)))
 	mux.Handle("POST /api/check-directory", authAPI(http.HandlerFunc(fileHandler.CheckDirectory)))
 	mux.Handle("POST /api/create-directory", authAPI(http.HandlerFunc(fileHandler.CreateDirectory)))
 	mux.Handle("POST /api/upload", authAPI(http.HandlerFunc(fileHandler.Upload)))
+	mux.Handle("OPTIONS /api/uploads/{$}", authAPI(http.HandlerFunc(fileHandler.TusOptions)))
+	mux.Handle("POST /api/uploads/{$}", authAPI(http.HandlerFunc(fileHandler.TusCreate)))
+	mux.Handle("HEAD /api/uploads/{id}", authAPI(http.HandlerFunc(fileHandler.TusHead)))
+	mux.Handle("PATCH /api/uploads/{id}", authAPI(http.HandlerFunc(fileHandler.TusPatch)))
+	mux.Handle("DELETE /api/uploads/{id}", authAPI(http.HandlerFunc(fileHandler.TusDelete)))
 	mux.Handle("POST /api/read-file", authAPI(http.HandlerFunc(fileHandler.ReadFile)))
 	mux.Handle("GET /api/download-file", authAPI(http.HandlerFunc(fileHandler.DownloadFile)))
+	mux.Handle("GET /api/download-archive", authAPI(http.HandlerFunc(fileHandler.DownloadArchive)))
+	mux.Handle("GET /api/files/list", authAPI(http.HandlerFunc(fileHandler.ListDirectory)))
+	mux.Handle("GET /api/files/search", authAPI(http.HandlerFunc(fileHandler.SearchFiles)))
+	mux.Handle("GET /api/files/tail", authAPI(http.HandlerFunc(fileHandler.TailFile)))
+	mux.Handle("GET /api/files/watch", authAPI(http.HandlerFunc(fileHandler.WatchFiles)))
+	mux.Handle("GET /api/files/thumbnail", authAPI(http.HandlerFunc(fileHandler.Thumbnail)))
+	mux.Handle("GET /api/files/disk-usage", authAPI(http.HandlerFunc(fileHandler.DiskUsage)))
+	mux.Handle("POST /api/move", authAPI(http.HandlerFunc(fileHandler.MovePath)))
+	mux.Handle("POST /api/batch", authAPI(http.HandlerFunc(fileHandler.Batch)))
 	mux.Handle("POST /api/delete-folder", authAPI(http.HandlerFunc(fileHandler.DeleteFolder)))
+	mux.Handle("GET /api/trash", authAPI(http.HandlerFunc(fileHandler.ListTrash)))
+	mux.Handle("POST /api/trash/restore", authAPI(http.HandlerFunc(fileHandler.RestoreTrash)))
+	mux.Handle("POST /api/trash/purge", authAPI(http.HandlerFunc(fileHandler.PurgeTrash)))
+	mux.Handle("GET /api/snapshots", authAPI(http.HandlerFunc(fileHandler.ListSnapshots)))
+	mux.Handle("POST /api/snapshots", authAPI(http.HandlerFunc(fileHandler.CreateSnapshot)))
+	mux.Handle("GET /api/snapshots/diff", authAPI(http.HandlerFunc(fileHandler.DiffSnapshots)))
+	mux.Handle("POST /api/snapshots/restore", authAPI(http.HandlerFunc(fileHandler.RestoreSnapshot)))
+	mux.Handle("POST /api/snapshots/delete", authAPI(http.HandlerFunc(fileHandler.DeleteSnapshot)))
 	mux.Handle("GET /api/sites", authAPI(http.HandlerFunc(fileHandler.ListSites)))
+	mux.Handle("GET /api/quota", authAPI(http.HandlerFunc(fileHandler.QuotaUsage)))
 
 	mux.Handle("POST /api/edit/list-files", authAPI(http.HandlerFunc(editorHandler.ListFiles)))
 	mux.Handle("POST /api/edit/read-file", authAPI(http.HandlerFunc(editorHandler.ReadFile)))
 	mux.Handle("POST /api/edit/write-file", authAPI(http.HandlerFunc(editorHandler.WriteFile)))
 	mux.Handle("POST /api/edit/check-mtimes", authAPI(http.HandlerFunc(editorHandler.CheckMtimes)))
 	mux.Handle("POST /api/edit/delete", authAPI(http.HandlerFunc(editorHandler.Delete)))
+	mux.Handle("GET /api/edit/trash", authAPI(http.HandlerFunc(editorHandler.ListTrash)))
+	mux.Handle("POST /api/edit/trash/restore", authAPI(http.HandlerFunc(editorHandler.RestoreTrash)))
+	mux.Handle("POST /api/edit/trash/purge", authAPI(http.HandlerFunc(editorHandler.PurgeTrash)))
 	mux.Handle("POST /api/edit/copy", authAPI(http.HandlerFunc(editorHandler.Copy)))
+	mux.Handle("GET /api/edit/watch", authAPI(http.HandlerFunc(editorHandler.WatchFiles)))
+	mux.Handle("GET /api/edit/thumbnail", authAPI(http.HandlerFunc(editorHandler.Thumbnail)))
+
+	mux.Handle("GET /api/supervisor/services", authAPI(http.HandlerFunc(serviceHandler.ListServices)))
+	mux.Handle("POST /api/supervisor/start", authAPI(http.HandlerFunc(serviceHandler.StartService)))
+	mux.Handle("POST /api/supervisor/stop", authAPI(http.HandlerFunc(serviceHandler.StopService)))
+	mux.Handle("POST /api/supervisor/restart", authAPI(http.HandlerFunc(serviceHandler.RestartService)))
+	mux.Handle("POST /api/supervisor/remove", authAPI(http.HandlerFunc(serviceHandler.RemoveService)))
+	mux.Handle("GET /api/supervisor/logs", authAPI(http.HandlerFunc(serviceHandler.ServiceLogs)))
+
+	mux.Handle("GET /api/processes", authAPI(http.HandlerFunc(processHandler.List)))
+	mux.Handle("POST /api/processes/signal", authAPI(http.HandlerFunc(processHandler.Signal)))
 
 	mux.Handle("GET /api/templates", authAPI(http.HandlerFunc(templateHandler.ListTemplates)))
 	mux.Handle("POST /api/templates", authAPI(http.HandlerFunc(templateHandler.CreateTemplate)))
@@ -123,12 +181,13 @@ func Setup(t testing.TB) *TestServer {
 	server := httptest.NewTLSServer(mux)
 
 	return &TestServer{
-		Server:    server,
-		Sessions:  sessions,
-		Limiter:   limiter,
-		WSHandler: wsHandler,
-		Config:    cfg,
-		TempDir:   tempDir,
+		Server:     server,
+		Sessions:   sessions,
+		Limiter:    limiter,
+		WSHandler:  wsHandler,
+		Supervisor: services,
+		Config:     cfg,
+		TempDir:    tempDir,
 	}
 }
 
@@ -151,6 +210,11 @@ func (ts *TestServer) Cleanup() {
 		defer cancel()
 		ts.WSHandler.Shutdown(ctx)
 	}
+	if ts.Supervisor != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ts.Supervisor.Shutdown(ctx)
+	}
 	if ts.Server != nil {
 		ts.Server.Close()
 	}